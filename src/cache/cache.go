@@ -0,0 +1,172 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+
+// A persistent store of Trello's responses, so workspaces, boards, lists, and
+// cards can still be browsed, if stale, when Trello can't be reached, even
+// across mounts. Each response is kept as a JSON file in the store's
+// directory, named after a hash of its key.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type entry struct {
+	Key     string          `json:"key"`
+	Fetched time.Time       `json:"fetched"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type Store struct {
+	dir string
+}
+
+// Open the store in 'dir', creating it if need be, and discarding entries
+// left corrupt by a crash.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &Store{dir: dir}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(hash[:])+".json")
+}
+
+// Obtain what was last stored for a key, and when.
+func (s *Store) Get(key string) ([]byte, time.Time, bool) {
+	contents, err := ioutil.ReadFile(s.path(key))
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	var e entry
+	if err := json.Unmarshal(contents, &e); err != nil || e.Key != key {
+		return nil, time.Time{}, false
+	}
+	return e.Data, e.Fetched, true
+}
+
+// Store a response for a key. Only valid JSON is kept; anything else is
+// ignored. Entries are replaced atomically, and durably, so a crash never
+// leaves one half-written.
+func (s *Store) Put(key string, data []byte) {
+	if !json.Valid(data) {
+		return
+	}
+	contents, err := json.Marshal(&entry{
+		Key: key, Fetched: time.Now(), Data: data,
+	})
+	if err != nil {
+		return
+	}
+	if err := WriteFile(s.path(key), contents); err != nil {
+		log.Printf("cache > error storing %s: %s\n", key, err)
+	}
+}
+
+// Discard whatever a crash may have left behind: temporary files never
+// renamed into place, and entries that can't be read, or aren't named after
+// their key, should any have been written by something less careful.
+func (s *Store) check() error {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	discarded := 0
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() {
+			continue
+		} else if IsTempFile(name) {
+			os.Remove(filepath.Join(s.dir, name))
+			continue
+		} else if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		path := filepath.Join(s.dir, name)
+		contents, err := ioutil.ReadFile(path)
+		var e entry
+		if err == nil {
+			err = json.Unmarshal(contents, &e)
+		}
+		if err == nil && s.path(e.Key) == path && json.Valid(e.Data) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		discarded++
+	}
+	if discarded > 0 {
+		log.Printf("cache > discarded %d corrupt entries\n", discarded)
+	}
+	return nil
+}
+
+// Temporary files, written before being renamed into place.
+const tempPrefix = ".tmp-"
+
+// Whether a file is a temporary one left behind by WriteFile(), should the
+// system have crashed before it was renamed into place.
+func IsTempFile(name string) bool {
+	return strings.HasPrefix(name, tempPrefix)
+}
+
+// Write a file so it's either replaced as a whole, or left as it was, even
+// should the system crash meanwhile: the contents are written to a
+// temporary file alongside, synced, and renamed into place, with the
+// directory synced in turn so the rename sticks.
+func WriteFile(path string, contents []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, tempPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return SyncDir(dir)
+}
+
+// Sync a directory, so entries just added to, or removed from, it stick.
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
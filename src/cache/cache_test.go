@@ -0,0 +1,158 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package cache
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Entries stored are found again once the store is reopened, while
+// whatever a crash may have left behind is discarded.
+func TestStoreReopened(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put("/boards/b1", []byte(`{"id": "b1"}`))
+	s.Put("/boards/b2", []byte("not json"))
+
+	leftovers := []string{
+		filepath.Join(dir, tempPrefix+"123"),
+		s.path("/boards/b3"),
+		// an entry for a key other than it's named after.
+		filepath.Join(dir, filepath.Base(s.path("/boards/b4"))),
+	}
+	contents := map[string]string{
+		leftovers[0]: `{"key": "/boards/b3"`,
+		leftovers[1]: `{"key": "/boards/b3", "data": {"id": `,
+		leftovers[2]: `{"key": "/boards/b1", "data": {}}`,
+	}
+	for path, body := range contents {
+		if err := ioutil.WriteFile(path, []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if s, err = Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	data, fetched, exists := s.Get("/boards/b1")
+	if !exists || string(data) != `{"id":"b1"}` || fetched.IsZero() {
+		t.Errorf("stored entry found as %q (%v), at %s", data, exists, fetched)
+	}
+	if _, _, exists := s.Get("/boards/b2"); exists {
+		t.Errorf("invalid JSON stored")
+	}
+	for _, path := range leftovers {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s not discarded: %v", filepath.Base(path), err)
+		}
+	}
+}
+
+// Responses obtained are served again once Trello can't be reached, but
+// only to the token they were obtained with.
+func TestServeCached(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := t.TempDir()
+	t.Setenv("TRELLOFS_TEST", dir)
+	fixture := filepath.Join(dir, "boards-b1.json")
+	err := ioutil.WriteFile(fixture, []byte(`{"id":"b1"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := trello.Trello("me", "key", "token")
+	ctx.SetCache(s)
+	if _, err := ctx.ApiGet("/boards/b1"); err != nil {
+		t.Fatal(err)
+	}
+	// as if Trello couldn't be reached.
+	if err := os.Remove(fixture); err != nil {
+		t.Fatal(err)
+	}
+	body, err := ctx.ApiGet("/boards/b1")
+	if err != nil || string(body) != `{"id":"b1"}` {
+		t.Errorf("served %q (%v) offline, want the cached board", body, err)
+	}
+	other := trello.Trello("me", "key", "other")
+	other.SetCache(s)
+	if body, err := other.ApiGet("/boards/b1"); err == nil {
+		t.Errorf("served %q cached for another token", body)
+	}
+}
+
+// Files written at once are each replaced as a whole, readers finding one
+// or the other, never a mix of both nor a part of either, with nothing left
+// behind.
+func TestWriteFileConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "entry.json")
+	size := 1 << 16
+	if err := WriteFile(path, []byte(strings.Repeat("a", size))); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range []string{"a", "b", "c", "d"} {
+		wg.Add(2)
+		go func(contents []byte) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if err := WriteFile(path, contents); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}([]byte(strings.Repeat(c, size)))
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				contents, err := ioutil.ReadFile(path)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(contents) != size || strings.Trim(
+					string(contents), string(contents[:1]),
+				) != "" {
+					t.Errorf("read %d bytes, mixed or cut short",
+						len(contents))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("%d files left behind", len(files)-1)
+	}
+}
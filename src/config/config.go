@@ -16,10 +16,238 @@ import (
 	"os"
 )
 
+// Credentials a workspace is accessed with. Their member ID is that of
+// whoever the token belongs to, unless set.
+type Credentials struct {
+	ID    string `json:"id"`
+	Key   string `json:"key"`
+	Token string `json:"token"`
+}
+
+// A Trello account, mounted as a top-level directory named after it. The
+// account's member ID is that of whoever the token belongs to, unless set.
+type Account struct {
+	Name  string `json:"name"`
+	ID    string `json:"id"`
+	Key   string `json:"key"`
+	Token string `json:"token"`
+}
+
+// Glob patterns selecting entities to mount. See path.Match for the syntax.
+type Selection struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+// Relaying Trello notifications; see the notify package.
+type Notify struct {
+	// Notification types to relay, e.g. 'addedToCard', 'cardDueSoon'.
+	Types []string `json:"types"`
+
+	// Seconds between checks for new notifications.
+	Interval int `json:"interval"`
+
+	// Show desktop notifications, through 'notify-send'.
+	Desktop bool `json:"desktop"`
+
+	// Script run for each notification, given it as JSON on its standard
+	// input.
+	Hook string `json:"hook"`
+}
+
+// Receiving actions through webhooks; see the webhook package.
+type Webhook struct {
+	// Address to listen on, e.g. ':8089'.
+	Listen string `json:"listen"`
+
+	// URL Trello reaches the listener through.
+	CallbackURL string `json:"callbackURL"`
+
+	// The application's secret, to check requests come from Trello.
+	Secret string `json:"secret"`
+}
+
+// Periodic board snapshots; see fs/snapshots.go.
+type Snapshots struct {
+	// Boards to snapshot, by name, ID, or short link.
+	Boards []string `json:"boards"`
+
+	// Hours between snapshots; 24 by default.
+	Interval int `json:"interval"`
+
+	// How many of a board's periodic snapshots to keep; all of them if
+	// unset.
+	Keep int `json:"keep"`
+}
+
+// Work-in-progress limits; see fs/wip.go.
+type WIPLimits struct {
+	// How many cards each list may hold, keyed by '<board>/<list>', each
+	// by name or ID, e.g. "Sprint/Doing", or by the list's ID alone.
+	Lists map[string]int `json:"lists"`
+
+	// Refuse new cards on lists at their limit, failing with EDQUOT,
+	// rather than only reporting lists over it.
+	Enforce bool `json:"enforce"`
+}
+
+// Talking to Trello; see trello/transport.go.
+type HTTP struct {
+	// Seconds a request may take, reading its response included; 60 by
+	// default.
+	Timeout int `json:"timeout"`
+
+	// Times requests for entities are retried when Trello can't be
+	// reached, backing off exponentially. Not retried by default.
+	Retries int `json:"retries"`
+
+	// Idle connections to keep around for reuse.
+	MaxIdleConns int `json:"maxIdleConns"`
+
+	// URL of the proxy to go through, e.g. "http://proxy:3128"; those in
+	// $HTTPS_PROXY and friends are used if not set.
+	Proxy string `json:"proxy"`
+}
+
+// Queueing changes to cards while Trello can't be reached; see the journal
+// package.
+type WriteBack struct {
+	// Directory to keep the queued changes in.
+	Dir string `json:"dir"`
+
+	// Seconds between attempts at making the queued changes; 30 by
+	// default.
+	Interval int `json:"interval"`
+}
+
 type Config struct {
 	ID    string `json:"id"`
 	Key   string `json:"key"`
 	Token string `json:"token"`
+
+	// Credentials to be used for specific workspaces, keyed by the
+	// workspace's name or ID. Workspaces not listed here will use the
+	// top-level key and token.
+	Workspaces map[string]Credentials `json:"workspaces"`
+
+	// Accounts to mount, each as a top-level directory named after it,
+	// holding its workspaces, e.g. 'work' and 'personal'. The top-level
+	// key and token, or the first account's if unset, are then only used
+	// for what belongs to no account in particular, e.g. '/.me'.
+	Accounts []Account `json:"accounts"`
+
+	// Name workspace directories after their display name rather than
+	// their short name.
+	UseDisplayName bool `json:"useDisplayName"`
+
+	// Seconds between refreshes of each kind of entity, keyed by
+	// 'workspaces', 'boards', 'lists', or 'cards'; 0 to never refresh them
+	// once loaded. Entities not listed are refreshed as often as usual.
+	Refresh map[string]int `json:"refresh"`
+
+	// Seconds after which nodes not accessed are no longer refreshed in
+	// the background.
+	RefreshIdleTimeout int `json:"refreshIdleTimeout"`
+
+	// Allow removing members from boards by unlinking their files. Off by
+	// default, so a stray 'rm -r' can't lock folks out of a board.
+	AllowMemberRemoval bool `json:"allowMemberRemoval"`
+
+	// Days without activity after which a card is considered stale.
+	StaleDays int `json:"staleDays"`
+
+	// How many lists a board's 'lists' directory lists, the rest being
+	// listed in its '.overflow'; 200 by default, or all if negative.
+	MaxLists int `json:"maxLists"`
+
+	// Present cards on their lists as hard links to their directories in
+	// the board's 'cards', rather than as symlinks to them.
+	HardlinkCards bool `json:"hardlinkCards"`
+
+	// Days for which removed cards and lists are listed under each
+	// workspace's '.recently-removed'.
+	TrashDays int `json:"trashDays"`
+
+	// Give cards files with their description's word count and broken
+	// markdown links, for boards used as documentation. Off by default.
+	DocMeta bool `json:"docMeta"`
+
+	// Timezone in which to render card dates, as an IANA name such as
+	// "Europe/Lisbon", or "local"; and their format, as a Go time layout,
+	// e.g. "2006-01-02 15:04". UTC and RFC 3339 by default.
+	Timezone   string `json:"timezone"`
+	DateFormat string `json:"dateFormat"`
+
+	// Prefix board and label names with their color, according to this
+	// template, in which '{color}' and '{emoji}' are replaced by the
+	// color's name and emoji; e.g., "{emoji} ".
+	ColorPrefix string `json:"colorPrefix"`
+
+	// Templates naming boards, lists, and cards, keyed by 'board', 'list',
+	// or 'card'; e.g., "{{pos}}-{{name}}". See README.md for the available
+	// fields.
+	Naming map[string]string `json:"naming"`
+
+	// Name boards, lists, and cards after their Trello ID, so paths survive
+	// renames. Either for the whole mount, or only for the workspaces and
+	// boards listed in 'idNamesIn', by name or ID.
+	IDNames   bool     `json:"idNames"`
+	IDNamesIn []string `json:"idNamesIn"`
+
+	// Workspaces to mount, matched against their name, display name, or
+	// ID; and, keyed by workspace name or ID, which of their boards to
+	// mount, matched against their name or ID. Everything is mounted by
+	// default.
+	MountWorkspaces Selection            `json:"mountWorkspaces"`
+	MountBoards     map[string]Selection `json:"mountBoards"`
+
+	// Boards to mount, as '<workspace>/<board>' patterns, e.g. "Work/*"; a
+	// workspace alone stands for all of its boards. Mounted along with
+	// mountWorkspaces and mountBoards, if set.
+	Boards []string `json:"boards"`
+
+	// Views to present under each board's 'views' directory, keyed by name,
+	// as queries over the board's cards; e.g., 'label == "bug" AND due <
+	// 7d'. See README.md for the syntax.
+	Views map[string]string `json:"views"`
+
+	// Relay the user's notifications while mounted. Off unless set.
+	Notify *Notify `json:"notify"`
+
+	// Shell commands to run on events, keyed by event: 'cardCreated',
+	// 'cardMoved', or 'cardDueSoon'. Each is given the event as JSON on its
+	// standard input. Cards are due soon within 'dueSoonHours' (24 by
+	// default) of their due date.
+	Hooks        map[string][]string `json:"hooks"`
+	DueSoonHours int                 `json:"dueSoonHours"`
+
+	// ID of the list files dropped into '/.inbox' are filed in, as cards.
+	InboxList string `json:"inboxList"`
+
+	// Directory to keep Trello's responses in, to browse with while Trello
+	// can't be reached. No caching unless set.
+	CacheDir string `json:"cacheDir"`
+
+	// Queue changes to cards made while Trello can't be reached, rather
+	// than fail them, making them once it can. Off unless set.
+	WriteBack *WriteBack `json:"writeBack"`
+
+	// Directory to keep board snapshots in, taken through the control
+	// socket. Kept in memory, for as long as mounted, unless set.
+	SnapshotDir string `json:"snapshotDir"`
+
+	// Boards to snapshot periodically. Off unless set.
+	Snapshots *Snapshots `json:"snapshots"`
+
+	// Have Trello push actions on mounted boards, rather than only polling
+	// for changes. Off unless set.
+	Webhook *Webhook `json:"webhook"`
+
+	// Limit how many cards lists may hold. No limits unless set.
+	WIPLimits *WIPLimits `json:"wipLimits"`
+
+	// How requests are made to Trello. The defaults are used unless set.
+	HTTP *HTTP `json:"http"`
 }
 
 func ReadConfig(cfg string) (*Config, error) {
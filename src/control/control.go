@@ -0,0 +1,114 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+
+// A control socket, to adjust a running filesystem. Commands are sent one
+// per line, as a command name followed by its arguments, separated by
+// spaces; each is answered with a single line, either 'ok [<output>]' or
+// 'error <message>'. E.g.,
+//
+//	$ echo "max-inflight-requests 4" | nc -U /run/user/1000/trellofs.sock
+//	ok
+package control
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Handles a command, given its arguments, returning its output.
+type Handler func(args []string) (string, error)
+
+type Server struct {
+	lock     sync.Mutex
+	handlers map[string]Handler
+}
+
+func NewServer() *Server {
+	s := &Server{handlers: make(map[string]Handler)}
+	s.Handle("help", s.help)
+	return s
+}
+
+func (s *Server) Handle(cmd string, handler Handler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.handlers[cmd] = handler
+}
+
+func (s *Server) help(args []string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var cmds []string
+	for cmd := range s.handlers {
+		cmds = append(cmds, cmd)
+	}
+	sort.Strings(cmds)
+	return strings.Join(cmds, " "), nil
+}
+
+// Listen on a unix socket at 'path', serving commands until the listener
+// fails. A stale socket left behind at 'path' is replaced.
+func (s *Server) Serve(path string) error {
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	os.Chmod(path, 0600)
+
+	log.Printf("control > listening on %s\n", path)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		out, err := s.exec(fields[0], fields[1:])
+		if err != nil {
+			fmt.Fprintf(conn, "error %s\n", err)
+		} else if out != "" {
+			fmt.Fprintf(conn, "ok %s\n", out)
+		} else {
+			fmt.Fprintf(conn, "ok\n")
+		}
+	}
+}
+
+func (s *Server) exec(cmd string, args []string) (string, error) {
+	s.lock.Lock()
+	handler, exists := s.handlers[cmd]
+	s.lock.Unlock()
+
+	if !exists {
+		return "", fmt.Errorf("unknown command '%s'", cmd)
+	}
+	log.Printf("control > %s %s\n", cmd, strings.Join(args, " "))
+	return handler(args)
+}
@@ -0,0 +1,87 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package control
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Commands sent to the socket, one per line, are answered one line each.
+func TestServe(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	value := 10
+	s := NewServer()
+	s.Handle("set", func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("usage: set <n>")
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return "", err
+		}
+		value = n
+		return "", nil
+	})
+	s.Handle("get", func(args []string) (string, error) {
+		return strconv.Itoa(value), nil
+	})
+
+	path := filepath.Join(t.TempDir(), "trellofs.sock")
+	// a stale socket is replaced.
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(path)
+
+	var conn net.Conn
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		var err error
+		if conn, err = net.Dial("unix", path); err == nil {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer conn.Close()
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode %o, want 600", info.Mode().Perm())
+	}
+
+	fmt.Fprint(conn, "set 4\n\nget\nset\nbogus 1\nhelp\n")
+	replies := bufio.NewScanner(conn)
+	for _, want := range []string{
+		"ok",
+		"ok 4",
+		"error usage: set <n>",
+		"error unknown command 'bogus'",
+		"ok get help set",
+	} {
+		if !replies.Scan() {
+			t.Fatalf("no reply, want %q: %v", want, replies.Err())
+		}
+		if got := replies.Text(); got != want {
+			t.Errorf("replied %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"os"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A Trello account mounted as a top-level directory named after it, holding
+// the account's workspaces; see Options.Accounts.
+type Account struct {
+	Name string
+	Ctx  *trello.TrelloCtx
+}
+
+// The root, when mounting several accounts, holding a directory per
+// account, each listing the account's workspaces as the root otherwise
+// would.
+type FSAccountsRoot struct {
+	BaseFSNode
+
+	Accounts []*TrelloTreeRoot
+}
+
+func (tree *Tree) initAccountsRoot() FSNode {
+	root := &FSAccountsRoot{
+		BaseFSNode: BaseFSNode{
+			name:   "/",
+			uid:    tree.uid,
+			gid:    tree.gid,
+			NodeID: fuseops.RootInodeID,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  tree.uid,
+				Gid:  tree.gid,
+			},
+			isDir:    true,
+			TrelloID: "rootID",
+			Ctx:      tree.ctx,
+		},
+	}
+	for _, account := range tree.opts.Accounts {
+		dir := tree.newTreeRoot(account.Name, account.Ctx, nil)
+		dir.TrelloID = "account:" + account.Name
+		dir.parent = root
+		root.Accounts = append(root.Accounts, dir)
+	}
+	return root
+}
+
+// The accounts' directories are added to the tree along with the root,
+// and never change.
+func (node *FSAccountsRoot) ShouldUpdate() bool {
+	return !node.isLoaded()
+}
+
+func (node *FSAccountsRoot) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+	node.markUpdated()
+	return nil, nil, nil
+}
+
+func (node *FSAccountsRoot) LookupChild(name string) (FSNode, error) {
+	for _, account := range node.Accounts {
+		if account.GetName() == name {
+			return account, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSAccountsRoot) GetEntries() []FSNode {
+	entries := make([]FSNode, len(node.Accounts))
+	for i, account := range node.Accounts {
+		entries[i] = account
+	}
+	return entries
+}
+
+// Refresh the directories workspaces are found in, should they be due: the
+// root's, or each account's. Must be called with both the updates lock and
+// the tree's lock held.
+func (tree *Tree) refreshRoots() {
+	if tree.Root != nil {
+		tree.refreshNode(tree.Root)
+	}
+	root, isAccounts := tree.inodes[fuseops.RootInodeID].(*FSAccountsRoot)
+	if isAccounts {
+		for _, account := range root.Accounts {
+			tree.refreshNode(account)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Each account's workspaces are listed in its own directory, through its
+// own context, both being browsed at once, with what looks through every
+// workspace, e.g. '.trellofs/bookmarks', finding those of each account.
+func TestAccounts(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/alice/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/members/bob/organizations":   `[{"id": "w2", "name": "home"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1",
+				"shortLink": "rm"}
+		]`,
+		"/organizations/w2/boards": `[
+			{"id": "b2", "name": "Chores", "idOrganization": "w2",
+				"shortLink": "ch"}
+		]`,
+	})
+	tree := newTestTree(t, Options{
+		MountPoint: "/mnt/trello",
+		Accounts: []Account{
+			{Name: "work", Ctx: trello.Trello("alice", "k1", "t1")},
+			{Name: "personal", Ctx: trello.Trello("bob", "k2", "t2")},
+		},
+	})
+	ctx := context.Background()
+	accounts := map[string]FSNode{
+		"work":     lookupPath(t, tree, "work"),
+		"personal": lookupPath(t, tree, "personal"),
+	}
+	want := map[string][]string{
+		"work":     {"eng", "Roadmap"},
+		"personal": {"home", "Chores"},
+	}
+
+	var wg sync.WaitGroup
+	for name, account := range accounts {
+		wg.Add(1)
+		go func(name string, account FSNode) {
+			defer wg.Done()
+			ws, err := tree.Lookup(ctx, account.GetNodeID(), want[name][0])
+			if err != nil {
+				t.Errorf("%s: %s", name, err)
+				return
+			}
+			entries, err := tree.Entries(ctx, ws.GetNodeID())
+			if err != nil {
+				t.Errorf("%s: %s", name, err)
+				return
+			}
+			var boards []string
+			for _, entry := range entries {
+				if _, isBoard := entry.(*FSBoard); isBoard {
+					boards = append(boards, entry.GetName())
+				}
+			}
+			if !reflect.DeepEqual(boards, want[name][1:]) {
+				t.Errorf("%s boards: %q, want %q",
+					name, boards, want[name][1:])
+			}
+		}(name, account)
+	}
+	wg.Wait()
+
+	bookmarks := readFile(t, tree, lookupPath(t, tree, ".trellofs/bookmarks"))
+	for _, path := range []string{
+		"/mnt/trello/work/eng/Roadmap", "/mnt/trello/personal/home/Chores",
+	} {
+		if !strings.Contains(bookmarks, "\t"+path+"\n") {
+			t.Errorf("%s not bookmarked:\n%s", path, bookmarks)
+		}
+	}
+}
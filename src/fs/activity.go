@@ -0,0 +1,82 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Days of activity covered by a board's '.activity.json'.
+const activityDays = 90
+
+type activityDay struct {
+	Date    string         `json:"date"`
+	Total   int            `json:"total"`
+	Members map[string]int `json:"members"`
+}
+
+// Generate the board's activity over the last 90 days, as JSON: how many
+// actions were taken each day, in total and by each member, by username,
+// with days running from the oldest to today, and those without activity
+// included, as
+//
+//	{"from": "<date>", "to": "<date>", "days": [
+//	    {"date": "<date>", "total": <n>, "members": {"<username>": <n>}}
+//	]}
+//
+// Days are as in Options.Location. Trello only keeps track of the most
+// recent 1000 actions, so busy boards may be missing their older days'.
+func (node *FSBoard) activity() ([]byte, error) {
+	_, loc := node.tree.opts.dateLayout()
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	from := today.AddDate(0, 0, -(activityDays - 1))
+
+	node.Lock()
+	board := *node.Board
+	node.Unlock()
+	actions, err := board.GetActions(node.api(), from)
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]*activityDay, activityDays)
+	byDate := make(map[string]*activityDay, activityDays)
+	for i := range days {
+		date := from.AddDate(0, 0, i).Format("2006-01-02")
+		days[i] = &activityDay{Date: date, Members: make(map[string]int)}
+		byDate[date] = days[i]
+	}
+	for _, action := range actions {
+		at, err := time.Parse(time.RFC3339, action.Date)
+		if err != nil {
+			continue
+		}
+		day, exists := byDate[at.In(loc).Format("2006-01-02")]
+		if !exists {
+			continue
+		}
+		day.Total++
+		if username := action.MemberCreator.Username; username != "" {
+			day.Members[username]++
+		}
+	}
+
+	contents, err := json.MarshalIndent(struct {
+		From string         `json:"from"`
+		To   string         `json:"to"`
+		Days []*activityDay `json:"days"`
+	}{days[0].Date, days[len(days)-1].Date, days}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(contents, '\n'), nil
+}
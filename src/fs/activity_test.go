@@ -0,0 +1,81 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// A board's actions are counted by day, and by member, over the last 90
+// days, those before being left out, with every day accounted for.
+func TestBoardActivity(t *testing.T) {
+	now := time.Now().UTC()
+	at := func(daysAgo int) string {
+		return now.AddDate(0, 0, -daysAgo).Format(time.RFC3339)
+	}
+	withFixtures(t, map[string]string{
+		"/boards/b1/actions": fmt.Sprintf(`[
+			{"date": %q, "memberCreator": {"username": "alice"}},
+			{"date": %q, "memberCreator": {"username": "alice"}},
+			{"date": %q, "memberCreator": {"username": "bob"}},
+			{"date": %q, "memberCreator": {"username": "alice"}},
+			{"date": %q, "memberCreator": {}},
+			{"date": %q, "memberCreator": {"username": "bob"}}
+		]`, at(0), at(0), at(0), at(1), at(1), at(100)),
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+
+	contents, err := board.activity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var activity struct {
+		From string         `json:"from"`
+		To   string         `json:"to"`
+		Days []*activityDay `json:"days"`
+	}
+	if err := json.Unmarshal(contents, &activity); err != nil {
+		t.Fatal(err)
+	}
+	from := now.AddDate(0, 0, -89).Format("2006-01-02")
+	to := now.Format("2006-01-02")
+	if activity.From != from || activity.To != to {
+		t.Errorf("activity from %s to %s, want from %s to %s",
+			activity.From, activity.To, from, to)
+	}
+	if len(activity.Days) != activityDays {
+		t.Fatalf("activity over %d days", len(activity.Days))
+	}
+	last := activity.Days[len(activity.Days)-2:]
+	want := []*activityDay{
+		{
+			Date:    now.AddDate(0, 0, -1).Format("2006-01-02"),
+			Total:   2,
+			Members: map[string]int{"alice": 1},
+		},
+		{Date: to, Total: 3, Members: map[string]int{"alice": 2, "bob": 1}},
+	}
+	if !reflect.DeepEqual(last, want) {
+		t.Errorf("last days' activity: %+v %+v, want %+v %+v",
+			*last[0], *last[1], *want[0], *want[1])
+	}
+	for _, day := range activity.Days[:len(activity.Days)-2] {
+		if day.Total != 0 || len(day.Members) != 0 {
+			t.Errorf("activity on %s: %+v", day.Date, *day)
+		}
+	}
+}
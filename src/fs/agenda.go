@@ -0,0 +1,88 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// The user's agenda: the open cards they're on, across all boards, with
+// their due dates, obtained in one request on a schedule of its own. It
+// feeds '.me/cards', and keeps the due dates of the user's cards already
+// loaded fresh for boards' 'views/due' in between refreshes of each board's
+// cards, which those views still rely on for everyone else's cards.
+const agendaInterval = 60 * time.Second
+
+type agenda struct {
+	lock  sync.Mutex
+	at    time.Time
+	cards []trello.Card
+}
+
+// The agendas obtained, by the credentials they were obtained with, as
+// workspaces may be accessed with their own.
+type agendas struct {
+	lock   sync.Mutex
+	byAuth map[string]*agenda
+}
+
+func (a *agendas) get(ctx *trello.TrelloCtx) *agenda {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.byAuth == nil {
+		a.byAuth = make(map[string]*agenda)
+	}
+	key := ctx.Key + "/" + ctx.Token
+	if _, exists := a.byAuth[key]; !exists {
+		a.byAuth[key] = &agenda{}
+	}
+	return a.byAuth[key]
+}
+
+// Obtain the user's agenda, as of at most agendaInterval ago, with the
+// cards loaded taking on their due dates whenever it's obtained anew. Must
+// be called with the updates lock held, and neither the tree's lock nor any
+// node's.
+func (tree *Tree) agendaCards(ctx *trello.TrelloCtx) ([]trello.Card, error) {
+	a := tree.agendas.get(ctx)
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if !a.at.IsZero() && time.Since(a.at) < agendaInterval {
+		return a.cards, nil
+	}
+	cards, err := trello.GetMemberDueDates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.at = time.Now()
+	a.cards = cards
+
+	var updated int
+	for _, card := range cards {
+		tree.lock.Lock()
+		node, isCard := tree.nodeByTrelloID(card.ID).(*FSCard)
+		tree.lock.Unlock()
+		if !isCard {
+			continue
+		}
+		if node.setDue(card.Due, card.DueComplete) {
+			updated++
+		}
+	}
+	if updated > 0 {
+		log.Printf("agenda: updated due dates of %d cards\n", updated)
+	}
+	return a.cards, nil
+}
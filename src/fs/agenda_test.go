@@ -0,0 +1,79 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The user's cards take on their due dates from their agenda, for due views
+// and their meta files, before their board's cards are obtained anew, with
+// '.me/cards' read from the same agenda meanwhile.
+func TestAgendaDueDates(t *testing.T) {
+	due := time.Now().UTC().AddDate(0, 0, -2).Format(time.RFC3339)
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Fix", "shortLink": "aa"},
+			{"id": "c2", "name": "Ship", "shortLink": "bb"}
+		]`,
+		"/boards/b1/lists":   `[]`,
+		"/boards/b1/members": `[]`,
+		"/members/me/cards": `[
+			{"id": "c1", "name": "Fix", "shortLink": "aa", "idBoard": "b1",
+				"due": "` + due + `"}
+		]`,
+	})
+	tree := newTestTree(t, Options{Layout: "v2"})
+	ctx := context.Background()
+	meta := lookupPath(t, tree, "eng/Roadmap/cards/Fix/Due")
+	if got := readFile(t, tree, meta); got != "" {
+		t.Fatalf("card due %q before the agenda was obtained", got)
+	}
+	me := lookupPath(t, tree, ".me/cards")
+	overdue := lookupPath(t, tree, "eng/Roadmap/views/due/overdue")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			entries, err := tree.Entries(ctx, overdue.GetNodeID())
+			if err != nil {
+				t.Error(err)
+			} else if entryNamed(entries, "Fix") == nil ||
+				entryNamed(entries, "Ship") != nil {
+				t.Errorf("overdue lists %d entries", len(entries))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 1024)
+			n, err := tree.ReadAt(ctx, me.GetNodeID(), buf, 0)
+			if err != nil && n == 0 {
+				t.Error(err)
+			} else if got := string(buf[:n]); got != "aa\t"+due+"\tFix\n" {
+				t.Errorf(".me/cards:\n%s", got)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := readFile(t, tree, meta); !strings.HasPrefix(got, due) {
+		t.Errorf("card due %q once the agenda was obtained, want %s",
+			got, due)
+	}
+}
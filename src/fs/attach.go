@@ -0,0 +1,95 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"log"
+)
+
+// Whether a board, known by any of 'keys' (its name, ID, or short link), is
+// to be mounted: it must not have been detached, and must either have been
+// attached, or be selected both by 'filter' and by Options.Boards, on the
+// workspace known by any of 'wsKeys'. Must be called with the updates lock
+// held.
+func (tree *Tree) boardWanted(
+	filter Filter,
+	wsKeys []string,
+	keys ...string,
+) bool {
+	for _, key := range keys {
+		if tree.detached[key] {
+			return false
+		}
+	}
+	for _, key := range keys {
+		if tree.attached[key] {
+			return true
+		}
+	}
+	return filter.selects(keys...) &&
+		tree.opts.Boards.selectsBoard(wsKeys, keys)
+}
+
+// Attach a board, by name, ID, or short link, to the live mount, regardless
+// of the configured board filters. Returns how many boards were attached.
+func (tree *Tree) AttachBoard(key string) int {
+	tree.updates.Lock()
+	defer tree.updates.Unlock()
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	delete(tree.detached, key)
+	tree.attached[key] = true
+	log.Printf("attach board %s\n", key)
+	return tree.refreshBoards()
+}
+
+// Detach a board, by name, ID, or short link, from the live mount, removing
+// its subtree. Returns how many boards were detached.
+func (tree *Tree) DetachBoard(key string) int {
+	tree.updates.Lock()
+	defer tree.updates.Unlock()
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	delete(tree.attached, key)
+	tree.detached[key] = true
+	log.Printf("detach board %s\n", key)
+	return -tree.refreshBoards()
+}
+
+// Refresh all workspaces at once, so boards are added or removed as needed,
+// returning how many more boards are mounted afterwards. Must be called
+// with both the updates lock and the tree's lock held.
+func (tree *Tree) refreshBoards() int {
+	before := 0
+	var workspaces []*FSWorkspace
+	for _, node := range tree.inodes {
+		switch n := node.(type) {
+		case *FSBoard:
+			before++
+		case *FSWorkspace:
+			workspaces = append(workspaces, n)
+		}
+	}
+	for _, ws := range workspaces {
+		ws.Lock()
+		ws.markStale()
+		ws.Unlock()
+		tree.refreshNode(ws)
+	}
+	after := 0
+	for _, node := range tree.inodes {
+		if _, isBoard := node.(*FSBoard); isBoard {
+			after++
+		}
+	}
+	return after - before
+}
@@ -0,0 +1,103 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Boards may be attached to, and detached from, a live mount, regardless
+// of the configured filters.
+func TestAttachDetachBoard(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "shortLink": "rm"},
+			{"id": "b2", "name": "Ops", "shortLink": "op"}
+		]`,
+	})
+	tree := newTestTree(t, Options{
+		BoardFilters: map[string]Filter{"eng": {Exclude: []string{"Ops"}}},
+	})
+	ws := newTestWorkspace(tree, &trello.Workspace{ID: "w1", Name: "eng"})
+	boards := func() []string {
+		entries, err := tree.Entries(context.Background(), ws.GetNodeID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, entry := range entries {
+			if _, isBoard := entry.(*FSBoard); isBoard {
+				names = append(names, entry.GetName())
+			}
+		}
+		return names
+	}
+	if got := boards(); len(got) != 1 || got[0] != "Roadmap" {
+		t.Fatalf("boards mounted: %q", got)
+	}
+
+	if n := tree.AttachBoard("op"); n != 1 {
+		t.Errorf("%d boards attached, want 1", n)
+	}
+	if got := boards(); len(got) != 2 {
+		t.Errorf("boards mounted once attached: %q", got)
+	}
+	if n := tree.DetachBoard("Roadmap"); n != 1 {
+		t.Errorf("%d boards detached, want 1", n)
+	}
+	if got := boards(); len(got) != 1 || got[0] != "Ops" {
+		t.Errorf("boards mounted once detached: %q", got)
+	}
+	if ws.ByID["b1"] != nil || tree.byID["b1"] != 0 {
+		t.Errorf("board detached still known")
+	}
+}
+
+// Boards may be attached and detached while the workspace is listed.
+// Meant to be run with '-race'.
+func TestAttachDetachConcurrent(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/organizations/w1/boards": `[{"id": "b1", "name": "Roadmap"}]`,
+	})
+	tree := newTestTree(t, Options{})
+	ws := newTestWorkspace(tree, &trello.Workspace{ID: "w1", Name: "eng"})
+
+	done := make(chan bool)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case err := <-listing(tree, ws):
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		tree.DetachBoard("Roadmap")
+		tree.AttachBoard("Roadmap")
+	}
+	close(done)
+	wg.Wait()
+
+	if ws.ByID["b1"] == nil {
+		t.Errorf("board attached last not mounted")
+	}
+}
@@ -0,0 +1,319 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"syscall"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A card's uploaded attachments, one file per attachment. Attachments
+// linking elsewhere are left out. Files created in the directory are
+// uploaded as new attachments once they are closed.
+type FSCardAttachmentsDir struct {
+	BaseFSNode
+
+	CardNode *FSCard
+
+	Files   []*FSAttachment
+	ByName  map[string]*FSAttachment
+	byID    map[string]*FSAttachment
+	uploads []*attachmentUpload
+}
+
+// A file created in the attachments directory. Once uploaded, it stands for
+// the resulting attachment for as long as the attachment exists.
+type attachmentUpload struct {
+	file *FSControlFile
+	id   string
+	data []byte
+}
+
+// An attachment, downloaded from Trello as it is read.
+type FSAttachment struct {
+	BaseFSNode
+
+	Attachment trello.Attachment
+}
+
+func newCardAttachmentsDir(card *FSCard) *FSCardAttachmentsDir {
+	return &FSCardAttachmentsDir{
+		BaseFSNode: BaseFSNode{
+			name: "attachments",
+			uid:  card.uid,
+			gid:  card.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  card.uid,
+				Gid:  card.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/attachments", card.GetTrelloID()),
+			Ctx:      card.Ctx,
+			parent:   card,
+		},
+		CardNode: card,
+		ByName:   make(map[string]*FSAttachment),
+		byID:     make(map[string]*FSAttachment),
+	}
+}
+
+func (node *FSCardAttachmentsDir) ShouldUpdate() bool {
+	return node.shouldUpdate(60.0)
+}
+
+func (node *FSCardAttachmentsDir) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	attachments, err := node.tree.cardAttachments(node.api(), node.CardNode.Card)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+
+	uploaded := make(map[string]bool)
+	for _, upload := range node.uploads {
+		if upload.id != "" {
+			uploaded[upload.id] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, attachment := range attachments {
+		if !attachment.IsUpload {
+			continue
+		}
+		seen[attachment.ID] = true
+		if uploaded[attachment.ID] {
+			continue
+		}
+
+		if existing, exists := node.byID[attachment.ID]; exists {
+			existing.setAttachment(attachment)
+			name, renamed := renamedName(
+				existing.GetName(), escapeName(attachment.Name), "",
+				attachment.ID,
+				func(name string) bool {
+					other := node.ByName[name]
+					return other != nil && other != existing
+				},
+			)
+			if renamed {
+				delete(node.ByName, existing.GetName())
+				existing.setName(name)
+				node.ByName[name] = existing
+			}
+			continue
+		}
+		name := escapeName(attachment.Name)
+		if _, exists := node.ByName[name]; exists {
+			name = fmt.Sprintf("%s (%s)", name, attachment.ID)
+		}
+		file := &FSAttachment{
+			BaseFSNode: BaseFSNode{
+				name: name,
+				uid:  node.uid,
+				gid:  node.gid,
+				NodeAttrs: fuseops.InodeAttributes{
+					Mode:  0400,
+					Nlink: 1,
+					Uid:   node.uid,
+					Gid:   node.gid,
+				},
+				isDir:    false,
+				TrelloID: attachment.ID,
+				Ctx:      node.Ctx,
+				parent:   node,
+			},
+		}
+		file.setAttachment(attachment)
+		node.Files = append(node.Files, file)
+		node.ByName[name] = file
+		node.byID[attachment.ID] = file
+		newNodes = append(newNodes, file)
+	}
+
+	var remaining []*FSAttachment
+	for _, file := range node.Files {
+		if seen[file.GetTrelloID()] {
+			remaining = append(remaining, file)
+			continue
+		}
+		delete(node.ByName, file.GetName())
+		delete(node.byID, file.GetTrelloID())
+		rmNodes = append(rmNodes, file)
+	}
+	node.Files = remaining
+
+	var uploads []*attachmentUpload
+	for _, upload := range node.uploads {
+		if upload.id != "" && !seen[upload.id] {
+			rmNodes = append(rmNodes, upload.file)
+			continue
+		}
+		uploads = append(uploads, upload)
+	}
+	node.uploads = uploads
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSCardAttachmentsDir) Create(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if _, exists := node.ByName[name]; exists {
+		return nil, syscall.EEXIST
+	}
+	for _, upload := range node.uploads {
+		if upload.file.GetName() == name {
+			return nil, syscall.EEXIST
+		}
+	}
+
+	upload := &attachmentUpload{}
+	upload.file = newControlFile(
+		node, name, 0.0,
+		func() ([]byte, error) { return upload.data, nil },
+		func(data []byte) error { return node.upload(upload, data) },
+	)
+	node.uploads = append(node.uploads, upload)
+	return upload.file, nil
+}
+
+// Upload what was written to a created file. Files can only be uploaded
+// once; the resulting attachment can't be modified.
+func (node *FSCardAttachmentsDir) upload(
+	upload *attachmentUpload,
+	data []byte,
+) error {
+	node.Lock()
+	defer node.Unlock()
+
+	if upload.id != "" {
+		return syscall.EPERM
+	}
+	card := node.CardNode.Card
+	name := unescapeName(upload.file.GetName())
+	attachment, err := card.AddAttachment(node.api(), name, data)
+	if err != nil {
+		return err
+	}
+	log.Printf(
+		"uploaded %s to card %s (%s) as %s, %d bytes\n",
+		name, card.Name, card.ID, attachment.ID, len(data),
+	)
+	upload.id = attachment.ID
+	upload.data = data
+	node.markStale()
+	return nil
+}
+
+func (node *FSCardAttachmentsDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if file, exists := node.ByName[name]; exists {
+		return file, nil
+	}
+	for _, upload := range node.uploads {
+		if upload.file.GetName() == name {
+			return upload.file, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSCardAttachmentsDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, 0, len(node.Files)+len(node.uploads))
+	for _, file := range node.Files {
+		entries = append(entries, file)
+	}
+	for _, upload := range node.uploads {
+		entries = append(entries, upload.file)
+	}
+	return entries
+}
+
+func (node *FSAttachment) setAttachment(attachment trello.Attachment) {
+	node.Lock()
+	defer node.Unlock()
+	node.Attachment = attachment
+	node.setSize(uint64(attachment.Bytes))
+}
+
+func (node *FSAttachment) ShouldUpdate() bool {
+	return false
+}
+
+func (node *FSAttachment) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, fuse.EINVAL
+}
+
+func (node *FSAttachment) LookupChild(name string) (FSNode, error) {
+	return nil, fuse.ENOENT
+}
+
+func (node *FSAttachment) GetEntries() []FSNode {
+	return nil
+}
+
+func (node *FSAttachment) ReadAt(dst []byte, offset int64) (int, error) {
+	return node.download(context.Background(), dst, offset)
+}
+
+// Download the attachment's contents at a given offset, abandoning the
+// download once 'ctx' is done. Attachments are downloaded as they're read,
+// rather than when updated, so reads are handed the reading operation's
+// context by the tree.
+func (node *FSAttachment) download(
+	ctx context.Context,
+	dst []byte,
+	offset int64,
+) (int, error) {
+	node.Lock()
+	attachment := node.Attachment
+	node.Unlock()
+
+	if offset >= attachment.Bytes {
+		return 0, io.EOF
+	}
+	data, err := node.Ctx.WithContext(ctx).ApiDownload(
+		attachment.URL, offset, len(dst),
+	)
+	if err != nil {
+		log.Printf(
+			"error downloading attachment %s (%s): %s\n",
+			attachment.Name, attachment.ID, err,
+		)
+		return 0, apiErrno(err)
+	}
+	n := copy(dst, data)
+	if n < len(dst) {
+		return n, io.EOF
+	}
+	return n, nil
+}
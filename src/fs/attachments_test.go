@@ -0,0 +1,143 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+const attachmentsFixture = `[
+	{"id": "a1", "name": "report.pdf", "bytes": 11, "isUpload": true,
+		"url": "https://trello.com/1/cards/c1/attachments/a1/download/r.pdf"},
+	{"id": "a2", "name": "Design doc", "isUpload": false,
+		"url": "https://example.com/design"}
+]`
+
+// A card's uploaded attachments are listed, sized as uploaded, and
+// downloaded as they're read.
+func TestCardAttachments(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/cards/c1/attachments": attachmentsFixture,
+	})
+	err := ioutil.WriteFile(
+		filepath.Join(dir, "1-cards-c1-attachments-a1-download-r.pdf"),
+		[]byte("hello world"), 0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	card := newTestCard(board, &trello.Card{ID: "c1", Name: "Fix"})
+	attachments := newCardAttachmentsDir(card)
+	addNodes(tree, attachments)
+
+	entries, err := tree.Entries(context.Background(), attachments.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entryNamed(entries, "Design doc") != nil {
+		t.Errorf("link attachment listed")
+	}
+	report := entryNamed(entries, "report.pdf")
+	if report == nil {
+		t.Fatal("uploaded attachment not listed")
+	}
+	if size := report.GetNodeAttrs().Size; size != 11 {
+		t.Errorf("attachment sized %d, want 11", size)
+	}
+	if got := readFile(t, tree, report); got != "hello world" {
+		t.Errorf("attachment reads %q", got)
+	}
+	// only the range read is downloaded.
+	buf := make([]byte, 3)
+	n, err := tree.ReadAt(context.Background(), report.GetNodeID(), buf, 6)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "wor" {
+		t.Errorf("attachment reads %q at 6", got)
+	}
+}
+
+// Files created in a card's attachments are uploaded once closed, and can't
+// be modified afterwards.
+func TestCardAttachmentsUpload(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/cards/c1/attachments":      attachmentsFixture,
+		"POST /cards/c1/attachments": `{"id": "a3", "name": "notes.txt"}`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	card := newTestCard(board, &trello.Card{ID: "c1", Name: "Fix"})
+	attachments := newCardAttachmentsDir(card)
+	addNodes(tree, attachments)
+	ctx := context.Background()
+	if err := <-listing(tree, attachments); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := tree.Create(ctx, attachments.GetNodeID(), "report.pdf")
+	if err != syscall.EEXIST {
+		t.Errorf("attachment created over another: %v", err)
+	}
+	notes, err := tree.Create(ctx, attachments.GetNodeID(), "notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(t, tree, notes, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, tree, notes); got != "hello" {
+		t.Errorf("upload reads %q", got)
+	}
+	if err := writeFile(t, tree, notes, "again"); err != syscall.EPERM {
+		t.Errorf("upload modified: %v", err)
+	}
+	requests := []string{
+		"POST /cards/c1/attachments?name=notes.txt (file: 5 bytes)",
+	}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+	if attachments.isLoaded() {
+		t.Errorf("attachments not marked stale once uploaded to")
+	}
+
+	// the upload stands for the resulting attachment.
+	err = ioutil.WriteFile(
+		filepath.Join(dir, "cards-c1-attachments.json"),
+		[]byte(`[{"id": "a3", "name": "notes.txt", "isUpload": true}]`),
+		0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := tree.Entries(ctx, attachments.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.GetName())
+	}
+	want := []string{"notes.txt", ".refresh"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("attachments listed as %q, want %q", names, want)
+	}
+}
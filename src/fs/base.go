@@ -10,17 +10,25 @@
 package fs
 
 import (
+	"os"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/jecluis/trellofs/src/trello"
 
+	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fuseops"
 )
 
 type BaseFSNode struct {
 	lock sync.Mutex
 
+	// guards the node's name and attributes, which are read by whoever
+	// serves the node, or lists its parent, while they change; see
+	// setName() and setSize().
+	attrs sync.RWMutex
+
 	name string
 
 	uid uint32
@@ -32,11 +40,27 @@ type BaseFSNode struct {
 	isDir    bool
 	TrelloID string
 
+	// the directory this node canonically lives in; nil for the root.
+	parent FSNode
+
 	lastUpdate time.Time
+	lastAccess time.Time
+
+	// the tree this node belongs to; set once the node is added to it.
+	tree *Tree
+	// the lock serializing updates to the node, i.e. its board's, or nil
+	// for nodes living outside boards; see Tree.lockUpdatesFor().
+	updates *updateLock
 
 	Ctx *trello.TrelloCtx
 }
 
+// The node's context, bound to the operation being served, if any; see
+// Tree.api(). Must be called with the node's updates lock held.
+func (base *BaseFSNode) api() *trello.TrelloCtx {
+	return base.tree.api(base.Ctx, base.updates)
+}
+
 func (base *BaseFSNode) Lock() {
 	base.lock.Lock()
 }
@@ -46,17 +70,51 @@ func (base *BaseFSNode) Unlock() {
 }
 
 func (base *BaseFSNode) GetName() string {
+	base.attrs.RLock()
+	defer base.attrs.RUnlock()
 	return base.name
 }
 
+// Rename the node, e.g. for its entity having been renamed in Trello. The
+// kernel can't be told the old name is gone (see entryTimeout), so the node
+// may still be found by it until its lookup expires. Must be called with the
+// node's updates lock held.
+func (base *BaseFSNode) setName(name string) {
+	base.attrs.Lock()
+	defer base.attrs.Unlock()
+	base.name = name
+}
+
 func (base *BaseFSNode) GetNodeID() fuseops.InodeID {
 	return base.NodeID
 }
 
 func (base *BaseFSNode) GetNodeAttrs() fuseops.InodeAttributes {
+	base.attrs.RLock()
+	defer base.attrs.RUnlock()
 	return base.NodeAttrs
 }
 
+// Change the node's size, e.g. as written to, once it's been published,
+// i.e. may be served.
+func (base *BaseFSNode) setSize(size uint64) {
+	base.attrs.Lock()
+	defer base.attrs.Unlock()
+	base.NodeAttrs.Size = size
+}
+
+func (base *BaseFSNode) setMode(mode os.FileMode) {
+	base.attrs.Lock()
+	defer base.attrs.Unlock()
+	base.NodeAttrs.Mode = mode
+}
+
+func (base *BaseFSNode) setModTime(mtime time.Time) {
+	base.attrs.Lock()
+	defer base.attrs.Unlock()
+	base.NodeAttrs.Mtime = mtime
+}
+
 func (base *BaseFSNode) GetTrelloID() string {
 	return base.TrelloID
 }
@@ -65,6 +123,19 @@ func (base *BaseFSNode) SetNodeID(id fuseops.InodeID) {
 	base.NodeID = id
 }
 
+func (base *BaseFSNode) GetParent() FSNode {
+	return base.parent
+}
+
+func (base *BaseFSNode) setTree(tree *Tree, updates *updateLock) {
+	base.tree = tree
+	base.updates = updates
+}
+
+func (base *BaseFSNode) getUpdates() *updateLock {
+	return base.updates
+}
+
 func (base *BaseFSNode) getLastUpdated() time.Time {
 	return base.lastUpdate
 }
@@ -73,6 +144,31 @@ func (base *BaseFSNode) markUpdated() {
 	base.lastUpdate = time.Now()
 }
 
+// Force the node to be updated on its next refresh.
+func (base *BaseFSNode) markStale() {
+	base.lastUpdate = time.Time{}
+}
+
+// Whether the node has been updated, and not marked stale since, i.e.,
+// whether it has anything worth serving while being refreshed.
+func (base *BaseFSNode) isLoaded() bool {
+	base.Lock()
+	defer base.Unlock()
+	return !base.lastUpdate.IsZero()
+}
+
+func (base *BaseFSNode) MarkAccessed() {
+	base.Lock()
+	defer base.Unlock()
+	base.lastAccess = time.Now()
+}
+
+func (base *BaseFSNode) GetLastAccessed() time.Time {
+	base.Lock()
+	defer base.Unlock()
+	return base.lastAccess
+}
+
 func (base *BaseFSNode) shouldUpdate(interval float64) bool {
 	base.Lock()
 	defer base.Unlock()
@@ -81,6 +177,84 @@ func (base *BaseFSNode) shouldUpdate(interval float64) bool {
 	return secs >= interval
 }
 
+// Like shouldUpdate, for nodes refreshing the given kind of entity, i.e.
+// "workspaces", "boards", "lists", or "cards", whose interval may be
+// configured. Nodes configured never to be refreshed are only loaded once,
+// or when marked stale.
+func (base *BaseFSNode) shouldRefresh(entity string, interval float64) bool {
+	if base.tree != nil {
+		if every, exists := base.tree.opts.RefreshIntervals[entity]; exists {
+			if every == 0 {
+				return !base.isLoaded()
+			}
+			interval = every.Seconds()
+		}
+	}
+	return base.shouldUpdate(interval)
+}
+
 func (base *BaseFSNode) ReadAt(dst []byte, offset int64) (int, error) {
 	return 0, nil
 }
+
+func (base *BaseFSNode) ReadLink() (string, error) {
+	return "", fuse.EINVAL
+}
+
+func (base *BaseFSNode) WriteAt(data []byte, offset int64) (int, error) {
+	return 0, syscall.EPERM
+}
+
+func (base *BaseFSNode) Truncate(size uint64) error {
+	return syscall.EPERM
+}
+
+func (base *BaseFSNode) Flush() error {
+	return nil
+}
+
+func (base *BaseFSNode) Release() error {
+	return nil
+}
+
+func (base *BaseFSNode) Unlink(name string) error {
+	return syscall.EPERM
+}
+
+func (base *BaseFSNode) Create(name string) (FSNode, error) {
+	return nil, syscall.EPERM
+}
+
+func (base *BaseFSNode) MkDir(name string) (FSNode, error) {
+	return nil, syscall.EPERM
+}
+
+func (base *BaseFSNode) RmDir(name string) error {
+	return syscall.EPERM
+}
+
+func (base *BaseFSNode) Rename(
+	name string,
+	newParent FSNode,
+	newName string,
+) error {
+	return syscall.EPERM
+}
+
+func (base *BaseFSNode) Chmod(mode os.FileMode) error {
+	return syscall.EPERM
+}
+
+// Modification times are ignored unless they mean something to the node,
+// so that, e.g., 'touch' keeps working.
+func (base *BaseFSNode) SetMtime(mtime time.Time) error {
+	return nil
+}
+
+func (base *BaseFSNode) ListXattrs() []string {
+	return nil
+}
+
+func (base *BaseFSNode) GetXattr(name string) ([]byte, error) {
+	return nil, fuse.ENOATTR
+}
@@ -13,22 +13,32 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/jecluis/trellofs/src/trello"
 
 	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fuseops"
-	"github.com/jacobsa/fuse/fuseutil"
 )
 
 type FSBoardCardsDirMeta struct {
 	BaseFSNode
 
 	BoardNode *FSBoard
+
+	hooks hookState
+
+	// what the board's cards were tagged as when last gone through.
+	etag string
+
+	// cards obtained along with the board; see hydrate.go.
+	hydrated *hydration
+
+	mirrors mirrorLinks
 }
 
 func (node *FSBoardCardsDirMeta) ShouldUpdate() bool {
-	return node.shouldUpdate(30.0)
+	return node.shouldRefresh("cards", 30.0)
 }
 
 func (node *FSBoardCardsDirMeta) Update() ([]FSNode, []FSNode, error) {
@@ -43,44 +53,45 @@ func (node *FSBoardCardsDirMeta) Update() ([]FSNode, []FSNode, error) {
 	)
 
 	board := boardNode.Board
-	cards, err := board.GetCards(node.Ctx)
-	if err != nil {
+	var cards []trello.Card
+	var err error
+	if h := takeHydration(&node.hydrated); h != nil {
+		cards = h.cards
+	} else {
+		cards, err = board.GetCardsIfChanged(node.api(), &node.etag)
+	}
+	if err == trello.ErrNotModified {
+		log.Printf(
+			"cards for board %s (%s) unchanged\n",
+			boardNode.GetName(), boardNode.GetTrelloID(),
+		)
+		node.markUpdated()
+		return nil, nil, nil
+	} else if err != nil {
 		log.Printf(
 			"error updating cars for board %s (%s) id %d\n",
 			boardNode.GetName(), boardNode.GetTrelloID(), boardNode.GetNodeID(),
 		)
 		return nil, nil, err
 	}
+	cards, mirrors := splitMirrors(cards)
+	node.detectEvents(cards)
 
 	var newNodes []FSNode = make([]FSNode, 0)
+	seen := make(map[string]bool)
 	for _, card := range cards {
+		card := card
+		seen[card.ID] = true
 		log.Printf("==> card %s board nil: %t\n", card.Name, card.Board == nil)
-		if _, exists := boardNode.ByCardID[card.ID]; exists {
+		if existing, exists := boardNode.ByCardID[card.ID]; exists {
+			existing.setCard(card)
+			boardNode.renameCard(existing, nil)
 			continue
 		}
 
-		newCard := &FSCard{
-			BaseFSNode: BaseFSNode{
-				name: card.Name,
-				uid:  node.uid,
-				gid:  node.gid,
-				NodeAttrs: fuseops.InodeAttributes{
-					Mode: 0700 | os.ModeDir,
-					Uid:  node.uid,
-					Gid:  node.gid,
-				},
-				isDir:    true,
-				TrelloID: card.ID,
-				Ctx:      node.Ctx,
-			},
-			Card:   &card,
-			ByName: make(map[string]*FSCardMetaFile),
-			ByID:   make(map[string]*FSCardMetaFile),
-		}
+		newCard := newCardNode(boardNode, &card)
 		newNodes = append(newNodes, newCard)
-		boardNode.Cards = append(boardNode.Cards, newCard)
-		boardNode.ByCardID[card.ID] = newCard
-		boardNode.ByCardName[card.Name] = newCard
+		boardNode.addCard(newCard)
 
 		log.Printf(
 			"new card on board %s (%s): %s (%s)\n",
@@ -88,6 +99,27 @@ func (node *FSBoardCardsDirMeta) Update() ([]FSNode, []FSNode, error) {
 			newCard.GetName(), newCard.GetTrelloID(),
 		)
 	}
+
+	var removedNodes []FSNode
+	for _, card := range append([]*FSCard{}, boardNode.Cards...) {
+		if seen[card.GetTrelloID()] {
+			continue
+		}
+		log.Printf(
+			"card %s (%s) gone from board %s (%s)\n",
+			card.GetName(), card.GetTrelloID(),
+			boardNode.GetName(), boardNode.GetTrelloID(),
+		)
+		removedNodes = append(removedNodes, card)
+		boardNode.removeCard(card)
+	}
+	newLinks, goneLinks := node.mirrors.update(
+		node.tree, node, mirrors,
+		func(name string) bool { return boardNode.ByCardName[name] != nil },
+	)
+	newNodes = append(newNodes, newLinks...)
+	removedNodes = append(removedNodes, goneLinks...)
+
 	node.markUpdated()
 	log.Printf(
 		"updated cards for board %s (%s): %d new nodes, %d total cards\n",
@@ -95,65 +127,56 @@ func (node *FSBoardCardsDirMeta) Update() ([]FSNode, []FSNode, error) {
 		len(newNodes), len(boardNode.Cards),
 	)
 
-	return newNodes, nil, nil
+	return newNodes, removedNodes, nil
 }
 
+// The board's cards may be added to by its lists' updates, so they're looked
+// at with the board's lock held, rather than the directory's; mirrors, on
+// the other hand, are the directory's.
 func (node *FSBoardCardsDirMeta) LookupChild(name string) (FSNode, error) {
-	node.Lock()
-	defer node.Unlock()
-
+	node.BoardNode.Lock()
 	for _, card := range node.BoardNode.Cards {
 		if card.GetName() == name {
+			node.BoardNode.Unlock()
 			return card, nil
 		}
 	}
-	return nil, fuse.ENOENT
-}
+	node.BoardNode.Unlock()
 
-func (node *FSBoardCardsDirMeta) ReadDir(dst []byte, offset int) int {
 	node.Lock()
 	defer node.Unlock()
+	if link := node.mirrors.lookup(name); link != nil {
+		return link, nil
+	}
+	return nil, fuse.ENOENT
+}
 
-	log.Printf(
-		"read dir %s/%s (%s) id %d, offset %d\n",
-		node.BoardNode.GetName(),
-		node.GetName(), node.GetTrelloID(), node.GetNodeID(), offset,
-	)
-	var size int
-	for i := offset; i < len(node.BoardNode.Cards); i++ {
-		card := node.BoardNode.Cards[i]
-		log.Printf("-> card ptr null: %t\n", card.Card == nil)
-		tmp := fuseutil.WriteDirent(dst[size:], fuseutil.Dirent{
-			Name:   card.GetName(),
-			Inode:  card.GetNodeID(),
-			Type:   fuseutil.DT_Directory,
-			Offset: fuseops.DirOffset(i + 1),
-		})
-		if tmp == 0 {
-			log.Printf(
-				"read dir > no more space to write dirent for %s (%s)\n",
-				node.BoardNode.GetName(), node.BoardNode.GetTrelloID(),
-			)
-			break
-		}
-		log.Printf(
-			"read dir %s/%s id %d: wrote direntry for %s (%s) id %d\n",
-			node.BoardNode.GetName(), node.GetName(), node.GetNodeID(),
-			card.GetName(), card.GetTrelloID(), card.GetNodeID(),
-		)
-		size += tmp
+func (node *FSBoardCardsDirMeta) GetEntries() []FSNode {
+	node.BoardNode.Lock()
+	entries := make([]FSNode, len(node.BoardNode.Cards))
+	for i, card := range node.BoardNode.Cards {
+		entries[i] = card
 	}
-	return size
+	node.BoardNode.Unlock()
+
+	node.Lock()
+	defer node.Unlock()
+	return append(entries, node.mirrors.entries()...)
 }
 
 type FSBoardListsDirMeta struct {
 	BaseFSNode
 
 	BoardNode *FSBoard
+	Overflow  *FSListsOverflowDir
+
+	// lists, and their cards, obtained along with the board; see
+	// hydrate.go.
+	hydrated *hydration
 }
 
 func (node *FSBoardListsDirMeta) ShouldUpdate() bool {
-	return node.shouldUpdate(60.0)
+	return node.shouldRefresh("lists", 60.0)
 }
 
 func (node *FSBoardListsDirMeta) Update() ([]FSNode, []FSNode, error) {
@@ -167,7 +190,14 @@ func (node *FSBoardListsDirMeta) Update() ([]FSNode, []FSNode, error) {
 	)
 
 	board := node.BoardNode.Board
-	lists, err := board.GetLists(node.BoardNode.Ctx)
+	var lists []trello.List
+	var err error
+	h := takeHydration(&node.hydrated)
+	if h != nil {
+		lists = h.lists
+	} else {
+		lists, err = board.GetLists(node.BoardNode.api())
+	}
 	if err != nil {
 		log.Printf(
 			"error updating lists for board %s (%s)\n",
@@ -183,15 +213,28 @@ func (node *FSBoardListsDirMeta) Update() ([]FSNode, []FSNode, error) {
 		node.BoardNode.GetTrelloID(),
 	)
 
+	// lists are only given an inode once looked up or listed, as boards
+	// may have hundreds of them; see listsoverflow.go.
 	var newNodes []FSNode = make([]FSNode, 0)
+	seen := make(map[string]bool)
 	for _, list := range lists {
-		if _, exists := node.BoardNode.ByListID[list.ID]; exists {
+		list := list
+		seen[list.ID] = true
+		if existing, exists := node.BoardNode.ByListID[list.ID]; exists {
+			node.renameList(existing, &list)
 			continue
 		}
 
+		name := uniqueName(
+			node.tree.opts.listName(&list, node.BoardNode.idNames),
+			shortID(list.ID), list.ID,
+			func(name string) bool {
+				return node.BoardNode.ByListName[name] != nil
+			},
+		)
 		newList := &FSList{
 			BaseFSNode: BaseFSNode{
-				name: list.Name,
+				name: name,
 				uid:  node.uid,
 				gid:  node.gid,
 				NodeAttrs: fuseops.InodeAttributes{
@@ -202,16 +245,20 @@ func (node *FSBoardListsDirMeta) Update() ([]FSNode, []FSNode, error) {
 				isDir:    true,
 				TrelloID: list.ID,
 				Ctx:      node.BoardNode.Ctx,
+				parent:   node,
 			},
 			ByID:      make(map[string]*FSCard),
 			ByName:    make(map[string]*FSCard),
+			links:     make(map[string]*FSSymlink),
 			BoardNode: node.BoardNode,
 			List:      &list,
 		}
-		newNodes = append(newNodes, newList)
+		if h != nil {
+			newList.hydrated = h.forList(list.ID)
+		}
 		node.BoardNode.Lists = append(node.BoardNode.Lists, newList)
 		node.BoardNode.ByListID[list.ID] = newList
-		node.BoardNode.ByListName[list.Name] = newList
+		node.BoardNode.ByListName[name] = newList
 
 		log.Printf(
 			"new list %s (%s) on board %s (%s)\n",
@@ -219,6 +266,27 @@ func (node *FSBoardListsDirMeta) Update() ([]FSNode, []FSNode, error) {
 			node.BoardNode.GetName(), node.BoardNode.GetTrelloID(),
 		)
 	}
+
+	// cards on a list that's gone are either gone as well, or have been
+	// moved elsewhere; either way, they're not the list's to remove.
+	var removedNodes []FSNode
+	listsLeft := make([]*FSList, 0, len(node.BoardNode.Lists))
+	for _, list := range node.BoardNode.Lists {
+		if seen[list.GetTrelloID()] {
+			listsLeft = append(listsLeft, list)
+			continue
+		}
+		log.Printf(
+			"list %s (%s) gone from board %s (%s)\n",
+			list.GetName(), list.GetTrelloID(),
+			node.BoardNode.GetName(), node.BoardNode.GetTrelloID(),
+		)
+		removedNodes = append(removedNodes, list)
+		delete(node.BoardNode.ByListID, list.GetTrelloID())
+		delete(node.BoardNode.ByListName, list.GetName())
+	}
+	node.BoardNode.Lists = listsLeft
+
 	node.markUpdated()
 	log.Printf(
 		"updated lists for board %s (%s): %d new nodes, %d total lists\n",
@@ -226,13 +294,50 @@ func (node *FSBoardListsDirMeta) Update() ([]FSNode, []FSNode, error) {
 		len(newNodes), len(node.BoardNode.Lists),
 	)
 
-	return newNodes, nil, nil
+	return newNodes, removedNodes, nil
 }
 
+// Keep a list's directory named after it, should it have been renamed.
+func (node *FSBoardListsDirMeta) renameList(
+	listNode *FSList,
+	list *trello.List,
+) {
+	boardNode := node.BoardNode
+	listNode.Lock()
+	*listNode.List = *list
+	listNode.Unlock()
+
+	name, renamed := renamedName(
+		listNode.GetName(),
+		node.tree.opts.listName(list, boardNode.idNames),
+		shortID(list.ID), list.ID,
+		func(name string) bool {
+			other := boardNode.ByListName[name]
+			return other != nil && other != listNode
+		},
+	)
+	if !renamed {
+		return
+	}
+	log.Printf(
+		"list %s (%s) on board %s (%s) renamed to %s\n",
+		listNode.GetName(), list.ID,
+		boardNode.GetName(), boardNode.GetTrelloID(), name,
+	)
+	delete(boardNode.ByListName, listNode.GetName())
+	listNode.setName(name)
+	boardNode.ByListName[name] = listNode
+}
+
+// Lists left out of the listing, for being beyond Options.MaxLists, are
+// looked up all the same.
 func (node *FSBoardListsDirMeta) LookupChild(name string) (FSNode, error) {
 	node.Lock()
 	defer node.Unlock()
 
+	if name == node.Overflow.GetName() {
+		return node.Overflow, nil
+	}
 	for _, list := range node.BoardNode.Lists {
 		if list.GetName() == name {
 			return list, nil
@@ -241,39 +346,19 @@ func (node *FSBoardListsDirMeta) LookupChild(name string) (FSNode, error) {
 	return nil, fuse.ENOENT
 }
 
-func (node *FSBoardListsDirMeta) ReadDir(dst []byte, offset int) int {
+func (node *FSBoardListsDirMeta) GetEntries() []FSNode {
 	node.Lock()
 	defer node.Unlock()
 
-	log.Printf(
-		"read dir %s/%s (%s) id %d, offset %d\n",
-		node.BoardNode.GetName(),
-		node.GetName(), node.GetTrelloID(), node.GetNodeID(), offset,
-	)
-	var size int
-	for i := offset; i < len(node.BoardNode.Lists); i++ {
-		list := node.BoardNode.Lists[i]
-		tmp := fuseutil.WriteDirent(dst[size:], fuseutil.Dirent{
-			Name:   list.GetName(),
-			Inode:  list.GetNodeID(),
-			Type:   fuseutil.DT_Directory,
-			Offset: fuseops.DirOffset(i + 1),
-		})
-		if tmp == 0 {
-			log.Printf(
-				"read dir > no more space to write dirent for %s (%s)\n",
-				node.BoardNode.GetName(), node.BoardNode.GetTrelloID(),
-			)
-			break
-		}
-		log.Printf(
-			"read dir %s/%s id %d: wrote direntry for %s (%s) id %d\n",
-			node.BoardNode.GetName(), node.GetName(), node.GetNodeID(),
-			list.GetName(), list.GetTrelloID(), list.GetNodeID(),
-		)
-		size += tmp
+	listed, overflow := node.listed()
+	entries := make([]FSNode, len(listed), len(listed)+1)
+	for i, list := range listed {
+		entries[i] = list
+	}
+	if len(overflow) > 0 {
+		entries = append(entries, node.Overflow)
 	}
-	return size
+	return entries
 }
 
 type FSBoard struct {
@@ -281,6 +366,24 @@ type FSBoard struct {
 
 	MetaCardsDir *FSBoardCardsDirMeta
 	MetaListsDir *FSBoardListsDirMeta
+	MetaSummary  *FSGeneratedFile
+	MetaPrefsDir *FSBoardPrefsDir
+	MetaMembers  *FSBoardMembersDir
+	MetaLabels   *FSBoardLabelsDir
+	MetaName     *FSGeneratedFile
+	MetaDot      *FSGeneratedFile
+	MetaMermaid  *FSGeneratedFile
+	MetaCycles   *FSGeneratedFile
+	MetaActivity *FSGeneratedFile
+	MetaRaw      *FSGeneratedFile
+	MetaViews    *FSBoardViewsDir
+	MetaFind     *FSControlFile
+
+	MetaDuplicates *FSBoardDuplicatesDir
+	MetaMentions   *FSBoardMentionsDir
+
+	MetaChangelog *FSGeneratedFile
+	changelog     []byte
 
 	Cards      []*FSCard
 	ByCardID   map[string]*FSCard
@@ -291,10 +394,22 @@ type FSBoard struct {
 	ByListName map[string]*FSList
 
 	Board *trello.Board
+
+	// whether the board, its lists, and its cards are named after their ID.
+	idNames bool
+
+	// periods cards spent in lists, as last obtained; see listStints().
+	stints   []*listStint
+	stintsAt time.Time
+
+	// serializes updates to, and changes of, the board and whatever lives
+	// within it, so that boards are updated in parallel; see
+	// Tree.lockUpdatesFor().
+	boardUpdates updateLock
 }
 
 func (node *FSBoard) ShouldUpdate() bool {
-	return node.shouldUpdate(30.0)
+	return node.shouldRefresh("boards", 30.0)
 }
 
 func (node *FSBoard) Update() ([]FSNode, []FSNode, error) {
@@ -324,6 +439,7 @@ func (node *FSBoard) Update() ([]FSNode, []FSNode, error) {
 			isDir:    true,
 			TrelloID: fmt.Sprintf("%s/cards", node.GetTrelloID()),
 			Ctx:      node.Ctx,
+			parent:   node,
 		},
 		BoardNode: node,
 	}
@@ -340,10 +456,59 @@ func (node *FSBoard) Update() ([]FSNode, []FSNode, error) {
 			isDir:    true,
 			TrelloID: fmt.Sprintf("%s/lists", node.GetTrelloID()),
 			Ctx:      node.Ctx,
+			parent:   node,
 		},
 		BoardNode: node,
 	}
-	newNodes = append(newNodes, node.MetaCardsDir, node.MetaListsDir)
+	node.MetaListsDir.Overflow = newListsOverflowDir(node.MetaListsDir)
+	node.MetaSummary = newGeneratedFile(
+		node, ".summary", 30.0, node.summary,
+	)
+	node.MetaPrefsDir = newBoardPrefsDir(node)
+	node.MetaMembers = newBoardMembersDir(node)
+	node.MetaLabels = newBoardLabelsDir(node)
+	node.MetaDot = newGeneratedFile(node, "graph.dot", 60.0, node.graphDot)
+	node.MetaMermaid = newGeneratedFile(
+		node, "graph.mmd", 60.0, node.graphMermaid,
+	)
+	node.MetaCycles = newGeneratedFile(
+		node, "cycle-times.csv", 60.0, node.cycleTimes,
+	)
+	node.MetaActivity = newGeneratedFile(
+		node, ".activity.json", 300.0, node.activity,
+	)
+	node.MetaRaw = newRawFile(node, node.Ctx, "boards")
+	node.MetaFind = newFindFile(node)
+	node.MetaDuplicates = newBoardDuplicatesDir(node)
+	node.MetaMentions = newBoardMentionsDir(node)
+	node.MetaChangelog = newGeneratedFile(
+		node, ".changelog", 0.0, func() ([]byte, error) {
+			node.Lock()
+			defer node.Unlock()
+			return node.changelog, nil
+		},
+	)
+	newNodes = append(
+		newNodes, node.MetaCardsDir, node.MetaListsDir, node.MetaSummary,
+		node.MetaPrefsDir, node.MetaMembers, node.MetaLabels,
+		node.MetaDot, node.MetaMermaid, node.MetaCycles, node.MetaFind,
+		node.MetaChangelog, node.MetaDuplicates, node.MetaMentions,
+		node.MetaActivity, node.MetaRaw,
+	)
+	// there if views are configured, or, from the v2 layout on, for its own.
+	if len(node.tree.opts.Views) > 0 || node.tree.opts.layoutSince("v2") {
+		node.MetaViews = newBoardViewsDir(node)
+		newNodes = append(newNodes, node.MetaViews)
+	}
+	if node.idNames {
+		node.MetaName = newGeneratedFile(
+			node, "name", 30.0, func() ([]byte, error) {
+				return []byte(node.Board.Name + "\n"), nil
+			},
+		)
+		newNodes = append(newNodes, node.MetaName)
+	}
+	node.hydrate()
 	node.markUpdated()
 	log.Printf(
 		"updated board %s (%s)", node.Board.Name, node.Board.ID,
@@ -369,39 +534,154 @@ func (node *FSBoard) LookupChild(name string) (FSNode, error) {
 	} else if name == "cards" {
 		child = node.MetaCardsDir
 		err = nil
+	} else if name == ".summary" {
+		child = node.MetaSummary
+		err = nil
+	} else if name == "_prefs" {
+		child = node.MetaPrefsDir
+		err = nil
+	} else if name == "members" {
+		child = node.MetaMembers
+		err = nil
+	} else if name == "labels" {
+		child = node.MetaLabels
+		err = nil
+	} else if name == "graph.dot" {
+		child = node.MetaDot
+		err = nil
+	} else if name == "graph.mmd" {
+		child = node.MetaMermaid
+		err = nil
+	} else if name == "cycle-times.csv" {
+		child = node.MetaCycles
+		err = nil
+	} else if name == ".find" {
+		child = node.MetaFind
+		err = nil
+	} else if name == ".changelog" {
+		child = node.MetaChangelog
+		err = nil
+	} else if name == ".activity.json" {
+		child = node.MetaActivity
+		err = nil
+	} else if name == ".raw.json" {
+		child = node.MetaRaw
+		err = nil
+	} else if name == ".duplicates" {
+		child = node.MetaDuplicates
+		err = nil
+	} else if name == "by-mention" {
+		child = node.MetaMentions
+		err = nil
+	} else if name == "views" && node.MetaViews != nil {
+		child = node.MetaViews
+		err = nil
+	} else if name == "name" && node.MetaName != nil {
+		child = node.MetaName
+		err = nil
 	}
 	return child, err
 }
 
-func (node *FSBoard) ReadDir(dst []byte, offset int) int {
+func (node *FSBoard) GetEntries() []FSNode {
 	node.Lock()
 	defer node.Unlock()
 
-	fmt.Printf(
-		"read dir board %s (%s) id %d, offset %d\n",
-		node.GetName(), node.GetTrelloID(), node.GetNodeID(), offset,
+	var entries []FSNode = make([]FSNode, 0)
+	if node.MetaCardsDir != nil && node.MetaListsDir != nil {
+		entries = append(
+			entries, node.MetaCardsDir, node.MetaListsDir, node.MetaSummary,
+			node.MetaPrefsDir, node.MetaMembers, node.MetaLabels,
+			node.MetaDot, node.MetaMermaid, node.MetaCycles, node.MetaFind,
+			node.MetaChangelog, node.MetaDuplicates, node.MetaMentions,
+			node.MetaActivity, node.MetaRaw,
+		)
+	}
+	if node.MetaViews != nil {
+		entries = append(entries, node.MetaViews)
+	}
+	if node.MetaName != nil {
+		entries = append(entries, node.MetaName)
+	}
+	return entries
+}
+
+// Add a card to the board, unless already known.
+func (node *FSBoard) addCard(card *FSCard) {
+	node.Lock()
+	defer node.Unlock()
+
+	if _, exists := node.ByCardID[card.GetTrelloID()]; exists {
+		return
+	}
+	node.Cards = append(node.Cards, card)
+	node.ByCardID[card.GetTrelloID()] = card
+	node.ByCardName[card.GetName()] = card
+}
+
+// Keep a card's directory named after it, should it have been renamed.
+// 'locked' is the list whose lock the caller holds, if any, e.g. as it's
+// being updated; the board's and other lists' are taken as needed. Cards only
+// ever change lists with the board's updates lock held, as must this be
+// called.
+func (node *FSBoard) renameCard(card *FSCard, locked *FSList) {
+	card.Lock()
+	want := node.tree.opts.cardName(card.Card, node.idNames)
+	shortLink, id := card.Card.ShortLink, card.Card.ID
+	card.Unlock()
+
+	node.Lock()
+	name, renamed := renamedName(
+		card.GetName(), want, shortLink, id,
+		func(name string) bool {
+			other := node.ByCardName[name]
+			return other != nil && other != card
+		},
 	)
+	if !renamed {
+		node.Unlock()
+		return
+	}
+	log.Printf(
+		"card %s (%s) on board %s (%s) renamed to %s\n",
+		card.GetName(), id, node.GetName(), node.GetTrelloID(), name,
+	)
+	oldName := card.GetName()
+	delete(node.ByCardName, oldName)
+	card.setName(name)
+	node.ByCardName[name] = card
+	lists := append([]*FSList{}, node.Lists...)
+	node.Unlock()
 
-	var entries []FSNode = make([]FSNode, 2)
-	entries[0] = node.MetaCardsDir
-	entries[1] = node.MetaListsDir
-
-	var size int
-	for i := offset; i < len(entries); i++ {
-		tmp := fuseutil.WriteDirent(dst[size:], fuseutil.Dirent{
-			Name:   entries[i].GetName(),
-			Inode:  entries[i].GetNodeID(),
-			Type:   fuseutil.DT_Directory,
-			Offset: fuseops.DirOffset(i + 1),
-		})
-		if tmp == 0 {
-			log.Printf(
-				"read dir board > no more space to write dirent for %s\n",
-				entries[i].GetName(),
-			)
+	for _, list := range lists {
+		if list != locked {
+			list.Lock()
+		}
+		if list.ByName[oldName] == card {
+			delete(list.ByName, oldName)
+			list.ByName[name] = card
+		}
+		if list != locked {
+			list.Unlock()
+		}
+	}
+}
+
+// Remove a card from the board, and from whichever of its lists holds it.
+func (node *FSBoard) removeCard(card *FSCard) {
+	node.Lock()
+	for i, entry := range node.Cards {
+		if entry == card {
+			node.Cards = append(node.Cards[:i], node.Cards[i+1:]...)
 			break
 		}
-		size += tmp
 	}
-	return size
+	delete(node.ByCardID, card.GetTrelloID())
+	delete(node.ByCardName, card.GetName())
+	node.Unlock()
+	for _, list := range node.Lists {
+		list.Lock()
+		list.removeCard(card)
+		list.Unlock()
+	}
 }
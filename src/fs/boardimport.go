@@ -0,0 +1,298 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A workspace's '.import' directory. Trello board exports written to it are
+// recreated as new boards in the workspace; for each export '<file>', the
+// import's progress is reported in '<file>.progress'.
+type FSWorkspaceImportDir struct {
+	BaseFSNode
+
+	WorkspaceNode *FSWorkspace
+
+	entries []FSNode
+}
+
+func newWorkspaceImportDir(ws *FSWorkspace) *FSWorkspaceImportDir {
+	return &FSWorkspaceImportDir{
+		BaseFSNode: BaseFSNode{
+			name: ".import",
+			uid:  ws.uid,
+			gid:  ws.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  ws.uid,
+				Gid:  ws.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/.import", ws.GetTrelloID()),
+			Ctx:      ws.Ctx,
+			parent:   ws,
+		},
+		WorkspaceNode: ws,
+	}
+}
+
+func (node *FSWorkspaceImportDir) ShouldUpdate() bool {
+	return false
+}
+
+func (node *FSWorkspaceImportDir) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, nil
+}
+
+func (node *FSWorkspaceImportDir) Create(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if strings.HasSuffix(name, ".progress") {
+		return nil, syscall.EPERM
+	}
+	for _, entry := range node.entries {
+		if entry.GetName() == name {
+			return nil, syscall.EEXIST
+		}
+	}
+
+	importer := &boardImporter{ws: node.WorkspaceNode}
+	file := newControlFile(
+		node, name, 0.0,
+		func() ([]byte, error) { return nil, nil },
+		importer.submit,
+	)
+	progress := newGeneratedFile(
+		node, name+".progress", 0.0, importer.result,
+	)
+	node.entries = append(node.entries, file, progress)
+	return file, nil
+}
+
+func (node *FSWorkspaceImportDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, entry := range node.entries {
+		if entry.GetName() == name {
+			return entry, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSWorkspaceImportDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+	return append([]FSNode{}, node.entries...)
+}
+
+// Recreates a board from a Trello export: its labels, lists, cards, and
+// checklists. Archived lists and cards are skipped.
+type boardImporter struct {
+	job
+
+	ws *FSWorkspace
+}
+
+func (imp *boardImporter) submit(data []byte) error {
+	if err := imp.start(); err != nil {
+		log.Printf("import > refusing export, another one is still running\n")
+		return err
+	}
+
+	var export trello.BoardExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		log.Printf("import > unable to parse export: %s\n", err)
+		imp.reportf("error\t%s\n", err)
+		imp.finish()
+		return err
+	} else if export.Name == "" {
+		imp.reportf("error\tnot a board export\n")
+		imp.finish()
+		return syscall.EINVAL
+	}
+	go imp.run(&export)
+	return nil
+}
+
+func (imp *boardImporter) run(export *trello.BoardExport) {
+	defer imp.finish()
+
+	ctx := imp.ws.Ctx
+	var board *trello.Board
+	err := paced(func() error {
+		var err error
+		board, err = imp.ws.Workspace.CreateBoard(
+			ctx, export.Name, url.Values{
+				"desc":          {export.Desc},
+				"defaultLists":  {"false"},
+				"defaultLabels": {"false"},
+			},
+		)
+		return err
+	})
+	if err != nil {
+		imp.reportf("error\tboard\t%s\n", err)
+		return
+	}
+	imp.reportf("board\tok\t%s\n", board.ID)
+
+	failed := 0
+	labels := make(map[string]string)
+	for _, label := range export.Labels {
+		label := label
+		var newLabel *trello.Label
+		err := paced(func() error {
+			var err error
+			newLabel, err = board.CreateLabel(ctx, label.Name, label.Color)
+			return err
+		})
+		if err != nil {
+			failed++
+			imp.reportf("error\tlabel %s\t%s\n", label.ID, err)
+			continue
+		}
+		labels[label.ID] = newLabel.ID
+	}
+	imp.reportf("labels\t%d/%d\n", len(labels), len(export.Labels))
+
+	exportLists := export.Lists
+	sort.SliceStable(exportLists, func(i, j int) bool {
+		return exportLists[i].Pos < exportLists[j].Pos
+	})
+	lists := make(map[string]*trello.List)
+	numLists := 0
+	for _, list := range exportLists {
+		if list.Closed {
+			continue
+		}
+		numLists++
+		list := list
+		var newList *trello.List
+		err := paced(func() error {
+			var err error
+			newList, err = board.CreateList(ctx, list.Name)
+			return err
+		})
+		if err != nil {
+			failed++
+			imp.reportf("error\tlist %s\t%s\n", list.ID, err)
+			continue
+		}
+		lists[list.ID] = newList
+	}
+	imp.reportf("lists\t%d/%d\n", len(lists), numLists)
+
+	exportCards := export.Cards
+	sort.SliceStable(exportCards, func(i, j int) bool {
+		return exportCards[i].Pos < exportCards[j].Pos
+	})
+	cards := make(map[string]*trello.Card)
+	numCards := 0
+	for _, card := range exportCards {
+		list, exists := lists[card.ListID]
+		if card.Closed || !exists {
+			continue
+		}
+		numCards++
+		card := card
+		fields := url.Values{}
+		if card.Desc != "" {
+			fields.Set("desc", card.Desc)
+		}
+		if card.Due != "" {
+			fields.Set("due", card.Due)
+		}
+		var labelIDs []string
+		for _, id := range card.LabelIDs {
+			if newID, exists := labels[id]; exists {
+				labelIDs = append(labelIDs, newID)
+			}
+		}
+		if len(labelIDs) > 0 {
+			fields.Set("idLabels", strings.Join(labelIDs, ","))
+		}
+
+		var newCard *trello.Card
+		err := paced(func() error {
+			var err error
+			newCard, err = list.CreateCard(ctx, card.Name, fields)
+			return err
+		})
+		if err != nil {
+			failed++
+			imp.reportf("error\tcard %s\t%s\n", card.ID, err)
+			continue
+		}
+		cards[card.ID] = newCard
+		if len(cards)%25 == 0 {
+			imp.reportf("cards\t%d/%d\n", len(cards), numCards)
+		}
+	}
+	imp.reportf("cards\t%d/%d\n", len(cards), numCards)
+
+	numChecklists := 0
+	numImported := 0
+	for _, checklist := range export.Checklists {
+		card, exists := cards[checklist.CardID]
+		if !exists {
+			continue
+		}
+		numChecklists++
+		checklist := checklist
+		var newChecklist *trello.Checklist
+		err := paced(func() error {
+			var err error
+			newChecklist, err = card.CreateChecklist(ctx, checklist.Name)
+			return err
+		})
+		if err != nil {
+			failed++
+			imp.reportf("error\tchecklist %s\t%s\n", checklist.ID, err)
+			continue
+		}
+		for _, item := range checklist.CheckItems {
+			item := item
+			err := paced(func() error {
+				return newChecklist.AddItem(
+					ctx, item.Name, item.State == "complete",
+				)
+			})
+			if err != nil {
+				failed++
+				imp.reportf("error\tcheck item %s\t%s\n", item.ID, err)
+			}
+		}
+		numImported++
+	}
+	imp.reportf("checklists\t%d/%d\n", numImported, numChecklists)
+
+	// stale before done, for those waiting on the report to find the board.
+	imp.ws.Lock()
+	imp.ws.markStale()
+	imp.ws.Unlock()
+
+	imp.reportf("done\t%d failed\n", failed)
+}
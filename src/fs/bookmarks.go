@@ -0,0 +1,78 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type bookmark struct {
+	kind string
+	url  string
+	path string
+}
+
+// List every mounted workspace and board, loading them if need be, as
+//
+//	<workspace|board>	<URL>	<path>
+//
+// sorted by path, so launchers, e.g. rofi or fzf, may offer jumping to them.
+// Paths are under Options.MountPoint, or relative to the root if unset.
+// Called with the updates lock held, when the file is updated.
+func (tree *Tree) bookmarks() ([]byte, error) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	tree.refreshRoots()
+	var workspaces []*FSWorkspace
+	for _, node := range tree.inodes {
+		if ws, isWorkspace := node.(*FSWorkspace); isWorkspace {
+			workspaces = append(workspaces, ws)
+		}
+	}
+	for _, ws := range workspaces {
+		tree.refreshNode(ws)
+	}
+
+	path := func(node FSNode) string {
+		mount := strings.TrimSuffix(tree.opts.MountPoint, "/")
+		return mount + "/" + nodePath(node)
+	}
+	var bookmarks []bookmark
+	for _, node := range tree.inodes {
+		switch n := node.(type) {
+		case *FSWorkspace:
+			n.Lock()
+			url := "https://trello.com/w/" + n.Workspace.Name
+			n.Unlock()
+			bookmarks = append(bookmarks, bookmark{"workspace", url, path(n)})
+		case *FSBoard:
+			n.Lock()
+			url := n.Board.ShortURL
+			if url == "" {
+				url = "https://trello.com/b/" + n.Board.ShortLink
+			}
+			n.Unlock()
+			bookmarks = append(bookmarks, bookmark{"board", url, path(n)})
+		}
+	}
+	sort.Slice(bookmarks, func(i, j int) bool {
+		return bookmarks[i].path < bookmarks[j].path
+	})
+
+	var buf bytes.Buffer
+	for _, entry := range bookmarks {
+		fmt.Fprintf(&buf, "%s\t%s\t%s\n", entry.kind, entry.url, entry.path)
+	}
+	return buf.Bytes(), nil
+}
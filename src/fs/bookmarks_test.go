@@ -0,0 +1,61 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// '.trellofs/bookmarks' lists every workspace and board mounted, even if
+// yet to be looked up, with their URLs and paths under the mount point.
+func TestBookmarks(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1",
+				"shortLink": "rm", "shortUrl": "https://trello.com/b/rm"},
+			{"id": "b2", "name": "Bugs", "idOrganization": "w1",
+				"shortLink": "bg"}
+		]`,
+	})
+	tree := newTestTree(t, Options{MountPoint: "/mnt/trello/"})
+
+	bookmarks := lookupPath(t, tree, ".trellofs/bookmarks")
+	got := readFile(t, tree, bookmarks)
+	want := "workspace\thttps://trello.com/w/eng\t/mnt/trello/eng\n" +
+		"board\thttps://trello.com/b/bg\t/mnt/trello/eng/Bugs\n" +
+		"board\thttps://trello.com/b/rm\t/mnt/trello/eng/Roadmap\n"
+	if got != want {
+		t.Errorf("bookmarks:\n%s\nwant:\n%s", got, want)
+	}
+
+	// boards added are listed once refreshed.
+	err := ioutil.WriteFile(
+		filepath.Join(dir, "organizations-w1-boards.json"),
+		[]byte(`[{"id": "b3", "name": "Ops", "idOrganization": "w1",
+			"shortLink": "op"}]`),
+		0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh := lookupPath(t, tree, ".trellofs/refresh")
+	if err := writeFile(t, tree, refresh, "1\n"); err != nil {
+		t.Fatal(err)
+	}
+	got = readFile(t, tree, bookmarks)
+	want = "workspace\thttps://trello.com/w/eng\t/mnt/trello/eng\n" +
+		"board\thttps://trello.com/b/op\t/mnt/trello/eng/Ops\n"
+	if got != want {
+		t.Errorf("bookmarks once refreshed:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,36 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"sync"
+)
+
+// Buffers used while serving requests, pooled so that frequent reads, e.g.
+// from shell prompts or file managers polling the tree, don't churn the
+// heap. Buffers are kept as pointers, so putting them back doesn't allocate.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new([]byte) },
+}
+
+// Obtain a buffer of 'size' bytes, whose contents are undefined.
+func getBuffer(size int) *[]byte {
+	buf := bufferPool.Get().(*[]byte)
+	if cap(*buf) < size {
+		*buf = make([]byte, size)
+	}
+	*buf = (*buf)[:size]
+	return buf
+}
+
+// Return a buffer to the pool, once nothing refers to its contents.
+func putBuffer(buf *[]byte) {
+	bufferPool.Put(buf)
+}
@@ -0,0 +1,154 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Obtain a tree, and the inode IDs of '.trellofs', and of a file of 'size'
+// bytes in it, generated once; neither reaches Trello.
+func newBenchTree(
+	tb testing.TB,
+	size int,
+) (*Tree, fuseops.InodeID, fuseops.InodeID) {
+	out := log.Writer()
+	log.SetOutput(ioutil.Discard)
+	tb.Cleanup(func() { log.SetOutput(out) })
+
+	tree, err := NewTree(0, 0, trello.Trello("me", "", ""), Options{})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	ctl, err := tree.Lookup(
+		context.Background(), fuseops.RootInodeID, ".trellofs",
+	)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	contents := make([]byte, size)
+	file := newGeneratedFile(
+		ctl, "bench", 3600.0,
+		func() ([]byte, error) { return contents, nil },
+	)
+	tree.lock.Lock()
+	tree.addNode(file)
+	tree.lock.Unlock()
+	return tree, ctl.GetNodeID(), file.GetNodeID()
+}
+
+func BenchmarkFUSEReadDir(b *testing.B) {
+	tree, dir, _ := newBenchTree(b, 4096)
+	fs := &trelloFS{tree: tree}
+	dst := make([]byte, 4096)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		op := &fuseops.ReadDirOp{Inode: dir, Dst: dst}
+		if err := fs.ReadDir(context.Background(), op); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFUSEReadFile(b *testing.B) {
+	tree, _, file := newBenchTree(b, 4096)
+	fs := &trelloFS{tree: tree}
+	dst := make([]byte, 4096)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		op := &fuseops.ReadFileOp{
+			Inode:     file,
+			Dst:       dst,
+			OpContext: fuseops.OpContext{Pid: 1},
+		}
+		if err := fs.ReadFile(context.Background(), op); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Serve 9P over a pipe, with fid 1 open on 'node', and obtain the client's
+// end.
+func open9P(b *testing.B, tree *Tree, node fuseops.InodeID) net.Conn {
+	client, server := net.Pipe()
+	c := &p9Conn{
+		tree:  tree,
+		conn:  server,
+		msize: p9MaxMsize,
+		fids: map[uint32]*p9Fid{
+			1: {path: []FSNode{tree.GetNode(node)}, open: true},
+		},
+	}
+	go c.serve()
+	b.Cleanup(func() { client.Close() })
+	return client
+}
+
+// Read through fid 1, from the start, as a Tread would.
+func read9P(b *testing.B, conn net.Conn, msg []byte, reply []byte) {
+	if _, err := conn.Write(msg); err != nil {
+		b.Fatal(err)
+	}
+	if _, err := io.ReadFull(conn, reply[:4]); err != nil {
+		b.Fatal(err)
+	}
+	size := binary.LittleEndian.Uint32(reply)
+	if _, err := io.ReadFull(conn, reply[4:size]); err != nil {
+		b.Fatal(err)
+	} else if reply[4] != p9Tread+1 {
+		b.Fatalf("unexpected reply type %d", reply[4])
+	}
+}
+
+func benchmark9PRead(b *testing.B, dir bool) {
+	tree, ctl, file := newBenchTree(b, 4096)
+	node := file
+	if dir {
+		node = ctl
+	}
+	conn := open9P(b, tree, node)
+
+	e := &p9Encoder{}
+	e.u32(0)
+	e.u8(p9Tread)
+	e.u16(1) // tag
+	e.u32(1) // fid
+	e.u64(0) // offset
+	e.u32(8192)
+	binary.LittleEndian.PutUint32(e.buf, uint32(len(e.buf)))
+	reply := make([]byte, p9MaxMsize)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		read9P(b, conn, e.buf, reply)
+	}
+}
+
+func Benchmark9PReadDir(b *testing.B) {
+	benchmark9PRead(b, true)
+}
+
+func Benchmark9PReadFile(b *testing.B) {
+	benchmark9PRead(b, false)
+}
@@ -0,0 +1,182 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// A bulk command, one per line written to '.trellofs/bulk'. Commands apply
+// to 'card', to each of 'cards', and to each card on 'board' whose name
+// matches the 'match' regular expression.
+//
+//	{"op": "move", "card": "<id>", "list": "<list id>"}
+//	{"op": "addLabel", "board": "<id>", "match": "^bug", "label": "<id>"}
+//	{"op": "removeLabel", "cards": ["<id>", ...], "label": "<id>"}
+//	{"op": "archive", "card": "<id>"}
+//
+// Requests are made with the given workspace's credentials, if any.
+type bulkCommand struct {
+	Op        string   `json:"op"`
+	Card      string   `json:"card"`
+	Cards     []string `json:"cards"`
+	Board     string   `json:"board"`
+	Match     string   `json:"match"`
+	List      string   `json:"list"`
+	Label     string   `json:"label"`
+	Workspace string   `json:"workspace"`
+}
+
+type bulkRunner struct {
+	job
+
+	tree *Tree
+}
+
+// Start running the submitted commands in the background. Only one batch
+// may run at a time.
+func (runner *bulkRunner) submit(data []byte) error {
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := runner.start(); err != nil {
+		log.Printf("bulk > refusing batch, another one is still running\n")
+		return err
+	}
+	go runner.run(lines)
+	return nil
+}
+
+func (runner *bulkRunner) run(lines []string) {
+
+	failed := 0
+	for i, line := range lines {
+		var cmd bulkCommand
+		err := json.Unmarshal([]byte(line), &cmd)
+		if err == nil {
+			err = runner.exec(&cmd)
+		}
+		if err != nil {
+			failed++
+			runner.reportf("%d\terror\t%s\n", i+1, err)
+		} else {
+			runner.reportf("%d\tok\n", i+1)
+		}
+	}
+	runner.reportf("done\t%d ok\t%d failed\n", len(lines)-failed, failed)
+	runner.finish()
+}
+
+func (runner *bulkRunner) exec(cmd *bulkCommand) error {
+
+	ctx := runner.tree.ctx
+	if cmd.Workspace != "" {
+		wsCtx, exists := runner.tree.opts.WorkspaceCtx[cmd.Workspace]
+		if !exists {
+			return fmt.Errorf("no credentials for workspace %s", cmd.Workspace)
+		}
+		ctx = wsCtx
+	}
+
+	var op func(card *trello.Card) error
+	switch cmd.Op {
+	case "move":
+		if cmd.List == "" {
+			return errors.New("missing 'list'")
+		}
+		op = func(card *trello.Card) error {
+			return card.Update(ctx, url.Values{"idList": {cmd.List}})
+		}
+	case "addLabel":
+		if cmd.Label == "" {
+			return errors.New("missing 'label'")
+		}
+		op = func(card *trello.Card) error {
+			return card.AddLabel(ctx, cmd.Label)
+		}
+	case "removeLabel":
+		if cmd.Label == "" {
+			return errors.New("missing 'label'")
+		}
+		op = func(card *trello.Card) error {
+			return card.RemoveLabel(ctx, cmd.Label)
+		}
+	case "archive":
+		op = func(card *trello.Card) error {
+			return card.Update(ctx, url.Values{"closed": {"true"}})
+		}
+	default:
+		return fmt.Errorf("unknown op '%s'", cmd.Op)
+	}
+
+	cards, err := runner.targets(ctx, cmd)
+	if err != nil {
+		return err
+	} else if len(cards) == 0 {
+		return errors.New("no matching cards")
+	}
+	for i := range cards {
+		card := &cards[i]
+		err := paced(func() error { return op(card) })
+		if err != nil {
+			return fmt.Errorf("card %s: %s", card.ID, err)
+		}
+	}
+	return nil
+}
+
+// Obtain the cards a command applies to.
+func (runner *bulkRunner) targets(
+	ctx *trello.TrelloCtx,
+	cmd *bulkCommand,
+) ([]trello.Card, error) {
+
+	var cards []trello.Card
+	if cmd.Card != "" {
+		cards = append(cards, trello.Card{ID: cmd.Card})
+	}
+	for _, id := range cmd.Cards {
+		cards = append(cards, trello.Card{ID: id})
+	}
+	if cmd.Board == "" {
+		return cards, nil
+	}
+
+	match, err := regexp.Compile(cmd.Match)
+	if err != nil {
+		return nil, err
+	}
+	var boardCards []trello.Card
+	err = paced(func() error {
+		var err error
+		boardCards, err = (&trello.Board{ID: cmd.Board}).GetCards(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, card := range boardCards {
+		if match.MatchString(card.Name) {
+			cards = append(cards, card)
+		}
+	}
+	return cards, nil
+}
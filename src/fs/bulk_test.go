@@ -0,0 +1,71 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+// Bulk commands apply to the cards given, or matched on a board, reporting
+// how each went.
+func TestBulkCommands(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/boards/b1/cards": `[
+			{"id": "c2", "name": "bug: crash"},
+			{"id": "c3", "name": "feature: export"},
+			{"id": "c4", "name": "bug: typo"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	runner := &bulkRunner{tree: tree}
+
+	runner.run([]string{
+		`{"op": "move", "card": "c1", "list": "l2"}`,
+		`{"op": "addLabel", "board": "b1", "match": "^bug", "label": "lb"}`,
+		`{"op": "archive", "workspace": "eng", "card": "c1"}`,
+		`{"op": "explode", "card": "c1"}`,
+		`not json`,
+	})
+	report, _ := runner.result()
+	want := "1\tok\n" +
+		"2\tok\n" +
+		"3\terror\tno credentials for workspace eng\n" +
+		"4\terror\tunknown op 'explode'\n" +
+		"5\terror\tinvalid character 'o' in literal null " +
+		"(expecting 'u')\n" +
+		"done\t2 ok\t3 failed\n"
+	if string(report) != want {
+		t.Errorf("report:\n%s\nwant:\n%s", report, want)
+	}
+	requests := []string{
+		"PUT /cards/c1?idList=l2",
+		"POST /cards/c2/idLabels?value=lb",
+		"POST /cards/c4/idLabels?value=lb",
+	}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+}
+
+// A batch being run holds back the next one.
+func TestBulkBusy(t *testing.T) {
+	withFixtures(t, nil)
+	runner := &bulkRunner{tree: newTestTree(t, Options{})}
+	if err := runner.start(); err != nil {
+		t.Fatal(err)
+	}
+	err := runner.submit([]byte(`{"op": "archive", "card": "c1"}`))
+	if err != syscall.EBUSY {
+		t.Errorf("batch submitted while another runs: %v", err)
+	}
+	runner.finish()
+}
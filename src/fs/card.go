@@ -10,23 +10,47 @@
 package fs
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/jecluis/trellofs/src/trello"
 
 	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fuseops"
-	"github.com/jacobsa/fuse/fuseutil"
 )
 
 type FSCardMetaFile struct {
 	BaseFSNode
 
 	contents []byte
+	buffer   []byte
+	dirty    bool
 
-	Card *trello.Card
+	Card     *trello.Card
+	CardNode *FSCard
+}
+
+// Meta files that may be written to, and the card field they update.
+var writableCardMeta = map[string]string{
+	"Desc":  "desc",
+	"Due":   "due",
+	"Start": "start",
+}
+
+// Check a value written to a meta file is acceptable for its field. Dates
+// are RFC 3339 timestamps, or as rendered, or empty to clear them.
+func (opts *Options) checkCardMeta(name string, value []byte) error {
+	if cardDateMeta[name] {
+		_, err := opts.parseDate(string(value))
+		return err
+	}
+	return nil
 }
 
 func (node *FSCardMetaFile) ShouldUpdate() bool {
@@ -41,25 +65,32 @@ func (node *FSCardMetaFile) LookupChild(name string) (FSNode, error) {
 	return nil, fuse.ENOENT
 }
 
-func (node *FSCardMetaFile) ReadDir(dst []byte, offset int) int {
-	return 0
+func (node *FSCardMetaFile) GetEntries() []FSNode {
+	return nil
 }
 
 func (node *FSCardMetaFile) ReadAt(dst []byte, offset int64) (int, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	contents := node.contents
+	if node.dirty {
+		contents = node.buffer
+	}
 
 	log.Printf(
 		"read file %s/%s meta %s, offset %d, len %d\n",
 		node.Card.Board.Name,
 		node.Card.Name,
 		node.GetName(),
-		offset, len(node.contents),
+		offset, len(contents),
 	)
 
-	if offset > int64(len(node.contents)) {
+	if offset > int64(len(contents)) {
 		return 0, io.EOF
 	}
 
-	n := copy(dst, node.contents[offset:])
+	n := copy(dst, contents[offset:])
 	if n < len(dst) {
 		return n, io.EOF
 	}
@@ -67,17 +98,293 @@ func (node *FSCardMetaFile) ReadAt(dst []byte, offset int64) (int, error) {
 	return n, nil
 }
 
+// Replace the file's contents, unless there are pending writes.
+func (node *FSCardMetaFile) setContents(contents []byte) {
+	node.Lock()
+	defer node.Unlock()
+
+	node.contents = contents
+	if !node.dirty {
+		node.setSize(uint64(len(contents)))
+	}
+}
+
+func (node *FSCardMetaFile) WriteAt(data []byte, offset int64) (int, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if _, writable := writableCardMeta[node.GetName()]; !writable {
+		return 0, syscall.EPERM
+	}
+	if !node.dirty {
+		node.buffer = append([]byte{}, node.contents...)
+		node.dirty = true
+	}
+	end := int(offset) + len(data)
+	if end > len(node.buffer) {
+		buffer := make([]byte, end)
+		copy(buffer, node.buffer)
+		node.buffer = buffer
+	}
+	copy(node.buffer[offset:], data)
+	node.setSize(uint64(len(node.buffer)))
+	return len(data), nil
+}
+
+func (node *FSCardMetaFile) Truncate(size uint64) error {
+	node.Lock()
+	defer node.Unlock()
+
+	if _, writable := writableCardMeta[node.GetName()]; !writable {
+		return syscall.EPERM
+	}
+	if !node.dirty {
+		node.buffer = append([]byte{}, node.contents...)
+		node.dirty = true
+	}
+	if size > uint64(len(node.buffer)) {
+		buffer := make([]byte, size)
+		copy(buffer, node.buffer)
+		node.buffer = buffer
+	} else {
+		node.buffer = node.buffer[:size]
+	}
+	node.setSize(size)
+	return nil
+}
+
+// Commit pending writes to the card. Should the field have changed remotely
+// since it was last read, the write is set aside as a conflict instead, and
+// fails with EBUSY.
+// Writes that couldn't be committed remain pending, so they're not lost and
+// may be flushed again.
+func (node *FSCardMetaFile) Flush() error {
+	node.Lock()
+	if !node.dirty {
+		node.Unlock()
+		return nil
+	}
+	base := node.contents
+	buffer := append([]byte{}, node.buffer...)
+	node.Unlock()
+
+	// meta files hold no trailing newline, but 'echo' adds one.
+	local := bytes.TrimSuffix(buffer, []byte("\n"))
+	// unless nothing changed, e.g. the file was opened for writing and
+	// closed.
+	if !bytes.Equal(local, base) {
+		err := node.tree.opts.checkCardMeta(node.GetName(), local)
+		if err != nil {
+			return err
+		}
+		err = node.CardNode.commit(node, base, local)
+		if err == errSetAside {
+			// it's in '.conflict' now, rather than pending.
+			node.Lock()
+			node.discard(buffer)
+			node.Unlock()
+			return syscall.EBUSY
+		} else if err != nil {
+			return err
+		}
+	}
+
+	node.Lock()
+	defer node.Unlock()
+	node.discard(buffer)
+	return nil
+}
+
+// Drop the pending write 'buffer' held, unless written to since. Must be
+// called with the node's lock held.
+func (node *FSCardMetaFile) discard(buffer []byte) {
+	if bytes.Equal(node.buffer, buffer) {
+		node.buffer = nil
+		node.dirty = false
+		node.setSize(uint64(len(node.contents)))
+	}
+}
+
 type FSCard struct {
 	BaseFSNode
 
-	MetaFiles []*FSCardMetaFile
-	ByName    map[string]*FSCardMetaFile
-	ByID      map[string]*FSCardMetaFile
-	Card      *trello.Card
+	MetaFiles    []*FSCardMetaFile
+	MetaDaysIdle *FSGeneratedFile
+	MetaInList   *FSGeneratedFile
+	Checklists   *FSCardChecklistsDir
+	MetaComments *FSControlFile
+	MetaLabels   *FSControlFile
+	MetaMembers  *FSControlFile
+	MetaMail     *FSGeneratedFile
+	MetaDocument *FSControlFile
+	MetaRaw      *FSGeneratedFile
+	MetaReminder *FSGeneratedFile
+	MetaWords    *FSGeneratedFile
+	MetaLinks    *FSGeneratedFile
+	Links        *FSCardLinksDir
+	Attachments  *FSCardAttachmentsDir
+	Conflict     *FSCardConflictDir
+	ByName       map[string]*FSCardMetaFile
+	ByID         map[string]*FSCardMetaFile
+	Card         *trello.Card
+
+	// the last activity date the meta files were generated for; cleared
+	// whenever the card is changed locally, as its last activity date
+	// doesn't change along until it's obtained from Trello again.
+	metaKey string
+}
+
+const xattrDaysIdle = "user.trellofs.daysIdle"
+
+// The card's checklist progress, as '<complete>/<items>'.
+const xattrProgress = "user.trello.progress"
+
+// Create the node for a card. Cards canonically live in their board's
+// cards dir, regardless of whichever directory they are found through, and
+// so are named uniquely across the board.
+func newCardNode(boardNode *FSBoard, card *trello.Card) *FSCard {
+	name := uniqueName(
+		boardNode.tree.opts.cardName(card, boardNode.idNames),
+		card.ShortLink, card.ID,
+		func(name string) bool { return boardNode.ByCardName[name] != nil },
+	)
+	// presented on its list as well, as if hard linked there.
+	var nlink uint32 = 0
+	if boardNode.tree.opts.HardlinkCards {
+		nlink = 2
+	}
+	return &FSCard{
+		BaseFSNode: BaseFSNode{
+			name: name,
+			uid:  boardNode.uid,
+			gid:  boardNode.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode:  0700 | os.ModeDir,
+				Nlink: nlink,
+				Uid:   boardNode.uid,
+				Gid:   boardNode.gid,
+				Size:  cardSize(card),
+				Mtime: cardMtime(card),
+			},
+			isDir:    true,
+			TrelloID: card.ID,
+			Ctx:      boardNode.Ctx,
+			parent:   boardNode.MetaCardsDir,
+		},
+		Card:   card,
+		ByName: make(map[string]*FSCardMetaFile),
+		ByID:   make(map[string]*FSCardMetaFile),
+	}
+}
+
+// Replace the card's data with a more recent copy.
+func (node *FSCard) setCard(card trello.Card) {
+	node.Lock()
+	defer node.Unlock()
+	*node.Card = card
+	node.setSize(cardSize(&card))
+	node.setModTime(cardMtime(&card))
+	node.setReminderMtime(cardMtime(&card))
+}
+
+// Replace the card's data with a copy changed locally, e.g. once written
+// to, or queued to be, having its meta files regenerated when next updated.
+func (node *FSCard) setChangedCard(card trello.Card) {
+	node.setCard(card)
+	node.Lock()
+	node.metaKey = ""
+	node.Unlock()
+}
+
+// Cards' directories are modified at their due date, if any.
+func cardMtime(card *trello.Card) time.Time {
+	due, _ := time.Parse(time.RFC3339, card.Due)
+	return due
+}
+
+// Cards' directories are sized after how complete their checklists are, as a
+// percentage, so progress shows in 'ls -l'. Cards without checklist items
+// are sized 0.
+func cardSize(card *trello.Card) uint64 {
+	if card.Badges.CheckItems == 0 {
+		return 0
+	}
+	return uint64(
+		card.Badges.CheckItemsChecked * 100 / card.Badges.CheckItems,
+	)
+}
+
+// Account for one of the card's check items having been ticked, or
+// unticked, until the card is next obtained from Trello.
+func (node *FSCard) tickCheckItem(complete bool) {
+	node.Lock()
+	defer node.Unlock()
+
+	if complete {
+		node.Card.Badges.CheckItemsChecked++
+	} else if node.Card.Badges.CheckItemsChecked > 0 {
+		node.Card.Badges.CheckItemsChecked--
+	}
+	node.setSize(cardSize(node.Card))
+	node.metaKey = ""
+}
+
+// Take on due dates obtained other than along with the card, e.g. from the
+// user's agenda, regenerating the meta files showing them. Returns whether
+// they changed.
+func (node *FSCard) setDue(due string, complete bool) bool {
+	node.Lock()
+	if node.Card.Due == due && node.Card.DueComplete == complete {
+		node.Unlock()
+		return false
+	}
+	node.Card.Due = due
+	node.Card.DueComplete = complete
+	node.setModTime(cardMtime(node.Card))
+	node.setReminderMtime(cardMtime(node.Card))
+	node.metaKey = ""
+	card := *node.Card
+	metas := map[string]*FSCardMetaFile{
+		"Due":         node.ByName["Due"],
+		"DueComplete": node.ByName["DueComplete"],
+	}
+	node.Unlock()
+
+	for field, meta := range metas {
+		if meta != nil {
+			meta.setContents(node.tree.opts.cardMetaValue(&card, field))
+		}
+	}
+	return true
+}
+
+// Set the card's due date. Called with the updates lock held.
+func (node *FSCard) SetMtime(mtime time.Time) error {
+	due := []byte(mtime.UTC().Format(time.RFC3339))
+	node.tree.refresh(node)
+	node.Lock()
+	meta := node.ByName["Due"]
+	node.Unlock()
+	if meta == nil {
+		return syscall.EPERM
+	}
+	return node.write(meta, due)
+}
+
+// Days since the card's last activity, or -1 if unknown.
+func (node *FSCard) daysIdle() int {
+	node.Lock()
+	defer node.Unlock()
+
+	lastActive, err := time.Parse(time.RFC3339, node.Card.LastActive)
+	if err != nil {
+		return -1
+	}
+	return int(time.Since(lastActive).Hours() / 24)
 }
 
 func (node *FSCard) ShouldUpdate() bool {
-	return node.shouldUpdate(30.0)
+	return node.shouldRefresh("cards", 30.0)
 }
 
 func (node *FSCard) Update() ([]FSNode, []FSNode, error) {
@@ -91,16 +398,103 @@ func (node *FSCard) Update() ([]FSNode, []FSNode, error) {
 		board.Name, board.ID,
 	)
 
+	newNodes := node.updateMeta()
+	if node.MetaDaysIdle == nil {
+		node.MetaDaysIdle = newGeneratedFile(
+			node, "DaysIdle", 60.0, func() ([]byte, error) {
+				return []byte(fmt.Sprintf("%d\n", node.daysIdle())), nil
+			},
+		)
+		newNodes = append(newNodes, node.MetaDaysIdle)
+	}
+	if node.MetaInList == nil {
+		node.MetaInList = newGeneratedFile(
+			node, "DaysInList", 60.0, func() ([]byte, error) {
+				return []byte(fmt.Sprintf("%d\n", node.daysInList())), nil
+			},
+		)
+		newNodes = append(newNodes, node.MetaInList)
+	}
+	if node.Checklists == nil {
+		node.Checklists = newCardChecklistsDir(node)
+		newNodes = append(newNodes, node.Checklists)
+	}
+	if node.MetaComments == nil {
+		node.MetaComments = newCommentsFile(node)
+		newNodes = append(newNodes, node.MetaComments)
+	}
+	if node.MetaLabels == nil {
+		node.MetaLabels = newCardLabelsFile(node)
+		newNodes = append(newNodes, node.MetaLabels)
+	}
+	if node.MetaMembers == nil {
+		node.MetaMembers = newCardMembersFile(node)
+		newNodes = append(newNodes, node.MetaMembers)
+	}
+	if node.MetaMail == nil {
+		node.MetaMail = newCardMailFile(node)
+		newNodes = append(newNodes, node.MetaMail)
+	}
+	if node.MetaDocument == nil {
+		node.MetaDocument = newCardDocumentFile(node)
+		newNodes = append(newNodes, node.MetaDocument)
+	}
+	if node.MetaRaw == nil {
+		node.MetaRaw = newRawFile(node, node.Ctx, "cards")
+		newNodes = append(newNodes, node.MetaRaw)
+	}
+	if node.MetaWords == nil && node.tree.opts.DocMeta {
+		node.MetaWords = newGeneratedFile(
+			node, "WordCount", 30.0, node.wordCount,
+		)
+		node.MetaLinks = newGeneratedFile(
+			node, "BrokenLinks", 30.0, node.brokenLinks,
+		)
+		newNodes = append(newNodes, node.MetaWords, node.MetaLinks)
+	}
+	if node.Links == nil {
+		node.Links = newCardLinksDir(node)
+		newNodes = append(newNodes, node.Links)
+	}
+	if node.Attachments == nil {
+		node.Attachments = newCardAttachmentsDir(node)
+		newNodes = append(newNodes, node.Attachments)
+	}
+
+	var rmNodes []FSNode = make([]FSNode, 0)
+	added, removed := node.updateReminder()
+	if added != nil {
+		newNodes = append(newNodes, added)
+	} else if removed != nil {
+		rmNodes = append(rmNodes, removed)
+	}
+	return newNodes, rmNodes, nil
+}
+
+// Update the card's meta files, a file per field. Fields only change along
+// with the card's last activity date, so, as long as that's known and the
+// same, the card isn't looked at again. Called with the card's lock held.
+func (node *FSCard) updateMeta() []FSNode {
 	var newNodes []FSNode = make([]FSNode, 0)
-	meta := getMeta(*node.Card)
+	if node.metaKey != "" && node.metaKey == node.Card.LastActive {
+		return newNodes
+	}
+	node.metaKey = node.Card.LastActive
+
+	meta := node.tree.opts.cardMeta(node.Card)
 	for _, entry := range meta {
 		log.Printf(
 			"card meta name: %s, value: %s\n",
 			entry.Name, string(entry.Contents),
 		)
-		if _, exists := node.ByName[entry.Name]; exists {
+		if existing, exists := node.ByName[entry.Name]; exists {
+			existing.setContents(entry.Contents)
 			continue
 		}
+		mode := os.FileMode(0400)
+		if _, writable := writableCardMeta[entry.Name]; writable {
+			mode = 0600
+		}
 		trelloID := fmt.Sprintf("%s/_meta/%s", node.GetTrelloID(), entry.Name)
 		metaFile := &FSCardMetaFile{
 			BaseFSNode: BaseFSNode{
@@ -108,7 +502,7 @@ func (node *FSCard) Update() ([]FSNode, []FSNode, error) {
 				uid:  node.uid,
 				gid:  node.gid,
 				NodeAttrs: fuseops.InodeAttributes{
-					Mode:  0600,
+					Mode:  mode,
 					Nlink: 1,
 					Uid:   node.uid,
 					Gid:   node.gid,
@@ -116,17 +510,18 @@ func (node *FSCard) Update() ([]FSNode, []FSNode, error) {
 				},
 				isDir:    false,
 				TrelloID: trelloID,
+				parent:   node,
 			},
 			contents: entry.Contents,
 			Card:     node.Card,
+			CardNode: node,
 		}
 		newNodes = append(newNodes, metaFile)
 		node.MetaFiles = append(node.MetaFiles, metaFile)
 		node.ByName[entry.Name] = metaFile
 		node.ByID[trelloID] = metaFile
 	}
-
-	return newNodes, nil, nil
+	return newNodes
 }
 
 func (node *FSCard) LookupChild(name string) (FSNode, error) {
@@ -138,41 +533,88 @@ func (node *FSCard) LookupChild(name string) (FSNode, error) {
 			return entry, nil
 		}
 	}
+	for _, entry := range node.metaEntries() {
+		if entry.GetName() == name {
+			return entry, nil
+		}
+	}
 	return nil, fuse.ENOENT
 }
 
-func (node *FSCard) ReadDir(dst []byte, offset int) int {
+func (node *FSCard) GetEntries() []FSNode {
 	node.Lock()
 	defer node.Unlock()
 
-	log.Printf(
-		"read dir %s/%s (%s), offset %d\n",
-		node.Card.Board.Name,
-		node.GetName(), node.GetTrelloID(),
-		offset,
-	)
-	var size int
-	for i := offset; i < len(node.MetaFiles); i++ {
-		entry := node.MetaFiles[i]
-		tmp := fuseutil.WriteDirent(dst[size:], fuseutil.Dirent{
-			Name:   entry.GetName(),
-			Inode:  entry.GetNodeID(),
-			Type:   fuseutil.DT_File,
-			Offset: fuseops.DirOffset(i + 1),
-		})
-		if tmp == 0 {
-			log.Printf(
-				"read dir > no more space to write dirent for %s/%s (%s)\n",
-				node.Card.Board.Name, node.GetName(), node.GetTrelloID(),
-			)
-			break
-		}
-		log.Printf(
-			"read dir %s/%s id %d: wrote direntry for %s (%s) id %d\n",
-			node.Card.Board.Name, node.GetName(), node.GetNodeID(),
-			entry.GetName(), entry.GetTrelloID(), entry.GetNodeID(),
-		)
-		size += tmp
+	entries := make([]FSNode, 0, len(node.MetaFiles)+15)
+	for _, entry := range node.MetaFiles {
+		entries = append(entries, entry)
+	}
+	return append(entries, node.metaEntries()...)
+}
+
+// The card's entries other than its fields, as far as they exist.
+func (node *FSCard) metaEntries() []FSNode {
+	var entries []FSNode
+	if node.MetaDaysIdle != nil {
+		entries = append(entries, node.MetaDaysIdle)
+	}
+	if node.MetaInList != nil {
+		entries = append(entries, node.MetaInList)
+	}
+	if node.Checklists != nil {
+		entries = append(entries, node.Checklists)
+	}
+	if node.MetaComments != nil {
+		entries = append(entries, node.MetaComments)
+	}
+	if node.MetaLabels != nil {
+		entries = append(entries, node.MetaLabels)
+	}
+	if node.MetaMembers != nil {
+		entries = append(entries, node.MetaMembers)
+	}
+	if node.MetaMail != nil {
+		entries = append(entries, node.MetaMail)
+	}
+	if node.MetaDocument != nil {
+		entries = append(entries, node.MetaDocument)
+	}
+	if node.MetaRaw != nil {
+		entries = append(entries, node.MetaRaw)
+	}
+	if node.MetaReminder != nil {
+		entries = append(entries, node.MetaReminder)
+	}
+	if node.MetaWords != nil {
+		entries = append(entries, node.MetaWords, node.MetaLinks)
+	}
+	if node.Links != nil {
+		entries = append(entries, node.Links)
+	}
+	if node.Attachments != nil {
+		entries = append(entries, node.Attachments)
+	}
+	if node.Conflict != nil {
+		entries = append(entries, node.Conflict)
+	}
+	return entries
+}
+
+func (node *FSCard) ListXattrs() []string {
+	return []string{xattrDaysIdle, xattrProgress}
+}
+
+func (node *FSCard) GetXattr(name string) ([]byte, error) {
+	switch name {
+	case xattrDaysIdle:
+		return []byte(strconv.Itoa(node.daysIdle())), nil
+	case xattrProgress:
+		node.Lock()
+		defer node.Unlock()
+		badges := node.Card.Badges
+		return []byte(fmt.Sprintf(
+			"%d/%d", badges.CheckItemsChecked, badges.CheckItems,
+		)), nil
 	}
-	return size
+	return nil, fuse.ENOATTR
 }
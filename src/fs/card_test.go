@@ -0,0 +1,198 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Obtain a card's node, its meta files generated, without reaching Trello.
+func newMetaCard(tb testing.TB) *FSCard {
+	out := log.Writer()
+	log.SetOutput(ioutil.Discard)
+	tb.Cleanup(func() { log.SetOutput(out) })
+
+	tree, err := NewTree(0, 0, trello.Trello("me", "", ""), Options{})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	card := &trello.Card{
+		ID:         "c1",
+		Name:       "My card",
+		Desc:       "Some description",
+		Due:        "2022-03-01T12:00:00.000Z",
+		LastActive: "2022-02-01T12:00:00.000Z",
+		Labels: []trello.CardLabel{
+			{ID: "l1", Name: "bug", Color: "red"},
+		},
+		MemberIDs: []string{"m1", "m2"},
+		Board:     &trello.Board{ID: "b1", Name: "My board"},
+	}
+	node := &FSCard{
+		BaseFSNode: BaseFSNode{
+			name: card.Name, TrelloID: card.ID, tree: tree,
+		},
+		Card:   card,
+		ByName: make(map[string]*FSCardMetaFile),
+		ByID:   make(map[string]*FSCardMetaFile),
+	}
+	node.updateMeta()
+	return node
+}
+
+// Cards changed locally have their meta files regenerated, even though their
+// last activity date is as it was.
+func TestUpdateMetaChangedLocally(t *testing.T) {
+	node := newMetaCard(t)
+	for _, change := range []struct {
+		name   string
+		field  string
+		change func()
+	}{
+		{"due", "Due", func() {
+			node.setDue("2022-04-01T12:00:00.000Z", false)
+		}},
+		{"due complete", "DueComplete", func() {
+			node.setDue("2022-04-01T12:00:00.000Z", true)
+		}},
+		{"check item", "Badges", func() { node.tickCheckItem(true) }},
+	} {
+		before := string(node.ByName[change.field].contents)
+		change.change()
+		node.updateMeta()
+		got := string(node.ByName[change.field].contents)
+		want := node.tree.opts.cardMetaValue(node.Card, change.field)
+		if got == before || got != string(want) {
+			t.Errorf(
+				"%s: %s = %q, was %q, want %q",
+				change.name, change.field, got, before, want,
+			)
+		}
+	}
+}
+
+// Cards refreshed without having changed keep their meta files.
+func BenchmarkUpdateMeta(b *testing.B) {
+	node := newMetaCard(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		node.updateMeta()
+	}
+}
+
+// Cards changed, remotely or locally, have their meta files regenerated.
+func BenchmarkUpdateMetaChanged(b *testing.B) {
+	node := newMetaCard(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		node.metaKey = ""
+		node.updateMeta()
+	}
+}
+
+// Descriptions written to a card's Desc are updated in Trello once the file
+// is closed, unless left as they were.
+func TestWriteDesc(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/cards/c1": `{"id": "c1", "name": "My card",
+			"desc": "Some description"}`,
+	})
+	node := newMetaCard(t)
+	node.Ctx = node.tree.ctx
+	tree := node.tree
+	desc := node.ByName["Desc"]
+	addNodes(tree, node, desc)
+
+	if err := writeFile(t, tree, desc, "Some description\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := requestsMade(t, dir); got != nil {
+		t.Errorf("unchanged description updated: %q", got)
+	}
+	if err := writeFile(t, tree, desc, "New text\n"); err != nil {
+		t.Fatal(err)
+	}
+	requests := []string{"PUT /cards/c1?desc=New+text"}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+	if got := readFile(t, tree, desc); got != "New text" {
+		t.Errorf("Desc = %q once written, want %q", got, "New text")
+	}
+}
+
+// Due dates are set by writing RFC 3339 timestamps to a card's Due, or by
+// changing its directory's modification time, as 'touch -d' would, which
+// follows the card's due date.
+func TestWriteDue(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/cards/c1": `{"id": "c1", "name": "My card",
+			"due": "2022-03-01T12:00:00.000Z"}`,
+	})
+	// as Trello answers with the card updated.
+	answer := func(due time.Time) {
+		err := ioutil.WriteFile(
+			filepath.Join(dir, "PUT-cards-c1.json"), []byte(fmt.Sprintf(
+				`{"id": "c1", "name": "My card", "due": %q}`,
+				due.Format(time.RFC3339),
+			)), 0600,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	node := newMetaCard(t)
+	node.Ctx = node.tree.ctx
+	tree := node.tree
+	due := node.ByName["Due"]
+	addNodes(tree, node, due)
+
+	if err := writeFile(t, tree, due, "next week\n"); err != syscall.EINVAL {
+		t.Errorf("invalid due date written: %v", err)
+	}
+	written := time.Date(2022, 4, 1, 9, 0, 0, 0, time.UTC)
+	answer(written)
+	if err := writeFile(t, tree, due, "2022-04-01T09:00:00Z\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := node.GetNodeAttrs().Mtime; !got.Equal(written) {
+		t.Errorf("card modified at %s, want %s", got, written)
+	}
+	touched := time.Date(2022, 5, 1, 18, 0, 0, 0, time.UTC)
+	answer(touched)
+	err := tree.SetMtime(context.Background(), node.GetNodeID(), touched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := node.GetNodeAttrs().Mtime; !got.Equal(touched) {
+		t.Errorf("card modified at %s once touched, want %s", got, touched)
+	}
+	requests := []string{
+		"PUT /cards/c1?due=2022-04-01T09%3A00%3A00Z",
+		"PUT /cards/c1?due=2022-05-01T18%3A00%3A00Z",
+	}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// A card's labels, one per line, as '<name>\t<color>'; labels without a name
+// are named after their color. Writing a set of labels, one per line, adds
+// and removes labels so the card carries exactly those. Only the name on
+// each line is looked at, and it may also be the label's ID.
+func newCardLabelsFile(card *FSCard) *FSControlFile {
+	var file *FSControlFile
+	file = newControlFile(
+		card, "labels", 30.0,
+		card.labels,
+		func(contents []byte) error {
+			if err := card.setLabels(contents); err != nil {
+				return err
+			}
+			file.markStale()
+			return nil
+		},
+	)
+	return file
+}
+
+func (node *FSCard) labels() ([]byte, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	var buf bytes.Buffer
+	for _, label := range node.Card.Labels {
+		name := label.Name
+		if name == "" {
+			name = label.Color
+		}
+		fmt.Fprintf(&buf, "%s\t%s\n", name, label.Color)
+	}
+	return buf.Bytes(), nil
+}
+
+func (node *FSCard) setLabels(contents []byte) error {
+	node.Lock()
+	card := *node.Card
+	node.Unlock()
+
+	available, err := card.Board.GetLabels(node.api())
+	if err != nil {
+		return err
+	}
+	findLabel := func(name string) *trello.Label {
+		for i := range available {
+			label := &available[i]
+			if label.ID == name || label.Name == name ||
+				(label.Name == "" && label.Color == name) {
+				return label
+			}
+		}
+		return nil
+	}
+
+	var wanted []trello.CardLabel
+	isWanted := make(map[string]bool)
+	for _, line := range strings.Split(string(contents), "\n") {
+		name := strings.TrimSpace(strings.SplitN(line, "\t", 2)[0])
+		if name == "" {
+			continue
+		}
+		label := findLabel(name)
+		if label == nil {
+			return fmt.Errorf("unknown label '%s'", name)
+		}
+		if isWanted[label.ID] {
+			continue
+		}
+		isWanted[label.ID] = true
+		wanted = append(wanted, trello.CardLabel{
+			ID: label.ID, Name: label.Name, Color: label.Color,
+		})
+	}
+
+	current := make(map[string]bool)
+	for _, label := range card.Labels {
+		current[label.ID] = true
+		if isWanted[label.ID] {
+			continue
+		}
+		if err := card.RemoveLabel(node.api(), label.ID); err != nil {
+			return err
+		}
+	}
+	for _, label := range wanted {
+		if current[label.ID] {
+			continue
+		}
+		if err := card.AddLabel(node.api(), label.ID); err != nil {
+			return err
+		}
+	}
+
+	node.Lock()
+	node.Card.Labels = wanted
+	node.metaKey = ""
+	node.Unlock()
+	return nil
+}
@@ -0,0 +1,184 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// Separates what may be edited in 'card.md' from what's only there to be
+// read. Being an HTML comment, it's not rendered.
+const cardDocumentMarker = "<!-- trellofs: read-only below this line -->"
+
+// The card as a single Markdown document, so it may be read and edited as a
+// whole rather than through its many small files. Only the title and the
+// description may be edited; once the file is closed, whichever of the two
+// changed is written to the card.
+func newCardDocumentFile(card *FSCard) *FSControlFile {
+	var file *FSControlFile
+	file = newControlFile(
+		card, "card.md", 30.0,
+		card.document,
+		func(contents []byte) error {
+			if err := card.setDocument(contents); err != nil {
+				return err
+			}
+			file.markStale()
+			return nil
+		},
+	)
+	return file
+}
+
+// Generate the card's document, as
+//
+//	# <name>
+//
+//	<description>
+//
+//	<!-- trellofs: read-only below this line -->
+//
+//	## Checklists
+//
+//	### <checklist>
+//
+//	- [x] <complete item>
+//	- [ ] <incomplete item>
+//
+//	## Comments
+//
+//	### <username>, <date>
+//
+//	<text>
+//
+// with checklists and comments, oldest first, left out if there are none.
+func (node *FSCard) document() ([]byte, error) {
+	node.Lock()
+	card := *node.Card
+	node.Unlock()
+
+	checklists, err := node.tree.cardChecklists(node.api(), &card)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := node.tree.cardComments(node.api(), &card)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", card.Name)
+	if desc := strings.Trim(card.Desc, "\n"); desc != "" {
+		fmt.Fprintf(&buf, "%s\n\n", desc)
+	}
+	fmt.Fprintf(&buf, "%s\n", cardDocumentMarker)
+
+	if len(checklists) > 0 {
+		buf.WriteString("\n## Checklists\n")
+	}
+	for _, checklist := range checklists {
+		fmt.Fprintf(&buf, "\n### %s\n\n", checklist.Name)
+		for _, item := range checklist.CheckItems {
+			mark := " "
+			if item.State == "complete" {
+				mark = "x"
+			}
+			fmt.Fprintf(&buf, "- [%s] %s\n", mark, item.Name)
+		}
+	}
+
+	if len(comments) > 0 {
+		buf.WriteString("\n## Comments\n")
+	}
+	for i := len(comments) - 1; i >= 0; i-- {
+		comment := comments[i]
+		fmt.Fprintf(
+			&buf, "\n### %s, %s\n\n%s\n",
+			comment.MemberCreator.Username,
+			node.tree.opts.renderDate(comment.Date),
+			strings.Trim(comment.Data.Text, "\n"),
+		)
+	}
+	return buf.Bytes(), nil
+}
+
+// Obtain the name and description from the card's document: the name from
+// its first line, as a '# <name>' heading, the description from whatever
+// follows, up to the read-only marker if it's still there.
+func parseCardDocument(contents []byte) (string, string, error) {
+	lines := strings.Split(string(contents), "\n")
+	if !strings.HasPrefix(lines[0], "# ") {
+		return "", "", syscall.EINVAL
+	}
+	name := strings.TrimSpace(strings.TrimPrefix(lines[0], "# "))
+	if name == "" {
+		return "", "", syscall.EINVAL
+	}
+
+	var desc []string
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == cardDocumentMarker {
+			break
+		}
+		desc = append(desc, line)
+	}
+	return name, strings.Trim(strings.Join(desc, "\n"), "\n"), nil
+}
+
+// Write the name and description from the card's document, if changed.
+// Called with the updates lock held.
+func (node *FSCard) setDocument(contents []byte) error {
+	name, desc, err := parseCardDocument(contents)
+	if err != nil {
+		log.Printf(
+			"error parsing card.md for card %s (%s): not starting "+
+				"with a '# <name>' heading\n",
+			node.GetName(), node.GetTrelloID(),
+		)
+		return err
+	}
+
+	node.Lock()
+	card := *node.Card
+	node.Unlock()
+
+	values := url.Values{}
+	if name != card.Name {
+		values.Set("name", name)
+	}
+	if desc != strings.Trim(card.Desc, "\n") {
+		values.Set("desc", desc)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	if err := card.Update(node.api(), values); err != nil {
+		return err
+	}
+	node.setChangedCard(card)
+
+	for _, field := range []string{"Name", "Desc"} {
+		node.Lock()
+		meta := node.ByName[field]
+		node.Unlock()
+		if meta != nil {
+			meta.setContents(node.tree.opts.cardMetaValue(&card, field))
+		}
+	}
+	if cardsDir, ok := node.GetParent().(*FSBoardCardsDirMeta); ok {
+		cardsDir.BoardNode.renameCard(node, nil)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// 'card.md' renders the card as a Markdown document, and writing it back
+// renames the card and sets its description, leaving out what can't be
+// edited.
+func TestCardDocument(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Fix", "idList": "l1", "shortLink": "aa",
+				"desc": "It crashes\n"}
+		]`,
+		"/boards/b1/lists":     `[{"id": "l1", "name": "To do"}]`,
+		"/cards/c1/checklists": checklistsFixture,
+		"/cards/c1/actions": `[
+			{"date": "2022-03-02", "memberCreator": {"username": "bob"},
+				"data": {"text": "Done"}},
+			{"date": "2022-03-01", "memberCreator": {"username": "alice"},
+				"data": {"text": "On it"}}
+		]`,
+		"PUT /cards/c1": `{"id": "c1", "name": "Fix crash", "idList": "l1",
+			"shortLink": "aa", "desc": "It crashes\non start"}`,
+	})
+	tree := newTestTree(t, Options{})
+	doc := lookupPath(t, tree, "eng/Roadmap/cards/Fix/card.md")
+
+	contents := readFile(t, tree, doc)
+	head := "# Fix\n\nIt crashes\n\n" + cardDocumentMarker + "\n"
+	if !strings.HasPrefix(contents, head) {
+		t.Errorf("card.md:\n%s\nwant it starting with:\n%s", contents, head)
+	}
+	for _, want := range []string{
+		"\n## Checklists\n", "\n## Comments\n",
+		"\n### alice, 2022-03-01\n\nOn it\n\n### bob, 2022-03-02\n\nDone\n",
+	} {
+		if !strings.Contains(contents, want) {
+			t.Errorf("card.md:\n%s\nwant it with:\n%s", contents, want)
+		}
+	}
+
+	if err := writeFile(t, tree, doc, "Fix crash\n"); err != syscall.EINVAL {
+		t.Errorf("card.md written with no title: %v", err)
+	}
+	edited := strings.Replace(
+		contents, head,
+		"# Fix crash\n\nIt crashes\non start\n\n"+cardDocumentMarker+"\n", 1,
+	)
+	if err := writeFile(t, tree, doc, edited); err != nil {
+		t.Fatal(err)
+	}
+	requests := []string{
+		"PUT /cards/c1?desc=It+crashes%0Aon+start&name=Fix+crash",
+	}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+	desc := lookupPath(t, tree, "eng/Roadmap/cards/Fix crash/Desc")
+	if got := readFile(t, tree, desc); got != "It crashes\non start" {
+		t.Errorf("description %q once card.md written", got)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A card's members, one per line, as '<username>\t<full name>'. Writing a
+// set of usernames, one per line, assigns and unassigns members so the card
+// has exactly those; e.g., appending a username assigns that member. Only
+// the username on each line is looked at, and it may also be the member's
+// ID.
+func newCardMembersFile(card *FSCard) *FSControlFile {
+	var file *FSControlFile
+	file = newControlFile(
+		card, "members", 30.0,
+		card.members,
+		func(contents []byte) error {
+			if err := card.setMembers(contents); err != nil {
+				return err
+			}
+			file.markStale()
+			return nil
+		},
+	)
+	return file
+}
+
+func (node *FSCard) members() ([]byte, error) {
+	node.Lock()
+	card := *node.Card
+	node.Unlock()
+
+	boardMembers, err := card.Board.GetMembers(node.api())
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, id := range card.MemberIDs {
+		line := id + "\n"
+		for _, member := range boardMembers {
+			if member.ID == id {
+				line = fmt.Sprintf("%s\t%s\n", member.Username, member.FullName)
+				break
+			}
+		}
+		buf.WriteString(line)
+	}
+	return buf.Bytes(), nil
+}
+
+func (node *FSCard) setMembers(contents []byte) error {
+	node.Lock()
+	card := *node.Card
+	node.Unlock()
+
+	boardMembers, err := card.Board.GetMembers(node.api())
+	if err != nil {
+		return err
+	}
+	findMember := func(name string) string {
+		for _, member := range boardMembers {
+			if member.ID == name || member.Username == name {
+				return member.ID
+			}
+		}
+		return ""
+	}
+
+	var wanted []string
+	isWanted := make(map[string]bool)
+	for _, line := range strings.Split(string(contents), "\n") {
+		name := strings.TrimSpace(strings.SplitN(line, "\t", 2)[0])
+		if name == "" {
+			continue
+		}
+		id := findMember(strings.TrimPrefix(name, "@"))
+		if id == "" {
+			return fmt.Errorf("unknown member '%s'", name)
+		}
+		if isWanted[id] {
+			continue
+		}
+		isWanted[id] = true
+		wanted = append(wanted, id)
+	}
+
+	current := make(map[string]bool)
+	for _, id := range card.MemberIDs {
+		current[id] = true
+		if isWanted[id] {
+			continue
+		}
+		if err := card.RemoveMember(node.api(), id); err != nil {
+			return err
+		}
+	}
+	for _, id := range wanted {
+		if current[id] {
+			continue
+		}
+		if err := card.AddMember(node.api(), id); err != nil {
+			return err
+		}
+	}
+
+	node.Lock()
+	node.Card.MemberIDs = wanted
+	node.metaKey = ""
+	node.Unlock()
+	return nil
+}
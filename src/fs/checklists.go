@@ -0,0 +1,428 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A card's checklists, one directory per checklist. All checklists, and
+// their items, are obtained at once when this directory is updated.
+type FSCardChecklistsDir struct {
+	BaseFSNode
+
+	CardNode *FSCard
+
+	Checklists []*FSChecklist
+	ByName     map[string]*FSChecklist
+	byID       map[string]*FSChecklist
+}
+
+// A checklist, one file per check item. Kept up to date by its card's
+// checklists directory.
+type FSChecklist struct {
+	BaseFSNode
+
+	Checklist trello.Checklist
+
+	Items  []*FSCheckItem
+	ByName map[string]*FSCheckItem
+	byID   map[string]*FSCheckItem
+}
+
+// A check item, reading as its state followed by its name, e.g. "[x] milk".
+// Its state may be changed by writing "complete" or "incomplete" to it, or
+// by editing the box. Complete items are also executable, so 'chmod +x' and
+// 'chmod -x' work too.
+type FSCheckItem struct {
+	BaseFSNode
+
+	ChecklistNode *FSChecklist
+	Item          trello.CheckItem
+
+	contents []byte
+	buffer   []byte
+	dirty    bool
+}
+
+func newCardChecklistsDir(card *FSCard) *FSCardChecklistsDir {
+	return &FSCardChecklistsDir{
+		BaseFSNode: BaseFSNode{
+			name: "checklists",
+			uid:  card.uid,
+			gid:  card.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  card.uid,
+				Gid:  card.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/checklists", card.GetTrelloID()),
+			Ctx:      card.Ctx,
+			parent:   card,
+		},
+		CardNode: card,
+		ByName:   make(map[string]*FSChecklist),
+		byID:     make(map[string]*FSChecklist),
+	}
+}
+
+func (node *FSCardChecklistsDir) ShouldUpdate() bool {
+	return node.shouldUpdate(30.0)
+}
+
+func (node *FSCardChecklistsDir) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	checklists, err := node.tree.cardChecklists(node.api(), node.CardNode.Card)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+
+	seen := make(map[string]bool)
+	for _, checklist := range checklists {
+		seen[checklist.ID] = true
+
+		existing, exists := node.byID[checklist.ID]
+		if !exists {
+			name := escapeName(checklist.Name)
+			if _, exists := node.ByName[name]; exists {
+				name = fmt.Sprintf("%s (%s)", name, checklist.ID)
+			}
+			existing = &FSChecklist{
+				BaseFSNode: BaseFSNode{
+					name: name,
+					uid:  node.uid,
+					gid:  node.gid,
+					NodeAttrs: fuseops.InodeAttributes{
+						Mode: 0700 | os.ModeDir,
+						Uid:  node.uid,
+						Gid:  node.gid,
+					},
+					isDir:    true,
+					TrelloID: checklist.ID,
+					Ctx:      node.Ctx,
+					parent:   node,
+				},
+				ByName: make(map[string]*FSCheckItem),
+				byID:   make(map[string]*FSCheckItem),
+			}
+			node.Checklists = append(node.Checklists, existing)
+			node.ByName[name] = existing
+			node.byID[checklist.ID] = existing
+			newNodes = append(newNodes, existing)
+		} else if name, renamed := renamedName(
+			existing.GetName(), escapeName(checklist.Name), "", checklist.ID,
+			func(name string) bool {
+				other := node.ByName[name]
+				return other != nil && other != existing
+			},
+		); renamed {
+			delete(node.ByName, existing.GetName())
+			existing.setName(name)
+			node.ByName[name] = existing
+		}
+		added, removed := existing.setChecklist(checklist)
+		newNodes = append(newNodes, added...)
+		rmNodes = append(rmNodes, removed...)
+	}
+
+	var remaining []*FSChecklist
+	for _, checklist := range node.Checklists {
+		if seen[checklist.GetTrelloID()] {
+			remaining = append(remaining, checklist)
+			continue
+		}
+		delete(node.ByName, checklist.GetName())
+		delete(node.byID, checklist.GetTrelloID())
+		rmNodes = append(rmNodes, checklist)
+	}
+	node.Checklists = remaining
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSCardChecklistsDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if checklist, exists := node.ByName[name]; exists {
+		return checklist, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSCardChecklistsDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Checklists))
+	for i, checklist := range node.Checklists {
+		entries[i] = checklist
+	}
+	return entries
+}
+
+// Replace the checklist's data with a more recent copy, returning its new
+// and removed items.
+func (node *FSChecklist) setChecklist(
+	checklist trello.Checklist,
+) ([]FSNode, []FSNode) {
+	node.Lock()
+	defer node.Unlock()
+
+	node.Checklist = checklist
+
+	var newNodes []FSNode
+	var rmNodes []FSNode
+
+	seen := make(map[string]bool)
+	for _, item := range checklist.CheckItems {
+		seen[item.ID] = true
+
+		if existing, exists := node.byID[item.ID]; exists {
+			existing.setItem(item)
+			name, renamed := renamedName(
+				existing.GetName(), escapeName(item.Name), "", item.ID,
+				func(name string) bool {
+					other := node.ByName[name]
+					return other != nil && other != existing
+				},
+			)
+			if renamed {
+				delete(node.ByName, existing.GetName())
+				existing.setName(name)
+				node.ByName[name] = existing
+			}
+			continue
+		}
+		name := escapeName(item.Name)
+		if _, exists := node.ByName[name]; exists {
+			name = fmt.Sprintf("%s (%s)", name, item.ID)
+		}
+		file := &FSCheckItem{
+			BaseFSNode: BaseFSNode{
+				name: name,
+				uid:  node.uid,
+				gid:  node.gid,
+				NodeAttrs: fuseops.InodeAttributes{
+					Nlink: 1,
+					Uid:   node.uid,
+					Gid:   node.gid,
+				},
+				isDir:    false,
+				TrelloID: item.ID,
+				Ctx:      node.Ctx,
+				parent:   node,
+			},
+			ChecklistNode: node,
+		}
+		file.setItem(item)
+		node.Items = append(node.Items, file)
+		node.ByName[name] = file
+		node.byID[item.ID] = file
+		newNodes = append(newNodes, file)
+	}
+
+	var remaining []*FSCheckItem
+	for _, file := range node.Items {
+		if seen[file.GetTrelloID()] {
+			remaining = append(remaining, file)
+			continue
+		}
+		delete(node.ByName, file.GetName())
+		delete(node.byID, file.GetTrelloID())
+		rmNodes = append(rmNodes, file)
+	}
+	node.Items = remaining
+
+	return newNodes, rmNodes
+}
+
+func (node *FSChecklist) ShouldUpdate() bool {
+	return false
+}
+
+func (node *FSChecklist) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, fuse.EINVAL
+}
+
+func (node *FSChecklist) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if item, exists := node.ByName[name]; exists {
+		return item, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSChecklist) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Items))
+	for i, item := range node.Items {
+		entries[i] = item
+	}
+	return entries
+}
+
+// Replace the item's data with a more recent copy.
+func (node *FSCheckItem) setItem(item trello.CheckItem) {
+	node.Lock()
+	defer node.Unlock()
+
+	box, mode := " ", os.FileMode(0600)
+	if item.State == "complete" {
+		box, mode = "x", 0700
+	}
+	node.setMode(mode)
+	node.Item = item
+	node.contents = []byte(fmt.Sprintf("[%s] %s\n", box, item.Name))
+	if !node.dirty {
+		node.setSize(uint64(len(node.contents)))
+	}
+}
+
+// Set the item's state on Trello, either "complete" or "incomplete".
+func (node *FSCheckItem) setState(state string) error {
+	node.Lock()
+	item := node.Item
+	node.Unlock()
+
+	if item.State == state {
+		return nil
+	}
+	cardID := node.ChecklistNode.Checklist.CardID
+	if err := item.SetState(node.api(), cardID, state); err != nil {
+		return apiErrno(err)
+	}
+	node.setItem(item)
+	dir := node.ChecklistNode.GetParent().(*FSCardChecklistsDir)
+	dir.CardNode.tickCheckItem(state == "complete")
+	return nil
+}
+
+func (node *FSCheckItem) ShouldUpdate() bool {
+	return false
+}
+
+func (node *FSCheckItem) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, fuse.EINVAL
+}
+
+func (node *FSCheckItem) LookupChild(name string) (FSNode, error) {
+	return nil, fuse.ENOENT
+}
+
+func (node *FSCheckItem) GetEntries() []FSNode {
+	return nil
+}
+
+func (node *FSCheckItem) ReadAt(dst []byte, offset int64) (int, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	contents := node.contents
+	if node.dirty {
+		contents = node.buffer
+	}
+	if offset > int64(len(contents)) {
+		return 0, io.EOF
+	}
+
+	n := copy(dst, contents[offset:])
+	if n < len(dst) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (node *FSCheckItem) WriteAt(data []byte, offset int64) (int, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if !node.dirty {
+		node.buffer = append([]byte{}, node.contents...)
+		node.dirty = true
+	}
+	end := int(offset) + len(data)
+	if end > len(node.buffer) {
+		buffer := make([]byte, end)
+		copy(buffer, node.buffer)
+		node.buffer = buffer
+	}
+	copy(node.buffer[offset:], data)
+	node.setSize(uint64(len(node.buffer)))
+	return len(data), nil
+}
+
+func (node *FSCheckItem) Truncate(size uint64) error {
+	node.Lock()
+	defer node.Unlock()
+
+	if !node.dirty {
+		node.buffer = append([]byte{}, node.contents...)
+		node.dirty = true
+	}
+	if size > uint64(len(node.buffer)) {
+		buffer := make([]byte, size)
+		copy(buffer, node.buffer)
+		node.buffer = buffer
+	} else {
+		node.buffer = node.buffer[:size]
+	}
+	node.setSize(size)
+	return nil
+}
+
+// Set the item's state according to what was written: either "complete" or
+// "incomplete", or the item's contents with the box ticked or not.
+func (node *FSCheckItem) Flush() error {
+	node.Lock()
+	if !node.dirty {
+		node.Unlock()
+		return nil
+	}
+	value := strings.TrimSpace(string(node.buffer))
+	node.buffer = nil
+	node.dirty = false
+	node.setSize(uint64(len(node.contents)))
+	node.Unlock()
+
+	switch {
+	case value == "complete" || strings.HasPrefix(value, "[x]"):
+		return node.setState("complete")
+	case value == "incomplete" || strings.HasPrefix(value, "[ ]"):
+		return node.setState("incomplete")
+	}
+	return fuse.EINVAL
+}
+
+func (node *FSCheckItem) Chmod(mode os.FileMode) error {
+	if mode&0100 != 0 {
+		return node.setState("complete")
+	}
+	return node.setState("incomplete")
+}
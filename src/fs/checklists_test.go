@@ -0,0 +1,181 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+const checklistsFixture = `[
+	{"id": "k1", "name": "Shopping", "idCard": "c1", "checkItems": [
+		{"id": "i1", "name": "milk", "state": "incomplete"},
+		{"id": "i2", "name": "eggs", "state": "complete"}
+	]},
+	{"id": "k2", "name": "Shopping", "idCard": "c1", "checkItems": []}
+]`
+
+// A card's checklists are directories, one file per check item reading as
+// its state and name, complete items executable.
+func TestCardChecklists(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/cards/c1/checklists": checklistsFixture,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	card := newTestCard(board, &trello.Card{ID: "c1", Name: "Errands"})
+	checklists := newCardChecklistsDir(card)
+	addNodes(tree, checklists)
+
+	ctx := context.Background()
+
+	entries, err := tree.Entries(ctx, checklists.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entryNamed(entries, "Shopping (k2)") == nil {
+		t.Errorf("checklists sharing a name not told apart")
+	}
+	shopping := entryNamed(entries, "Shopping")
+	if shopping == nil {
+		t.Fatal("checklist not listed")
+	}
+	for name, want := range map[string]struct {
+		contents string
+		mode     os.FileMode
+	}{
+		"milk": {"[ ] milk\n", 0600},
+		"eggs": {"[x] eggs\n", 0700},
+	} {
+		item, err := tree.Lookup(ctx, shopping.GetNodeID(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := readFile(t, tree, item); got != want.contents {
+			t.Errorf("%s reads %q, want %q", name, got, want.contents)
+		}
+		if mode := item.GetNodeAttrs().Mode; mode != want.mode {
+			t.Errorf("%s has mode %v, want %v", name, mode, want.mode)
+		}
+	}
+}
+
+// Check items are ticked, or unticked, by writing their state, editing
+// their box, or chmod'ing them, only changes reaching Trello.
+func TestCheckItemSetState(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/cards/c1/checklists": checklistsFixture,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	card := newTestCard(board, &trello.Card{ID: "c1", Name: "Errands"})
+	checklists := newCardChecklistsDir(card)
+	addNodes(tree, checklists)
+	ctx := context.Background()
+
+	shopping, err := tree.Lookup(ctx, checklists.GetNodeID(), "Shopping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	milk, err := tree.Lookup(ctx, shopping.GetNodeID(), "milk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(t, tree, milk, "complete\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, tree, milk); got != "[x] milk\n" {
+		t.Errorf("milk reads %q once complete", got)
+	}
+	if err := writeFile(t, tree, milk, "[x] milk\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(t, tree, milk, "[ ] milk\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.Chmod(ctx, milk.GetNodeID(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if mode := milk.GetNodeAttrs().Mode; mode != 0700 {
+		t.Errorf("milk has mode %v once complete", mode)
+	}
+	if err := writeFile(t, tree, milk, "maybe\n"); err != syscall.EINVAL {
+		t.Errorf("item set to neither state: %v", err)
+	}
+
+	const item = "PUT /cards/c1/checkItem/i1"
+	requests := []string{
+		item + "?state=complete",
+		item + "?state=incomplete",
+		item + "?state=complete",
+	}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+}
+
+// Cards' directories are sized after how complete their checklists are, and
+// tell so as an xattr, following check items as they're ticked.
+func TestCardProgress(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/cards/c1/checklists": checklistsFixture,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	card := newTestCard(board, &trello.Card{
+		ID: "c1", Name: "Errands",
+		Badges: trello.CardBadges{CheckItems: 2, CheckItemsChecked: 1},
+	})
+	checklists := newCardChecklistsDir(card)
+	addNodes(tree, checklists)
+	ctx := context.Background()
+
+	progress := func(size uint64, xattr string) {
+		t.Helper()
+		if got := card.GetNodeAttrs().Size; got != size {
+			t.Errorf("card sized %d, want %d", got, size)
+		}
+		got, err := tree.GetXattr(card.GetNodeID(), xattrProgress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != xattr {
+			t.Errorf("card progress %q, want %q", got, xattr)
+		}
+	}
+	progress(50, "1/2")
+
+	shopping, err := tree.Lookup(ctx, checklists.GetNodeID(), "Shopping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	milk, err := tree.Lookup(ctx, shopping.GetNodeID(), "milk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(t, tree, milk, "complete\n"); err != nil {
+		t.Fatal(err)
+	}
+	progress(100, "2/2")
+	if err := tree.Chmod(ctx, milk.GetNodeID(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	progress(50, "1/2")
+
+	idle := newTestCard(board, &trello.Card{ID: "c2", Name: "Idle"})
+	if size := idle.GetNodeAttrs().Size; size != 0 {
+		t.Errorf("card without check items sized %d", size)
+	}
+}
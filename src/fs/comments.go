@@ -0,0 +1,71 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A card's comments, oldest first. The file is append-only: whatever is
+// appended to it is posted as a new comment once the file is closed.
+func newCommentsFile(card *FSCard) *FSControlFile {
+	var file *FSControlFile
+	file = newControlFile(
+		card, "comments", 30.0,
+		card.comments,
+		func(contents []byte) error {
+			if err := card.addComment(file.contents, contents); err != nil {
+				return err
+			}
+			// have the file regenerated with the new comment on its
+			// next read.
+			file.markStale()
+			return nil
+		},
+	)
+	return file
+}
+
+// Generate the card's comments, oldest first, as
+//
+//	[<date>] <username>:
+//	<text>
+//
+// with a blank line between comments.
+func (node *FSCard) comments() ([]byte, error) {
+	comments, err := node.tree.cardComments(node.api(), node.Card)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for i := len(comments) - 1; i >= 0; i-- {
+		comment := comments[i]
+		fmt.Fprintf(
+			&buf, "[%s] %s:\n%s\n\n",
+			comment.Date, comment.MemberCreator.Username, comment.Data.Text,
+		)
+	}
+	return buf.Bytes(), nil
+}
+
+// Post whatever was appended to the comments file as a new comment.
+func (node *FSCard) addComment(before []byte, after []byte) error {
+	if !bytes.HasPrefix(after, before) {
+		return errors.New("comments can only be appended to")
+	}
+	text := strings.TrimSpace(string(after[len(before):]))
+	if text == "" {
+		return nil
+	}
+	return node.Card.AddComment(node.api(), text)
+}
@@ -0,0 +1,60 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// A card's comments read oldest first, and text appended to them is posted
+// as a new comment, though they can't be otherwise written.
+func TestCardComments(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/cards/c1/actions": `[
+			{"date": "2022-03-02", "memberCreator": {"username": "bob"},
+				"data": {"text": "Done"}},
+			{"date": "2022-03-01", "memberCreator": {"username": "alice"},
+				"data": {"text": "On it"}}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	card := newTestCard(board, &trello.Card{ID: "c1", Name: "Fix"})
+	comments := newCommentsFile(card)
+	addNodes(tree, comments)
+	ctx := context.Background()
+
+	before := readFile(t, tree, comments)
+	want := "[2022-03-01] alice:\nOn it\n\n[2022-03-02] bob:\nDone\n\n"
+	if before != want {
+		t.Errorf("comments:\n%s\nwant:\n%s", before, want)
+	}
+
+	// as with 'echo Thanks >> comments'.
+	id := comments.GetNodeID()
+	_, err := tree.WriteAt(ctx, id, []byte("Thanks\n"), int64(len(before)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.Flush(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(t, tree, comments, "Rewritten\n"); err == nil {
+		t.Errorf("comments rewritten")
+	}
+	requests := []string{"POST /cards/c1/actions/comments?text=Thanks"}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+}
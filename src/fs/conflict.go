@@ -0,0 +1,218 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A card's '.conflict' directory, present while a write to one of its meta
+// files conflicts with a remote change. Holds both versions, 'local.md' and
+// 'remote.md'; writing 'local' or 'remote' to 'resolution' keeps that
+// version, while writing anything else takes it as the merged version.
+type FSCardConflictDir struct {
+	BaseFSNode
+
+	CardNode *FSCard
+	MetaFile *FSCardMetaFile
+
+	local  []byte
+	remote []byte
+
+	entries []FSNode
+}
+
+func newCardConflictDir(
+	card *FSCard,
+	meta *FSCardMetaFile,
+	local []byte,
+	remote []byte,
+) *FSCardConflictDir {
+	node := &FSCardConflictDir{
+		BaseFSNode: BaseFSNode{
+			name: ".conflict",
+			uid:  card.uid,
+			gid:  card.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  card.uid,
+				Gid:  card.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/.conflict", card.GetTrelloID()),
+			Ctx:      card.Ctx,
+			parent:   card,
+		},
+		CardNode: card,
+		MetaFile: meta,
+		local:    local,
+		remote:   remote,
+	}
+	resolution := newControlFile(
+		node, "resolution", 0.0,
+		func() ([]byte, error) { return nil, nil },
+		node.resolve,
+	)
+	resolution.NodeAttrs.Mode = 0200
+	node.entries = []FSNode{
+		newGeneratedFile(node, "local.md", 0.0, func() ([]byte, error) {
+			return node.local, nil
+		}),
+		newGeneratedFile(node, "remote.md", 0.0, func() ([]byte, error) {
+			return node.remote, nil
+		}),
+		resolution,
+	}
+	return node
+}
+
+func (node *FSCardConflictDir) ShouldUpdate() bool {
+	return false
+}
+
+func (node *FSCardConflictDir) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, nil
+}
+
+func (node *FSCardConflictDir) LookupChild(name string) (FSNode, error) {
+	for _, entry := range node.entries {
+		if entry.GetName() == name {
+			return entry, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSCardConflictDir) GetEntries() []FSNode {
+	return append([]FSNode{}, node.entries...)
+}
+
+func (node *FSCardConflictDir) resolve(data []byte) error {
+
+	value := data
+	switch strings.TrimSpace(string(data)) {
+	case "local":
+		value = node.local
+	case "remote":
+		value = node.remote
+	}
+	log.Printf(
+		"resolving conflict on %s for card %s (%s)\n",
+		node.MetaFile.GetName(),
+		node.CardNode.GetName(), node.CardNode.GetTrelloID(),
+	)
+	if err := node.CardNode.write(node.MetaFile, value); err != nil {
+		return err
+	}
+
+	node.CardNode.Lock()
+	node.CardNode.Conflict = nil
+	node.CardNode.Unlock()
+
+	// along with its files.
+	tree := node.CardNode.tree
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	tree.removeNode(node)
+	return nil
+}
+
+// Obtain a meta file's value from a given copy of the card.
+func (opts *Options) cardMetaValue(card *trello.Card, name string) []byte {
+	for _, entry := range opts.cardMeta(card) {
+		if entry.Name == name {
+			return entry.Contents
+		}
+	}
+	return nil
+}
+
+// A write conflicting with a remote change, set aside in '.conflict' rather
+// than applied.
+var errSetAside = errors.New("write set aside as a conflict")
+
+// Commit a write to one of the card's meta files, 'base' being the contents
+// the write was based on. If the remote value has since changed, to
+// something other than what is being written, the write is set aside in
+// '.conflict' until resolved, failing with errSetAside; writes are refused
+// meanwhile, rather than have them replace the conflict.
+func (node *FSCard) commit(
+	meta *FSCardMetaFile,
+	base []byte,
+	local []byte,
+) error {
+
+	node.Lock()
+	conflicted := node.Conflict != nil
+	node.Unlock()
+	if conflicted {
+		return syscall.EBUSY
+	}
+
+	remote, err := trello.GetCard(node.api(), node.GetTrelloID())
+	if err != nil {
+		return apiErrno(err)
+	}
+	remoteValue := node.tree.opts.cardMetaValue(remote, meta.GetName())
+	if !bytes.Equal(remoteValue, base) && !bytes.Equal(remoteValue, local) {
+		log.Printf(
+			"conflict writing %s for card %s (%s)\n",
+			meta.GetName(), node.GetName(), node.GetTrelloID(),
+		)
+		node.Lock()
+		node.Conflict = newCardConflictDir(node, meta, local, remoteValue)
+		node.Unlock()
+		return errSetAside
+	}
+	return node.write(meta, local)
+}
+
+// Write a meta file's value to the card.
+func (node *FSCard) write(meta *FSCardMetaFile, value []byte) error {
+
+	field, writable := writableCardMeta[meta.GetName()]
+	if !writable {
+		return syscall.EPERM
+	}
+
+	fieldValue := string(value)
+	if cardDateMeta[meta.GetName()] {
+		date, err := node.tree.opts.parseDate(fieldValue)
+		if err != nil {
+			return err
+		}
+		fieldValue = date
+		value = []byte(node.tree.opts.renderDate(date))
+	}
+
+	node.Lock()
+	card := *node.Card
+	node.Unlock()
+
+	err := card.Update(node.api(), url.Values{field: {fieldValue}})
+	if err != nil {
+		return apiErrno(err)
+	}
+	node.setChangedCard(card)
+	meta.setContents(value)
+	return nil
+}
@@ -0,0 +1,85 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// Writes conflicting with a remote change fail to close, are set aside in
+// '.conflict', and leave the tree along with it once resolved.
+func TestConflictSetAside(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TRELLOFS_TEST", dir)
+	err := ioutil.WriteFile(
+		filepath.Join(dir, "cards-c1.json"),
+		[]byte(`{"id": "c1", "name": "My card", "desc": "Remote"}`), 0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := newMetaCard(t)
+	node.Ctx = node.tree.ctx
+	tree := node.tree
+	tree.lock.Lock()
+	tree.addNode(node)
+	desc := node.ByName["Desc"]
+	tree.addNode(desc)
+	tree.lock.Unlock()
+
+	// as with 'echo Local > Desc'.
+	if err := desc.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := desc.WriteAt([]byte("Local\n"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := desc.Flush(); err != syscall.EBUSY {
+		t.Fatalf("flush: got %v, want EBUSY", err)
+	}
+	if desc.dirty {
+		t.Errorf("write still pending once set aside")
+	}
+	conflict := node.Conflict
+	if conflict == nil {
+		t.Fatal("no .conflict directory")
+	}
+	if string(conflict.local) != "Local" ||
+		string(conflict.remote) != "Remote" {
+		t.Errorf(
+			"conflict: local %q, remote %q", conflict.local, conflict.remote,
+		)
+	}
+
+	tree.lock.Lock()
+	tree.addNode(conflict)
+	for _, entry := range conflict.GetEntries() {
+		tree.addNode(entry)
+	}
+	tree.lock.Unlock()
+	known := len(tree.inodes)
+
+	if err := conflict.resolve([]byte("local\n")); err != nil {
+		t.Fatal(err)
+	}
+	if node.Conflict != nil {
+		t.Errorf("conflict not cleared once resolved")
+	}
+	if got := string(desc.contents); got != "Local" {
+		t.Errorf("Desc = %q, want %q", got, "Local")
+	}
+	if got, want := len(tree.inodes), known-4; got != want {
+		t.Errorf("%d nodes known once resolved, want %d", got, want)
+	}
+}
@@ -0,0 +1,180 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"io"
+	"log"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A writable file whose contents are obtained with 'get', and committed with
+// 'set' once the file is flushed. Writes are buffered until then.
+type FSControlFile struct {
+	BaseFSNode
+
+	contents []byte
+	buffer   []byte
+	dirty    bool
+	interval float64
+	get      func() ([]byte, error)
+	set      func([]byte) error
+}
+
+func newControlFile(
+	parent FSNode,
+	name string,
+	interval float64,
+	get func() ([]byte, error),
+	set func([]byte) error,
+) *FSControlFile {
+	attrs := parent.GetNodeAttrs()
+	return &FSControlFile{
+		BaseFSNode: BaseFSNode{
+			name: name,
+			uid:  attrs.Uid,
+			gid:  attrs.Gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode:  0600,
+				Nlink: 1,
+				Uid:   attrs.Uid,
+				Gid:   attrs.Gid,
+			},
+			isDir:    false,
+			TrelloID: parent.GetTrelloID() + "/" + name,
+			parent:   parent,
+		},
+		interval: interval,
+		get:      get,
+		set:      set,
+	}
+}
+
+func (node *FSControlFile) ShouldUpdate() bool {
+	return node.shouldUpdate(node.interval)
+}
+
+// As with generated files, the contents are obtained without holding the
+// node's lock.
+func (node *FSControlFile) Update() ([]FSNode, []FSNode, error) {
+	contents, err := node.get()
+	if err != nil {
+		log.Printf(
+			"error obtaining %s (%s): %s\n",
+			node.GetName(), node.GetTrelloID(), err,
+		)
+		return nil, nil, err
+	}
+
+	node.Lock()
+	defer node.Unlock()
+	node.contents = contents
+	if !node.dirty {
+		node.setSize(uint64(len(contents)))
+	}
+	node.markUpdated()
+	return nil, nil, nil
+}
+
+func (node *FSControlFile) LookupChild(name string) (FSNode, error) {
+	return nil, fuse.ENOENT
+}
+
+func (node *FSControlFile) GetEntries() []FSNode {
+	return nil
+}
+
+func (node *FSControlFile) ReadAt(dst []byte, offset int64) (int, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	contents := node.contents
+	if node.dirty {
+		contents = node.buffer
+	}
+	if offset > int64(len(contents)) {
+		return 0, io.EOF
+	}
+
+	n := copy(dst, contents[offset:])
+	if n < len(dst) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (node *FSControlFile) WriteAt(data []byte, offset int64) (int, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if !node.dirty {
+		node.buffer = append([]byte{}, node.contents...)
+		node.dirty = true
+	}
+	end := int(offset) + len(data)
+	if end > len(node.buffer) {
+		buffer := make([]byte, end)
+		copy(buffer, node.buffer)
+		node.buffer = buffer
+	}
+	copy(node.buffer[offset:], data)
+	node.setSize(uint64(len(node.buffer)))
+	return len(data), nil
+}
+
+func (node *FSControlFile) Truncate(size uint64) error {
+	node.Lock()
+	defer node.Unlock()
+
+	if !node.dirty {
+		node.buffer = append([]byte{}, node.contents...)
+		node.dirty = true
+	}
+	if size > uint64(len(node.buffer)) {
+		buffer := make([]byte, size)
+		copy(buffer, node.buffer)
+		node.buffer = buffer
+	} else {
+		node.buffer = node.buffer[:size]
+	}
+	node.setSize(size)
+	return nil
+}
+
+// What's been written is set without holding the node's lock, as when
+// obtaining the contents.
+func (node *FSControlFile) Flush() error {
+	node.Lock()
+	if !node.dirty {
+		node.Unlock()
+		return nil
+	}
+	buffer := node.buffer
+	node.buffer = nil
+	node.dirty = false
+	node.Unlock()
+
+	err := node.set(buffer)
+	node.Lock()
+	defer node.Unlock()
+	if err != nil {
+		log.Printf(
+			"error setting %s (%s): %s\n",
+			node.GetName(), node.GetTrelloID(), err,
+		)
+		node.setSize(uint64(len(node.contents)))
+		return apiErrno(err)
+	}
+	node.contents = buffer
+	node.setSize(uint64(len(buffer)))
+	return nil
+}
@@ -0,0 +1,111 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"os"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// The '.trellofs' directory, holding files controlling the filesystem itself
+// rather than any particular Trello entity.
+type FSCtlDir struct {
+	BaseFSNode
+
+	tree    *Tree
+	entries []FSNode
+
+	bulk *bulkRunner
+}
+
+func newCtlDir(tree *Tree, root FSNode) *FSCtlDir {
+	return &FSCtlDir{
+		BaseFSNode: BaseFSNode{
+			name: ".trellofs",
+			uid:  tree.uid,
+			gid:  tree.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  tree.uid,
+				Gid:  tree.gid,
+			},
+			isDir:    true,
+			TrelloID: "_trellofs",
+			parent:   root,
+		},
+		tree: tree,
+		bulk: &bulkRunner{tree: tree},
+	}
+}
+
+func (node *FSCtlDir) ShouldUpdate() bool {
+	node.Lock()
+	defer node.Unlock()
+	return node.entries == nil
+}
+
+func (node *FSCtlDir) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	bulk := newControlFile(
+		node, "bulk", 0.0,
+		func() ([]byte, error) { return nil, nil },
+		node.bulk.submit,
+	)
+	bulk.NodeAttrs.Mode = 0200
+	refresh := newControlFile(
+		node, "refresh", 0.0,
+		func() ([]byte, error) { return nil, nil },
+		node.tree.refreshAll,
+	)
+	refresh.NodeAttrs.Mode = 0200
+	node.entries = []FSNode{
+		bulk,
+		newGeneratedFile(node, "bulk.result", 0.0, node.bulk.result),
+		newSnapshotsDir(node),
+		newSearchDir(node),
+		newSchemaDir(node),
+		newGeneratedFile(node, "status", 0.0, node.tree.status),
+		newGeneratedFile(node, "stats", 0.0, node.tree.stats),
+		newGeneratedFile(node, "last-errors", 0.0, node.tree.lastErrors),
+		newPendingDir(node),
+		refresh,
+		newControlFile(node, "log_level", 0.0, getLogLevel, setLogLevel),
+		newGeneratedFile(node, "bookmarks", 60.0, node.tree.bookmarks),
+		newGeneratedFile(
+			node, "layout-version", 0.0, func() ([]byte, error) {
+				return []byte(node.tree.opts.layout() + "\n"), nil
+			},
+		),
+	}
+	node.markUpdated()
+	return node.entries, nil, nil
+}
+
+func (node *FSCtlDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, entry := range node.entries {
+		if entry.GetName() == name {
+			return entry, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSCtlDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+	return append([]FSNode{}, node.entries...)
+}
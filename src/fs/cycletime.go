@@ -0,0 +1,219 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// A period a card spent in a list. 'entered' is zero if unknown, and 'left'
+// is zero if the card is still in the list.
+type listStint struct {
+	cardID    string
+	cardName  string
+	shortLink string
+	listID    string
+	listName  string
+	entered   time.Time
+	left      time.Time
+}
+
+func (stint *listStint) days() float64 {
+	left := stint.left
+	if left.IsZero() {
+		left = time.Now()
+	}
+	return left.Sub(stint.entered).Hours() / 24
+}
+
+// The time an entity was created at, as encoded in its ID.
+func idTime(id string) time.Time {
+	if len(id) < 8 {
+		return time.Time{}
+	}
+	secs, err := strconv.ParseInt(id[:8], 16, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0)
+}
+
+// Obtain the periods the board's cards spent in each list, oldest first,
+// from the board's actions. Cards still on the board end with a stint in
+// their current list; if the actions don't tell when they entered it, they
+// are assumed to have been created there, unless earlier stints are known.
+// Results are kept for a minute, so cards can share them.
+func (node *FSBoard) listStints() ([]*listStint, error) {
+	node.Lock()
+	if node.stints != nil && time.Since(node.stintsAt).Seconds() < 60.0 {
+		stints := node.stints
+		node.Unlock()
+		return stints, nil
+	}
+	node.Unlock()
+
+	board := node.Board
+	actions, err := board.GetListMoves(node.api())
+	if err != nil {
+		return nil, err
+	}
+	cards, err := board.GetCards(node.api())
+	if err != nil {
+		return nil, err
+	}
+	lists, err := board.GetLists(node.api())
+	if err != nil {
+		return nil, err
+	}
+
+	var stints []*listStint
+	open := make(map[string]*listStint)
+	known := make(map[string]bool)
+	for i := len(actions) - 1; i >= 0; i-- {
+		action := &actions[i]
+		date, err := time.Parse(time.RFC3339, action.Date)
+		if err != nil {
+			continue
+		}
+		card := action.Data.Card
+		if stint, exists := open[card.ID]; exists {
+			stint.left = date
+			delete(open, card.ID)
+		}
+		list := action.Data.List
+		if action.Type == "updateCard" {
+			list = action.Data.ListAfter
+		} else if action.Type == "moveCardFromBoard" {
+			continue
+		}
+		if list.ID == "" {
+			continue
+		}
+		stint := &listStint{
+			cardID:    card.ID,
+			cardName:  card.Name,
+			shortLink: card.ShortLink,
+			listID:    list.ID,
+			listName:  list.Name,
+			entered:   date,
+		}
+		stints = append(stints, stint)
+		open[card.ID] = stint
+		known[card.ID] = true
+	}
+
+	current := make(map[string]bool)
+	for _, card := range cards {
+		current[card.ID] = true
+		stint, exists := open[card.ID]
+		if exists && stint.listID == card.ListID {
+			stint.cardName = card.Name
+			stint.shortLink = card.ShortLink
+			continue
+		}
+		stint = &listStint{
+			cardID:    card.ID,
+			cardName:  card.Name,
+			shortLink: card.ShortLink,
+			listID:    card.ListID,
+		}
+		if !known[card.ID] {
+			stint.entered = idTime(card.ID)
+		}
+		stints = append(stints, stint)
+	}
+
+	listNames := make(map[string]string)
+	for _, list := range lists {
+		listNames[list.ID] = list.Name
+	}
+	var remaining []*listStint
+	for _, stint := range stints {
+		if stint.left.IsZero() && !current[stint.cardID] {
+			// archived or deleted, at some point we don't know of.
+			continue
+		}
+		if name, exists := listNames[stint.listID]; exists {
+			stint.listName = name
+		}
+		remaining = append(remaining, stint)
+	}
+	sort.SliceStable(remaining, func(i, j int) bool {
+		if remaining[i].entered.IsZero() || remaining[j].entered.IsZero() {
+			return !remaining[i].entered.IsZero() &&
+				remaining[j].entered.IsZero()
+		}
+		return remaining[i].entered.Before(remaining[j].entered)
+	})
+
+	node.Lock()
+	node.stints = remaining
+	node.stintsAt = time.Now()
+	node.Unlock()
+	return remaining, nil
+}
+
+// Generate the board's cycle times report: a CSV line per period a card
+// spent in a list, with the number of days it lasted, or has lasted so far.
+// Unknown dates are left empty.
+func (node *FSBoard) cycleTimes() ([]byte, error) {
+	stints, err := node.listStints()
+	if err != nil {
+		return nil, err
+	}
+
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{
+		"card", "shortLink", "list", "entered", "left", "days",
+	})
+	for _, stint := range stints {
+		days := ""
+		if !stint.entered.IsZero() {
+			days = fmt.Sprintf("%.1f", stint.days())
+		}
+		writer.Write([]string{
+			stint.cardName, stint.shortLink, stint.listName,
+			formatTime(stint.entered), formatTime(stint.left), days,
+		})
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// Days the card has been in its current list, or -1 if unknown.
+func (node *FSCard) daysInList() int {
+	boardNode := node.GetParent().(*FSBoardCardsDirMeta).BoardNode
+	stints, err := boardNode.listStints()
+	if err != nil {
+		return -1
+	}
+	for _, stint := range stints {
+		if stint.cardID == node.GetTrelloID() && stint.left.IsZero() {
+			if stint.entered.IsZero() {
+				return -1
+			}
+			return int(stint.days())
+		}
+	}
+	return -1
+}
@@ -0,0 +1,86 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// The time cards spent in each list is told from the board's actions, or
+// from when they were created, for those never moved. Cards gone from the
+// board at some unknown point are left out.
+func TestCycleTimes(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/boards/b1/actions": `[
+			{"type": "updateCard", "date": "2022-03-05T00:00:00Z", "data": {
+				"card": {"id": "c1", "name": "Fix", "shortLink": "aa"},
+				"listBefore": {"id": "l1", "name": "To do"},
+				"listAfter": {"id": "l2", "name": "Doing"}
+			}},
+			{"type": "createCard", "date": "2022-03-01T00:00:00Z", "data": {
+				"card": {"id": "c1", "name": "Fix", "shortLink": "aa"},
+				"list": {"id": "l1", "name": "To do"}
+			}},
+			{"type": "createCard", "date": "2022-02-01T00:00:00Z", "data": {
+				"card": {"id": "c9", "name": "Gone", "shortLink": "zz"},
+				"list": {"id": "l1", "name": "To do"}
+			}}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Fix", "shortLink": "aa", "idList": "l2"},
+			{"id": "62000000c2", "name": "Setup", "shortLink": "bb",
+				"idList": "l1"}
+		]`,
+		"/boards/b1/lists": `[
+			{"id": "l1", "name": "Backlog"},
+			{"id": "l2", "name": "Doing"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	fix := newTestCard(board, &trello.Card{ID: "c1", Name: "Fix"})
+
+	report, err := board.cycleTimes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(string(report))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// those still in their lists have been for however long it's been.
+	for _, row := range rows {
+		if row[4] == "" {
+			row[5] = "-"
+		}
+	}
+	want := [][]string{
+		{"card", "shortLink", "list", "entered", "left", "days"},
+		{"Setup", "bb", "Backlog", "2022-02-06T17:06:08Z", "", "-"},
+		{"Fix", "aa", "Backlog", "2022-03-01T00:00:00Z",
+			"2022-03-05T00:00:00Z", "4.0"},
+		{"Fix", "aa", "Doing", "2022-03-05T00:00:00Z", "", "-"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("cycle times:\n%q\nwant:\n%q", rows, want)
+	}
+
+	since, _ := time.Parse(time.RFC3339, "2022-03-05T00:00:00Z")
+	days := int(time.Since(since).Hours() / 24)
+	if got := fix.daysInList(); got != days {
+		t.Errorf("card in its list for %d days, want %d", got, days)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Card meta files holding dates, rendered as set by Options.DateFormat and
+// Options.Location rather than as Trello's RFC 3339 UTC timestamps.
+var cardDateMeta = map[string]bool{"Due": true, "Start": true}
+
+func (opts *Options) rendersDates() bool {
+	return opts.DateFormat != "" || opts.Location != nil
+}
+
+func (opts *Options) dateLayout() (string, *time.Location) {
+	layout, loc := opts.DateFormat, opts.Location
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	return layout, loc
+}
+
+// Render a date as obtained from Trello. Left alone if not a date, e.g.
+// empty.
+func (opts *Options) renderDate(value string) string {
+	if !opts.rendersDates() {
+		return value
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	layout, loc := opts.dateLayout()
+	return date.In(loc).Format(layout)
+}
+
+// Parse a date as rendered, or as an RFC 3339 timestamp, into what Trello
+// expects. Empty dates are left empty, to clear them.
+func (opts *Options) parseDate(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date.UTC().Format(time.RFC3339), nil
+	}
+	layout, loc := opts.dateLayout()
+	date, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		return "", syscall.EINVAL
+	}
+	return date.UTC().Format(time.RFC3339), nil
+}
+
+// Obtain a card's meta files' contents, with dates rendered.
+func (opts *Options) cardMeta(card *trello.Card) []MetaEntry {
+	entries := getMeta(*card)
+	for i, entry := range entries {
+		if cardDateMeta[entry.Name] {
+			entries[i].Contents = []byte(opts.renderDate(string(entry.Contents)))
+		}
+	}
+	return entries
+}
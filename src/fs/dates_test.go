@@ -0,0 +1,70 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Card dates read in the timezone and format configured, and may be
+// written back as read, or as RFC 3339 timestamps, being sent to Trello in
+// UTC either way.
+func TestCardDates(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Fix", "idList": "l1",
+				"due": "2022-03-01T12:00:00.000Z",
+				"start": "2022-02-28T23:30:00.000Z"}
+		]`,
+		"/boards/b1/lists": `[{"id": "l1", "name": "To do"}]`,
+		// as compared with what's written, lest it conflict.
+		"/cards/c1": `{"id": "c1", "name": "Fix",
+			"due": "2022-03-01T12:00:00.000Z",
+			"start": "2022-02-28T23:30:00.000Z"}`,
+		"PUT /cards/c1": `{"id": "c1", "name": "Fix"}`,
+	})
+	tree := newTestTree(t, Options{
+		DateFormat: "2006-01-02 15:04",
+		Location:   time.FixedZone("UTC+1", 60*60),
+	})
+	card := "eng/Roadmap/cards/Fix/"
+	due := lookupPath(t, tree, card+"Due")
+	if got := readFile(t, tree, due); got != "2022-03-01 13:00" {
+		t.Errorf("due %q, want 2022-03-01 13:00", got)
+	}
+	start := lookupPath(t, tree, card+"Start")
+	if got := readFile(t, tree, start); got != "2022-03-01 00:30" {
+		t.Errorf("start %q, want 2022-03-01 00:30", got)
+	}
+
+	if err := writeFile(t, tree, due, "2022-04-01 10:00"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(t, tree, start, "2022-03-20T08:00:00Z"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(t, tree, due, "01/04/2022"); err != syscall.EINVAL {
+		t.Errorf("date written in another format: %v", err)
+	}
+	requests := []string{
+		"PUT /cards/c1?due=2022-04-01T09%3A00%3A00Z",
+		"PUT /cards/c1?start=2022-03-20T08%3A00%3A00Z",
+	}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jacobsa/fuse"
+)
+
+// A board's 'views/due' directory, holding a view per due date bucket, each
+// with symlinks to the cards due then: 'overdue', for cards past due and not
+// marked complete; 'today'; 'this-week', up to Sunday; 'later'; and 'none',
+// for cards without a due date. Days are as in Options.Location. Cards
+// marked complete before today are in none of them.
+type FSBoardDueViews struct {
+	BaseFSNode
+
+	BoardNode *FSBoard
+
+	Views []*FSBoardView
+}
+
+var dueBuckets = []string{"overdue", "today", "this-week", "later", "none"}
+
+func newBoardDueViews(views *FSBoardViewsDir) *FSBoardDueViews {
+	return &FSBoardDueViews{
+		BaseFSNode: BaseFSNode{
+			name:      "due",
+			uid:       views.uid,
+			gid:       views.gid,
+			NodeAttrs: views.GetNodeAttrs(),
+			isDir:     true,
+			TrelloID:  fmt.Sprintf("%s/due", views.GetTrelloID()),
+			Ctx:       views.Ctx,
+			parent:    views,
+		},
+		BoardNode: views.BoardNode,
+	}
+}
+
+// Obtain which bucket a card falls in, if any.
+func (node *FSBoardDueViews) dueBucket(card *queryCard, now time.Time) string {
+	if card.due.IsZero() {
+		return "none"
+	} else if card.due.Before(now) && !card.dueComplete {
+		return "overdue"
+	}
+
+	_, loc := node.tree.opts.dateLayout()
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	tomorrow := today.AddDate(0, 0, 1)
+	// weeks start on Monday.
+	nextWeek := today.AddDate(0, 0, 7-(int(today.Weekday())+6)%7)
+	switch {
+	case card.due.Before(today):
+		return ""
+	case card.due.Before(tomorrow):
+		return "today"
+	case card.due.Before(nextWeek):
+		return "this-week"
+	}
+	return "later"
+}
+
+// The buckets are set up along with the directory.
+func (node *FSBoardDueViews) ShouldUpdate() bool {
+	node.Lock()
+	defer node.Unlock()
+	return node.getLastUpdated().IsZero()
+}
+
+func (node *FSBoardDueViews) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	// already set up, and being refreshed as a whole.
+	if node.Views != nil {
+		node.markUpdated()
+		return newNodes, nil, nil
+	}
+	for _, bucket := range dueBuckets {
+		bucket := bucket
+		view := newBoardView(
+			node, node.BoardNode, bucket,
+			func(card *queryCard, now time.Time) bool {
+				return node.dueBucket(card, now) == bucket
+			},
+		)
+		view.agenda = true
+		node.Views = append(node.Views, view)
+		newNodes = append(newNodes, view)
+	}
+	node.markUpdated()
+	return newNodes, nil, nil
+}
+
+func (node *FSBoardDueViews) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, view := range node.Views {
+		if view.GetName() == name {
+			return view, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSBoardDueViews) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Views))
+	for i, view := range node.Views {
+		entries[i] = view
+	}
+	return entries
+}
@@ -0,0 +1,342 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A board's '.duplicates' directory, holding a directory per group of cards
+// with the same, or nearly the same, name, e.g. left behind by an import.
+// Groups are named after their first card, by name, and hold a symlink per
+// card, named '<short link> (<list>)'.
+type FSBoardDuplicatesDir struct {
+	BaseFSNode
+
+	BoardNode *FSBoard
+
+	Groups []*FSDuplicatesGroup
+	byName map[string]*FSDuplicatesGroup
+}
+
+type FSDuplicatesGroup struct {
+	BaseFSNode
+
+	Links  []*FSSymlink
+	byCard map[string]*FSSymlink
+}
+
+func newBoardDuplicatesDir(board *FSBoard) *FSBoardDuplicatesDir {
+	return &FSBoardDuplicatesDir{
+		BaseFSNode: BaseFSNode{
+			name: ".duplicates",
+			uid:  board.uid,
+			gid:  board.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0500 | os.ModeDir,
+				Uid:  board.uid,
+				Gid:  board.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/.duplicates", board.GetTrelloID()),
+			Ctx:      board.Ctx,
+			parent:   board,
+		},
+		BoardNode: board,
+		byName:    make(map[string]*FSDuplicatesGroup),
+	}
+}
+
+// Names are compared ignoring case, punctuation, and spacing.
+func normalizeCardName(name string) string {
+	var words []string
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		words = append(words, strings.ToLower(word))
+	}
+	return strings.Join(words, " ")
+}
+
+// The Levenshtein distance between two strings, giving up once over 'max'.
+func editDistance(a string, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra)-len(rb) > max || len(rb)-len(ra) > max {
+		return max + 1
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		best := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+			if cur[j] < best {
+				best = cur[j]
+			}
+		}
+		if best > max {
+			return max + 1
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// Whether two normalized names are nearly the same: a typo or two apart,
+// for names long enough for that not to be a different name altogether.
+func nearlySameName(a string, b string) bool {
+	if a == b {
+		return true
+	}
+	shortest := len([]rune(a))
+	if n := len([]rune(b)); n < shortest {
+		shortest = n
+	}
+	max := shortest / 8
+	if max > 2 {
+		max = 2
+	}
+	return max > 0 && editDistance(a, b, max) <= max
+}
+
+// Group the board's known cards by name, leaving out those without any
+// duplicates. Groups, and the cards within, are sorted by name.
+func (node *FSBoard) duplicateCards() [][]*FSCard {
+	node.Lock()
+	cards := append([]*FSCard{}, node.Cards...)
+	node.Unlock()
+
+	byKey := make(map[string][]*FSCard)
+	var keys []string
+	for _, card := range cards {
+		card.Lock()
+		key := normalizeCardName(card.Card.Name)
+		card.Unlock()
+		if _, exists := byKey[key]; !exists {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], card)
+	}
+	sort.Strings(keys)
+
+	// merge nearly same names into the first of them.
+	merged := make(map[string]string)
+	for i, key := range keys {
+		if _, exists := merged[key]; exists {
+			continue
+		}
+		merged[key] = key
+		for _, other := range keys[i+1:] {
+			if _, exists := merged[other]; !exists &&
+				nearlySameName(key, other) {
+				merged[other] = key
+			}
+		}
+	}
+
+	var groups [][]*FSCard
+	for _, key := range keys {
+		if merged[key] != key {
+			continue
+		}
+		var group []*FSCard
+		for _, other := range keys {
+			if merged[other] == key {
+				group = append(group, byKey[other]...)
+			}
+		}
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].GetName() < group[j].GetName()
+		})
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+func (node *FSBoardDuplicatesDir) ShouldUpdate() bool {
+	return node.shouldUpdate(60.0)
+}
+
+// Duplicates are found among the cards and lists already known, which are
+// refreshed first if due.
+func (node *FSBoardDuplicatesDir) Update() ([]FSNode, []FSNode, error) {
+	boardNode := node.BoardNode
+	node.tree.refresh(boardNode.MetaCardsDir)
+	node.tree.refresh(boardNode.MetaListsDir)
+
+	boardNode.Lock()
+	lists := make(map[string]string)
+	for id, list := range boardNode.ByListID {
+		lists[id] = list.List.Name
+	}
+	boardNode.Unlock()
+
+	node.Lock()
+	defer node.Unlock()
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+	var groups []*FSDuplicatesGroup
+	byName := make(map[string]*FSDuplicatesGroup)
+	for _, cards := range boardNode.duplicateCards() {
+		name := cards[0].GetName()
+		group, exists := node.byName[name]
+		if !exists {
+			group = &FSDuplicatesGroup{
+				BaseFSNode: BaseFSNode{
+					name:      name,
+					uid:       node.uid,
+					gid:       node.gid,
+					NodeAttrs: node.GetNodeAttrs(),
+					isDir:     true,
+					TrelloID: fmt.Sprintf(
+						"%s/%s", node.GetTrelloID(), cards[0].GetTrelloID(),
+					),
+					Ctx:    node.Ctx,
+					parent: node,
+				},
+				byCard: make(map[string]*FSSymlink),
+			}
+			newNodes = append(newNodes, group)
+		}
+		added, removed := group.setCards(cards, lists)
+		newNodes = append(newNodes, added...)
+		rmNodes = append(rmNodes, removed...)
+		byName[name] = group
+		groups = append(groups, group)
+	}
+	for name, group := range node.byName {
+		if _, exists := byName[name]; !exists {
+			rmNodes = append(rmNodes, group)
+		}
+	}
+	node.byName = byName
+	node.Groups = groups
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSBoardDuplicatesDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if group, exists := node.byName[name]; exists {
+		return group, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSBoardDuplicatesDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Groups))
+	for i, group := range node.Groups {
+		entries[i] = group
+	}
+	return entries
+}
+
+// Link to the group's cards, given the names of the board's lists by ID.
+// Links are renamed, i.e. replaced, as their cards move between lists.
+func (node *FSDuplicatesGroup) setCards(
+	cards []*FSCard, lists map[string]string,
+) ([]FSNode, []FSNode) {
+	node.Lock()
+	defer node.Unlock()
+
+	var added []FSNode
+	var removed []FSNode
+	var links []*FSSymlink
+	byCard := make(map[string]*FSSymlink)
+	for _, card := range cards {
+		card.Lock()
+		name := fmt.Sprintf(
+			"%s (%s)", card.Card.ShortLink, escapeName(lists[card.Card.ListID]),
+		)
+		card.Unlock()
+		link, exists := node.byCard[card.GetTrelloID()]
+		if exists && link.GetName() != name {
+			removed = append(removed, link)
+			exists = false
+		}
+		if !exists {
+			link = newSymlink(node, name, relativePath(node, card))
+			added = append(added, link)
+		}
+		byCard[card.GetTrelloID()] = link
+		links = append(links, link)
+	}
+	for id, link := range node.byCard {
+		if _, exists := byCard[id]; !exists {
+			removed = append(removed, link)
+		}
+	}
+	node.byCard = byCard
+	node.Links = links
+	return added, removed
+}
+
+func (node *FSDuplicatesGroup) ShouldUpdate() bool {
+	return false
+}
+
+// Groups are kept up to date by the '.duplicates' directory.
+func (node *FSDuplicatesGroup) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, nil
+}
+
+func (node *FSDuplicatesGroup) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, link := range node.Links {
+		if link.GetName() == name {
+			return link, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSDuplicatesGroup) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Links))
+	for i, link := range node.Links {
+		entries[i] = link
+	}
+	return entries
+}
@@ -0,0 +1,77 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// Cards named the same, but for case, punctuation, or a typo, are grouped
+// in '.duplicates', while short names a letter apart are told apart.
+func TestDuplicateCards(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/lists": `[
+			{"id": "l1", "name": "To do"},
+			{"id": "l2", "name": "Done"}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Fix login bug", "shortLink": "aa",
+				"idList": "l1"},
+			{"id": "c2", "name": "fix login-bug!", "shortLink": "bb",
+				"idList": "l2"},
+			{"id": "c3", "name": "Fix logn bug", "shortLink": "cc",
+				"idList": "l1"},
+			{"id": "c4", "name": "Docs", "shortLink": "dd", "idList": "l1"},
+			{"id": "c5", "name": "Dogs", "shortLink": "ee", "idList": "l1"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	ctx := context.Background()
+
+	duplicates := lookupPath(t, tree, "eng/Roadmap/.duplicates")
+	entries, err := tree.Entries(ctx, duplicates.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var groups []string
+	for _, entry := range entries {
+		groups = append(groups, entry.GetName())
+	}
+	want := []string{"Fix login bug", ".refresh"}
+	if !reflect.DeepEqual(groups, want) {
+		t.Fatalf("groups %q, want %q", groups, want)
+	}
+
+	entries, err = tree.Entries(ctx, entries[0].GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var links []string
+	for _, entry := range entries {
+		if target, err := entry.ReadLink(); err == nil {
+			links = append(links, entry.GetName()+" -> "+target)
+		}
+	}
+	cards := "../../../../eng/Roadmap/cards/"
+	want = []string{
+		"aa (To do) -> " + cards + "Fix login bug",
+		"cc (To do) -> " + cards + "Fix logn bug",
+		"bb (Done) -> " + cards + "fix login-bug!",
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("group links:\n%q\nwant:\n%q", links, want)
+	}
+}
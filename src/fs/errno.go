@@ -0,0 +1,47 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"errors"
+	"net/http"
+	"syscall"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse"
+)
+
+// The errno to fail an operation with, given why a request to Trello
+// failed: EACCES if not allowed, ENOENT if there's no such thing, EAGAIN if
+// rate limited, EINTR if the operation was interrupted, and EIO otherwise.
+// Errnos are returned as they are.
+func apiErrno(err error) error {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return errno
+	} else if trello.IsCancelled(err) {
+		return syscall.EINTR
+	}
+	var apiErr *trello.APIError
+	if !errors.As(err, &apiErr) {
+		return fuse.EIO
+	}
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized ||
+		apiErr.StatusCode == http.StatusForbidden:
+		return syscall.EACCES
+	case apiErr.StatusCode == http.StatusNotFound:
+		return fuse.ENOENT
+	case apiErr.IsRateLimited():
+		return syscall.EAGAIN
+	}
+	return fuse.EIO
+}
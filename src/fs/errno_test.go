@@ -0,0 +1,51 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"syscall"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse"
+)
+
+// Trello's API errors fail operations with the errno closest to why they
+// failed, even once wrapped.
+func TestAPIErrno(t *testing.T) {
+	apiErr := func(status int) error {
+		return fmt.Errorf("updating card: %w", &trello.APIError{
+			Method: "PUT", Endpoint: "/cards/c1", StatusCode: status,
+		})
+	}
+	for _, test := range []struct {
+		err  error
+		want error
+	}{
+		{apiErr(http.StatusUnauthorized), syscall.EACCES},
+		{apiErr(http.StatusForbidden), syscall.EACCES},
+		{apiErr(http.StatusNotFound), fuse.ENOENT},
+		{apiErr(http.StatusTooManyRequests), syscall.EAGAIN},
+		{apiErr(http.StatusBadRequest), fuse.EIO},
+		{apiErr(http.StatusBadGateway), fuse.EIO},
+		{fmt.Errorf("cancelled: %w", context.Canceled), syscall.EINTR},
+		{syscall.EINVAL, syscall.EINVAL},
+		{errors.New("connection refused"), fuse.EIO},
+	} {
+		if got := apiErrno(test.err); got != test.want {
+			t.Errorf("%v failed with %v, want %v", test.err, got, test.want)
+		}
+	}
+}
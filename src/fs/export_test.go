@@ -0,0 +1,67 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// A board's export is served as the mount's only board, its archived lists
+// left out, and nothing in it may be changed.
+func TestServeExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "board.json")
+	err := ioutil.WriteFile(path, []byte(`{
+		"id": "b1", "name": "Roadmap", "shortLink": "rm",
+		"lists": [
+			{"id": "l1", "name": "To do"},
+			{"id": "l2", "name": "Old", "closed": true}
+		],
+		"cards": [
+			{"id": "c1", "name": "Fix", "shortLink": "aa", "idList": "l1",
+				"idBoard": "b1", "desc": "It crashes"}
+		],
+		"checklists": [], "labels": [], "members": [], "actions": []
+	}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	export, err := trello.LoadExport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trelloCtx := trello.Trello("me", "", "")
+	trelloCtx.SetExport(export)
+	tree, err := NewTree(0, 0, trelloCtx, Options{
+		RootWorkspace: export.Workspace.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desc := lookupPath(t, tree, "Roadmap/cards/Fix/Desc")
+	if got := readFile(t, tree, desc); got != "It crashes" {
+		t.Errorf("description %q, want It crashes", got)
+	}
+	lists := lookupPath(t, tree, "Roadmap/lists")
+	ctx := context.Background()
+	if _, err := tree.Lookup(ctx, lists.GetNodeID(), "Old"); err == nil {
+		t.Errorf("archived list served")
+	}
+	lookupPath(t, tree, "Roadmap/lists/To do/Fix")
+	if err := writeFile(t, tree, desc, "Fixed"); err != syscall.EROFS {
+		t.Errorf("export written to: %v", err)
+	}
+}
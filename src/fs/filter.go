@@ -0,0 +1,111 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"log"
+	"path"
+	"strings"
+)
+
+// Selects entities by glob patterns, as understood by path.Match (e.g.,
+// "team-*"). An entity is selected if it matches any of the Include patterns,
+// or if there are none, unless it also matches any of the Exclude patterns.
+type Filter struct {
+	Include []string
+	Exclude []string
+}
+
+// Whether any of 'keys' (e.g., an entity's name and ID) matches any of
+// 'patterns'. Malformed patterns match nothing.
+func matchesAny(patterns []string, keys []string) bool {
+	for _, pattern := range patterns {
+		for _, key := range keys {
+			matched, err := path.Match(pattern, key)
+			if err != nil {
+				log.Printf("filter > bad pattern '%s': %s\n", pattern, err)
+				break
+			}
+			if matched && key != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Whether an entity, known by any of 'keys', is selected by the filter.
+func (filter *Filter) selects(keys ...string) bool {
+	if matchesAny(filter.Exclude, keys) {
+		return false
+	}
+	return len(filter.Include) == 0 || matchesAny(filter.Include, keys)
+}
+
+// Selects boards by '<workspace>/<board>' glob patterns (e.g., "Work/*", or
+// "Personal/Groceries"), matched against a workspace's name, display name,
+// and ID, and a board's name, ID, and short link. A workspace pattern alone
+// selects all of its boards. Everything is selected if there are no
+// patterns.
+type BoardSelection []string
+
+// Split a pattern into its workspace and board patterns, the latter "*" if
+// there's none.
+func splitBoardPattern(pattern string) (string, string) {
+	parts := strings.SplitN(pattern, "/", 2)
+	if len(parts) == 1 || parts[1] == "" {
+		return parts[0], "*"
+	}
+	return parts[0], parts[1]
+}
+
+// Whether any of a workspace's boards may be selected, for the workspace
+// known by any of 'keys'.
+func (sel BoardSelection) selectsWorkspace(keys ...string) bool {
+	if len(sel) == 0 {
+		return true
+	}
+	for _, pattern := range sel {
+		wsPattern, _ := splitBoardPattern(pattern)
+		if matchesAny([]string{wsPattern}, keys) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether a board, known by any of 'boardKeys', is selected, on the
+// workspace known by any of 'wsKeys'.
+func (sel BoardSelection) selectsBoard(
+	wsKeys []string,
+	boardKeys []string,
+) bool {
+	if len(sel) == 0 {
+		return true
+	}
+	for _, pattern := range sel {
+		wsPattern, boardPattern := splitBoardPattern(pattern)
+		if matchesAny([]string{wsPattern}, wsKeys) &&
+			matchesAny([]string{boardPattern}, boardKeys) {
+			return true
+		}
+	}
+	return false
+}
+
+// Obtain the filter for a workspace's boards, if any.
+func (opts *Options) boardFilter(keys ...string) Filter {
+	for _, key := range keys {
+		if filter, exists := opts.BoardFilters[key]; exists {
+			return filter
+		}
+	}
+	return Filter{}
+}
@@ -0,0 +1,111 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Finds a board's cards by name, among those already known. Patterns are
+// globs, or regular expressions if enclosed in slashes, e.g. '/^bug/'.
+type cardFinder struct {
+	sync.Mutex
+
+	board *FSBoard
+	match func(name string) bool
+}
+
+// A board's '.find' file. Writing a pattern to it, then reading it, yields
+// the paths of the cards whose names match, relative to the board, one per
+// line.
+func newFindFile(board *FSBoard) *FSControlFile {
+	finder := &cardFinder{board: board}
+	var file *FSControlFile
+	file = newControlFile(
+		board, ".find", 0.0,
+		finder.results,
+		func(contents []byte) error {
+			if err := finder.setPattern(contents); err != nil {
+				return err
+			}
+			// have the file regenerated with the results on its next read.
+			file.markStale()
+			return nil
+		},
+	)
+	return file
+}
+
+func (finder *cardFinder) setPattern(contents []byte) error {
+	pattern := strings.TrimSpace(string(contents))
+
+	var match func(name string) bool
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") &&
+		strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return syscall.EINVAL
+		}
+		match = re.MatchString
+	} else if pattern != "" {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return syscall.EINVAL
+		}
+		match = func(name string) bool {
+			matched, _ := path.Match(pattern, name)
+			return matched
+		}
+	}
+
+	finder.Lock()
+	defer finder.Unlock()
+	finder.match = match
+	return nil
+}
+
+// Called with the updates lock held, when the file is updated.
+func (finder *cardFinder) results() ([]byte, error) {
+	finder.Lock()
+	match := finder.match
+	finder.Unlock()
+	if match == nil {
+		return nil, nil
+	}
+
+	board := finder.board
+	board.tree.refresh(board.MetaCardsDir)
+	board.Lock()
+	cards := append([]*FSCard{}, board.Cards...)
+	board.Unlock()
+
+	prefix := nodePath(board) + "/"
+	var paths []string
+	for _, card := range cards {
+		card.Lock()
+		name := card.Card.Name
+		card.Unlock()
+		if match(name) {
+			paths = append(paths, strings.TrimPrefix(nodePath(card), prefix))
+		}
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	for _, p := range paths {
+		buf.WriteString(p + "\n")
+	}
+	return buf.Bytes(), nil
+}
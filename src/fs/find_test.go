@@ -0,0 +1,58 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Patterns written to a board's '.find', as globs or regular expressions,
+// have it read as the paths of the cards whose names match.
+func TestFindCards(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "bug: crash"},
+			{"id": "c2", "name": "feature: export"},
+			{"id": "c3", "name": "bug: leak"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	find := newFindFile(board)
+	addNodes(tree, find)
+
+	if got := readFile(t, tree, find); got != "" {
+		t.Errorf("found %q before searching", got)
+	}
+	for _, search := range []struct {
+		pattern string
+		want    string
+	}{
+		{"bug:*\n", "cards/bug: crash\ncards/bug: leak\n"},
+		{"/export$/\n", "cards/feature: export\n"},
+		{"docs*", ""},
+	} {
+		if err := writeFile(t, tree, find, search.pattern); err != nil {
+			t.Fatal(err)
+		}
+		if got := readFile(t, tree, find); got != search.want {
+			t.Errorf("%q found:\n%s\nwant:\n%s", search.pattern, got,
+				search.want)
+		}
+	}
+	for _, pattern := range []string{"/(/", "[bug"} {
+		if err := writeFile(t, tree, find, pattern); err != syscall.EINVAL {
+			t.Errorf("invalid pattern %q searched: %v", pattern, err)
+		}
+	}
+}
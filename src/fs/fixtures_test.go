@@ -0,0 +1,229 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Have Trello's responses served from 'fixtures', by endpoint, e.g.
+// '/boards/b1/lists', or by method and endpoint for requests other than
+// GETs, e.g. 'POST /cards', for the duration of the test. Requests other
+// than GETs are logged to 'requests.log' in the directory returned.
+func withFixtures(tb testing.TB, fixtures map[string]string) string {
+	dir := tb.TempDir()
+	tb.Setenv("TRELLOFS_TEST", dir)
+	for endpoint, body := range fixtures {
+		name := strings.NewReplacer(" ", "", "/", "-").Replace(
+			strings.TrimPrefix(endpoint, "/"),
+		)
+		err := ioutil.WriteFile(
+			filepath.Join(dir, name+".json"), []byte(body), 0600,
+		)
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// Obtain a tree, its logs discarded, with the given options.
+func newTestTree(tb testing.TB, opts Options) *Tree {
+	out := log.Writer()
+	log.SetOutput(ioutil.Discard)
+	tb.Cleanup(func() { log.SetOutput(out) })
+
+	tree, err := NewTree(0, 0, trello.Trello("me", "", ""), opts)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return tree
+}
+
+// Obtain a workspace's node, as known to 'tree', without it having been
+// loaded.
+func newTestWorkspace(tree *Tree, ws *trello.Workspace) *FSWorkspace {
+	node := &FSWorkspace{
+		BaseFSNode: BaseFSNode{
+			name: ws.Name, TrelloID: ws.ID, isDir: true, Ctx: tree.ctx,
+		},
+		ByID:      make(map[string]*FSBoard),
+		ByName:    make(map[string]*FSBoard),
+		Workspace: ws,
+	}
+	addNodes(tree, node)
+	return node
+}
+
+// Obtain a board's node, as known to 'tree', without it having been loaded.
+func newTestBoard(tree *Tree, board *trello.Board) *FSBoard {
+	node := &FSBoard{
+		BaseFSNode: BaseFSNode{
+			name:      board.Name,
+			NodeAttrs: fuseops.InodeAttributes{Mode: 0700 | os.ModeDir},
+			isDir:     true,
+			TrelloID:  board.ID,
+			Ctx:       tree.ctx,
+		},
+		Board:      board,
+		ByCardID:   make(map[string]*FSCard),
+		ByCardName: make(map[string]*FSCard),
+		ByListID:   make(map[string]*FSList),
+		ByListName: make(map[string]*FSList),
+	}
+	node.MetaCardsDir = &FSBoardCardsDirMeta{
+		BaseFSNode: BaseFSNode{
+			name:      "cards",
+			NodeAttrs: fuseops.InodeAttributes{Mode: 0700 | os.ModeDir},
+			isDir:     true,
+			TrelloID:  board.ID + "/cards",
+			Ctx:       tree.ctx,
+			parent:    node,
+		},
+		BoardNode: node,
+	}
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	tree.addNode(node)
+	tree.addNode(node.MetaCardsDir)
+	return node
+}
+
+// Obtain the node of a list on 'board', as known to the tree, as loaded
+// with no cards.
+func newTestList(board *FSBoard, list *trello.List) *FSList {
+	list.Board = board.Board
+	node := &FSList{
+		BaseFSNode: BaseFSNode{
+			name:      list.Name,
+			NodeAttrs: fuseops.InodeAttributes{Mode: 0700 | os.ModeDir},
+			isDir:     true,
+			TrelloID:  list.ID,
+			Ctx:       board.Ctx,
+			parent:    board,
+		},
+		ByID:      make(map[string]*FSCard),
+		ByName:    make(map[string]*FSCard),
+		links:     make(map[string]*FSSymlink),
+		BoardNode: board,
+		List:      list,
+	}
+	node.markUpdated()
+	board.Lists = append(board.Lists, node)
+	board.ByListID[list.ID] = node
+	board.ByListName[node.GetName()] = node
+
+	tree := board.tree
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	tree.addNode(node)
+	return node
+}
+
+// Obtain the node of a card on 'board', as known to the tree, without it
+// having been loaded.
+func newTestCard(board *FSBoard, card *trello.Card) *FSCard {
+	card.Board = board.Board
+	node := newCardNode(board, card)
+	board.Cards = append(board.Cards, node)
+	board.ByCardID[card.ID] = node
+	board.ByCardName[node.GetName()] = node
+
+	tree := board.tree
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	tree.addNode(node)
+	return node
+}
+
+// Obtain the entry named 'name' amongst 'entries', or nil.
+func entryNamed(entries []FSNode, name string) FSNode {
+	for _, entry := range entries {
+		if entry.GetName() == name {
+			return entry
+		}
+	}
+	return nil
+}
+
+// Look up a node by its path from the root, as the kernel would, one
+// component at a time.
+func lookupPath(tb testing.TB, tree *Tree, path string) FSNode {
+	node := tree.GetNode(fuseops.RootInodeID)
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		child, err := tree.Lookup(
+			context.Background(), node.GetNodeID(), name,
+		)
+		if err != nil {
+			tb.Fatalf("lookup %s in %s: %s", name, path, err)
+		}
+		node = child
+	}
+	return node
+}
+
+// Read a file's contents through the tree, as the kernel would.
+func readFile(tb testing.TB, tree *Tree, node FSNode) string {
+	buf := make([]byte, 1<<16)
+	n, err := tree.ReadAt(context.Background(), node.GetNodeID(), buf, 0)
+	if err != nil && err != io.EOF {
+		tb.Fatalf("read %s: %s", node.GetName(), err)
+	}
+	return string(buf[:n])
+}
+
+// Write a file's contents through the tree, as 'echo data > file' would,
+// returning why closing it failed, if it did.
+func writeFile(tb testing.TB, tree *Tree, node FSNode, data string) error {
+	ctx := context.Background()
+	if err := tree.Truncate(ctx, node.GetNodeID(), 0); err != nil {
+		return err
+	}
+	_, err := tree.WriteAt(ctx, node.GetNodeID(), []byte(data), 0)
+	if err != nil {
+		return err
+	}
+	if err := tree.Flush(ctx, node.GetNodeID()); err != nil {
+		return err
+	}
+	return tree.Release(ctx, node.GetNodeID())
+}
+
+// Requests other than GETs made to Trello from the fixtures in 'dir', as
+// "<method> <endpoint>".
+func requestsMade(tb testing.TB, dir string) []string {
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "requests.log"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		tb.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")
+}
+
+// Have the tree know of nodes made outside of their parents' updates.
+func addNodes(tree *Tree, nodes ...FSNode) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	for _, node := range nodes {
+		tree.addNode(node)
+	}
+}
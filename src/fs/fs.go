@@ -11,138 +11,76 @@ package fs
 
 import (
 	"context"
-	"errors"
 	"io"
 	"log"
 	"os"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/jecluis/trellofs/src/trello"
-
 	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fuseops"
 	"github.com/jacobsa/fuse/fuseutil"
+	"github.com/jecluis/trellofs/src/trello"
 )
 
-type trelloFS struct {
-	fuseutil.NotImplementedFileSystem
-
-	Root *TrelloTreeRoot
+// How long the kernel may cache a name's lookup. Our version of the fuse
+// package can't notify the kernel of entries being removed, so names of
+// entities gone from Trello linger until their lookup expires.
+const entryTimeout = 30 * time.Second
 
-	uid uint32
-	gid uint32
+// Block size reported to statfs(2). Being in memory, the filesystem has no
+// capacity as such, so it reports a nominal amount of blocks and inodes free
+// on top of those used, lest tools think it full and refuse to write to it.
+const statfsBlockSize = 4096
+const statfsFreeBlocks = 1 << 20
+const statfsFreeInodes = 1 << 20
 
-	lock sync.Mutex
+type trelloFS struct {
+	fuseutil.NotImplementedFileSystem
 
-	inodes     []FSNode
-	freeInodes []fuseops.InodeID
-	byID       map[string]fuseops.InodeID
+	tree *Tree
 
-	ctx *trello.TrelloCtx
+	// the files open, by handle, and how many handles each has open, so
+	// files are told once they're no longer open at all.
+	handlesLock sync.Mutex
+	lastHandle  fuseops.HandleID
+	handles     map[fuseops.HandleID]fuseops.InodeID
+	opened      map[fuseops.InodeID]int
 }
 
-func (fs *trelloFS) initRoot() FSNode {
-
-	rootAttrs := fuseops.InodeAttributes{
-		Mode: 0700 | os.ModeDir,
-		Uid:  fs.uid,
-		Gid:  fs.gid,
-	}
-	fs.Root = &TrelloTreeRoot{
-		BaseFSNode: BaseFSNode{
-			name:      "/",
-			uid:       fs.uid,
-			gid:       fs.gid,
-			NodeID:    fuseops.RootInodeID,
-			NodeAttrs: rootAttrs,
-			isDir:     true,
-			TrelloID:  "rootID",
-			Ctx:       fs.ctx,
-		},
-		byID:   make(map[string]*FSWorkspace),
-		byName: make(map[string]*FSWorkspace),
-	}
-	return fs.Root
-}
-
-func NewTrelloFS(
-	uid uint32,
-	gid uint32,
-	ctx *trello.TrelloCtx,
-) (fuse.Server, error) {
+func NewTrelloFS(tree *Tree) fuse.Server {
 	fs := &trelloFS{
-		uid:    uid,
-		gid:    gid,
-		inodes: make([]FSNode, fuseops.RootInodeID+1),
-		byID:   make(map[string]fuseops.InodeID),
-		ctx:    ctx,
+		tree: tree,
 	}
-	fs.inodes[fuseops.RootInodeID] = fs.initRoot()
-	return fuseutil.NewFileSystemServer(fs), nil
+	return fuseutil.NewFileSystemServer(fs)
 }
 
-func (fs *trelloFS) refreshNode(node FSNode) {
-
-	if !node.ShouldUpdate() {
-		return
-	}
-	log.Printf(
-		"refreshing node id %d, %s (%s)\n",
-		node.GetNodeID(), node.GetName(), node.GetTrelloID(),
-	)
-	add, rm, err := node.Update()
-
-	if err != nil {
-		log.Printf(
-			"error updating node %s (%s) id %d\n",
-			node.GetName(),
-			node.GetTrelloID(),
-			node.GetNodeID(),
-		)
-		return
+// Obtain the dirent type for a given node, from its attributes.
+func direntType(node FSNode) fuseutil.DirentType {
+	mode := node.GetNodeAttrs().Mode
+	if mode&os.ModeDir != 0 {
+		return fuseutil.DT_Directory
+	} else if mode&os.ModeSymlink != 0 {
+		return fuseutil.DT_Link
 	}
-
-	for _, n := range add {
-		numFree := len(fs.freeInodes)
-		id := fuseops.InodeID(len(fs.inodes))
-		if numFree > 0 {
-			id = fs.freeInodes[numFree-1]
-			log.Printf(
-				"refresh > reuse id %d for %s (%s)\n",
-				id, n.GetName(), n.GetTrelloID(),
-			)
-			fs.freeInodes = fs.freeInodes[:numFree-1]
-			fs.inodes[id] = n
-		} else {
-			fs.inodes = append(fs.inodes, n)
-		}
-		fs.byID[n.GetTrelloID()] = id
-		n.SetNodeID(id)
-		log.Printf(
-			"added new node %s (%s) id %d\n",
-			n.GetName(),
-			n.GetTrelloID(),
-			n.GetNodeID(),
-		)
-	}
-
-	for _, n := range rm {
-		log.Printf(
-			"not implemented: remove node %s (%s) id %d\n",
-			n.GetName(),
-			n.GetTrelloID(),
-			n.GetNodeID(),
-		)
-	}
-
+	return fuseutil.DT_File
 }
 
 func (fs *trelloFS) StatFS(
 	ctx context.Context,
 	op *fuseops.StatFSOp,
 ) error {
-	log.Println("statfs not implemented")
+	nodes, size := fs.tree.Usage()
+	used := (size + statfsBlockSize - 1) / statfsBlockSize
+
+	op.BlockSize = statfsBlockSize
+	op.IoSize = statfsBlockSize
+	op.Blocks = used + statfsFreeBlocks
+	op.BlocksFree = statfsFreeBlocks
+	op.BlocksAvailable = statfsFreeBlocks
+	op.Inodes = nodes + statfsFreeInodes
+	op.InodesFree = statfsFreeInodes
 	return nil
 }
 
@@ -155,31 +93,23 @@ func (fs *trelloFS) LookUpInode(
 		return fuse.EINVAL
 	}
 
-	fs.lock.Lock()
-	defer fs.lock.Unlock()
-
-	parent := fs.inodes[op.Parent]
-	if parent == nil {
-		log.Fatalf(
-			"lookup inode %s, parent id %d not found\n", op.Name, op.Parent,
-		)
-		return fuse.ENOENT
-	}
-
-	fs.refreshNode(parent)
-
-	child, err := parent.LookupChild(op.Name)
+	ctx = trello.NoteFailures(ctx)
+	child, err := fs.tree.Lookup(ctx, op.Parent, op.Name)
 	if err != nil {
+		fs.tree.noteFailure(ctx, "lookup", op.Parent, op.Name, err)
 		log.Printf(
 			"lookup inode %s, parent id %d, not found\n",
 			op.Name, op.Parent,
 		)
+		return err
+	}
+	op.Entry.Child = fs.tree.Remember(child)
+	if op.Entry.Child == 0 {
 		return fuse.ENOENT
 	}
-	op.Entry.Child = child.GetNodeID()
 	op.Entry.Attributes = child.GetNodeAttrs()
 	op.Entry.AttributesExpiration = time.Now().Add(365 * 24 * time.Hour)
-	op.Entry.EntryExpiration = op.Entry.AttributesExpiration
+	op.Entry.EntryExpiration = time.Now().Add(entryTimeout)
 
 	return nil
 }
@@ -193,9 +123,11 @@ func (fs *trelloFS) GetInodeAttributes(
 		return fuse.EINVAL
 	}
 
-	fs.lock.Lock()
-	defer fs.lock.Unlock()
-	op.Attributes = fs.inodes[op.Inode].GetNodeAttrs()
+	node := fs.tree.GetNode(op.Inode)
+	if node == nil {
+		return fuse.ENOENT
+	}
+	op.Attributes = node.GetNodeAttrs()
 	op.AttributesExpiration = time.Now().Add(365 * 24 * time.Hour)
 	return nil
 }
@@ -208,7 +140,33 @@ func (fs *trelloFS) SetInodeAttributes(
 	if op.OpContext.Pid == 0 {
 		return fuse.EINVAL
 	}
-	return fuse.EIO
+
+	ctx = trello.NoteFailures(ctx)
+	if op.Size != nil {
+		err := fs.tree.Truncate(ctx, op.Inode, *op.Size)
+		if err != nil {
+			return fs.tree.noteFailure(ctx, "truncate", op.Inode, "", err)
+		}
+	}
+	if op.Mode != nil {
+		err := fs.tree.Chmod(ctx, op.Inode, *op.Mode)
+		if err != nil {
+			return fs.tree.noteFailure(ctx, "chmod", op.Inode, "", err)
+		}
+	}
+	if op.Mtime != nil {
+		err := fs.tree.SetMtime(ctx, op.Inode, *op.Mtime)
+		if err != nil {
+			return fs.tree.noteFailure(ctx, "setmtime", op.Inode, "", err)
+		}
+	}
+	node := fs.tree.GetNode(op.Inode)
+	if node == nil {
+		return fuse.ENOENT
+	}
+	op.Attributes = node.GetNodeAttrs()
+	op.AttributesExpiration = time.Now().Add(365 * 24 * time.Hour)
+	return nil
 }
 
 func (fs *trelloFS) OpenDir(
@@ -225,28 +183,32 @@ func (fs *trelloFS) ReadDir(
 ) error {
 	log.Printf("read dir > id %d\n", op.Inode)
 
-	fs.lock.Lock()
-	defer fs.lock.Unlock()
-
-	parent := fs.inodes[op.Inode]
-	if parent == nil {
-		log.Printf("read dir > failed to find parent inode %d\n", op.Inode)
-		return fuse.ENOENT
+	ctx = trello.NoteFailures(ctx)
+	entries, err := fs.tree.Entries(ctx, op.Inode)
+	if err != nil {
+		log.Printf("read dir > failed to obtain entries for %d\n", op.Inode)
+		return fs.tree.noteFailure(ctx, "readdir", op.Inode, "", err)
 	}
-	log.Printf(
-		"read dir > id %d, %s (%s)\n",
-		parent.GetNodeID(), parent.GetName(), parent.GetTrelloID(),
-	)
 
-	fs.refreshNode(parent)
-	op.BytesRead = parent.ReadDir(op.Dst, int(op.Offset))
+	for i := int(op.Offset); i < len(entries); i++ {
+		entry := entries[i]
+		tmp := fuseutil.WriteDirent(op.Dst[op.BytesRead:], fuseutil.Dirent{
+			Name:   entry.GetName(),
+			Inode:  entry.GetNodeID(),
+			Type:   direntType(entry),
+			Offset: fuseops.DirOffset(i + 1),
+		})
+		if tmp == 0 {
+			log.Printf(
+				"read dir > no more space to write dirent for %s\n",
+				entry.GetName(),
+			)
+			break
+		}
+		op.BytesRead += tmp
+	}
 
-	log.Printf(
-		"read dir %d > %s (bytes read: %d)\n",
-		op.Inode,
-		string(op.Dst),
-		op.BytesRead,
-	)
+	log.Printf("read dir %d > bytes read: %d\n", op.Inode, op.BytesRead)
 	return nil
 }
 
@@ -255,9 +217,66 @@ func (fs *trelloFS) OpenFile(
 	op *fuseops.OpenFileOp,
 ) error {
 	log.Printf("open file > id %d\n", op.Inode)
+	// Contents may be generated on the fly, and their size change without
+	// the kernel being aware of it.
+	op.UseDirectIO = true
+	op.Handle = fs.openHandle(op.Inode)
 	return nil
 }
 
+// Obtain a handle for a file being opened.
+func (fs *trelloFS) openHandle(id fuseops.InodeID) fuseops.HandleID {
+	fs.handlesLock.Lock()
+	defer fs.handlesLock.Unlock()
+
+	if fs.handles == nil {
+		fs.handles = make(map[fuseops.HandleID]fuseops.InodeID)
+		fs.opened = make(map[fuseops.InodeID]int)
+	}
+	fs.lastHandle++
+	fs.handles[fs.lastHandle] = id
+	fs.opened[id]++
+	return fs.lastHandle
+}
+
+// Drop a file's handle, once closed. Returns the file's inode, and whether
+// it's no longer open at all.
+func (fs *trelloFS) closeHandle(
+	handle fuseops.HandleID,
+) (fuseops.InodeID, bool) {
+	fs.handlesLock.Lock()
+	defer fs.handlesLock.Unlock()
+
+	id, exists := fs.handles[handle]
+	if !exists {
+		return 0, false
+	}
+	delete(fs.handles, handle)
+	fs.opened[id]--
+	if fs.opened[id] > 0 {
+		return id, false
+	}
+	delete(fs.opened, id)
+	return id, true
+}
+
+// Handles are released once all descriptors sharing them are closed, each
+// having been flushed as closed. Failures can't be told to whoever closed
+// the file, and are only noted.
+func (fs *trelloFS) ReleaseFileHandle(
+	ctx context.Context,
+	op *fuseops.ReleaseFileHandleOp,
+) error {
+	id, closed := fs.closeHandle(op.Handle)
+	if !closed {
+		return nil
+	}
+	log.Printf("release file > id %d\n", id)
+	ctx = trello.NoteFailures(ctx)
+	err := fs.tree.Release(ctx, id)
+	return fs.tree.noteFailure(ctx, "release", id, "", err)
+}
+
 func (fs *trelloFS) ReadFile(
 	ctx context.Context,
 	op *fuseops.ReadFileOp,
@@ -268,23 +287,190 @@ func (fs *trelloFS) ReadFile(
 		return fuse.EINVAL
 	}
 
-	fs.lock.Lock()
-	defer fs.lock.Unlock()
+	ctx = trello.NoteFailures(ctx)
+	bytes, err := fs.tree.ReadAt(ctx, op.Inode, op.Dst, op.Offset)
+	op.BytesRead = bytes
+	if err == io.EOF {
+		return nil
+	}
+	return fs.tree.noteFailure(ctx, "read", op.Inode, "", err)
+}
+
+func (fs *trelloFS) WriteFile(
+	ctx context.Context,
+	op *fuseops.WriteFileOp,
+) error {
+	log.Printf("write file > id %d, offset %d\n", op.Inode, op.Offset)
+
+	ctx = trello.NoteFailures(ctx)
+	_, err := fs.tree.WriteAt(ctx, op.Inode, op.Data, op.Offset)
+	return fs.tree.noteFailure(ctx, "write", op.Inode, "", err)
+}
 
-	if int(op.Inode) >= len(fs.inodes) {
-		panic(errors.New("Inode does not exist"))
+func (fs *trelloFS) FlushFile(
+	ctx context.Context,
+	op *fuseops.FlushFileOp,
+) error {
+	log.Printf("flush file > id %d\n", op.Inode)
+	ctx = trello.NoteFailures(ctx)
+	err := fs.tree.Flush(ctx, op.Inode)
+	return fs.tree.noteFailure(ctx, "flush", op.Inode, "", err)
+}
+
+func (fs *trelloFS) SyncFile(
+	ctx context.Context,
+	op *fuseops.SyncFileOp,
+) error {
+	log.Printf("sync file > id %d\n", op.Inode)
+	ctx = trello.NoteFailures(ctx)
+	err := fs.tree.Flush(ctx, op.Inode)
+	return fs.tree.noteFailure(ctx, "fsync", op.Inode, "", err)
+}
+
+func (fs *trelloFS) MkDir(
+	ctx context.Context,
+	op *fuseops.MkDirOp,
+) error {
+	log.Printf("mkdir > parent %d, name %s\n", op.Parent, op.Name)
+
+	ctx = trello.NoteFailures(ctx)
+	child, err := fs.tree.MkDir(ctx, op.Parent, op.Name)
+	if err != nil {
+		return fs.tree.noteFailure(ctx, "mkdir", op.Parent, op.Name, err)
 	}
+	op.Entry.Child = fs.tree.Remember(child)
+	if op.Entry.Child == 0 {
+		return fuse.ENOENT
+	}
+	op.Entry.Attributes = child.GetNodeAttrs()
+	op.Entry.AttributesExpiration = time.Now().Add(365 * 24 * time.Hour)
+	op.Entry.EntryExpiration = time.Now().Add(entryTimeout)
+	return nil
+}
+
+// Inodes whose nodes have been removed are only reused once forgotten.
+func (fs *trelloFS) ForgetInode(
+	ctx context.Context,
+	op *fuseops.ForgetInodeOp,
+) error {
+	fs.tree.Forget(op.Inode, op.N)
+	return nil
+}
+
+func (fs *trelloFS) CreateFile(
+	ctx context.Context,
+	op *fuseops.CreateFileOp,
+) error {
+	log.Printf("create file > parent %d, name %s\n", op.Parent, op.Name)
+
+	ctx = trello.NoteFailures(ctx)
+	child, err := fs.tree.Create(ctx, op.Parent, op.Name)
+	if err != nil {
+		return fs.tree.noteFailure(ctx, "create", op.Parent, op.Name, err)
+	}
+	op.Entry.Child = fs.tree.Remember(child)
+	if op.Entry.Child == 0 {
+		return fuse.ENOENT
+	}
+	op.Entry.Attributes = child.GetNodeAttrs()
+	op.Entry.AttributesExpiration = time.Now().Add(365 * 24 * time.Hour)
+	op.Entry.EntryExpiration = time.Now().Add(entryTimeout)
+	op.Handle = fs.openHandle(op.Entry.Child)
+	return nil
+}
 
-	node := fs.inodes[op.Inode]
-	bytes, err := node.ReadAt(op.Dst, op.Offset)
+func (fs *trelloFS) Unlink(
+	ctx context.Context,
+	op *fuseops.UnlinkOp,
+) error {
+	log.Printf("unlink > parent %d, name %s\n", op.Parent, op.Name)
+	ctx = trello.NoteFailures(ctx)
+	err := fs.tree.Unlink(ctx, op.Parent, op.Name)
+	return fs.tree.noteFailure(ctx, "unlink", op.Parent, op.Name, err)
+}
 
+func (fs *trelloFS) RmDir(
+	ctx context.Context,
+	op *fuseops.RmDirOp,
+) error {
+	log.Printf("rmdir > parent %d, name %s\n", op.Parent, op.Name)
+	ctx = trello.NoteFailures(ctx)
+	err := fs.tree.RmDir(ctx, op.Parent, op.Name)
+	return fs.tree.noteFailure(ctx, "rmdir", op.Parent, op.Name, err)
+}
+
+func (fs *trelloFS) Rename(
+	ctx context.Context,
+	op *fuseops.RenameOp,
+) error {
 	log.Printf(
-		"read file > read %s (%s) id %d, bytes: %d\n",
-		node.GetName(), node.GetTrelloID(), node.GetNodeID(), bytes,
+		"rename > parent %d, name %s, new parent %d, new name %s\n",
+		op.OldParent, op.OldName, op.NewParent, op.NewName,
 	)
-	op.BytesRead = bytes
-	if err == io.EOF {
+	ctx = trello.NoteFailures(ctx)
+	err := fs.tree.Rename(
+		ctx, op.OldParent, op.OldName, op.NewParent, op.NewName,
+	)
+	return fs.tree.noteFailure(ctx, "rename", op.OldParent, op.OldName, err)
+}
+
+func (fs *trelloFS) ListXattr(
+	ctx context.Context,
+	op *fuseops.ListXattrOp,
+) error {
+	names, err := fs.tree.ListXattrs(op.Inode)
+	if err != nil {
+		return err
+	}
+
+	var buf []byte
+	for _, name := range names {
+		buf = append(buf, name...)
+		buf = append(buf, 0)
+	}
+	op.BytesRead = len(buf)
+	if len(op.Dst) == 0 {
 		return nil
+	} else if len(op.Dst) < len(buf) {
+		return syscall.ERANGE
 	}
-	return err
+	copy(op.Dst, buf)
+	return nil
+}
+
+func (fs *trelloFS) GetXattr(
+	ctx context.Context,
+	op *fuseops.GetXattrOp,
+) error {
+	value, err := fs.tree.GetXattr(op.Inode, op.Name)
+	if err != nil {
+		return err
+	}
+
+	op.BytesRead = len(value)
+	if len(op.Dst) == 0 {
+		return nil
+	} else if len(op.Dst) < len(value) {
+		return syscall.ERANGE
+	}
+	copy(op.Dst, value)
+	return nil
+}
+
+func (fs *trelloFS) ReadSymlink(
+	ctx context.Context,
+	op *fuseops.ReadSymlinkOp,
+) error {
+	log.Printf("read symlink > id %d\n", op.Inode)
+
+	node := fs.tree.GetNode(op.Inode)
+	if node == nil {
+		return fuse.ENOENT
+	}
+	target, err := node.ReadLink()
+	if err != nil {
+		return err
+	}
+	op.Target = target
+	return nil
 }
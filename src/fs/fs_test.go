@@ -0,0 +1,151 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// statfs(2) counts the nodes known and the blocks their contents take, and
+// leaves room to spare.
+func TestStatFS(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/cards/c1/actions": `[
+			{"date": "2022-03-01", "memberCreator": {"username": "alice"},
+				"data": {"text": "` + strings.Repeat("x", 5000) + `"}}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	fs := &trelloFS{tree: tree}
+	statfs := func() *fuseops.StatFSOp {
+		op := &fuseops.StatFSOp{}
+		if err := fs.StatFS(context.Background(), op); err != nil {
+			t.Fatal(err)
+		}
+		if op.BlockSize != statfsBlockSize || op.IoSize != statfsBlockSize {
+			t.Errorf("blocks of %d bytes, read and written %d at a time",
+				op.BlockSize, op.IoSize)
+		}
+		if op.BlocksFree == 0 || op.BlocksAvailable != op.BlocksFree ||
+			op.InodesFree == 0 {
+			t.Errorf("%d blocks free, %d available, %d inodes free",
+				op.BlocksFree, op.BlocksAvailable, op.InodesFree)
+		}
+		return op
+	}
+
+	before := statfs()
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	card := newTestCard(board, &trello.Card{ID: "c1", Name: "Fix"})
+	comments := newCommentsFile(card)
+	addNodes(tree, comments)
+	readFile(t, tree, comments)
+
+	after := statfs()
+	if got := after.Inodes - after.InodesFree; got !=
+		before.Inodes-before.InodesFree+4 {
+		t.Errorf("%d inodes used once a board, its cards, a card and its "+
+			"comments are known, from %d",
+			got, before.Inodes-before.InodesFree)
+	}
+	// the comments take two blocks.
+	if got := after.Blocks - after.BlocksFree; got !=
+		before.Blocks-before.BlocksFree+2 {
+		t.Errorf("%d blocks used once 5000 bytes are read, from %d",
+			got, before.Blocks-before.BlocksFree)
+	}
+}
+
+// A node looked up concurrently keeps its inode until the kernel has
+// forgotten every lookup, even once gone from Trello; only then is the inode
+// reused.
+func TestForgetInode(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/boards/b1/cards": `[{"id": "c1", "name": "One"}]`,
+	})
+	tree := newTestTree(t, Options{})
+	fs := &trelloFS{tree: tree}
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	cards := board.MetaCardsDir
+	ctx := context.Background()
+
+	const lookups = 8
+	ids := make(chan fuseops.InodeID, lookups)
+	var wg sync.WaitGroup
+	for i := 0; i < lookups; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			op := &fuseops.LookUpInodeOp{
+				Parent:    cards.GetNodeID(),
+				Name:      "One",
+				OpContext: fuseops.OpContext{Pid: 1},
+			}
+			if err := fs.LookUpInode(ctx, op); err != nil {
+				t.Error(err)
+				return
+			}
+			ids <- op.Entry.Child
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	id := <-ids
+	for other := range ids {
+		if other != id {
+			t.Fatalf("card looked up as both %d and %d", id, other)
+		}
+	}
+
+	err := ioutil.WriteFile(
+		filepath.Join(dir, "boards-b1-cards.json"), []byte(`[]`), 0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.lock.Lock()
+	cards.markStale()
+	tree.lock.Unlock()
+	if err := <-listing(tree, cards); err != nil {
+		t.Fatal(err)
+	}
+	if tree.GetNode(id) != nil {
+		t.Fatalf("card gone from Trello still known as %d", id)
+	}
+
+	// a card added meanwhile can't take the inode the kernel still knows.
+	added := func(name string) fuseops.InodeID {
+		card := newTestCard(board, &trello.Card{ID: name, Name: name})
+		return card.GetNodeID()
+	}
+	forget := func(n uint64) {
+		op := &fuseops.ForgetInodeOp{Inode: id, N: n}
+		if err := fs.ForgetInode(ctx, op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	forget(lookups - 1)
+	if got := added("Two"); got == id {
+		t.Errorf("inode reused while still looked up once")
+	}
+	forget(1)
+	if got := added("Three"); got != id {
+		t.Errorf("card added as %d once %d was forgotten", got, id)
+	}
+}
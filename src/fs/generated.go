@@ -0,0 +1,102 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"io"
+	"log"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A read-only file whose contents are generated on demand, and regenerated
+// whenever it is read after 'interval' seconds have passed.
+type FSGeneratedFile struct {
+	BaseFSNode
+
+	contents []byte
+	interval float64
+	generate func() ([]byte, error)
+}
+
+func newGeneratedFile(
+	parent FSNode,
+	name string,
+	interval float64,
+	generate func() ([]byte, error),
+) *FSGeneratedFile {
+	attrs := parent.GetNodeAttrs()
+	return &FSGeneratedFile{
+		BaseFSNode: BaseFSNode{
+			name: name,
+			uid:  attrs.Uid,
+			gid:  attrs.Gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode:  0400,
+				Nlink: 1,
+				Uid:   attrs.Uid,
+				Gid:   attrs.Gid,
+			},
+			isDir:    false,
+			TrelloID: parent.GetTrelloID() + "/" + name,
+			parent:   parent,
+		},
+		interval: interval,
+		generate: generate,
+	}
+}
+
+func (node *FSGeneratedFile) ShouldUpdate() bool {
+	return node.shouldUpdate(node.interval)
+}
+
+// The contents are generated without holding the node's lock, as whatever
+// generates them may well need the tree's.
+func (node *FSGeneratedFile) Update() ([]FSNode, []FSNode, error) {
+	contents, err := node.generate()
+	if err != nil {
+		log.Printf(
+			"error generating %s (%s): %s\n",
+			node.GetName(), node.GetTrelloID(), err,
+		)
+		return nil, nil, err
+	}
+
+	node.Lock()
+	defer node.Unlock()
+	node.contents = contents
+	node.setSize(uint64(len(contents)))
+	node.markUpdated()
+	return nil, nil, nil
+}
+
+func (node *FSGeneratedFile) LookupChild(name string) (FSNode, error) {
+	return nil, fuse.ENOENT
+}
+
+func (node *FSGeneratedFile) GetEntries() []FSNode {
+	return nil
+}
+
+func (node *FSGeneratedFile) ReadAt(dst []byte, offset int64) (int, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if offset > int64(len(node.contents)) {
+		return 0, io.EOF
+	}
+
+	n := copy(dst, node.contents[offset:])
+	if n < len(dst) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,196 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// A board's structure: its lists, their open cards and labels, and the links
+// between cards.
+type boardGraph struct {
+	name  string
+	lists []trello.List
+	cards map[string][]trello.Card // by list ID
+
+	// links from card IDs to the short links of the cards they link to.
+	links map[string][]string
+	// short links of the board's cards, to their IDs.
+	byShortLink map[string]string
+}
+
+// Gather the board's structure. Links are found in card descriptions, and in
+// the links directories of cards whose links have already been looked at.
+func (node *FSBoard) graph() (*boardGraph, error) {
+
+	board := node.Board
+	lists, err := board.GetLists(node.api())
+	if err != nil {
+		return nil, err
+	}
+	cards, err := board.GetCards(node.api())
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &boardGraph{
+		name:        board.Name,
+		lists:       lists,
+		cards:       make(map[string][]trello.Card),
+		links:       make(map[string][]string),
+		byShortLink: make(map[string]string),
+	}
+	for _, card := range cards {
+		graph.cards[card.ListID] = append(graph.cards[card.ListID], card)
+		graph.byShortLink[card.ShortLink] = card.ID
+	}
+
+	node.Lock()
+	cardNodes := make(map[string]*FSCard)
+	for id, card := range node.ByCardID {
+		cardNodes[id] = card
+	}
+	node.Unlock()
+
+	for _, card := range cards {
+		seen := map[string]bool{card.ShortLink: true}
+		add := func(shortLink string) {
+			if !seen[shortLink] {
+				seen[shortLink] = true
+				graph.links[card.ID] = append(graph.links[card.ID], shortLink)
+			}
+		}
+		for _, match := range cardLinkRE.FindAllStringSubmatch(card.Desc, -1) {
+			add(match[1])
+		}
+		if cardNode, exists := cardNodes[card.ID]; exists {
+			cardNode.Lock()
+			linksDir := cardNode.Links
+			cardNode.Unlock()
+			if linksDir != nil {
+				linksDir.Lock()
+				for _, link := range linksDir.Links {
+					add(link.GetName())
+				}
+				linksDir.Unlock()
+			}
+		}
+	}
+	return graph, nil
+}
+
+func cardLabels(card *trello.Card) string {
+	var names []string
+	for _, label := range card.Labels {
+		name := label.Name
+		if name == "" {
+			name = label.Color
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func dotQuote(str string) string {
+	return `"` + strings.NewReplacer(
+		`\`, `\\`, `"`, `\"`, "\n", `\n`,
+	).Replace(str) + `"`
+}
+
+// Render the board's graph in Graphviz's DOT language, one cluster per list.
+func (node *FSBoard) graphDot() ([]byte, error) {
+	graph, err := node.graph()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "digraph %s {\n\trankdir=LR;\n", dotQuote(graph.name))
+	for _, list := range graph.lists {
+		fmt.Fprintf(
+			&buf, "\tsubgraph %s {\n\t\tlabel=%s;\n",
+			dotQuote("cluster_"+list.ID), dotQuote(list.Name),
+		)
+		for _, card := range graph.cards[list.ID] {
+			label := card.Name
+			if labels := cardLabels(&card); labels != "" {
+				label += "\n[" + labels + "]"
+			}
+			fmt.Fprintf(
+				&buf, "\t\t%s [shape=box, label=%s];\n",
+				dotQuote(card.ID), dotQuote(label),
+			)
+		}
+		buf.WriteString("\t}\n")
+	}
+	for _, list := range graph.lists {
+		for _, card := range graph.cards[list.ID] {
+			for _, shortLink := range graph.links[card.ID] {
+				target, onBoard := graph.byShortLink[shortLink]
+				if !onBoard {
+					target = shortLink
+					fmt.Fprintf(
+						&buf, "\t%s [shape=plaintext];\n", dotQuote(target),
+					)
+				}
+				fmt.Fprintf(
+					&buf, "\t%s -> %s;\n", dotQuote(card.ID), dotQuote(target),
+				)
+			}
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
+
+func mermaidQuote(str string) string {
+	return `"` + strings.ReplaceAll(str, `"`, "#quot;") + `"`
+}
+
+// Render the board's graph as a Mermaid flowchart, one subgraph per list.
+func (node *FSBoard) graphMermaid() ([]byte, error) {
+	graph, err := node.graph()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("flowchart LR\n")
+	for _, list := range graph.lists {
+		fmt.Fprintf(
+			&buf, "  subgraph %s [%s]\n", list.ID, mermaidQuote(list.Name),
+		)
+		for _, card := range graph.cards[list.ID] {
+			label := card.Name
+			if labels := cardLabels(&card); labels != "" {
+				label += "<br/>[" + labels + "]"
+			}
+			fmt.Fprintf(&buf, "    %s[%s]\n", card.ID, mermaidQuote(label))
+		}
+		buf.WriteString("  end\n")
+	}
+	for _, list := range graph.lists {
+		for _, card := range graph.cards[list.ID] {
+			for _, shortLink := range graph.links[card.ID] {
+				target, onBoard := graph.byShortLink[shortLink]
+				if !onBoard {
+					target = "ext_" + shortLink
+					fmt.Fprintf(&buf, "  %s(%s)\n", target, shortLink)
+				}
+				fmt.Fprintf(&buf, "  %s --> %s\n", card.ID, target)
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
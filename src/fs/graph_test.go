@@ -0,0 +1,77 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Boards' graphs hold their lists and cards, and the links between cards,
+// whether to cards on the board or elsewhere.
+func TestBoardGraph(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/boards/b1/lists": `[
+			{"id": "l1", "name": "To do"},
+			{"id": "l2", "name": "Done"}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Say \"hi\"", "shortLink": "aa",
+				"idList": "l1", "labels": [{"name": "bug"}, {"color": "red"}],
+				"desc": "After trello.com/c/bb and trello.com/c/zz"},
+			{"id": "c2", "name": "Setup", "shortLink": "bb", "idList": "l2"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+
+	dot, err := board.graphDot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph "Roadmap" {
+	rankdir=LR;
+	subgraph "cluster_l1" {
+		label="To do";
+		"c1" [shape=box, label="Say \"hi\"\n[bug, red]"];
+	}
+	subgraph "cluster_l2" {
+		label="Done";
+		"c2" [shape=box, label="Setup"];
+	}
+	"c1" -> "c2";
+	"zz" [shape=plaintext];
+	"c1" -> "zz";
+}
+`
+	if string(dot) != want {
+		t.Errorf("graph.dot:\n%s\nwant:\n%s", dot, want)
+	}
+
+	mermaid, err := board.graphMermaid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `flowchart LR
+  subgraph l1 ["To do"]
+    c1["Say #quot;hi#quot;<br/>[bug, red]"]
+  end
+  subgraph l2 ["Done"]
+    c2["Setup"]
+  end
+  c1 --> c2
+  ext_zz(zz)
+  c1 --> ext_zz
+`
+	if string(mermaid) != want {
+		t.Errorf("graph.mmd:\n%s\nwant:\n%s", mermaid, want)
+	}
+}
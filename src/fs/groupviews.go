@@ -0,0 +1,163 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jacobsa/fuse"
+)
+
+// A board's 'views/labels', 'views/colors', or 'views/members' directory,
+// holding a view per label, label color, or member, found on the board's
+// cards, with symlinks to the cards carrying the label, a label of the color,
+// or assigned to the member. Labels without a name are named after their
+// color, and those without a color are under the 'none' color.
+type FSBoardGroupViews struct {
+	BaseFSNode
+
+	BoardNode *FSBoard
+
+	// obtain the groups a card belongs to, e.g. its labels.
+	groups func(card *queryCard) []string
+
+	Views   []*FSBoardView
+	byGroup map[string]*FSBoardView
+}
+
+func newBoardGroupViews(
+	views *FSBoardViewsDir,
+	name string,
+	groups func(card *queryCard) []string,
+) *FSBoardGroupViews {
+	return &FSBoardGroupViews{
+		BaseFSNode: BaseFSNode{
+			name:      name,
+			uid:       views.uid,
+			gid:       views.gid,
+			NodeAttrs: views.GetNodeAttrs(),
+			isDir:     true,
+			TrelloID:  fmt.Sprintf("%s/%s", views.GetTrelloID(), name),
+			Ctx:       views.Ctx,
+			parent:    views,
+		},
+		BoardNode: views.BoardNode,
+		groups:    groups,
+		byGroup:   make(map[string]*FSBoardView),
+	}
+}
+
+func newBoardLabelViews(views *FSBoardViewsDir) *FSBoardGroupViews {
+	return newBoardGroupViews(
+		views, "labels",
+		func(card *queryCard) []string { return card.labels },
+	)
+}
+
+func newBoardColorViews(views *FSBoardViewsDir) *FSBoardGroupViews {
+	return newBoardGroupViews(
+		views, "colors",
+		func(card *queryCard) []string { return card.colors },
+	)
+}
+
+func newBoardMemberViews(views *FSBoardViewsDir) *FSBoardGroupViews {
+	return newBoardGroupViews(
+		views, "members",
+		func(card *queryCard) []string { return card.members },
+	)
+}
+
+func (node *FSBoardGroupViews) ShouldUpdate() bool {
+	return node.shouldUpdate(60.0)
+}
+
+// Groups are found on the cards already known, which are refreshed first if
+// due. Each group's view then keeps its own cards up to date.
+func (node *FSBoardGroupViews) Update() ([]FSNode, []FSNode, error) {
+	boardNode := node.BoardNode
+	node.tree.refresh(boardNode.MetaCardsDir)
+	node.tree.refresh(boardNode.MetaMembers)
+
+	found := make(map[string]bool)
+	for _, card := range boardNode.queryCards() {
+		for _, group := range node.groups(card) {
+			found[group] = true
+		}
+	}
+	var groups []string
+	for group := range found {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	node.Lock()
+	defer node.Unlock()
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+	var views []*FSBoardView
+	byGroup := make(map[string]*FSBoardView)
+	for _, group := range groups {
+		group := group
+		view, exists := node.byGroup[group]
+		if !exists {
+			view = newBoardView(
+				node, boardNode, group,
+				func(card *queryCard, now time.Time) bool {
+					for _, g := range node.groups(card) {
+						if g == group {
+							return true
+						}
+					}
+					return false
+				},
+			)
+			newNodes = append(newNodes, view)
+		}
+		byGroup[group] = view
+		views = append(views, view)
+	}
+	for group, view := range node.byGroup {
+		if _, exists := byGroup[group]; !exists {
+			rmNodes = append(rmNodes, view)
+		}
+	}
+	node.byGroup = byGroup
+	node.Views = views
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSBoardGroupViews) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, view := range node.Views {
+		if view.GetName() == name {
+			return view, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSBoardGroupViews) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Views))
+	for i, view := range node.Views {
+		entries[i] = view
+	}
+	return entries
+}
@@ -0,0 +1,159 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Events hook commands may be run on, as observed when refreshing boards'
+// cards.
+const (
+	hookCardCreated = "cardCreated"
+	hookCardMoved   = "cardMoved"
+	hookCardDueSoon = "cardDueSoon"
+)
+
+type hookEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type hookCard struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Desc      string `json:"desc"`
+	ShortLink string `json:"shortLink"`
+	URL       string `json:"url"`
+	Due       string `json:"due"`
+}
+
+// What hook commands are given on their standard input, as JSON.
+type hookEvent struct {
+	Event      string      `json:"event"`
+	Board      hookEntity  `json:"board"`
+	List       hookEntity  `json:"list"`
+	ListBefore *hookEntity `json:"listBefore,omitempty"`
+	Card       hookCard    `json:"card"`
+}
+
+// What a board's cards were like when last refreshed, to tell what changed.
+type hookState struct {
+	loaded  bool
+	lists   map[string]string // list IDs, by card ID
+	dueSoon map[string]string // due dates already reported, by card ID
+}
+
+// Run the commands configured for an event, in the background, through the
+// shell. The event's name is also passed on in $TRELLOFS_EVENT.
+func (tree *Tree) runHooks(event *hookEvent) {
+	commands := tree.opts.Hooks[event.Event]
+	if len(commands) == 0 {
+		return
+	}
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	log.Printf(
+		"hooks > %s for card %s (%s)\n",
+		event.Event, event.Card.Name, event.Card.ID,
+	)
+	for _, command := range commands {
+		go func(command string) {
+			cmd := exec.Command("sh", "-c", command)
+			cmd.Stdin = bytes.NewReader(eventJSON)
+			cmd.Env = append(os.Environ(), "TRELLOFS_EVENT="+event.Event)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				log.Printf(
+					"hooks > error running '%s': %s: %s\n",
+					command, err, bytes.TrimSpace(out),
+				)
+			}
+		}(command)
+	}
+}
+
+// Run hooks for whatever changed since the board's cards were last
+// refreshed. Nothing is reported on the first refresh, which sets the
+// baseline. Called with the cards dir's lock held.
+func (node *FSBoardCardsDirMeta) detectEvents(cards []trello.Card) {
+	if len(node.tree.opts.Hooks) == 0 {
+		return
+	}
+	state := &node.hooks
+	if state.lists == nil {
+		state.lists = make(map[string]string)
+		state.dueSoon = make(map[string]string)
+	}
+
+	board := node.BoardNode.Board
+	var listNames map[string]string
+	listName := func(id string) hookEntity {
+		if listNames == nil {
+			listNames = make(map[string]string)
+			lists, _ := board.GetLists(node.api())
+			for _, list := range lists {
+				listNames[list.ID] = list.Name
+			}
+		}
+		return hookEntity{ID: id, Name: listNames[id]}
+	}
+	newEvent := func(name string, card *trello.Card) *hookEvent {
+		return &hookEvent{
+			Event: name,
+			Board: hookEntity{ID: board.ID, Name: board.Name},
+			List:  listName(card.ListID),
+			Card: hookCard{
+				ID:        card.ID,
+				Name:      card.Name,
+				Desc:      card.Desc,
+				ShortLink: card.ShortLink,
+				URL:       card.URL,
+				Due:       card.Due,
+			},
+		}
+	}
+
+	dueSoon := time.Now().Add(node.tree.opts.DueSoon)
+	lists := make(map[string]string)
+	for i := range cards {
+		card := &cards[i]
+		lists[card.ID] = card.ListID
+		listID, known := state.lists[card.ID]
+		if state.loaded && !known {
+			node.tree.runHooks(newEvent(hookCardCreated, card))
+		} else if state.loaded && listID != card.ListID {
+			event := newEvent(hookCardMoved, card)
+			before := listName(listID)
+			event.ListBefore = &before
+			node.tree.runHooks(event)
+		}
+
+		due, err := time.Parse(time.RFC3339, card.Due)
+		if err != nil || card.DueComplete || due.After(dueSoon) ||
+			state.dueSoon[card.ID] == card.Due {
+			continue
+		}
+		state.dueSoon[card.ID] = card.Due
+		if due.After(time.Now()) {
+			node.tree.runHooks(newEvent(hookCardDueSoon, card))
+		}
+	}
+	state.lists = lists
+	state.loaded = true
+}
@@ -0,0 +1,109 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Hooks are run on cards created, moved, or soon due, as seen between
+// refreshes of their board's cards, each given the event as JSON.
+func TestCardHooks(t *testing.T) {
+	soon := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	dir := withFixtures(t, map[string]string{
+		"/boards/b1/lists": `[
+			{"id": "l1", "name": "To do"},
+			{"id": "l2", "name": "Done"}
+		]`,
+		"/boards/b1/cards": fmt.Sprintf(`[
+			{"id": "c1", "name": "Fix", "idList": "l1"},
+			{"id": "c2", "name": "Ship", "idList": "l1", "due": %q}
+		]`, soon),
+	})
+	events := t.TempDir()
+	// events are written whole, then renamed, to be found complete.
+	command := fmt.Sprintf(
+		`f=$(mktemp -p %s); cat > $f; mv $f $f.json`, events,
+	)
+	tree := newTestTree(t, Options{
+		DueSoon: 24 * time.Hour,
+		Hooks: map[string][]string{
+			hookCardCreated: {command},
+			hookCardMoved:   {command},
+			hookCardDueSoon: {command},
+		},
+	})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	cards := board.MetaCardsDir
+	if err := <-listing(tree, cards); err != nil {
+		t.Fatal(err)
+	}
+	err := ioutil.WriteFile(
+		filepath.Join(dir, "boards-b1-cards.json"), []byte(fmt.Sprintf(`[
+			{"id": "c1", "name": "Fix", "idList": "l2"},
+			{"id": "c2", "name": "Ship", "idList": "l1", "due": %q},
+			{"id": "c3", "name": "Docs", "idList": "l1"}
+		]`, soon)), 0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.lock.Lock()
+	cards.markStale()
+	tree.lock.Unlock()
+	if err := <-listing(tree, cards); err != nil {
+		t.Fatal(err)
+	}
+
+	var files []string
+	for deadline := time.Now().Add(5 * time.Second); len(files) < 3; {
+		if time.Now().After(deadline) {
+			t.Fatalf("hooks run for %d events, want 3", len(files))
+		}
+		time.Sleep(10 * time.Millisecond)
+		files, _ = filepath.Glob(filepath.Join(events, "*.json"))
+	}
+	var got []string
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var event hookEvent
+		if err := json.Unmarshal(contents, &event); err != nil {
+			t.Fatal(err)
+		}
+		summary := fmt.Sprintf(
+			"%s %s in %s", event.Event, event.Card.Name, event.List.Name,
+		)
+		if event.ListBefore != nil {
+			summary += " from " + event.ListBefore.Name
+		}
+		got = append(got, summary)
+	}
+	sort.Strings(got)
+	want := []string{
+		"cardCreated Docs in To do",
+		"cardDueSoon Ship in To do",
+		"cardMoved Fix in Done from To do",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events:\n%q\nwant:\n%q", got, want)
+	}
+}
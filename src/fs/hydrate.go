@@ -0,0 +1,75 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"log"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Boards are hydrated as they're first loaded: their open lists and cards
+// are obtained in one request, through trello.Board.GetFull(), and handed
+// to their 'cards' and 'lists' directories, and on to their lists, for
+// their first update, rather than have each ask for its own. What's handed
+// over is only used if still fresh by then.
+const hydrationTTL = 30 * time.Second
+
+type hydration struct {
+	at    time.Time
+	lists []trello.List
+	cards []trello.Card
+}
+
+// Obtain what was handed over, if anything, unless gone stale, and forget
+// about it either way.
+func takeHydration(h **hydration) *hydration {
+	taken := *h
+	*h = nil
+	if taken == nil || time.Since(taken.at) > hydrationTTL {
+		return nil
+	}
+	return taken
+}
+
+// The cards on a list, out of those handed over.
+func (h *hydration) forList(listID string) *hydration {
+	cards := make([]trello.Card, 0)
+	for _, card := range h.cards {
+		if card.ListID == listID {
+			cards = append(cards, card)
+		}
+	}
+	return &hydration{at: h.at, cards: cards}
+}
+
+// Obtain the board's lists and cards, handing them to its 'cards' and
+// 'lists' directories. Failing to is no matter, as they may still obtain
+// them on their own. Must be called with the board's lock held.
+func (node *FSBoard) hydrate() {
+	full, err := node.Board.GetFull(node.api())
+	if err != nil {
+		log.Printf(
+			"error hydrating board %s (%s): %s\n",
+			node.GetName(), node.GetTrelloID(), err,
+		)
+		return
+	} else if full.Lists == nil || full.Cards == nil {
+		return
+	}
+	log.Printf(
+		"hydrated board %s (%s): %d lists, %d cards\n",
+		node.GetName(), node.GetTrelloID(), len(full.Lists), len(full.Cards),
+	)
+	h := &hydration{at: time.Now(), lists: full.Lists, cards: full.Cards}
+	node.MetaCardsDir.hydrated = &hydration{at: h.at, cards: h.cards}
+	node.MetaListsDir.hydrated = h
+}
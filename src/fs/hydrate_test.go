@@ -0,0 +1,91 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// A board's lists and cards, and its lists' cards, are obtained along with
+// the board as it's first loaded, with none of them asking for their own,
+// however many are listed at once, in whatever order.
+func TestHydrateBoard(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		// with neither '/boards/b1/cards', '/boards/b1/lists' nor
+		// '/lists/<list>/cards' to be obtained.
+		"/boards/b1": `{"id": "b1", "name": "Roadmap",
+			"lists": [
+				{"id": "l1", "name": "To do"},
+				{"id": "l2", "name": "Doing"}
+			],
+			"cards": [
+				{"id": "c1", "name": "Fix", "idList": "l1"},
+				{"id": "c2", "name": "Ship", "idList": "l2"},
+				{"id": "c3", "name": "Test", "idList": "l2"}
+			]
+		}`,
+	})
+	tree := newTestTree(t, Options{})
+	lookupPath(t, tree, "eng/Roadmap")
+
+	want := map[string][]string{
+		"cards":       {"Fix", "Ship", "Test"},
+		"lists":       {"Doing", "To do"},
+		"lists/To do": {"Fix"},
+		"lists/Doing": {"Ship", "Test"},
+	}
+	dirs := map[string]FSNode{}
+	for _, path := range []string{"cards", "lists"} {
+		dirs[path] = lookupPath(t, tree, "eng/Roadmap/"+path)
+	}
+	// the lists' directories are only known once their board's lists are.
+	if _, err := tree.Entries(
+		context.Background(), dirs["lists"].GetNodeID(),
+	); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"lists/To do", "lists/Doing"} {
+		dirs[path] = lookupPath(t, tree, "eng/Roadmap/"+path)
+	}
+
+	var wg sync.WaitGroup
+	for path, dir := range dirs {
+		wg.Add(1)
+		go func(path string, dir FSNode) {
+			defer wg.Done()
+			entries, err := tree.Entries(
+				context.Background(), dir.GetNodeID(),
+			)
+			if err != nil {
+				t.Errorf("%s: %s", path, err)
+				return
+			}
+			var names []string
+			for _, entry := range entries {
+				if entry.GetName()[0] != '.' {
+					names = append(names, entry.GetName())
+				}
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, want[path]) {
+				t.Errorf("%s listed as %q, want %q", path, names, want[path])
+			}
+		}(path, dir)
+	}
+	wg.Wait()
+}
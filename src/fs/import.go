@@ -0,0 +1,148 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Creates cards on a list from CSV written to the list's '.import.csv', one
+// card per row:
+//
+//	name,desc,due,labels
+//
+// Only 'name' is required. 'labels' is a ';'-separated list of label names,
+// colors or IDs. A first row starting with 'name' is taken as a header.
+type csvImporter struct {
+	job
+
+	list *FSList
+}
+
+func (imp *csvImporter) submit(data []byte) error {
+
+	if err := imp.start(); err != nil {
+		log.Printf("import > refusing csv, another one is still running\n")
+		return err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		log.Printf("import > unable to parse csv: %s\n", err)
+		imp.reportf("error\t%s\n", err)
+		imp.finish()
+		return err
+	}
+	if len(rows) > 0 && len(rows[0]) > 0 &&
+		strings.EqualFold(strings.TrimSpace(rows[0][0]), "name") {
+		rows = rows[1:]
+	}
+	go imp.run(rows)
+	return nil
+}
+
+func (imp *csvImporter) run(rows [][]string) {
+
+	list := imp.list.List
+	ctx := imp.list.Ctx
+
+	var labels []trello.Label
+	err := paced(func() error {
+		var err error
+		labels, err = list.Board.GetLabels(ctx)
+		return err
+	})
+	if err != nil {
+		imp.reportf("error\tunable to obtain labels: %s\n", err)
+		imp.finish()
+		return
+	}
+
+	failed := 0
+	for i, row := range rows {
+		card, err := imp.create(row, labels)
+		if err != nil {
+			failed++
+			imp.reportf("%d\terror\t%s\n", i+1, err)
+		} else {
+			imp.reportf("%d\tok\t%s\n", i+1, card.ID)
+		}
+	}
+	// stale before done, for those waiting on the report to find the cards.
+	imp.list.Lock()
+	imp.list.markStale()
+	imp.list.Unlock()
+
+	imp.reportf("done\t%d ok\t%d failed\n", len(rows)-failed, failed)
+	imp.finish()
+}
+
+func (imp *csvImporter) create(
+	row []string,
+	labels []trello.Label,
+) (*trello.Card, error) {
+
+	field := func(i int) string {
+		if i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	name := field(0)
+	if name == "" {
+		return nil, fmt.Errorf("missing name")
+	}
+
+	fields := url.Values{}
+	if desc := field(1); desc != "" {
+		fields.Set("desc", desc)
+	}
+	if due := field(2); due != "" {
+		fields.Set("due", due)
+	}
+	var labelIDs []string
+	for _, want := range strings.Split(field(3), ";") {
+		if want = strings.TrimSpace(want); want == "" {
+			continue
+		}
+		id := ""
+		for _, label := range labels {
+			if want == label.ID || want == label.Name ||
+				(label.Name == "" && want == label.Color) {
+				id = label.ID
+				break
+			}
+		}
+		if id == "" {
+			return nil, fmt.Errorf("unknown label '%s'", want)
+		}
+		labelIDs = append(labelIDs, id)
+	}
+	if len(labelIDs) > 0 {
+		fields.Set("idLabels", strings.Join(labelIDs, ","))
+	}
+
+	var card *trello.Card
+	err := paced(func() error {
+		var err error
+		card, err = imp.list.List.CreateCard(imp.list.Ctx, name, fields)
+		return err
+	})
+	return card, err
+}
@@ -0,0 +1,192 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Wait for a job run in the background to be done, returning its report.
+func jobReport(tb testing.TB, result func() ([]byte, error)) string {
+	tb.Helper()
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		report, err := result()
+		if err != nil {
+			tb.Fatal(err)
+		}
+		if strings.Contains(string(report), "done\t") {
+			return string(report)
+		}
+		if time.Now().After(deadline) {
+			tb.Fatalf("job not done, reported:\n%s", report)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Rows written to a list's '.import.csv' are created as cards on it, and
+// how each went is reported in '.import.result'.
+func TestListImportCSV(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/boards/b1/labels": `[
+			{"id": "lb1", "name": "bug", "color": "red"},
+			{"id": "lb2", "name": "", "color": "green"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	list := &FSList{
+		BaseFSNode: BaseFSNode{
+			name: "To do", TrelloID: "l1", parent: board, isDir: true,
+			Ctx: tree.ctx,
+		},
+		List:      &trello.List{ID: "l1", Name: "To do", Board: board.Board},
+		BoardNode: board,
+	}
+	list.markUpdated()
+	importer := &csvImporter{list: list}
+
+	err := importer.submit([]byte(
+		"name,desc,due,labels\n" +
+			"Fix crash,It crashes,2022-10-01,bug;green\n" +
+			",no name\n" +
+			"Typo,,,docs\n" +
+			"Plain\n",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1\tok\t\n" +
+		"2\terror\tmissing name\n" +
+		"3\terror\tunknown label 'docs'\n" +
+		"4\tok\t\n" +
+		"done\t2 ok\t2 failed\n"
+	if report := jobReport(t, importer.result); report != want {
+		t.Errorf("report:\n%s\nwant:\n%s", report, want)
+	}
+	requests := []string{
+		"POST /cards?desc=It+crashes&due=2022-10-01&idLabels=lb1%2Clb2" +
+			"&idList=l1&name=Fix+crash",
+		"POST /cards?idList=l1&name=Plain",
+	}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+	if list.isLoaded() {
+		t.Errorf("list not marked stale once imported into")
+	}
+
+	if err := importer.submit([]byte("\"unterminated\n")); err == nil {
+		t.Errorf("malformed csv accepted")
+	}
+	if err := importer.start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := importer.submit([]byte("Busy\n")); err != syscall.EBUSY {
+		t.Errorf("csv imported while another import runs: %v", err)
+	}
+	importer.finish()
+}
+
+// Board exports written to a workspace's '.import' are recreated as new
+// boards, with their open lists and cards, labels and checklists, the
+// import's progress reported next to the export.
+func TestWorkspaceImportBoard(t *testing.T) {
+	dir := withFixtures(t, nil)
+	tree := newTestTree(t, Options{})
+	ws := &FSWorkspace{
+		BaseFSNode: BaseFSNode{
+			name: "eng", TrelloID: "w1", isDir: true, Ctx: tree.ctx,
+		},
+		Workspace: &trello.Workspace{ID: "w1"},
+	}
+	ws.markUpdated()
+	imports := newWorkspaceImportDir(ws)
+	addNodes(tree, ws, imports)
+	ctx := context.Background()
+
+	export, err := tree.Create(ctx, imports.GetNodeID(), "roadmap.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tree.Create(ctx, imports.GetNodeID(), "roadmap.json")
+	if err != syscall.EEXIST {
+		t.Errorf("export created twice: %v", err)
+	}
+	_, err = tree.Create(ctx, imports.GetNodeID(), "other.progress")
+	if err != syscall.EPERM {
+		t.Errorf("progress file created: %v", err)
+	}
+	progress, err := tree.Lookup(
+		ctx, imports.GetNodeID(), "roadmap.json.progress",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writeFile(t, tree, export, `{
+		"name": "Roadmap",
+		"desc": "What's next",
+		"labels": [{"id": "lb1", "name": "bug", "color": "red"}],
+		"lists": [
+			{"id": "l2", "name": "Done", "pos": 2},
+			{"id": "l1", "name": "To do", "pos": 1},
+			{"id": "l3", "name": "Old", "closed": true}
+		],
+		"cards": [
+			{"id": "c1", "name": "Fix", "idList": "l1", "idLabels": ["lb1"]},
+			{"id": "c2", "name": "Gone", "idList": "l1", "closed": true},
+			{"id": "c3", "name": "Lost", "idList": "l3"}
+		],
+		"checklists": [{
+			"id": "k1", "name": "Steps", "idCard": "c1",
+			"checkItems": [{"id": "i1", "name": "Test", "state": "complete"}]
+		}]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := jobReport(t, func() ([]byte, error) {
+		return []byte(readFile(t, tree, progress)), nil
+	})
+	want := "board\tok\t\n" +
+		"labels\t1/1\n" +
+		"lists\t2/2\n" +
+		"cards\t1/1\n" +
+		"checklists\t1/1\n" +
+		"done\t0 failed\n"
+	if report != want {
+		t.Errorf("progress:\n%s\nwant:\n%s", report, want)
+	}
+	// objects created in test mode have no IDs to refer to them by.
+	requests := []string{
+		"POST /boards?defaultLabels=false&defaultLists=false" +
+			"&desc=What%27s+next&idOrganization=w1&name=Roadmap",
+		"POST /labels?color=red&idBoard=&name=bug",
+		"POST /lists?idBoard=&name=To+do&pos=bottom",
+		"POST /lists?idBoard=&name=Done&pos=bottom",
+		"POST /cards?idLabels=&idList=&name=Fix",
+		"POST /checklists?idCard=&name=Steps",
+		"POST /checklists//checkItems?checked=true&name=Test",
+	}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+	if ws.isLoaded() {
+		t.Errorf("workspace not marked stale once imported into")
+	}
+}
@@ -0,0 +1,249 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// The '.inbox' directory. Files dropped into it become cards in the list
+// set in Options.InboxList, named after the file and described by its
+// contents, once written to and closed for good; the file then goes away.
+// Files whose card couldn't be created are kept, so their contents aren't
+// lost.
+type FSInboxDir struct {
+	BaseFSNode
+
+	list  *trello.List
+	files []*FSInboxFile
+}
+
+// A file in the inbox, buffered until no longer open.
+type FSInboxFile struct {
+	BaseFSNode
+
+	inbox   *FSInboxDir
+	buffer  []byte
+	written bool
+}
+
+func newInboxDir(tree *Tree, root FSNode) *FSInboxDir {
+	return &FSInboxDir{
+		BaseFSNode: BaseFSNode{
+			name: ".inbox",
+			uid:  tree.uid,
+			gid:  tree.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  tree.uid,
+				Gid:  tree.gid,
+			},
+			isDir:    true,
+			TrelloID: "_inbox",
+			Ctx:      tree.ctx,
+			parent:   root,
+		},
+		list: &trello.List{ID: tree.opts.InboxList},
+	}
+}
+
+func (node *FSInboxDir) ShouldUpdate() bool {
+	return false
+}
+
+func (node *FSInboxDir) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, nil
+}
+
+func (node *FSInboxDir) Create(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, file := range node.files {
+		if file.GetName() == name {
+			return nil, syscall.EEXIST
+		}
+	}
+	file := &FSInboxFile{
+		BaseFSNode: BaseFSNode{
+			name: name,
+			uid:  node.uid,
+			gid:  node.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode:  0600,
+				Nlink: 1,
+				Uid:   node.uid,
+				Gid:   node.gid,
+			},
+			isDir:    false,
+			TrelloID: node.GetTrelloID() + "/" + name,
+			Ctx:      node.Ctx,
+			parent:   node,
+		},
+		inbox: node,
+	}
+	node.files = append(node.files, file)
+	return file, nil
+}
+
+// File a card for the given file, removing it from the inbox.
+func (node *FSInboxDir) file(file *FSInboxFile, contents []byte) error {
+	name := strings.TrimSpace(unescapeName(file.GetName()))
+	if ext := strings.LastIndex(name, "."); ext > 0 {
+		name = name[:ext]
+	}
+	card, err := node.list.CreateCard(
+		node.api(), name, url.Values{"desc": {string(contents)}},
+	)
+	if err != nil {
+		return apiErrno(err)
+	}
+	log.Printf("inbox > filed %s as card %s\n", file.GetName(), card.ID)
+	node.remove(file)
+	return nil
+}
+
+// Remove a file from the inbox, and from the tree, so it's no longer found
+// by its inode either. Must be called with the updates lock held, but not
+// the tree's.
+func (node *FSInboxDir) remove(file *FSInboxFile) {
+	node.Lock()
+	for i, entry := range node.files {
+		if entry == file {
+			node.files = append(node.files[:i], node.files[i+1:]...)
+			break
+		}
+	}
+	node.Unlock()
+
+	node.tree.lock.Lock()
+	defer node.tree.lock.Unlock()
+	node.tree.removeNode(file)
+}
+
+// Discard a file, e.g. one that couldn't be filed.
+func (node *FSInboxDir) Unlink(name string) error {
+	file, err := node.LookupChild(name)
+	if err != nil {
+		return err
+	}
+	node.remove(file.(*FSInboxFile))
+	return nil
+}
+
+func (node *FSInboxDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, file := range node.files {
+		if file.GetName() == name {
+			return file, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSInboxDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.files))
+	for i, file := range node.files {
+		entries[i] = file
+	}
+	return entries
+}
+
+func (node *FSInboxFile) ShouldUpdate() bool {
+	return false
+}
+
+func (node *FSInboxFile) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, nil
+}
+
+func (node *FSInboxFile) LookupChild(name string) (FSNode, error) {
+	return nil, fuse.ENOENT
+}
+
+func (node *FSInboxFile) GetEntries() []FSNode {
+	return nil
+}
+
+func (node *FSInboxFile) ReadAt(dst []byte, offset int64) (int, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if offset > int64(len(node.buffer)) {
+		return 0, io.EOF
+	}
+	n := copy(dst, node.buffer[offset:])
+	if n < len(dst) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (node *FSInboxFile) WriteAt(data []byte, offset int64) (int, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	end := int(offset) + len(data)
+	if end > len(node.buffer) {
+		buffer := make([]byte, end)
+		copy(buffer, node.buffer)
+		node.buffer = buffer
+	}
+	copy(node.buffer[offset:], data)
+	node.setSize(uint64(len(node.buffer)))
+	node.written = true
+	return len(data), nil
+}
+
+func (node *FSInboxFile) Truncate(size uint64) error {
+	node.Lock()
+	defer node.Unlock()
+
+	if size > uint64(len(node.buffer)) {
+		buffer := make([]byte, size)
+		copy(buffer, node.buffer)
+		node.buffer = buffer
+	} else {
+		node.buffer = node.buffer[:size]
+	}
+	node.setSize(size)
+	return nil
+}
+
+// Files are filed once no longer open, rather than when flushed, so that
+// closing one of several descriptors for a file doesn't file it before it's
+// fully written. Files never written to, e.g. as created by 'touch', are
+// left be.
+func (node *FSInboxFile) Release() error {
+	node.Lock()
+	written := node.written
+	contents := append([]byte{}, node.buffer...)
+	node.Unlock()
+
+	if !written {
+		return nil
+	}
+	return node.inbox.file(node, contents)
+}
@@ -0,0 +1,73 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// Files dropped into '.inbox', several at once, become cards named after
+// them, and go away once closed; files only created are left be.
+func TestInbox(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"POST /cards": `{"id": "c1"}`,
+	})
+	tree := newTestTree(t, Options{InboxList: "l1"})
+	inbox := lookupPath(t, tree, ".inbox")
+	ctx := context.Background()
+
+	if _, err := tree.Create(ctx, inbox.GetNodeID(), "empty"); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 1; i <= 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			file, err := tree.Create(
+				ctx, inbox.GetNodeID(), fmt.Sprintf("idea %d.txt", i),
+			)
+			if err == nil {
+				err = writeFile(t, tree, file, fmt.Sprintf("about %d", i))
+			}
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := tree.Entries(ctx, inbox.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.GetName())
+	}
+	if want := []string{"empty", ".refresh"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("inbox holds %q once filed, want %q", names, want)
+	}
+	got := requestsMade(t, dir)
+	sort.Strings(got)
+	requests := []string{
+		"POST /cards?desc=about+1&idList=l1&name=idea+1",
+		"POST /cards?desc=about+2&idList=l1&name=idea+2",
+		"POST /cards?desc=about+3&idList=l1&name=idea+3",
+	}
+	if !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+}
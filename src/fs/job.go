@@ -0,0 +1,88 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Trello allows 100 requests per 10 seconds per token; stay below that so
+// background jobs don't starve everything else.
+const jobRequestInterval = 150 * time.Millisecond
+const jobMaxRetries = 5
+
+// A job running in the background, e.g. bulk operations or imports, along
+// with a report of its progress. Only one run of a given job at a time.
+type job struct {
+	lock sync.Mutex
+
+	running bool
+	report  bytes.Buffer
+}
+
+// Mark the job as running, resetting its report.
+func (j *job) start() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.running {
+		return syscall.EBUSY
+	}
+	j.running = true
+	j.report.Reset()
+	return nil
+}
+
+func (j *job) finish() {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.running = false
+}
+
+func (j *job) reportf(format string, args ...interface{}) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	fmt.Fprintf(&j.report, format, args...)
+}
+
+func (j *job) result() ([]byte, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return append([]byte{}, j.report.Bytes()...), nil
+}
+
+// Perform a request, spacing requests out and backing off whenever Trello
+// reports we are being rate limited.
+func paced(request func() error) error {
+
+	backoff := time.Second
+	for retry := 0; ; retry++ {
+		time.Sleep(jobRequestInterval)
+		err := request()
+
+		var apiErr *trello.APIError
+		if !errors.As(err, &apiErr) || !apiErr.IsRateLimited() {
+			return err
+		} else if retry == jobMaxRetries {
+			return err
+		}
+		log.Printf("rate limited, retrying in %s\n", backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,234 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A board's 'labels/by-color' directory, holding a directory per label
+// color, with symlinks to the labels of that color. Labels without a color
+// are under 'none'.
+type FSLabelColorsDir struct {
+	BaseFSNode
+
+	LabelsNode *FSBoardLabelsDir
+
+	Colors  []*FSLabelColorDir
+	byColor map[string]*FSLabelColorDir
+}
+
+type FSLabelColorDir struct {
+	BaseFSNode
+
+	Links   []*FSSymlink
+	byLabel map[string]*FSSymlink
+}
+
+func newLabelColorsDir(labels *FSBoardLabelsDir) *FSLabelColorsDir {
+	return &FSLabelColorsDir{
+		BaseFSNode: BaseFSNode{
+			name: "by-color",
+			uid:  labels.uid,
+			gid:  labels.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0500 | os.ModeDir,
+				Uid:  labels.uid,
+				Gid:  labels.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/by-color", labels.GetTrelloID()),
+			Ctx:      labels.Ctx,
+			parent:   labels,
+		},
+		LabelsNode: labels,
+		byColor:    make(map[string]*FSLabelColorDir),
+	}
+}
+
+// The color a label is grouped under.
+func labelColor(color string) string {
+	if color == "" {
+		return "none"
+	}
+	return color
+}
+
+func (node *FSLabelColorsDir) ShouldUpdate() bool {
+	return node.shouldUpdate(60.0)
+}
+
+// Labels are grouped as already known, refreshed first if due.
+func (node *FSLabelColorsDir) Update() ([]FSNode, []FSNode, error) {
+	labels := node.LabelsNode
+	node.tree.refresh(labels)
+
+	// label files, by color.
+	byColor := make(map[string][]*FSGeneratedFile)
+	labels.Lock()
+	for _, file := range labels.Labels {
+		label, exists := labels.byLabel[file.GetTrelloID()]
+		if !exists {
+			continue
+		}
+		color := labelColor(label.Color)
+		byColor[color] = append(byColor[color], file)
+	}
+	labels.Unlock()
+
+	var colors []string
+	for color := range byColor {
+		colors = append(colors, color)
+	}
+	sort.Strings(colors)
+
+	node.Lock()
+	defer node.Unlock()
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+	var dirs []*FSLabelColorDir
+	dirsByColor := make(map[string]*FSLabelColorDir)
+	for _, color := range colors {
+		dir, exists := node.byColor[color]
+		if !exists {
+			dir = &FSLabelColorDir{
+				BaseFSNode: BaseFSNode{
+					name:      escapeName(color),
+					uid:       node.uid,
+					gid:       node.gid,
+					NodeAttrs: node.GetNodeAttrs(),
+					isDir:     true,
+					TrelloID: fmt.Sprintf(
+						"%s/%s", node.GetTrelloID(), color,
+					),
+					Ctx:    node.Ctx,
+					parent: node,
+				},
+				byLabel: make(map[string]*FSSymlink),
+			}
+			newNodes = append(newNodes, dir)
+		}
+		added, removed := dir.setLabels(byColor[color])
+		newNodes = append(newNodes, added...)
+		rmNodes = append(rmNodes, removed...)
+		dirsByColor[color] = dir
+		dirs = append(dirs, dir)
+	}
+	for color, dir := range node.byColor {
+		if _, exists := dirsByColor[color]; !exists {
+			rmNodes = append(rmNodes, dir)
+		}
+	}
+	node.byColor = dirsByColor
+	node.Colors = dirs
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+// Link to the color's labels, returning the links added and removed.
+func (node *FSLabelColorDir) setLabels(
+	files []*FSGeneratedFile,
+) ([]FSNode, []FSNode) {
+	node.Lock()
+	defer node.Unlock()
+
+	var added []FSNode
+	var removed []FSNode
+	var links []*FSSymlink
+	byLabel := make(map[string]*FSSymlink)
+	for _, file := range files {
+		target := "../../" + file.GetName()
+		link, exists := node.byLabel[file.GetTrelloID()]
+		if exists {
+			link.setTarget(target)
+		} else {
+			link = newSymlink(node, file.GetName(), target)
+			link.TrelloID = fmt.Sprintf(
+				"%s/%s", node.GetTrelloID(), file.GetTrelloID(),
+			)
+			added = append(added, link)
+		}
+		byLabel[file.GetTrelloID()] = link
+		links = append(links, link)
+	}
+	for id, link := range node.byLabel {
+		if _, exists := byLabel[id]; !exists {
+			removed = append(removed, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].GetName() < links[j].GetName()
+	})
+	node.byLabel = byLabel
+	node.Links = links
+	return added, removed
+}
+
+func (node *FSLabelColorsDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, dir := range node.Colors {
+		if dir.GetName() == name {
+			return dir, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSLabelColorsDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Colors))
+	for i, dir := range node.Colors {
+		entries[i] = dir
+	}
+	return entries
+}
+
+func (node *FSLabelColorDir) ShouldUpdate() bool {
+	return false
+}
+
+// Kept up to date by the 'by-color' directory.
+func (node *FSLabelColorDir) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, nil
+}
+
+func (node *FSLabelColorDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, link := range node.Links {
+		if link.GetName() == name {
+			return link, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSLabelColorDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Links))
+	for i, link := range node.Links {
+		entries[i] = link
+	}
+	return entries
+}
@@ -0,0 +1,223 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A board's labels, one file per label. Labels without a name are named
+// after their color.
+type FSBoardLabelsDir struct {
+	BaseFSNode
+
+	BoardNode *FSBoard
+
+	MetaUsage   *FSGeneratedFile
+	MetaByColor *FSLabelColorsDir
+
+	Labels  []*FSGeneratedFile
+	ByName  map[string]*FSGeneratedFile
+	byLabel map[string]*trello.Label
+}
+
+func newBoardLabelsDir(board *FSBoard) *FSBoardLabelsDir {
+	node := &FSBoardLabelsDir{
+		BaseFSNode: BaseFSNode{
+			name: "labels",
+			uid:  board.uid,
+			gid:  board.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  board.uid,
+				Gid:  board.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/labels", board.GetTrelloID()),
+			Ctx:      board.Ctx,
+			parent:   board,
+		},
+		BoardNode: board,
+		ByName:    make(map[string]*FSGeneratedFile),
+		byLabel:   make(map[string]*trello.Label),
+	}
+	node.MetaUsage = newGeneratedFile(node, ".usage", 30.0, node.usage)
+	node.MetaByColor = newLabelColorsDir(node)
+	return node
+}
+
+func labelName(template string, label *trello.Label) string {
+	name := label.Name
+	if name == "" {
+		name = label.Color
+	}
+	return colorName(template, label.Color, escapeName(name))
+}
+
+func (node *FSBoardLabelsDir) ShouldUpdate() bool {
+	return node.shouldUpdate(60.0)
+}
+
+func (node *FSBoardLabelsDir) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	board := node.BoardNode.Board
+	labels, err := board.GetLabels(node.api())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+	if node.MetaUsage.GetNodeID() == 0 {
+		newNodes = append(newNodes, node.MetaUsage)
+	}
+	if node.MetaByColor.GetNodeID() == 0 {
+		newNodes = append(newNodes, node.MetaByColor)
+	}
+
+	seen := make(map[string]bool)
+	for i := range labels {
+		label := &labels[i]
+		seen[label.ID] = true
+
+		if existing, exists := node.byLabel[label.ID]; exists {
+			*existing = *label
+			continue
+		}
+		name := labelName(node.tree.opts.ColorPrefix, label)
+		if _, exists := node.ByName[name]; exists {
+			name = fmt.Sprintf("%s (%s)", name, label.ID)
+		}
+		node.byLabel[label.ID] = label
+		file := newGeneratedFile(node, name, 60.0, func() ([]byte, error) {
+			return []byte(fmt.Sprintf(
+				"id: %s\nname: %s\ncolor: %s\n",
+				label.ID, label.Name, label.Color,
+			)), nil
+		})
+		file.TrelloID = label.ID
+		node.Labels = append(node.Labels, file)
+		node.ByName[name] = file
+		newNodes = append(newNodes, file)
+	}
+
+	var remaining []*FSGeneratedFile
+	for _, file := range node.Labels {
+		if seen[file.GetTrelloID()] {
+			remaining = append(remaining, file)
+			continue
+		}
+		delete(node.ByName, file.GetName())
+		delete(node.byLabel, file.GetTrelloID())
+		rmNodes = append(rmNodes, file)
+	}
+	node.Labels = remaining
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+// Generate the label usage report, from the board's cached cards: how many
+// open cards carry each label, most used first. One tab-separated line per
+// label:
+//
+//	<name>	<color>	<open cards>
+func (node *FSBoardLabelsDir) usage() ([]byte, error) {
+
+	boardNode := node.BoardNode
+	boardNode.Lock()
+	cards := append([]*FSCard{}, boardNode.Cards...)
+	boardNode.Unlock()
+
+	type labelUsage struct {
+		label trello.CardLabel
+		count int
+	}
+	var usages []*labelUsage
+	byID := make(map[string]*labelUsage)
+
+	node.Lock()
+	for _, label := range node.byLabel {
+		usage := &labelUsage{label: trello.CardLabel{
+			ID: label.ID, Name: label.Name, Color: label.Color,
+		}}
+		usages = append(usages, usage)
+		byID[label.ID] = usage
+	}
+	node.Unlock()
+
+	for _, card := range cards {
+		card.Lock()
+		if !card.Card.Closed {
+			for _, label := range card.Card.Labels {
+				usage, exists := byID[label.ID]
+				if !exists {
+					usage = &labelUsage{label: label}
+					usages = append(usages, usage)
+					byID[label.ID] = usage
+				}
+				usage.count++
+			}
+		}
+		card.Unlock()
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].count != usages[j].count {
+			return usages[i].count > usages[j].count
+		}
+		return usages[i].label.Name < usages[j].label.Name
+	})
+
+	var buf bytes.Buffer
+	for _, usage := range usages {
+		fmt.Fprintf(
+			&buf, "%s\t%s\t%d\n",
+			usage.label.Name, usage.label.Color, usage.count,
+		)
+	}
+	return buf.Bytes(), nil
+}
+
+func (node *FSBoardLabelsDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if name == ".usage" {
+		return node.MetaUsage, nil
+	} else if name == "by-color" {
+		return node.MetaByColor, nil
+	} else if file, exists := node.ByName[name]; exists {
+		return file, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSBoardLabelsDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	var entries []FSNode = make([]FSNode, 0, len(node.Labels)+2)
+	entries = append(entries, node.MetaUsage, node.MetaByColor)
+	for _, file := range node.Labels {
+		entries = append(entries, file)
+	}
+	return entries
+}
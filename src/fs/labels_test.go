@@ -0,0 +1,202 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// A board's labels are listed in its 'labels', nameless ones after their
+// color, along with how many open cards carry each in '.usage'.
+func TestBoardLabelsUsage(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/boards/b1/labels": `[
+			{"id": "l1", "name": "Bug", "color": "red"},
+			{"id": "l2", "name": "Feature", "color": "green"},
+			{"id": "l3", "name": "", "color": "blue"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	bug := trello.CardLabel{ID: "l1", Name: "Bug", Color: "red"}
+	blue := trello.CardLabel{ID: "l3", Color: "blue"}
+	for _, card := range []trello.Card{
+		{ID: "c1", Labels: []trello.CardLabel{bug, blue}},
+		{ID: "c2", Labels: []trello.CardLabel{bug}},
+		{ID: "c3", Labels: []trello.CardLabel{bug}, Closed: true},
+	} {
+		card := card
+		card.Name = card.ID
+		newTestCard(board, &card)
+	}
+	labels := newBoardLabelsDir(board)
+	addNodes(tree, labels)
+
+	entries, err := tree.Entries(context.Background(), labels.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Bug", "Feature", "blue"} {
+		if entryNamed(entries, name) == nil {
+			t.Errorf("label %q not listed", name)
+		}
+	}
+	want := "Bug\tred\t2\n" +
+		"\tblue\t1\n" +
+		"Feature\tgreen\t0\n"
+	if got := readFile(t, tree, entryNamed(entries, ".usage")); got != want {
+		t.Errorf(".usage:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// Labels may be listed prefixed with their color, as configured.
+func TestBoardLabelsColorPrefix(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/boards/b1/labels": `[
+			{"id": "l1", "name": "Bug", "color": "red"},
+			{"id": "l2", "name": "", "color": "green_dark"},
+			{"id": "l3", "name": "Odd", "color": "mauve"}
+		]`,
+	})
+	tree := newTestTree(t, Options{ColorPrefix: "[{color}] "})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	labels := newBoardLabelsDir(board)
+	addNodes(tree, labels)
+
+	entries, err := tree.Entries(context.Background(), labels.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"[red] Bug", "[green_dark] green_dark", "Odd",
+	} {
+		if entryNamed(entries, name) == nil {
+			t.Errorf("label %q not listed", name)
+		}
+	}
+}
+
+// Cards carry exactly the labels written to their 'labels' file, by name,
+// color for those without one, or ID.
+func TestCardLabelsFile(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/boards/b1/labels": `[
+			{"id": "l1", "name": "Bug", "color": "red"},
+			{"id": "l2", "name": "Feature", "color": "green"},
+			{"id": "l3", "name": "", "color": "blue"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	card := newTestCard(board, &trello.Card{
+		ID: "c1", Name: "Fix", Labels: []trello.CardLabel{
+			{ID: "l1", Name: "Bug", Color: "red"},
+			{ID: "l3", Color: "blue"},
+		},
+	})
+	labels := newCardLabelsFile(card)
+	addNodes(tree, labels)
+
+	want := "Bug\tred\nblue\tblue\n"
+	if got := readFile(t, tree, labels); got != want {
+		t.Errorf("labels:\n%s\nwant:\n%s", got, want)
+	}
+	if err := writeFile(t, tree, labels, "blue\tblue\nl2\n"); err != nil {
+		t.Fatal(err)
+	}
+	want = "blue\tblue\nFeature\tgreen\n"
+	if got := readFile(t, tree, labels); got != want {
+		t.Errorf("labels once written:\n%s\nwant:\n%s", got, want)
+	}
+	if err := writeFile(t, tree, labels, "Docs\n"); err == nil {
+		t.Errorf("unknown label set")
+	}
+	requests := []string{
+		"DELETE /cards/c1/idLabels/l1",
+		"POST /cards/c1/idLabels?value=l2",
+	}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+}
+
+// Labels are grouped by color in 'labels/by-color', those without one under
+// 'none', and, from the v2 layout on, cards are in 'views/colors' by the
+// colors of their labels.
+func TestLabelColors(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/labels": `[
+			{"id": "l1", "name": "Bug", "color": "red"},
+			{"id": "l2", "name": "Urgent", "color": "red"},
+			{"id": "l3", "name": "Docs", "color": "blue"},
+			{"id": "l4", "name": "Misc", "color": ""}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Crash", "labels": [
+				{"id": "l1", "name": "Bug", "color": "red"},
+				{"id": "l3", "name": "Docs", "color": "blue"}
+			]},
+			{"id": "c2", "name": "Typo", "labels": [
+				{"id": "l2", "name": "Urgent", "color": "red"}
+			]},
+			{"id": "c3", "name": "Cleanup", "labels": [
+				{"id": "l4", "name": "Misc", "color": ""}
+			]}
+		]`,
+		"/boards/b1/lists":   `[]`,
+		"/boards/b1/members": `[]`,
+	})
+	tree := newTestTree(t, Options{Layout: "v2"})
+	ctx := context.Background()
+	links := func(path string) map[string]string {
+		dir := lookupPath(t, tree, path)
+		entries, err := tree.Entries(ctx, dir.GetNodeID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		links := make(map[string]string)
+		for _, entry := range entries {
+			if entry.GetName() != ".refresh" {
+				links[entry.GetName()], _ = entry.ReadLink()
+			}
+		}
+		return links
+	}
+
+	for color, want := range map[string]map[string]string{
+		"red":  {"Bug": "../../Bug", "Urgent": "../../Urgent"},
+		"blue": {"Docs": "../../Docs"},
+		"none": {"Misc": "../../Misc"},
+	} {
+		got := links("eng/Roadmap/labels/by-color/" + color)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s labels %q, want %q", color, got, want)
+		}
+	}
+	const cards = "../../../../../eng/Roadmap/cards/"
+	for color, want := range map[string]map[string]string{
+		"red":  {"Crash": cards + "Crash", "Typo": cards + "Typo"},
+		"blue": {"Crash": cards + "Crash"},
+		"none": {"Cleanup": cards + "Cleanup"},
+	} {
+		got := links("eng/Roadmap/views/colors/" + color)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s cards %q, want %q", color, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// How many failed operations '.trellofs/last-errors' remembers.
+const maxLastErrors = 32
+
+// An operation that failed for a request to Trello failing, as the errno
+// it failed with tells little of why.
+type opFailure struct {
+	at     time.Time
+	op     string
+	path   string
+	err    error
+	status int
+	reason string
+}
+
+// Note why an operation failed, should it have failed for a request to
+// Trello failing, as noted in 'ctx'; see trello.NoteFailures(). 'name' is
+// that of the entry within 'id' operated on, if any. Returns 'err' as is.
+func (tree *Tree) noteFailure(
+	ctx context.Context,
+	op string,
+	id fuseops.InodeID,
+	name string,
+	err error,
+) error {
+	if err == nil {
+		return nil
+	}
+	cause := trello.LastFailure(ctx)
+	if cause == nil {
+		return err
+	}
+	failure := opFailure{
+		at:     time.Now(),
+		op:     op,
+		err:    err,
+		reason: cause.Error(),
+	}
+	var apiErr *trello.APIError
+	if errors.As(cause, &apiErr) {
+		failure.status = apiErr.StatusCode
+		failure.reason = apiErr.Body
+	}
+
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	failure.path = "?"
+	if node := tree.inodes[id]; node != nil {
+		failure.path = path.Join("/", nodePath(node), name)
+	}
+	tree.failures = append(tree.failures, failure)
+	if len(tree.failures) > maxLastErrors {
+		tree.failures = tree.failures[len(tree.failures)-maxLastErrors:]
+	}
+	return err
+}
+
+// Describe the operations last failed for requests to Trello failing, oldest
+// first, as
+//
+//	<time>	<operation>	<path>	<error>	<HTTP status, or '-'>	<reason>
+//
+// the reason being Trello's message, if it had one.
+func (tree *Tree) lastErrors() ([]byte, error) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	var buf bytes.Buffer
+	for _, failure := range tree.failures {
+		status := "-"
+		if failure.status != 0 {
+			status = fmt.Sprint(failure.status)
+		}
+		// Trello's messages may span lines.
+		reason := strings.Join(strings.Fields(failure.reason), " ")
+		fmt.Fprintf(
+			&buf, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			failure.at.Format(time.RFC3339), failure.op, failure.path,
+			failure.err, status, reason,
+		)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,68 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Operations failing for requests to Trello failing are told of in
+// '.trellofs/last-errors', up to the last maxLastErrors of them, however
+// many fail at once.
+func TestLastErrors(t *testing.T) {
+	// with no workspaces to be obtained.
+	withFixtures(t, nil)
+	tree := newTestTree(t, Options{})
+	fs := &trelloFS{tree: tree}
+	lookup := func(name string) error {
+		return fs.LookUpInode(context.Background(), &fuseops.LookUpInodeOp{
+			Parent:    fuseops.RootInodeID,
+			Name:      name,
+			OpContext: fuseops.OpContext{Pid: 1},
+		})
+	}
+
+	if err := lookup("eng"); err == nil {
+		t.Fatal("workspace looked up with Trello failing")
+	}
+	lastErrors := lookupPath(t, tree, ".trellofs/last-errors")
+	lines := strings.Split(strings.TrimSuffix(
+		readFile(t, tree, lastErrors), "\n"), "\n",
+	)
+	if len(lines) != 1 {
+		t.Fatalf("last errors:\n%s", strings.Join(lines, "\n"))
+	}
+	fields := strings.Split(lines[0], "\t")
+	if len(fields) != 6 || fields[1] != "lookup" || fields[2] != "/eng" ||
+		fields[4] != "-" || !strings.Contains(fields[5], "organizations") {
+		t.Errorf("failure told as %q", fields)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < maxLastErrors+8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lookup(fmt.Sprintf("ws%d", i))
+		}(i)
+	}
+	wg.Wait()
+	got := readFile(t, tree, lastErrors)
+	if n := strings.Count(got, "\tlookup\t/ws"); n != maxLastErrors {
+		t.Errorf("%d failures told, want the last %d:\n%s",
+			n, maxLastErrors, got)
+	}
+}
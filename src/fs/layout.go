@@ -0,0 +1,41 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+// Directory layouts the tree may be presented in, as set by Options.Layout
+// and reported by '.trellofs/layout-version'. Changes to the layout only
+// apply from the version introducing them on, so scripts relying on a given
+// layout keep working for as long as they ask for it.
+//
+//	v1	the original layout.
+//	v2	lists hold symlinks to their cards' directories, rather than the
+//		directories themselves; a board's 'views' holds 'due', 'labels',
+//		'colors', and 'members' views of its own; cards hold a '.reminder',
+//		and lists a '.stats'.
+var Layouts = []string{"v1", "v2"}
+
+func (opts *Options) layout() string {
+	if opts.Layout == "" {
+		return Layouts[0]
+	}
+	return opts.Layout
+}
+
+// Whether the layout is at least 'version', i.e. has its changes.
+func (opts *Options) layoutSince(version string) bool {
+	for _, layout := range Layouts {
+		if layout == opts.layout() {
+			return layout == version
+		} else if layout == version {
+			return true
+		}
+	}
+	return false
+}
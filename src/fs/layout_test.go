@@ -0,0 +1,190 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Lists hold their cards' directories in v1, and symlinks to them from v2 on.
+func TestLayoutListCards(t *testing.T) {
+	for _, test := range []struct {
+		opts    Options
+		symlink bool
+	}{
+		{Options{}, false},
+		{Options{Layout: "v1"}, false},
+		{Options{Layout: "v2"}, true},
+		{Options{Layout: "v2", HardlinkCards: true}, false},
+	} {
+		tree := newTestTree(t, test.opts)
+		board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Board"})
+		card := newTestCard(board, &trello.Card{ID: "c1", Name: "Card"})
+		list := &FSList{
+			BaseFSNode: BaseFSNode{TrelloID: "l1", tree: tree},
+			links:      make(map[string]*FSSymlink),
+		}
+
+		entry := list.cardEntry(card)
+		link, isLink := entry.(*FSSymlink)
+		if isLink != test.symlink {
+			t.Errorf(
+				"layout %q, hardlinks %v: card presented as %T",
+				test.opts.Layout, test.opts.HardlinkCards, entry,
+			)
+		} else if isLink && link.target != "../../cards/Card" {
+			t.Errorf("symlink to %q", link.target)
+		}
+	}
+}
+
+func TestLayoutSince(t *testing.T) {
+	for _, test := range []struct {
+		layout  string
+		version string
+		want    bool
+	}{
+		{"", "v1", true},
+		{"", "v2", false},
+		{"v1", "v2", false},
+		{"v2", "v1", true},
+		{"v2", "v2", true},
+	} {
+		opts := Options{Layout: test.layout}
+		if got := opts.layoutSince(test.version); got != test.want {
+			t.Errorf(
+				"layout %q since %s = %v, want %v",
+				test.layout, test.version, got, test.want,
+			)
+		}
+	}
+}
+
+// The schema describes the v2 layout's additions only when in use, with a
+// board's preferences in '_prefs' either way.
+func TestLayoutSchema(t *testing.T) {
+	for _, test := range []struct {
+		layout string
+		v2     bool
+	}{
+		{"v1", false},
+		{"v2", true},
+	} {
+		tree := newTestTree(t, Options{Layout: test.layout})
+		schema := &FSSchemaDir{ctl: &FSCtlDir{tree: tree}}
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(schema.layout()); err != nil {
+			t.Fatal(err)
+		}
+		layout := buf.String()
+		if !strings.Contains(layout, `"/<workspace>/<board>/_prefs"`) {
+			t.Errorf("%s: no _prefs described", test.layout)
+		}
+		for _, path := range []string{
+			"/<workspace>/<board>/cards/<card>/.reminder",
+			"/<workspace>/<board>/lists/<list>/.stats",
+			"/<workspace>/<board>/views/members/<username>",
+		} {
+			if strings.Contains(layout, path) != test.v2 {
+				t.Errorf("%s: %s described: %v", test.layout, path, !test.v2)
+			}
+		}
+	}
+}
+
+// Cards with a due date only have a reminder from v2 on.
+func TestLayoutReminder(t *testing.T) {
+	for _, test := range []struct {
+		layout   string
+		reminder bool
+	}{
+		{"v1", false},
+		{"v2", true},
+	} {
+		tree := newTestTree(t, Options{Layout: test.layout})
+		board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Board"})
+		card := newTestCard(board, &trello.Card{
+			ID: "c1", Name: "Card", Due: "2022-03-01T12:00:00.000Z",
+		})
+		card.Lock()
+		added, _ := card.updateReminder()
+		card.Unlock()
+		if (added != nil) != test.reminder {
+			t.Errorf("%s: reminder added: %v", test.layout, added != nil)
+		}
+	}
+}
+
+// With Options.HardlinkCards, a card looked up on its list, and amongst its
+// board's cards, is the same node, linked twice, whichever is looked up
+// first.
+func TestHardlinkCards(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": `[{"id": "c1", "name": "Fix", "idList": "l1"}]`,
+		"/boards/b1/lists": `[{"id": "l1", "name": "To do"}]`,
+		"/lists/l1/cards":  `[{"id": "c1", "name": "Fix", "idList": "l1"}]`,
+	})
+	tree := newTestTree(t, Options{Layout: "v2", HardlinkCards: true})
+	dirs := []FSNode{
+		lookupPath(t, tree, "eng/Roadmap/cards"),
+		lookupPath(t, tree, "eng/Roadmap/lists/To do"),
+	}
+
+	found := make(chan FSNode, 2*len(dirs))
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		for _, dir := range dirs {
+			wg.Add(1)
+			go func(dir FSNode) {
+				defer wg.Done()
+				card, err := tree.Lookup(
+					context.Background(), dir.GetNodeID(), "Fix",
+				)
+				if err != nil {
+					t.Errorf("lookup in %s: %s", dir.GetName(), err)
+					return
+				}
+				found <- card
+			}(dir)
+		}
+	}
+	wg.Wait()
+	close(found)
+	var card FSNode
+	for node := range found {
+		if card == nil {
+			card = node
+		} else if node != card {
+			t.Fatalf("card looked up as both %d and %d",
+				card.GetNodeID(), node.GetNodeID())
+		}
+	}
+	if card == nil {
+		t.Fatal("card never looked up")
+	}
+	attrs := card.GetNodeAttrs()
+	if !attrs.Mode.IsDir() || attrs.Nlink != 2 {
+		t.Errorf("card presented as %s, linked %d times",
+			attrs.Mode, attrs.Nlink)
+	}
+}
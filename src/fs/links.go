@@ -0,0 +1,158 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Links to Trello cards, capturing their short link.
+var cardLinkRE = regexp.MustCompile(`trello\.com/c/([A-Za-z0-9]+)`)
+
+// Symlinks to the cards a card links to, through its description or its
+// attachments, named after the linked cards' short links.
+type FSCardLinksDir struct {
+	BaseFSNode
+
+	CardNode *FSCard
+
+	Links       []*FSSymlink
+	byShortLink map[string]*FSSymlink
+}
+
+func newCardLinksDir(card *FSCard) *FSCardLinksDir {
+	return &FSCardLinksDir{
+		BaseFSNode: BaseFSNode{
+			name: "links",
+			uid:  card.uid,
+			gid:  card.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0500 | os.ModeDir,
+				Uid:  card.uid,
+				Gid:  card.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/links", card.GetTrelloID()),
+			Ctx:      card.Ctx,
+			parent:   card,
+		},
+		CardNode:    card,
+		byShortLink: make(map[string]*FSSymlink),
+	}
+}
+
+// Obtain the short links of the cards a card links to, in order of
+// appearance.
+func (node *FSCardLinksDir) linkedCards() ([]string, error) {
+	node.CardNode.Lock()
+	card := *node.CardNode.Card
+	node.CardNode.Unlock()
+
+	attachments, err := node.tree.cardAttachments(node.api(), &card)
+	if err != nil {
+		return nil, err
+	}
+	texts := []string{card.Desc}
+	for _, attachment := range attachments {
+		texts = append(texts, attachment.URL)
+	}
+
+	var shortLinks []string
+	seen := map[string]bool{card.ShortLink: true}
+	for _, text := range texts {
+		for _, match := range cardLinkRE.FindAllStringSubmatch(text, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				shortLinks = append(shortLinks, match[1])
+			}
+		}
+	}
+	return shortLinks, nil
+}
+
+func (node *FSCardLinksDir) ShouldUpdate() bool {
+	return node.shouldUpdate(60.0)
+}
+
+// The cards linked to are found before taking the node's lock, as that may
+// take a few requests to Trello.
+func (node *FSCardLinksDir) Update() ([]FSNode, []FSNode, error) {
+	shortLinks, err := node.linkedCards()
+	if err != nil {
+		return nil, nil, err
+	}
+	known := make(map[string]*FSCard)
+	for _, shortLink := range shortLinks {
+		if card := node.tree.cardByShortLink(shortLink); card != nil {
+			known[shortLink] = card
+		}
+	}
+
+	node.Lock()
+	defer node.Unlock()
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+	var links []*FSSymlink
+	byShortLink := make(map[string]*FSSymlink)
+	for _, shortLink := range shortLinks {
+		// link cards we know of directly, and others through '.resolve'
+		// so they're only loaded if followed.
+		target := pathToRoot(node) + ".resolve/c/" + shortLink
+		if card, exists := known[shortLink]; exists {
+			target = relativePath(node, card)
+		}
+		link, exists := node.byShortLink[shortLink]
+		if exists {
+			link.setTarget(target)
+		} else {
+			link = newSymlink(node, shortLink, target)
+			newNodes = append(newNodes, link)
+		}
+		byShortLink[shortLink] = link
+		links = append(links, link)
+	}
+	for shortLink, link := range node.byShortLink {
+		if _, exists := byShortLink[shortLink]; !exists {
+			rmNodes = append(rmNodes, link)
+		}
+	}
+	node.byShortLink = byShortLink
+	node.Links = links
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSCardLinksDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if link, exists := node.byShortLink[name]; exists {
+		return link, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSCardLinksDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Links))
+	for i, link := range node.Links {
+		entries[i] = link
+	}
+	return entries
+}
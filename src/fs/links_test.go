@@ -0,0 +1,129 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Cards linked to from a card's description, or attachments, are linked
+// from its 'links', directly if known, or through '.resolve' otherwise.
+func TestCardLinks(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/cards/c1/attachments": `[
+			{"id": "a1", "url": "https://trello.com/c/Kn0wn/2-dep"},
+			{"id": "a2", "url": "https://example.com/c/N0tTrello"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	card := newTestCard(board, &trello.Card{
+		ID: "c1", Name: "Fix", ShortLink: "S3lf",
+		Desc: "After https://trello.com/c/Kn0wn, and trello.com/c/Unkn0wn " +
+			"(see trello.com/c/S3lf)",
+	})
+	newTestCard(board, &trello.Card{
+		ID: "c2", Name: "Dependency", ShortLink: "Kn0wn",
+	})
+	links := newCardLinksDir(card)
+	addNodes(tree, links)
+
+	entries, err := tree.Entries(context.Background(), links.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entryNamed(entries, "S3lf") != nil {
+		t.Errorf("card linked to itself")
+	}
+	if entryNamed(entries, "N0tTrello") != nil {
+		t.Errorf("card linked to a page not on Trello")
+	}
+	for name, want := range map[string]string{
+		"Kn0wn":   "../../../cards/Dependency",
+		"Unkn0wn": "../../../.resolve/c/Unkn0wn",
+	} {
+		link := entryNamed(entries, name)
+		if link == nil {
+			t.Errorf("no link to %s", name)
+			continue
+		}
+		if target, _ := link.ReadLink(); target != want {
+			t.Errorf("%s linked to %q, want %q", name, target, want)
+		}
+	}
+}
+
+// A card's links may be listed while being refreshed. Meant to be run with
+// '-race'.
+func TestCardLinksConcurrent(t *testing.T) {
+	withFixtures(t, map[string]string{"/cards/c1/attachments": `[]`})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	card := newTestCard(board, &trello.Card{
+		ID: "c1", Name: "Fix", Desc: "After trello.com/c/Kn0wn",
+	})
+	newTestCard(board, &trello.Card{
+		ID: "c2", Name: "Dependency", ShortLink: "Kn0wn",
+	})
+	links := newCardLinksDir(card)
+	addNodes(tree, links)
+	if err := <-listing(tree, links); err != nil {
+		t.Fatal(err)
+	}
+	tree.lock.Lock()
+	refresh := tree.refreshFile(links)
+	tree.lock.Unlock()
+
+	done := make(chan bool)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			_, err := tree.WriteAt(
+				context.Background(), refresh.GetNodeID(), []byte("1"), 0,
+			)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case err := <-listing(tree, links):
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}
+	}()
+	finished := make(chan bool)
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(10 * time.Second):
+		t.Fatal("links deadlocked being listed while refreshed")
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Inline links, '[text](target)', with the target up to the closing
+// parenthesis, if any, on the same line.
+var inlineLinkRegex = regexp.MustCompile(`\[([^\]]*)\]\(([^)]*)(\)?)`)
+
+// Reference links, '[text][ref]' or '[ref][]', and their definitions,
+// '[ref]: target'.
+var refLinkRegex = regexp.MustCompile(`\[([^\]]+)\]\[([^\]]*)\]`)
+var refDefRegex = regexp.MustCompile(`^\s{0,3}\[([^\]]+)\]:\s*(\S*)`)
+
+func wordCount(text string) int {
+	return len(strings.Fields(text))
+}
+
+// Find the links in a markdown text that can't lead anywhere: those with no
+// target, not closed, with a target that isn't a URL, or referring to an
+// undefined reference. Issues are described as '<line>: <issue>: <link>'.
+func brokenLinks(text string) []string {
+	lines := strings.Split(text, "\n")
+	refs := make(map[string]bool)
+	for _, line := range lines {
+		if match := refDefRegex.FindStringSubmatch(line); match != nil {
+			refs[strings.ToLower(match[1])] = match[2] != ""
+		}
+	}
+
+	var issues []string
+	report := func(line int, issue string, link string) {
+		issues = append(issues, fmt.Sprintf("%d: %s: %s", line, issue, link))
+	}
+	for i, line := range lines {
+		if refDefRegex.MatchString(line) {
+			continue
+		}
+		for _, match := range inlineLinkRegex.FindAllStringSubmatch(line, -1) {
+			target := strings.TrimSpace(match[2])
+			// a title may follow the target, quoted.
+			if fields := strings.Fields(target); len(fields) > 1 &&
+				strings.HasPrefix(fields[1], "\"") {
+				target = fields[0]
+			}
+			target = strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+			if match[3] == "" {
+				report(i+1, "unclosed", match[0])
+			} else if target == "" {
+				report(i+1, "no target", match[0])
+			} else if _, err := url.Parse(target); err != nil ||
+				strings.ContainsAny(target, " \t") {
+				report(i+1, "bad target", match[0])
+			}
+		}
+		for _, match := range refLinkRegex.FindAllStringSubmatch(line, -1) {
+			ref := match[2]
+			if ref == "" {
+				ref = match[1]
+			}
+			if defined, exists := refs[strings.ToLower(ref)]; !exists {
+				report(i+1, "undefined reference", match[0])
+			} else if !defined {
+				report(i+1, "no target", match[0])
+			}
+		}
+	}
+	return issues
+}
+
+// Generate the card's 'WordCount' and 'BrokenLinks' files, describing its
+// description as a document; see Options.DocMeta.
+func (node *FSCard) wordCount() ([]byte, error) {
+	node.Lock()
+	defer node.Unlock()
+	return []byte(fmt.Sprintf("%d\n", wordCount(node.Card.Desc))), nil
+}
+
+func (node *FSCard) brokenLinks() ([]byte, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	var buf bytes.Buffer
+	for _, issue := range brokenLinks(node.Card.Desc) {
+		buf.WriteString(issue + "\n")
+	}
+	return buf.Bytes(), nil
+}
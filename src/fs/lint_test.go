@@ -0,0 +1,58 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"testing"
+)
+
+// With DocMeta, cards count their description's words and point out the
+// links in it that lead nowhere, by line; otherwise, they don't.
+func TestCardDocMeta(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Docs", "idList": "l1", "desc":
+				"See [the spec](https://example.com/spec) and [notes][n].\n` +
+			`[empty]() and [open](https://example.com\n` +
+			`[n]: https://example.com/notes\n` +
+			`[gone][missing] [bad](not a url)"}
+		]`,
+		"/boards/b1/lists": `[{"id": "l1", "name": "To do"}]`,
+	})
+	tree := newTestTree(t, Options{DocMeta: true})
+	card := "eng/Roadmap/cards/Docs/"
+
+	words := readFile(t, tree, lookupPath(t, tree, card+"WordCount"))
+	if words != "14\n" {
+		t.Errorf("word count %q, want 14", words)
+	}
+	links := readFile(t, tree, lookupPath(t, tree, card+"BrokenLinks"))
+	want := "2: no target: [empty]()\n" +
+		"2: unclosed: [open](https://example.com\n" +
+		"4: bad target: [bad](not a url)\n" +
+		"4: undefined reference: [gone][missing]\n"
+	if links != want {
+		t.Errorf("broken links:\n%s\nwant:\n%s", links, want)
+	}
+
+	tree = newTestTree(t, Options{})
+	docs := lookupPath(t, tree, "eng/Roadmap/cards/Docs")
+	for _, name := range []string{"WordCount", "BrokenLinks"} {
+		_, err := tree.Lookup(context.Background(), docs.GetNodeID(), name)
+		if err == nil {
+			t.Errorf("%s without DocMeta", name)
+		}
+	}
+}
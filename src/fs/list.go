@@ -11,13 +11,12 @@ package fs
 
 import (
 	"log"
-	"os"
+	"net/url"
+	"syscall"
 
 	"github.com/jecluis/trellofs/src/trello"
 
 	"github.com/jacobsa/fuse"
-	"github.com/jacobsa/fuse/fuseops"
-	"github.com/jacobsa/fuse/fuseutil"
 )
 
 type FSList struct {
@@ -27,12 +26,32 @@ type FSList struct {
 	ByID   map[string]*FSCard
 	ByName map[string]*FSCard
 
+	// symlinks to the list's cards, by card ID, and those since dropped,
+	// to be removed from the tree on the list's next update.
+	links    map[string]*FSSymlink
+	unlinked []FSNode
+
+	// what the list's cards were tagged as when last gone through.
+	etag string
+
+	// cards obtained along with the board; see hydrate.go.
+	hydrated *hydration
+
+	mirrors mirrorLinks
+
+	MetaStale        *FSListStaleDir
+	MetaImport       *FSControlFile
+	MetaImportResult *FSGeneratedFile
+	MetaName         *FSGeneratedFile
+	MetaRaw          *FSGeneratedFile
+	MetaStats        *FSGeneratedFile
+
 	BoardNode *FSBoard
 	List      *trello.List
 }
 
 func (node *FSList) ShouldUpdate() bool {
-	return node.shouldUpdate(30.0)
+	return node.shouldRefresh("lists", 30.0)
 }
 
 func (node *FSList) Update() ([]FSNode, []FSNode, error) {
@@ -47,8 +66,21 @@ func (node *FSList) Update() ([]FSNode, []FSNode, error) {
 		boardNode.GetName(), boardNode.GetTrelloID(),
 	)
 
-	cards, err := node.List.GetCards(node.Ctx)
-	if err != nil {
+	var newNodes []FSNode = make([]FSNode, 0)
+	var cards []trello.Card
+	var err error
+	if h := takeHydration(&node.hydrated); h != nil {
+		cards = h.cards
+	} else {
+		cards, err = node.List.GetCardsIfChanged(node.api(), &node.etag)
+	}
+	if err == trello.ErrNotModified {
+		log.Printf(
+			"cards for list %s (%s) on board %s (%s) unchanged\n",
+			node.GetName(), node.GetTrelloID(),
+			boardNode.GetName(), boardNode.GetTrelloID(),
+		)
+	} else if err != nil {
 		log.Printf(
 			"error upating cards for list %s (%s) on board %s (%s): %s\n",
 			node.GetName(), node.GetTrelloID(),
@@ -56,7 +88,61 @@ func (node *FSList) Update() ([]FSNode, []FSNode, error) {
 			err,
 		)
 		return nil, nil, err
+	} else {
+		newNodes = node.setCards(cards)
 	}
+	if node.MetaStale == nil {
+		importer := &csvImporter{list: node}
+		node.MetaStale = newListStaleDir(node)
+		node.MetaImport = newControlFile(
+			node, ".import.csv", 0.0,
+			func() ([]byte, error) { return nil, nil },
+			importer.submit,
+		)
+		node.MetaImport.NodeAttrs.Mode = 0200
+		node.MetaImportResult = newGeneratedFile(
+			node, ".import.result", 0.0, importer.result,
+		)
+		node.MetaRaw = newRawFile(node, node.Ctx, "lists")
+		newNodes = append(
+			newNodes, node.MetaStale, node.MetaImport, node.MetaImportResult,
+			node.MetaRaw,
+		)
+		// lists only have one from the v2 layout on.
+		if node.tree.opts.layoutSince("v2") {
+			node.MetaStats = newGeneratedFile(
+				node, ".stats", 30.0, node.stats,
+			)
+			newNodes = append(newNodes, node.MetaStats)
+		}
+		if boardNode.idNames {
+			node.MetaName = newGeneratedFile(
+				node, ".name", 60.0, func() ([]byte, error) {
+					return []byte(node.List.Name + "\n"), nil
+				},
+			)
+			newNodes = append(newNodes, node.MetaName)
+		}
+	}
+	rmNodes := node.unlinked
+	node.unlinked = nil
+	node.markUpdated()
+	log.Printf(
+		"updated cards for list %s (%s) on board %s (%s): %d new nodes, %d total cards\n",
+		node.GetName(), node.GetTrelloID(),
+		boardNode.GetName(), boardNode.GetTrelloID(),
+		len(newNodes), len(boardNode.Cards),
+	)
+
+	return newNodes, rmNodes, nil
+}
+
+// Go through the list's cards, as obtained from Trello, returning those new
+// to the board, along with links to the cards its mirror cards stand for.
+// Must be called with the list's lock held.
+func (node *FSList) setCards(cards []trello.Card) []FSNode {
+	boardNode := node.BoardNode
+	cards, mirrors := splitMirrors(cards)
 
 	log.Printf(
 		"updating cards for list %s (%s) on board %s (%s)\n",
@@ -65,10 +151,15 @@ func (node *FSList) Update() ([]FSNode, []FSNode, error) {
 	)
 
 	var newNodes []FSNode = make([]FSNode, 0)
+	seen := make(map[string]bool)
 	for _, card := range cards {
+		card := card
+		seen[card.ID] = true
 		var newCard *FSCard = nil
 		if _, exists := boardNode.ByCardID[card.ID]; exists {
 			newCard = boardNode.ByCardID[card.ID]
+			newCard.setCard(card)
+			boardNode.renameCard(newCard, node)
 			log.Printf(
 				"reusing card on board %s (%s) for list %s (%s): %s (%s)\n",
 				boardNode.GetName(), boardNode.GetTrelloID(),
@@ -76,24 +167,7 @@ func (node *FSList) Update() ([]FSNode, []FSNode, error) {
 				newCard.GetName(), newCard.GetTrelloID(),
 			)
 		} else {
-			newCard = &FSCard{
-				BaseFSNode: BaseFSNode{
-					name: card.Name,
-					uid:  node.uid,
-					gid:  node.gid,
-					NodeAttrs: fuseops.InodeAttributes{
-						Mode: 0700 | os.ModeDir,
-						Uid:  node.uid,
-						Gid:  node.gid,
-					},
-					isDir:    true,
-					TrelloID: card.ID,
-					Ctx:      node.Ctx,
-				},
-				Card:   &card,
-				ByName: make(map[string]*FSCardMetaFile),
-				ByID:   make(map[string]*FSCardMetaFile),
-			}
+			newCard = newCardNode(boardNode, &card)
 			newNodes = append(newNodes, newCard)
 			log.Printf(
 				"new card %s (%s) on list %s (%s) for board %s (%s)\n",
@@ -102,72 +176,235 @@ func (node *FSList) Update() ([]FSNode, []FSNode, error) {
 				boardNode.GetName(), boardNode.GetTrelloID(),
 			)
 		}
-		if _, exists := node.ByID[card.ID]; !exists {
-			node.Cards = append(node.Cards, newCard)
-			node.ByID[card.ID] = newCard
-			node.ByName[card.Name] = newCard
-			boardNode.Cards = append(boardNode.Cards, newCard)
-			boardNode.ByCardID[card.ID] = newCard
-			boardNode.ByCardName[card.Name] = newCard
+		node.addCard(newCard)
+	}
+	// cards no longer on the list may have been moved to another list, so
+	// they're only removed from the tree once gone from the board.
+	for _, card := range append([]*FSCard{}, node.Cards...) {
+		if !seen[card.GetTrelloID()] {
+			node.removeCard(card)
 		}
 	}
-	node.markUpdated()
-	log.Printf(
-		"updated cards for list %s (%s) on board %s (%s): %d new nodes, %d total cards\n",
-		node.GetName(), node.GetTrelloID(),
-		boardNode.GetName(), boardNode.GetTrelloID(),
-		len(newNodes), len(boardNode.Cards),
+	newLinks, goneLinks := node.mirrors.update(
+		node.tree, node, mirrors,
+		func(name string) bool { return node.ByName[name] != nil },
 	)
-
-	return newNodes, nil, nil
+	node.unlinked = append(node.unlinked, goneLinks...)
+	return append(newNodes, newLinks...)
 }
 
 func (node *FSList) LookupChild(name string) (FSNode, error) {
 	node.Lock()
 	defer node.Unlock()
 
+	for _, entry := range node.metaEntries() {
+		if entry.GetName() == name {
+			return entry, nil
+		}
+	}
 	for _, card := range node.Cards {
 		if card.GetName() == name {
-			return card, nil
+			return node.cardEntry(card), nil
 		}
 	}
+	if link := node.mirrors.lookup(name); link != nil {
+		return link, nil
+	}
 	return nil, fuse.ENOENT
 }
 
-func (node *FSList) ReadDir(dst []byte, offset int) int {
+func (node *FSList) GetEntries() []FSNode {
 	node.Lock()
 	defer node.Unlock()
 
-	boardNode := node.BoardNode
+	entries := make([]FSNode, 0, len(node.Cards)+5)
+	for _, card := range node.Cards {
+		entries = append(entries, node.cardEntry(card))
+	}
+	entries = append(entries, node.mirrors.entries()...)
+	return append(entries, node.metaEntries()...)
+}
+
+// The list's structural entries, once it has been updated.
+func (node *FSList) metaEntries() []FSNode {
+	if node.MetaStale == nil {
+		return nil
+	}
+	entries := []FSNode{
+		node.MetaStale, node.MetaImport, node.MetaImportResult, node.MetaRaw,
+	}
+	if node.MetaStats != nil {
+		entries = append(entries, node.MetaStats)
+	}
+	if node.MetaName != nil {
+		entries = append(entries, node.MetaName)
+	}
+	return entries
+}
+
+// From the v2 layout on, cards are presented on their lists as symlinks to
+// their directories in the board's 'cards', e.g. '../../cards/<card>', so
+// each card has a single directory; or, with Options.HardlinkCards, and in
+// v1, as their directories themselves, with the former having both share an
+// inode, as hard links would. Must be called with the list's lock held.
+func (node *FSList) cardEntry(card *FSCard) FSNode {
+	if node.tree.opts.HardlinkCards || !node.tree.opts.layoutSince("v2") {
+		return card
+	}
+	return node.cardLink(card)
+}
+
+// Links are created as they're first listed or looked up, and follow their
+// cards' renames. Must be called with the list's lock held.
+func (node *FSList) cardLink(card *FSCard) *FSSymlink {
+	name := card.GetName()
+	target := "../../cards/" + name
+	link, exists := node.links[card.GetTrelloID()]
+	if !exists {
+		link = newSymlink(node, name, target)
+		link.TrelloID = card.GetTrelloID() + "/link"
+		node.links[card.GetTrelloID()] = link
+	} else if link.GetName() != name {
+		link.setName(name)
+		link.setTarget(target)
+	}
+	return link
+}
+
+// Add a card to the list, and to its board. Must be called with the list's
+// lock held.
+func (node *FSList) addCard(card *FSCard) {
+	if _, exists := node.ByID[card.GetTrelloID()]; exists {
+		return
+	}
+	node.Cards = append(node.Cards, card)
+	node.ByID[card.GetTrelloID()] = card
+	node.ByName[card.GetName()] = card
+	node.BoardNode.addCard(card)
+}
+
+// Remove a card from the list; it remains on its board. Must be called with
+// the list's lock held.
+func (node *FSList) removeCard(card *FSCard) {
+	for i, entry := range node.Cards {
+		if entry == card {
+			node.Cards = append(node.Cards[:i], node.Cards[i+1:]...)
+			break
+		}
+	}
+	delete(node.ByID, card.GetTrelloID())
+	delete(node.ByName, card.GetName())
+	if link, exists := node.links[card.GetTrelloID()]; exists {
+		delete(node.links, card.GetTrelloID())
+		node.unlinked = append(node.unlinked, link)
+	}
+}
+
+// Create a card on the list, named after the new directory. The card's
+// directory is what's created, as it must be a directory, though the list
+// presents it as a symlink once next looked up, if that's how the layout
+// presents cards. Fails with EDQUOT if the list is at its enforced WIP
+// limit.
+func (node *FSList) MkDir(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	cardName := unescapeName(name)
+	for _, card := range node.Cards {
+		if card.GetName() == name || card.Card.Name == cardName {
+			return nil, syscall.EEXIST
+		}
+	}
+	if err := node.checkWIP(); err != nil {
+		return nil, err
+	}
 
 	log.Printf(
-		"read dir %s/%s (%s) id %d, offset %d\n",
-		boardNode.GetName(),
-		node.GetName(), node.GetTrelloID(), node.GetNodeID(), offset,
+		"create card %s on list %s (%s)\n",
+		name, node.GetName(), node.GetTrelloID(),
 	)
-	var size int
-	for i := offset; i < len(node.Cards); i++ {
-		card := node.Cards[i]
-		tmp := fuseutil.WriteDirent(dst[size:], fuseutil.Dirent{
-			Name:   card.GetName(),
-			Inode:  card.GetNodeID(),
-			Type:   fuseutil.DT_Directory,
-			Offset: fuseops.DirOffset(i + 1),
-		})
-		if tmp == 0 {
-			log.Printf(
-				"read dir > no more space to write dirent for %s/%s (%s)\n",
-				boardNode.GetName(),
-				node.GetName(), node.GetTrelloID(),
-			)
+	card, err := node.List.CreateCard(node.api(), cardName, nil)
+	if err != nil {
+		return nil, apiErrno(err)
+	}
+	if card.Name == "" {
+		card.Name = cardName
+	}
+	newCard := newCardNode(node.BoardNode, card)
+	node.addCard(newCard)
+	return newCard, nil
+}
+
+// Move a card to another list on the same board. The card keeps its name,
+// so it can't be renamed in the process, and its symlink moves along. Fails
+// with EDQUOT if the target list is at its enforced WIP limit.
+func (node *FSList) Rename(
+	name string,
+	newParent FSNode,
+	newName string,
+) error {
+	target, isList := newParent.(*FSList)
+	if !isList || target.BoardNode != node.BoardNode {
+		return syscall.EXDEV
+	}
+	if newName != name {
+		return syscall.EINVAL
+	}
+	if target == node {
+		return nil
+	}
+
+	// always lock lists in the same order, lest we deadlock.
+	first, second := node, target
+	if first.GetTrelloID() > second.GetTrelloID() {
+		first, second = second, first
+	}
+	first.Lock()
+	defer first.Unlock()
+	second.Lock()
+	defer second.Unlock()
+
+	var card *FSCard = nil
+	for _, entry := range node.Cards {
+		if entry.GetName() == name {
+			card = entry
 			break
 		}
-		log.Printf(
-			"read dir %s/%s id %d: wrote direntry for %s (%s) id %d\n",
-			boardNode.GetName(), node.GetName(), node.GetNodeID(),
-			card.GetName(), card.GetTrelloID(), card.GetNodeID(),
-		)
-		size += tmp
 	}
-	return size
+	if card == nil {
+		return fuse.ENOENT
+	}
+	for _, entry := range target.Cards {
+		if entry.GetName() == name {
+			return syscall.EEXIST
+		}
+	}
+	if err := target.checkWIP(); err != nil {
+		return err
+	}
+
+	log.Printf(
+		"move card %s (%s) from list %s (%s) to list %s (%s)\n",
+		card.GetName(), card.GetTrelloID(),
+		node.GetName(), node.GetTrelloID(),
+		target.GetName(), target.GetTrelloID(),
+	)
+	card.Lock()
+	err := card.Card.Update(
+		node.api(), url.Values{"idList": {target.GetTrelloID()}},
+	)
+	card.metaKey = ""
+	card.Unlock()
+	if err != nil {
+		return apiErrno(err)
+	}
+	link, linked := node.links[card.GetTrelloID()]
+	delete(node.links, card.GetTrelloID())
+	node.removeCard(card)
+	if linked {
+		link.parent = target
+		target.links[card.GetTrelloID()] = link
+	}
+	target.addCard(card)
+	return nil
 }
@@ -0,0 +1,103 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Directories made in a list are created as cards on it, named after them.
+func TestListMkDir(t *testing.T) {
+	dir := withFixtures(t, nil)
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	list := newTestList(board, &trello.List{ID: "l1", Name: "To do"})
+	ctx := context.Background()
+
+	card, err := tree.MkDir(ctx, list.GetNodeID(), "New%2Fcard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := []string{"POST /cards?idList=l1&name=New%2Fcard"}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+	if card.GetName() != "New%2Fcard" || card.GetNodeID() == 0 {
+		t.Errorf("card created as %q (%d)", card.GetName(), card.GetNodeID())
+	}
+	if board.ByCardName["New%2Fcard"] != card {
+		t.Errorf("card created not on its board")
+	}
+	_, err = tree.MkDir(ctx, list.GetNodeID(), "New%2Fcard")
+	if err != syscall.EEXIST {
+		t.Errorf("card created twice: %v", err)
+	}
+}
+
+// Cards moved to another list on their board keep their inode, and may not
+// be renamed, nor moved off their board, in the process.
+func TestListRenameMovesCard(t *testing.T) {
+	dir := withFixtures(t, nil)
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	todo := newTestList(board, &trello.List{ID: "l1", Name: "To do"})
+	doing := newTestList(board, &trello.List{ID: "l2", Name: "Doing"})
+	other := newTestBoard(tree, &trello.Board{ID: "b2", Name: "Ops"})
+	elsewhere := newTestList(other, &trello.List{ID: "l3", Name: "To do"})
+	card := newTestCard(board, &trello.Card{ID: "c1", Name: "Fix"})
+	todo.addCard(card)
+	inode := card.GetNodeID()
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		target  *FSList
+		newName string
+		want    error
+	}{
+		{doing, "Fixed", syscall.EINVAL},
+		{elsewhere, "Fix", syscall.EXDEV},
+	} {
+		err := tree.Rename(
+			ctx, todo.GetNodeID(), "Fix", test.target.GetNodeID(), test.newName,
+		)
+		if err != test.want {
+			t.Errorf(
+				"moved to %s as %s: %v, want %v",
+				test.target.GetTrelloID(), test.newName, err, test.want,
+			)
+		}
+	}
+
+	err := tree.Rename(ctx, todo.GetNodeID(), "Fix", doing.GetNodeID(), "Fix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := []string{"PUT /cards/c1?idList=l2"}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+	if todo.ByName["Fix"] != nil || doing.ByName["Fix"] != card {
+		t.Errorf("card not moved between lists")
+	}
+	moved, err := tree.Lookup(ctx, doing.GetNodeID(), "Fix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.GetNodeID() != inode {
+		t.Errorf(
+			"card's inode %d once moved, was %d", moved.GetNodeID(), inode,
+		)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Boards may have hundreds of lists, e.g. once imported from elsewhere, so
+// a board's 'lists' only lists the first Options.MaxLists of them, along
+// with '.overflow', holding symlinks to the rest, e.g. '<list> -> ../<list>'.
+// Lists left out may still be looked up in 'lists' by name.
+type FSListsOverflowDir struct {
+	BaseFSNode
+
+	ListsDir *FSBoardListsDirMeta
+
+	Links  []*FSSymlink
+	byList map[string]*FSSymlink
+}
+
+func newListsOverflowDir(lists *FSBoardListsDirMeta) *FSListsOverflowDir {
+	return &FSListsOverflowDir{
+		BaseFSNode: BaseFSNode{
+			name: ".overflow",
+			uid:  lists.uid,
+			gid:  lists.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0500 | os.ModeDir,
+				Uid:  lists.uid,
+				Gid:  lists.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/.overflow", lists.GetTrelloID()),
+			Ctx:      lists.Ctx,
+			parent:   lists,
+		},
+		ListsDir: lists,
+		byList:   make(map[string]*FSSymlink),
+	}
+}
+
+// The board's lists, split into those listed in 'lists' and those beyond
+// Options.MaxLists, if any. Must be called with the lists dir's lock held.
+func (node *FSBoardListsDirMeta) listed() ([]*FSList, []*FSList) {
+	lists := node.BoardNode.Lists
+	max := node.tree.opts.MaxLists
+	if max <= 0 || len(lists) <= max {
+		return lists, nil
+	}
+	return lists[:max], lists[max:]
+}
+
+func (node *FSListsOverflowDir) ShouldUpdate() bool {
+	return node.shouldRefresh("lists", 60.0)
+}
+
+func (node *FSListsOverflowDir) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	node.ListsDir.Lock()
+	_, overflow := node.ListsDir.listed()
+	overflow = append([]*FSList{}, overflow...)
+	node.ListsDir.Unlock()
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+	var links []*FSSymlink
+	byList := make(map[string]*FSSymlink)
+	for _, list := range overflow {
+		name := list.GetName()
+		link, exists := node.byList[list.GetTrelloID()]
+		if !exists {
+			link = newSymlink(node, name, "../"+name)
+			link.TrelloID = fmt.Sprintf(
+				"%s/%s", node.GetTrelloID(), list.GetTrelloID(),
+			)
+			newNodes = append(newNodes, link)
+		} else if link.GetName() != name {
+			link.setName(name)
+			link.setTarget("../" + name)
+		}
+		byList[list.GetTrelloID()] = link
+		links = append(links, link)
+	}
+	for id, link := range node.byList {
+		if _, exists := byList[id]; !exists {
+			rmNodes = append(rmNodes, link)
+		}
+	}
+	node.byList = byList
+	node.Links = links
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSListsOverflowDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, link := range node.Links {
+		if link.GetName() == name {
+			return link, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSListsOverflowDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Links))
+	for i, link := range node.Links {
+		entries[i] = link
+	}
+	return entries
+}
@@ -0,0 +1,87 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// A board's 'lists' lists no more than Options.MaxLists lists, those beyond
+// being linked from '.overflow', and looked up in 'lists' all the same.
+func TestListsOverflow(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": `[]`,
+		"/boards/b1/lists": `[
+			{"id": "l1", "name": "To do"},
+			{"id": "l2", "name": "Doing"},
+			{"id": "l3", "name": "Review"},
+			{"id": "l4", "name": "Done"}
+		]`,
+	})
+	tree := newTestTree(t, Options{MaxLists: 2})
+	ctx := context.Background()
+	names := func(path string) []string {
+		dir := lookupPath(t, tree, path)
+		entries, err := tree.Entries(ctx, dir.GetNodeID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.GetName())
+		}
+		return names
+	}
+
+	want := []string{"To do", "Doing", ".overflow", ".refresh"}
+	if got := names("eng/Roadmap/lists"); !reflect.DeepEqual(got, want) {
+		t.Errorf("lists listed as %q, want %q", got, want)
+	}
+	want = []string{"Review", "Done", ".refresh"}
+	got := names("eng/Roadmap/lists/.overflow")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("overflow listed as %q, want %q", got, want)
+	}
+
+	// as 'cd lists/.overflow/Done' would, from several processes at once.
+	lists := lookupPath(t, tree, "eng/Roadmap/lists")
+	overflow := lookupPath(t, tree, "eng/Roadmap/lists/.overflow")
+	var wg sync.WaitGroup
+	for _, name := range []string{"Review", "Done", "Review", "Done"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			link, err := tree.Lookup(ctx, overflow.GetNodeID(), name)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			target, err := link.ReadLink()
+			if err != nil || target != "../"+name {
+				t.Errorf("%s links to %q (%v)", name, target, err)
+			}
+			list, err := tree.Lookup(ctx, lists.GetNodeID(), name)
+			if err != nil {
+				t.Error(err)
+			} else if list.GetNodeID() == 0 || list.GetName() != name {
+				t.Errorf("%s looked up as %q (%d)",
+					name, list.GetName(), list.GetNodeID())
+			}
+		}(name)
+	}
+	wg.Wait()
+}
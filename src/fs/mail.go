@@ -0,0 +1,147 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The card as an email message, so mail tooling, e.g. notmuch or mutt, can
+// index and read boards. The card's fields are headers, its description the
+// first part, followed by a part per comment, oldest first, and a part per
+// attachment, referring to it by URL.
+func newCardMailFile(card *FSCard) *FSGeneratedFile {
+	return newGeneratedFile(card, "card.eml", 30.0, card.mail)
+}
+
+// Called with the updates lock held, when the file is updated.
+func (node *FSCard) mail() ([]byte, error) {
+	node.Lock()
+	card := *node.Card
+	node.Unlock()
+
+	comments, err := node.tree.cardComments(node.api(), &card)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := node.tree.cardAttachments(node.api(), &card)
+	if err != nil {
+		return nil, err
+	}
+
+	boardNode := node.GetParent().(*FSBoardCardsDirMeta).BoardNode
+	node.tree.refresh(boardNode.MetaListsDir)
+	node.tree.refresh(boardNode.MetaMembers)
+	boardNode.Lock()
+	list := ""
+	if listNode, exists := boardNode.ByListID[card.ListID]; exists {
+		list = listNode.List.Name
+	}
+	membersDir := boardNode.MetaMembers
+	boardNode.Unlock()
+
+	usernames := membersDir.usernames()
+	var members []string
+	for _, id := range card.MemberIDs {
+		if username, exists := usernames[id]; exists {
+			members = append(members, username)
+		} else {
+			members = append(members, id)
+		}
+	}
+
+	var labels []string
+	for _, label := range card.Labels {
+		if label.Name == "" {
+			labels = append(labels, label.Color)
+		} else {
+			labels = append(labels, label.Name)
+		}
+	}
+	sort.Strings(labels)
+
+	var buf bytes.Buffer
+	header := func(key string, value string) {
+		if value != "" {
+			fmt.Fprintf(&buf, "%s: %s\r\n", key, mime.QEncoding.Encode(
+				"utf-8", value,
+			))
+		}
+	}
+	header("Message-ID", fmt.Sprintf("<%s@trello.com>", card.ID))
+	if date, err := time.Parse(time.RFC3339, card.LastActive); err == nil {
+		header("Date", date.Format(time.RFC1123Z))
+	}
+	header("From", fmt.Sprintf("trellofs <%s@trello.com>", card.Board.ID))
+	header("Subject", card.Name)
+	header("X-Trello-Board", card.Board.Name)
+	header("X-Trello-List", list)
+	header("X-Trello-Due", card.Due)
+	header("X-Trello-Labels", strings.Join(labels, ", "))
+	header("X-Trello-Members", strings.Join(members, ", "))
+	header("X-Trello-URL", card.URL)
+
+	// a boundary of our own, so the message is the same for as long as the
+	// card is.
+	writer := multipart.NewWriter(&buf)
+	writer.SetBoundary("trellofs-" + card.ID)
+	header("MIME-Version", "1.0")
+	header("Content-Type", mime.FormatMediaType(
+		"multipart/mixed", map[string]string{"boundary": writer.Boundary()},
+	))
+	buf.WriteString("\r\n")
+
+	text := func(description string, contents string) {
+		part, _ := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"text/plain; charset=utf-8"},
+			"Content-Transfer-Encoding": {"8bit"},
+			"Content-Disposition":       {"inline"},
+			"Content-Description": {
+				mime.QEncoding.Encode("utf-8", description),
+			},
+		})
+		contents = strings.ReplaceAll(contents, "\r\n", "\n")
+		part.Write([]byte(strings.ReplaceAll(contents, "\n", "\r\n") + "\r\n"))
+	}
+	text("Description", card.Desc)
+	for i := len(comments) - 1; i >= 0; i-- {
+		comment := comments[i]
+		text(fmt.Sprintf(
+			"Comment by %s, %s",
+			comment.MemberCreator.Username, comment.Date,
+		), comment.Data.Text)
+	}
+	for _, attachment := range attachments {
+		part, _ := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {mime.FormatMediaType(
+				"message/external-body", map[string]string{
+					"access-type": "URL", "URL": attachment.URL,
+				},
+			)},
+			"Content-Disposition": {mime.FormatMediaType(
+				"attachment", map[string]string{"filename": attachment.Name},
+			)},
+		})
+		mimeType := attachment.MimeType
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		fmt.Fprintf(part, "Content-Type: %s\r\n\r\n", mimeType)
+	}
+	writer.Close()
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,99 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Cards read as email messages, with their fields as headers, and their
+// description, comments, and attachments as parts, as mail tooling would
+// parse them.
+func TestCardMail(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Fix crash", "desc": "It crashes\non start",
+				"idList": "l1", "idMembers": ["m1"],
+				"due": "2022-03-01T12:00:00.000Z",
+				"labels": [{"name": "bug"}, {"color": "red"}]}
+		]`,
+		"/boards/b1/lists":   `[{"id": "l1", "name": "To do"}]`,
+		"/boards/b1/members": `[{"id": "m1", "username": "alice"}]`,
+		"/cards/c1/actions": `[
+			{"date": "2022-03-02", "memberCreator": {"username": "bob"},
+				"data": {"text": "Done"}},
+			{"date": "2022-03-01", "memberCreator": {"username": "alice"},
+				"data": {"text": "On it"}}
+		]`,
+		"/cards/c1/attachments": attachmentsFixture,
+	})
+	tree := newTestTree(t, Options{})
+	eml := lookupPath(t, tree, "eng/Roadmap/cards/Fix crash/card.eml")
+
+	msg, err := mail.ReadMessage(strings.NewReader(readFile(t, tree, eml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"Subject":          "Fix crash",
+		"X-Trello-Board":   "Roadmap",
+		"X-Trello-List":    "To do",
+		"X-Trello-Due":     "2022-03-01T12:00:00.000Z",
+		"X-Trello-Labels":  "bug, red",
+		"X-Trello-Members": "alice",
+	} {
+		if got := msg.Header.Get(key); got != want {
+			t.Errorf("%s: %q, want %q", key, got, want)
+		}
+	}
+	mediaType, params, err := mime.ParseMediaType(
+		msg.Header.Get("Content-Type"),
+	)
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("message of type %q: %v", mediaType, err)
+	}
+	var parts []string
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(part)
+		summary := part.Header.Get("Content-Description")
+		if summary == "" {
+			summary = part.FileName()
+		}
+		parts = append(parts, summary+": "+string(body))
+	}
+	want := []string{
+		"Description: It crashes\r\non start\r\n",
+		"Comment by alice, 2022-03-01: On it\r\n",
+		"Comment by bob, 2022-03-02: Done\r\n",
+		"report.pdf: Content-Type: application/octet-stream\r\n\r\n",
+		"Design doc: Content-Type: application/octet-stream\r\n\r\n",
+	}
+	if !reflect.DeepEqual(parts, want) {
+		t.Errorf("parts:\n%q\nwant:\n%q", parts, want)
+	}
+}
@@ -0,0 +1,225 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// The '.me' directory, holding the user's own planning data: the cards
+// they're on, and their saved searches. Trello's API doesn't expose the
+// "up next" planner, so it's not here.
+type FSMeDir struct {
+	BaseFSNode
+
+	MetaCards *FSGeneratedFile
+	Searches  *FSSavedSearchesDir
+}
+
+// The user's saved searches, one file per search, listing matching cards.
+type FSSavedSearchesDir struct {
+	BaseFSNode
+
+	Files  []*FSGeneratedFile
+	ByName map[string]*FSGeneratedFile
+	byID   map[string]*trello.SavedSearch
+}
+
+func newMeDir(tree *Tree, root FSNode) *FSMeDir {
+	node := &FSMeDir{
+		BaseFSNode: BaseFSNode{
+			name: ".me",
+			uid:  tree.uid,
+			gid:  tree.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  tree.uid,
+				Gid:  tree.gid,
+			},
+			isDir:    true,
+			TrelloID: "_me",
+			Ctx:      tree.ctx,
+			parent:   root,
+		},
+	}
+	node.Searches = &FSSavedSearchesDir{
+		BaseFSNode: BaseFSNode{
+			name: "searches",
+			uid:  tree.uid,
+			gid:  tree.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  tree.uid,
+				Gid:  tree.gid,
+			},
+			isDir:    true,
+			TrelloID: "_me/searches",
+			Ctx:      tree.ctx,
+			parent:   node,
+		},
+		ByName: make(map[string]*FSGeneratedFile),
+		byID:   make(map[string]*trello.SavedSearch),
+	}
+	node.MetaCards = newGeneratedFile(node, "cards", 60.0, node.cards)
+	return node
+}
+
+func (node *FSMeDir) ShouldUpdate() bool {
+	return node.shouldUpdate(3600.0)
+}
+
+func (node *FSMeDir) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	for _, entry := range []FSNode{node.MetaCards, node.Searches} {
+		if entry.GetNodeID() == 0 {
+			newNodes = append(newNodes, entry)
+		}
+	}
+	node.markUpdated()
+	return newNodes, nil, nil
+}
+
+func (node *FSMeDir) LookupChild(name string) (FSNode, error) {
+	for _, entry := range node.GetEntries() {
+		if entry.GetName() == name {
+			return entry, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSMeDir) GetEntries() []FSNode {
+	return []FSNode{node.MetaCards, node.Searches}
+}
+
+// Write one line per card, tab-separated, as
+//
+//	<short link>	<due date, or '-'>	<name>
+func (opts *Options) writeCards(buf *bytes.Buffer, cards []trello.Card) {
+	for _, card := range cards {
+		due := opts.renderDate(card.Due)
+		if due == "" {
+			due = "-"
+		}
+		fmt.Fprintf(buf, "%s\t%s\t%s\n", card.ShortLink, due, card.Name)
+	}
+}
+
+// Generate the list of open cards the user is on, soonest due first, and
+// those without a due date last.
+func (node *FSMeDir) cards() ([]byte, error) {
+	agenda, err := node.tree.agendaCards(node.api())
+	if err != nil {
+		return nil, err
+	}
+	cards := append([]trello.Card{}, agenda...)
+	sort.SliceStable(cards, func(i, j int) bool {
+		if cards[i].Due == "" || cards[j].Due == "" {
+			return cards[j].Due == "" && cards[i].Due != ""
+		}
+		return cards[i].Due < cards[j].Due
+	})
+	var buf bytes.Buffer
+	node.tree.opts.writeCards(&buf, cards)
+	return buf.Bytes(), nil
+}
+
+func (node *FSSavedSearchesDir) ShouldUpdate() bool {
+	return node.shouldUpdate(60.0)
+}
+
+func (node *FSSavedSearchesDir) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	searches, err := trello.GetSavedSearches(node.api())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+
+	seen := make(map[string]bool)
+	for i := range searches {
+		search := &searches[i]
+		seen[search.ID] = true
+
+		if existing, exists := node.byID[search.ID]; exists {
+			*existing = *search
+			continue
+		}
+		name := escapeName(search.Name)
+		if _, exists := node.ByName[name]; exists {
+			name = fmt.Sprintf("%s (%s)", name, search.ID)
+		}
+		node.byID[search.ID] = search
+		file := newGeneratedFile(node, name, 60.0, func() ([]byte, error) {
+			cards, err := trello.Search(node.api(), search.Query)
+			if err != nil {
+				return nil, err
+			}
+			var buf bytes.Buffer
+			node.tree.opts.writeCards(&buf, cards)
+			return buf.Bytes(), nil
+		})
+		file.TrelloID = search.ID
+		node.Files = append(node.Files, file)
+		node.ByName[name] = file
+		newNodes = append(newNodes, file)
+	}
+
+	var remaining []*FSGeneratedFile
+	for _, file := range node.Files {
+		if seen[file.GetTrelloID()] {
+			remaining = append(remaining, file)
+			continue
+		}
+		delete(node.ByName, file.GetName())
+		delete(node.byID, file.GetTrelloID())
+		rmNodes = append(rmNodes, file)
+	}
+	node.Files = remaining
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSSavedSearchesDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if file, exists := node.ByName[name]; exists {
+		return file, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSSavedSearchesDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Files))
+	for i, file := range node.Files {
+		entries[i] = file
+	}
+	return entries
+}
@@ -0,0 +1,46 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"testing"
+)
+
+// The user's cards are listed in '.me/cards', soonest due first, and their
+// saved searches' results in '.me/searches'.
+func TestMeDir(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/cards": `[
+			{"id": "c1", "name": "Whenever", "shortLink": "aa"},
+			{"id": "c2", "name": "Later", "shortLink": "bb",
+				"due": "2022-06-01T12:00:00.000Z"},
+			{"id": "c3", "name": "Soon", "shortLink": "cc",
+				"due": "2022-03-01T12:00:00.000Z"}
+		]`,
+		"/members/me/savedSearches": `[
+			{"id": "s1", "name": "Bugs", "query": "label:bug"}
+		]`,
+		"/search": `{"cards": [{"id": "c4", "name": "Crash",
+			"shortLink": "dd"}]}`,
+	})
+	tree := newTestTree(t, Options{})
+
+	want := "cc\t2022-03-01T12:00:00.000Z\tSoon\n" +
+		"bb\t2022-06-01T12:00:00.000Z\tLater\n" +
+		"aa\t-\tWhenever\n"
+	if got := readFile(t, tree, lookupPath(t, tree, ".me/cards")); got != want {
+		t.Errorf(".me/cards:\n%s\nwant:\n%s", got, want)
+	}
+	want = "dd\t-\tCrash\n"
+	bugs := lookupPath(t, tree, ".me/searches/Bugs")
+	if got := readFile(t, tree, bugs); got != want {
+		t.Errorf(".me/searches/Bugs:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,210 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A board's members, one file per member named after their username. Members
+// are added by writing their username or email address to '.invite', and
+// removed by unlinking their file.
+type FSBoardMembersDir struct {
+	BaseFSNode
+
+	BoardNode *FSBoard
+
+	MetaInvite *FSControlFile
+
+	Members  []*FSGeneratedFile
+	ByName   map[string]*FSGeneratedFile
+	byMember map[string]*trello.Member
+}
+
+func newBoardMembersDir(board *FSBoard) *FSBoardMembersDir {
+	node := &FSBoardMembersDir{
+		BaseFSNode: BaseFSNode{
+			name: "members",
+			uid:  board.uid,
+			gid:  board.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  board.uid,
+				Gid:  board.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/members", board.GetTrelloID()),
+			Ctx:      board.Ctx,
+			parent:   board,
+		},
+		BoardNode: board,
+		ByName:    make(map[string]*FSGeneratedFile),
+		byMember:  make(map[string]*trello.Member),
+	}
+	node.MetaInvite = newControlFile(
+		node, ".invite", 0.0,
+		func() ([]byte, error) { return nil, nil },
+		node.invite,
+	)
+	node.MetaInvite.NodeAttrs.Mode = 0200
+	return node
+}
+
+func (node *FSBoardMembersDir) ShouldUpdate() bool {
+	return node.shouldUpdate(30.0)
+}
+
+func (node *FSBoardMembersDir) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	board := node.BoardNode.Board
+	members, err := board.GetMembers(node.api())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+	if node.MetaInvite.GetNodeID() == 0 {
+		newNodes = append(newNodes, node.MetaInvite)
+	}
+
+	seen := make(map[string]bool)
+	for i := range members {
+		member := &members[i]
+		name := escapeName(member.Username)
+		seen[name] = true
+
+		if existing, exists := node.byMember[name]; exists {
+			*existing = *member
+			continue
+		}
+		node.byMember[name] = member
+		file := newGeneratedFile(node, name, 30.0, func() ([]byte, error) {
+			return []byte(fmt.Sprintf(
+				"id: %s\nusername: %s\nfullName: %s\n",
+				member.ID, member.Username, member.FullName,
+			)), nil
+		})
+		node.Members = append(node.Members, file)
+		node.ByName[name] = file
+		newNodes = append(newNodes, file)
+	}
+
+	var remaining []*FSGeneratedFile
+	for _, file := range node.Members {
+		if seen[file.GetName()] {
+			remaining = append(remaining, file)
+			continue
+		}
+		delete(node.ByName, file.GetName())
+		delete(node.byMember, file.GetName())
+		rmNodes = append(rmNodes, file)
+	}
+	node.Members = remaining
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSBoardMembersDir) invite(data []byte) error {
+	board := node.BoardNode.Board
+	for _, line := range strings.Split(string(data), "\n") {
+		who := strings.TrimSpace(line)
+		if who == "" {
+			continue
+		}
+		log.Printf(
+			"invite %s to board %s (%s)\n", who, board.Name, board.ID,
+		)
+		if err := board.AddMember(node.api(), who); err != nil {
+			return err
+		}
+	}
+	node.Lock()
+	node.markStale()
+	node.Unlock()
+	return nil
+}
+
+func (node *FSBoardMembersDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if name == ".invite" {
+		return node.MetaInvite, nil
+	} else if file, exists := node.ByName[name]; exists {
+		return file, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSBoardMembersDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	var entries []FSNode = make([]FSNode, 0, len(node.Members)+1)
+	entries = append(entries, node.MetaInvite)
+	for _, file := range node.Members {
+		entries = append(entries, file)
+	}
+	return entries
+}
+
+// Remove a member from the board.
+func (node *FSBoardMembersDir) Unlink(name string) error {
+	node.Lock()
+	defer node.Unlock()
+
+	member, exists := node.byMember[name]
+	if !exists {
+		return fuse.ENOENT
+	}
+	board := node.BoardNode.Board
+	log.Printf(
+		"remove member %s from board %s (%s)\n",
+		member.Username, board.Name, board.ID,
+	)
+	if err := board.RemoveMember(node.api(), member.ID); err != nil {
+		return apiErrno(err)
+	}
+
+	delete(node.byMember, name)
+	delete(node.ByName, name)
+	var remaining []*FSGeneratedFile
+	for _, file := range node.Members {
+		if file.GetName() != name {
+			remaining = append(remaining, file)
+		}
+	}
+	node.Members = remaining
+	return nil
+}
+
+// The board's members' usernames, keyed by member ID.
+func (node *FSBoardMembersDir) usernames() map[string]string {
+	node.Lock()
+	defer node.Unlock()
+
+	usernames := make(map[string]string)
+	for _, member := range node.byMember {
+		usernames[member.ID] = member.Username
+	}
+	return usernames
+}
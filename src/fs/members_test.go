@@ -0,0 +1,117 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Members are invited by writing their usernames, or email addresses, to
+// '.invite', and removed by unlinking their files, if allowed to.
+func TestBoardMembers(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/boards/b1/members": `[
+			{"id": "m1", "username": "alice", "fullName": "Alice"},
+			{"id": "m2", "username": "bob", "fullName": "Bob"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	members := newBoardMembersDir(board)
+	addNodes(tree, members)
+	ctx := context.Background()
+
+	entries, err := tree.Entries(ctx, members.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := entryNamed(entries, "alice")
+	if alice == nil || entryNamed(entries, "bob") == nil {
+		t.Fatal("board's members not listed")
+	}
+	want := "id: m1\nusername: alice\nfullName: Alice\n"
+	if got := readFile(t, tree, alice); got != want {
+		t.Errorf("alice = %q, want %q", got, want)
+	}
+
+	invite := entryNamed(entries, ".invite")
+	err = writeFile(t, tree, invite, "carol\ndave@example.com\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tree.Unlink(ctx, members.GetNodeID(), "bob")
+	if err != syscall.EPERM {
+		t.Errorf("removed a member unless allowed to: %v", err)
+	}
+	tree.opts.AllowMemberRemoval = true
+	if err := tree.Unlink(ctx, members.GetNodeID(), "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := members.LookupChild("bob"); err == nil {
+		t.Errorf("removed member still found")
+	}
+
+	requests := []string{
+		"PUT /boards/b1/members/carol?type=normal",
+		"PUT /boards/b1/members?email=dave%40example.com",
+		"DELETE /boards/b1/members/m2",
+	}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+}
+
+// Members are assigned to a card by appending their usernames to its
+// 'members' file, and unassigned by removing their lines.
+func TestCardMembersFile(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/boards/b1/members": `[
+			{"id": "m1", "username": "alice", "fullName": "Alice"},
+			{"id": "m2", "username": "bob", "fullName": "Bob"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	card := newTestCard(board, &trello.Card{
+		ID: "c1", Name: "Fix", MemberIDs: []string{"m1"},
+	})
+	members := newCardMembersFile(card)
+	addNodes(tree, members)
+
+	want := "alice\tAlice\n"
+	if got := readFile(t, tree, members); got != want {
+		t.Errorf("members = %q, want %q", got, want)
+	}
+	if err := writeFile(t, tree, members, want+"bob\n"); err != nil {
+		t.Fatal(err)
+	}
+	want = "alice\tAlice\nbob\tBob\n"
+	if got := readFile(t, tree, members); got != want {
+		t.Errorf("members = %q once bob assigned, want %q", got, want)
+	}
+	if err := writeFile(t, tree, members, "@bob\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(t, tree, members, "carol\n"); err == nil {
+		t.Errorf("member not on the board assigned")
+	}
+	requests := []string{
+		"POST /cards/c1/idMembers?value=m2",
+		"DELETE /cards/c1/idMembers/m1",
+	}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+}
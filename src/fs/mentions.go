@@ -0,0 +1,275 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A board's 'by-mention' directory, holding a directory per board member
+// mentioned, as '@<username>', in a card's description or comments, with a
+// symlink to each card mentioning them.
+type FSBoardMentionsDir struct {
+	BaseFSNode
+
+	BoardNode *FSBoard
+
+	Members []*FSMentionDir
+	byName  map[string]*FSMentionDir
+}
+
+type FSMentionDir struct {
+	BaseFSNode
+
+	Links  []*FSSymlink
+	byCard map[string]*FSSymlink
+}
+
+// Mentions are neither preceded by a word character, as in an email
+// address, nor followed by one.
+var mentionRegex = regexp.MustCompile(`(?:^|[^\w@])@(\w+)`)
+
+func newBoardMentionsDir(board *FSBoard) *FSBoardMentionsDir {
+	return &FSBoardMentionsDir{
+		BaseFSNode: BaseFSNode{
+			name: "by-mention",
+			uid:  board.uid,
+			gid:  board.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0500 | os.ModeDir,
+				Uid:  board.uid,
+				Gid:  board.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/by-mention", board.GetTrelloID()),
+			Ctx:      board.Ctx,
+			parent:   board,
+		},
+		BoardNode: board,
+		byName:    make(map[string]*FSMentionDir),
+	}
+}
+
+// Obtain the usernames mentioned in a text, lowercased.
+func mentions(text string) []string {
+	var usernames []string
+	for _, match := range mentionRegex.FindAllStringSubmatch(text, -1) {
+		usernames = append(usernames, strings.ToLower(match[1]))
+	}
+	return usernames
+}
+
+func (node *FSBoardMentionsDir) ShouldUpdate() bool {
+	return node.shouldUpdate(60.0)
+}
+
+// Mentions are found in the cards and members already known, which are
+// refreshed first if due, and in the board's comments, of which Trello only
+// keeps the most recent 1000.
+func (node *FSBoardMentionsDir) Update() ([]FSNode, []FSNode, error) {
+	boardNode := node.BoardNode
+	node.tree.refresh(boardNode.MetaCardsDir)
+	node.tree.refresh(boardNode.MetaMembers)
+
+	comments, err := boardNode.Board.GetComments(node.api())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	members := make(map[string]bool)
+	for _, username := range boardNode.MetaMembers.usernames() {
+		members[strings.ToLower(username)] = true
+	}
+	// cards mentioning each member, by card ID.
+	mentioned := make(map[string]map[string]bool)
+	mention := func(text string, cardID string) {
+		for _, username := range mentions(text) {
+			if !members[username] {
+				continue
+			}
+			if mentioned[username] == nil {
+				mentioned[username] = make(map[string]bool)
+			}
+			mentioned[username][cardID] = true
+		}
+	}
+
+	boardNode.Lock()
+	cards := make(map[string]*FSCard, len(boardNode.ByCardID))
+	for id, card := range boardNode.ByCardID {
+		cards[id] = card
+	}
+	boardNode.Unlock()
+	for id, card := range cards {
+		card.Lock()
+		mention(card.Card.Desc, id)
+		card.Unlock()
+	}
+	for _, comment := range comments {
+		mention(comment.Data.Text, comment.Data.Card.ID)
+	}
+
+	node.Lock()
+	defer node.Unlock()
+
+	var usernames []string
+	for username := range mentioned {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+	var dirs []*FSMentionDir
+	byName := make(map[string]*FSMentionDir)
+	for _, username := range usernames {
+		var mentioning []*FSCard
+		for id := range mentioned[username] {
+			// comments may be on cards since archived or deleted.
+			if card, exists := cards[id]; exists {
+				mentioning = append(mentioning, card)
+			}
+		}
+		if len(mentioning) == 0 {
+			continue
+		}
+		sort.Slice(mentioning, func(i, j int) bool {
+			return mentioning[i].GetName() < mentioning[j].GetName()
+		})
+
+		dir, exists := node.byName[username]
+		if !exists {
+			dir = &FSMentionDir{
+				BaseFSNode: BaseFSNode{
+					name:      username,
+					uid:       node.uid,
+					gid:       node.gid,
+					NodeAttrs: node.GetNodeAttrs(),
+					isDir:     true,
+					TrelloID: fmt.Sprintf(
+						"%s/%s", node.GetTrelloID(), username,
+					),
+					Ctx:    node.Ctx,
+					parent: node,
+				},
+				byCard: make(map[string]*FSSymlink),
+			}
+			newNodes = append(newNodes, dir)
+		}
+		added, removed := dir.setCards(mentioning)
+		newNodes = append(newNodes, added...)
+		rmNodes = append(rmNodes, removed...)
+		byName[username] = dir
+		dirs = append(dirs, dir)
+	}
+	for username, dir := range node.byName {
+		if _, exists := byName[username]; !exists {
+			rmNodes = append(rmNodes, dir)
+		}
+	}
+	node.byName = byName
+	node.Members = dirs
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSBoardMentionsDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if dir, exists := node.byName[name]; exists {
+		return dir, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSBoardMentionsDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Members))
+	for i, dir := range node.Members {
+		entries[i] = dir
+	}
+	return entries
+}
+
+// Link to the cards mentioning the member, replacing links to cards since
+// renamed.
+func (node *FSMentionDir) setCards(cards []*FSCard) ([]FSNode, []FSNode) {
+	node.Lock()
+	defer node.Unlock()
+
+	var added []FSNode
+	var removed []FSNode
+	var links []*FSSymlink
+	byCard := make(map[string]*FSSymlink)
+	for _, card := range cards {
+		link, exists := node.byCard[card.GetTrelloID()]
+		if exists && link.GetName() != card.GetName() {
+			removed = append(removed, link)
+			exists = false
+		}
+		if !exists {
+			link = newSymlink(node, card.GetName(), relativePath(node, card))
+			added = append(added, link)
+		}
+		byCard[card.GetTrelloID()] = link
+		links = append(links, link)
+	}
+	for id, link := range node.byCard {
+		if _, exists := byCard[id]; !exists {
+			removed = append(removed, link)
+		}
+	}
+	node.byCard = byCard
+	node.Links = links
+	return added, removed
+}
+
+func (node *FSMentionDir) ShouldUpdate() bool {
+	return false
+}
+
+// Kept up to date by the 'by-mention' directory.
+func (node *FSMentionDir) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, nil
+}
+
+func (node *FSMentionDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, link := range node.Links {
+		if link.GetName() == name {
+			return link, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSMentionDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Links))
+	for i, link := range node.Links {
+		entries[i] = link
+	}
+	return entries
+}
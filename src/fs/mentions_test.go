@@ -0,0 +1,72 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// Board members mentioned in cards' descriptions or comments, and only
+// them, have a directory in 'by-mention' linking to those cards.
+func TestMentions(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/members": `[
+			{"id": "m1", "username": "alice"},
+			{"id": "m2", "username": "bob"}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Fix", "desc": "Ask @Alice, or @carol"},
+			{"id": "c2", "name": "Mail", "desc": "Write to bob@alice.com"},
+			{"id": "c3", "name": "Ship"}
+		]`,
+		"/boards/b1/actions": `[
+			{"data": {"text": "@bob, please", "card": {"id": "c3"}}},
+			{"data": {"text": "@alice?", "card": {"id": "c9"}}}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	ctx := context.Background()
+
+	got := make(map[string][]string)
+	mentions := lookupPath(t, tree, "eng/Roadmap/by-mention")
+	entries, err := tree.Entries(ctx, mentions.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range entries {
+		if member.GetName() == refreshFileName {
+			continue
+		}
+		cards, err := tree.Entries(ctx, member.GetNodeID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[member.GetName()] = nil
+		for _, card := range cards {
+			if target, err := card.ReadLink(); err == nil {
+				name := member.GetName()
+				got[name] = append(got[name], target)
+			}
+		}
+	}
+	want := map[string][]string{
+		"alice": {"../../../../eng/Roadmap/cards/Fix"},
+		"bob":   {"../../../../eng/Roadmap/cards/Ship"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mentions:\n%q\nwant:\n%q", got, want)
+	}
+}
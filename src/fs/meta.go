@@ -13,6 +13,9 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type MetaEntry struct {
@@ -20,6 +23,11 @@ type MetaEntry struct {
 	Contents []byte
 }
 
+// Obtain a meta file per field of 'item', a struct, named after the field.
+// Fields are rendered according to their type: values implementing
+// fmt.Stringer as such, times as RFC 3339 timestamps, slices with an element
+// per line, and structs with a field per line, as '<field>: <value>'. Fields
+// of types not understood, e.g. maps, are left out.
 func getMeta(item interface{}) []MetaEntry {
 	var entries []MetaEntry
 
@@ -37,37 +45,12 @@ func getMeta(item interface{}) []MetaEntry {
 			i, field.Name, field.Type.Kind(),
 		)
 
-		var contentStr string = ""
-		fieldVal := v.Field(i).Interface()
-		unknown := false
-		switch field.Type.Name() {
-		case "string":
-			contentStr = fieldVal.(string)
-			break
-		case "bool":
-			b := fieldVal.(bool)
-			if b {
-				contentStr = "true"
-			} else {
-				contentStr = "false"
-			}
-			break
-		case "[]string":
-			arr := fieldVal.([]string)
-			for _, entry := range arr {
-				contentStr += fmt.Sprintf("%s\n", entry)
-			}
-			break
-		default:
+		contentStr, known := metaValue(v.Field(i))
+		if !known {
 			log.Printf(
 				"meta > field %d, name: %s, type %s unknown\n",
 				i, field.Name, field.Type.Kind(),
 			)
-			unknown = true
-			break
-		}
-
-		if unknown {
 			continue
 		}
 
@@ -79,3 +62,121 @@ func getMeta(item interface{}) []MetaEntry {
 
 	return entries
 }
+
+// Render a field's value as a meta file's contents.
+func metaValue(v reflect.Value) (string, bool) {
+	if value, known := metaScalar(v); known {
+		return value, true
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return "", true
+		}
+		return metaValue(v.Elem())
+	case reflect.Slice, reflect.Array:
+		var buf strings.Builder
+		for i := 0; i < v.Len(); i++ {
+			line, known := metaLine(v.Index(i))
+			if !known {
+				return "", false
+			}
+			buf.WriteString(line + "\n")
+		}
+		return buf.String(), true
+	case reflect.Struct:
+		var buf strings.Builder
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" || field.Tag.Get("json") == "-" {
+				continue
+			}
+			line, known := metaLine(v.Field(i))
+			if !known {
+				continue
+			}
+			fmt.Fprintf(&buf, "%s: %s\n", field.Name, line)
+		}
+		return buf.String(), true
+	}
+	return "", false
+}
+
+// Render a value on a single line, as a slice's element or a struct's
+// field: structs as their fields' values, tab-separated, and slices as their
+// elements, comma-separated.
+func metaLine(v reflect.Value) (string, bool) {
+	if value, known := metaScalar(v); known {
+		return value, true
+	}
+	var values []string
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return "", true
+		}
+		return metaLine(v.Elem())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			value, known := metaLine(v.Index(i))
+			if !known {
+				return "", false
+			}
+			values = append(values, value)
+		}
+		return strings.Join(values, ", "), true
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" || field.Tag.Get("json") == "-" {
+				continue
+			}
+			if value, known := metaLine(v.Field(i)); known {
+				values = append(values, value)
+			}
+		}
+		return strings.Join(values, "\t"), true
+	}
+	return "", false
+}
+
+// Render a value that isn't made of others: strings, booleans, numbers,
+// times, and whatever implements fmt.Stringer.
+func metaScalar(v reflect.Value) (string, bool) {
+	if !v.CanInterface() {
+		return "", false
+	}
+	switch value := v.Interface().(type) {
+	case time.Time:
+		if value.IsZero() {
+			return "", true
+		}
+		return value.UTC().Format(time.RFC3339), true
+	case *time.Time:
+		// lest it be rendered as a fmt.Stringer.
+		if value == nil || value.IsZero() {
+			return "", true
+		}
+		return value.UTC().Format(time.RFC3339), true
+	case fmt.Stringer:
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return "", true
+		}
+		return value.String(), true
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), true
+	}
+	return "", false
+}
@@ -0,0 +1,139 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+type metaInner struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type metaItem struct {
+	At       time.Time         `json:"at"`
+	AtPtr    *time.Time        `json:"atPtr"`
+	NilPtr   *metaInner        `json:"nilPtr"`
+	Inner    metaInner         `json:"inner"`
+	Inners   []metaInner       `json:"inners"`
+	Tags     []string          `json:"tags"`
+	Map      map[string]string `json:"map"`
+	Skipped  string            `json:"-"`
+	Untagged string
+}
+
+// Obtain the contents of the meta file named 'name', and whether there's one.
+func metaContents(entries []MetaEntry, name string) (string, bool) {
+	for _, entry := range entries {
+		if entry.Name == name {
+			return string(entry.Contents), true
+		}
+	}
+	return "", false
+}
+
+func TestGetMeta(t *testing.T) {
+	at := time.Date(2022, 3, 1, 13, 30, 0, 0, time.FixedZone("", 3600))
+	card := trello.Card{
+		ID:     "c1",
+		Name:   "My card",
+		Closed: true,
+		Pos:    1.5,
+		Labels: []trello.CardLabel{
+			{ID: "l1", Name: "bug", Color: "red"},
+			{ID: "l2", Color: "green"},
+		},
+		MemberIDs: []string{"m1", "m2"},
+		Badges:    trello.CardBadges{CheckItems: 3, CheckItemsChecked: 1},
+		Board:     &trello.Board{ID: "b1"},
+	}
+	board := trello.Board{
+		Name:  "My board",
+		Prefs: trello.BoardPrefs{PermissionLevel: "private", SelfJoin: true},
+	}
+	list := trello.List{Name: "Doing", Board: &board}
+	item := metaItem{
+		At:       at,
+		AtPtr:    &at,
+		Inner:    metaInner{Name: "inner", Count: 2},
+		Inners:   []metaInner{{"a", 1}, {"b", 2}},
+		Tags:     []string{"x", "y"},
+		Map:      map[string]string{"k": "v"},
+		Skipped:  "skipped",
+		Untagged: "untagged",
+	}
+
+	tests := []struct {
+		what     string
+		item     interface{}
+		field    string
+		contents string
+		present  bool
+	}{
+		{"card name", card, "Name", "My card", true},
+		{"card bool", card, "Closed", "true", true},
+		{"card float", card, "Pos", "1.5", true},
+		{"card labels", card, "Labels", "bug (red)\ngreen\n", true},
+		{"card members", card, "MemberIDs", "m1\nm2\n", true},
+		{
+			"card badges", card, "Badges",
+			"CheckItems: 3\nCheckItemsChecked: 1\n", true,
+		},
+		{"card empty due", card, "Due", "", true},
+		{"card board pointer", card, "Board", "", false},
+		{
+			"board prefs", board, "Prefs",
+			"PermissionLevel: private\nComments: \nVoting: \n" +
+				"Invitations: \nSelfJoin: true\nCardCovers: false\n" +
+				"CardAging: \nCalendarFeedEnabled: false\nBackground: \n",
+			true,
+		},
+		{"list name", list, "Name", "Doing", true},
+		{"list pos", list, "Pos", "0", true},
+		{"list board pointer", list, "Board", "", false},
+		{"time", item, "At", "2022-03-01T12:30:00Z", true},
+		{"time pointer", item, "AtPtr", "2022-03-01T12:30:00Z", true},
+		{"nil pointer", item, "NilPtr", "", true},
+		{"nested struct", item, "Inner", "Name: inner\nCount: 2\n", true},
+		{"struct slice", item, "Inners", "a\t1\nb\t2\n", true},
+		{"string slice", item, "Tags", "x\ny\n", true},
+		{"map", item, "Map", "", false},
+		{"json '-'", item, "Skipped", "", false},
+		{"untagged", item, "Untagged", "", false},
+	}
+	for _, test := range tests {
+		contents, present := metaContents(getMeta(test.item), test.field)
+		if present != test.present {
+			t.Errorf(
+				"%s: present %v, expected %v",
+				test.what, present, test.present,
+			)
+		} else if contents != test.contents {
+			t.Errorf(
+				"%s: got %q, expected %q",
+				test.what, contents, test.contents,
+			)
+		}
+	}
+}
+
+func TestGetMetaZeroTime(t *testing.T) {
+	contents, present := metaContents(getMeta(metaItem{}), "At")
+	if !present || contents != "" {
+		t.Errorf(
+			"zero time: got %q (present %v), expected \"\"",
+			contents, present,
+		)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Mirror cards stand for cards on other boards, and hold nothing but their
+// URL. Rather than presenting them as cards of their own, bound to go stale,
+// they're presented as symlinks to the mirrored cards' directories, named
+// after the mirrored cards' short links, both in their board's 'cards' and
+// in their list's directory. Mirrored cards not known yet are linked to
+// through '.resolve', so they're only loaded if followed.
+type mirrorLinks struct {
+	links []*FSSymlink
+
+	// by the mirror card's ID.
+	byID map[string]*FSSymlink
+}
+
+// Split cards into those being cards, and those being mirrors.
+func splitMirrors(cards []trello.Card) ([]trello.Card, []trello.Card) {
+	var own, mirrors []trello.Card
+	for _, card := range cards {
+		if card.Mirrored() != "" {
+			mirrors = append(mirrors, card)
+		} else {
+			own = append(own, card)
+		}
+	}
+	return own, mirrors
+}
+
+// Link 'parent' to the cards 'mirrors' stand for, with 'taken' telling which
+// names are in use by its other entries. Returns the links new, and those
+// gone. Must be called with the parent's lock held.
+func (m *mirrorLinks) update(
+	tree *Tree,
+	parent FSNode,
+	mirrors []trello.Card,
+	taken func(string) bool,
+) ([]FSNode, []FSNode) {
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+	var links []*FSSymlink
+	byID := make(map[string]*FSSymlink)
+	for _, mirror := range mirrors {
+		shortLink := mirror.Mirrored()
+		target := pathToRoot(parent) + ".resolve/c/" + shortLink
+		if card := tree.cardByShortLink(shortLink); card != nil {
+			target = relativePath(parent, card)
+		}
+		link, exists := m.byID[mirror.ID]
+		if exists {
+			link.setTarget(target)
+		} else {
+			name := uniqueName(
+				shortLink, mirror.ShortLink, mirror.ID,
+				func(name string) bool {
+					if taken(name) {
+						return true
+					}
+					for _, link := range links {
+						if link.GetName() == name {
+							return true
+						}
+					}
+					return false
+				},
+			)
+			link = newSymlink(parent, name, target)
+			link.TrelloID = parent.GetTrelloID() + "/" + mirror.ID
+			newNodes = append(newNodes, link)
+		}
+		byID[mirror.ID] = link
+		links = append(links, link)
+	}
+	for id, link := range m.byID {
+		if _, exists := byID[id]; !exists {
+			rmNodes = append(rmNodes, link)
+		}
+	}
+	m.byID = byID
+	m.links = links
+	return newNodes, rmNodes
+}
+
+func (m *mirrorLinks) lookup(name string) FSNode {
+	for _, link := range m.links {
+		if link.GetName() == name {
+			return link
+		}
+	}
+	return nil
+}
+
+func (m *mirrorLinks) entries() []FSNode {
+	entries := make([]FSNode, len(m.links))
+	for i, link := range m.links {
+		entries[i] = link
+	}
+	return entries
+}
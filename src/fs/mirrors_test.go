@@ -0,0 +1,96 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Mirror cards are presented as symlinks to the cards they mirror, if known,
+// or through '.resolve' otherwise, even while their board's cards are looked
+// up as they're refreshed.
+func TestMirrorCards(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"},
+			{"id": "b2", "name": "Ops", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Fix", "shortLink": "aa"},
+			{"id": "m1", "name": "https://trello.com/c/zz",
+				"cardRole": "mirror"},
+			{"id": "m2", "name": "https://trello.com/c/yy/2-gone",
+				"cardRole": "mirror"}
+		]`,
+		"/boards/b2/cards": `[
+			{"id": "c9", "name": "Deploy", "shortLink": "zz"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	ctx := context.Background()
+	ops := lookupPath(t, tree, "eng/Ops/cards")
+	if _, err := tree.Entries(ctx, ops.GetNodeID()); err != nil {
+		t.Fatal(err)
+	}
+	cards := lookupPath(t, tree, "eng/Roadmap/cards")
+	if _, err := tree.Entries(ctx, cards.GetNodeID()); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"zz": "../../../eng/Ops/cards/Deploy",
+		"yy": "../../../.resolve/c/yy",
+	} {
+		link, err := tree.Lookup(ctx, cards.GetNodeID(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target, err := link.ReadLink(); err != nil || target != want {
+			t.Errorf("mirror %s links to %q (%v), want %q",
+				name, target, err, want)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				dir := cards.(*FSBoardCardsDirMeta)
+				dir.Lock()
+				dir.markStale()
+				dir.Unlock()
+				if err := <-listing(tree, cards); err != nil {
+					t.Error(err)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				_, err := tree.Lookup(ctx, cards.GetNodeID(), "zz")
+				if err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("mirrors looked up while refreshed deadlocked")
+	}
+}
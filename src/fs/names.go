@@ -0,0 +1,211 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Directories either hold structural entries only (e.g., a board's 'cards'
+// and 'lists' directories, or a card's meta files), or user-named entities
+// (workspaces, boards, lists, cards). Should a directory holding user-named
+// entities need a structural entry, its name must start with a '.'.
+//
+// To guarantee user-named entities never shadow structural entries, their
+// names are escaped: a leading '.' becomes "%2E", and '%' becomes "%25" so
+// that escaped names can never collide with real names. A '/' can't be part
+// of a file name at all, and becomes "%2F".
+func escapeName(name string) string {
+	name = strings.ReplaceAll(name, "%", "%25")
+	name = strings.ReplaceAll(name, "/", "%2F")
+	if strings.HasPrefix(name, ".") {
+		name = "%2E" + strings.TrimPrefix(name, ".")
+	}
+	return name
+}
+
+// Undo escapeName(), e.g. to obtain a new entity's name from the name of the
+// file or directory being created.
+func unescapeName(name string) string {
+	return strings.NewReplacer(
+		"%25", "%", "%2F", "/", "%2E", ".",
+	).Replace(name)
+}
+
+// Make an (escaped) entity name unique among its siblings' names, for which
+// 'taken' tells whether a name is in use. Empty or taken names are suffixed
+// with the entity's short ID (e.g., a card's short link), as in
+// "TODO (AbCdEf12)", or its full ID, should even that be taken.
+func uniqueName(
+	name string, shortID string, id string, taken func(string) bool,
+) string {
+	if name != "" && !taken(name) {
+		return name
+	}
+	for _, suffix := range []string{shortID, id} {
+		candidate := escapeName(suffix)
+		if name != "" {
+			candidate = fmt.Sprintf("%s (%s)", name, candidate)
+		}
+		if suffix != "" && !taken(candidate) {
+			return candidate
+		}
+	}
+	return fmt.Sprintf("%s (%s)", name, escapeName(id))
+}
+
+// Obtain the name for an entity whose node is named 'current', should that
+// no longer be a name uniqueName() could have given it; e.g., for the entity
+// having been renamed in Trello. Names taken to tell the entity apart from
+// others are kept, even if those others are since gone.
+func renamedName(
+	current string, name string, shortID string, id string,
+	taken func(string) bool,
+) (string, bool) {
+	for _, suffix := range []string{"", shortID, id} {
+		candidate := name
+		if suffix != "" && name != "" {
+			candidate = fmt.Sprintf("%s (%s)", name, escapeName(suffix))
+		} else if suffix != "" {
+			candidate = escapeName(suffix)
+		}
+		if candidate != "" && candidate == current {
+			return current, false
+		}
+	}
+	return uniqueName(name, shortID, id, taken), true
+}
+
+// Lists have no short link; the last characters of their ID, a counter,
+// tell them apart just as well.
+func shortID(id string) string {
+	if len(id) <= 6 {
+		return id
+	}
+	return id[len(id)-6:]
+}
+
+// Emoji for each of Trello's board and label colors.
+var colorEmoji = map[string]string{
+	"green":  "🟩",
+	"lime":   "🟩",
+	"yellow": "🟨",
+	"orange": "🟧",
+	"red":    "🟥",
+	"pink":   "🟥",
+	"purple": "🟪",
+	"blue":   "🟦",
+	"sky":    "🟦",
+	"black":  "⬛",
+	"grey":   "⬜",
+}
+
+// Prefix an (escaped) entity name with its color, according to 'template',
+// in which '{color}' and '{emoji}' are replaced by the color's name and
+// emoji (e.g., "[{color}] ", or "{emoji} "). Names are left alone if there's
+// no template, or the color is not one of Trello's.
+func colorName(template string, color string, name string) string {
+	if template == "" {
+		return name
+	}
+	// labels may come in shades, e.g. 'green_dark'.
+	base := strings.SplitN(color, "_", 2)[0]
+	emoji, known := colorEmoji[base]
+	if !known {
+		return name
+	}
+	prefix := strings.NewReplacer(
+		"{color}", color, "{emoji}", emoji,
+	).Replace(template)
+	return escapeName(prefix) + name
+}
+
+// Name an entity according to 'template', in which each '{{field}}' is
+// replaced by that field's value (e.g., "{{shortLink}}-{{name}}"). Unknown
+// fields are left as they are. Without a template, entities are named after
+// their 'name' field. Either way, the resulting name is escaped.
+func templateName(template string, fields map[string]string) string {
+	if template == "" {
+		return escapeName(fields["name"])
+	}
+	pairs := make([]string, 0, 2*len(fields))
+	for field, value := range fields {
+		pairs = append(pairs, "{{"+field+"}}", value)
+	}
+	return escapeName(strings.NewReplacer(pairs...).Replace(template))
+}
+
+// Format a list's or card's position so that names sort in the same order
+// as Trello shows them, e.g. "0000016384" or "0000024576.5".
+func formatPos(pos float64) string {
+	whole, frac := math.Modf(pos)
+	str := fmt.Sprintf("%010d", int64(whole))
+	if frac != 0 {
+		fracStr := strconv.FormatFloat(frac, 'f', -1, 64)
+		str += strings.TrimPrefix(fracStr, "0")
+	}
+	return str
+}
+
+// Whether entities within the subtree identified by any of 'keys' (e.g., a
+// workspace's or board's name or ID) are to be named after their ID.
+func (opts *Options) idNamed(keys ...string) bool {
+	if opts.IDNames {
+		return true
+	}
+	for _, subtree := range opts.IDNamesIn {
+		for _, key := range keys {
+			if key != "" && key == subtree {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (opts *Options) boardName(board *trello.Board, byID bool) string {
+	if byID {
+		return escapeName(board.ID)
+	}
+	name := templateName(opts.NameTemplates["board"], map[string]string{
+		"name":      board.Name,
+		"id":        board.ID,
+		"shortLink": board.ShortLink,
+	})
+	return colorName(opts.ColorPrefix, board.Prefs.Background, name)
+}
+
+func (opts *Options) listName(list *trello.List, byID bool) string {
+	if byID {
+		return escapeName(list.ID)
+	}
+	return templateName(opts.NameTemplates["list"], map[string]string{
+		"name": list.Name,
+		"id":   list.ID,
+		"pos":  formatPos(list.Pos),
+	})
+}
+
+func (opts *Options) cardName(card *trello.Card, byID bool) string {
+	if byID {
+		return escapeName(card.ID)
+	}
+	return templateName(opts.NameTemplates["card"], map[string]string{
+		"name":      card.Name,
+		"id":        card.ID,
+		"shortLink": card.ShortLink,
+		"pos":       formatPos(card.Pos),
+	})
+}
@@ -0,0 +1,161 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Entity names never pass for structural entries, nor for each other once
+// escaped, and are obtained back from their escaped names.
+func TestEscapeName(t *testing.T) {
+	for name, want := range map[string]string{
+		"To do":      "To do",
+		".summary":   "%2Esummary",
+		"%2Esummary": "%252Esummary",
+		"a/b":        "a%2Fb",
+		"50%":        "50%25",
+		"a.b":        "a.b",
+	} {
+		escaped := escapeName(name)
+		if escaped != want {
+			t.Errorf("escapeName(%q) = %q, want %q", name, escaped, want)
+		}
+		if got := unescapeName(escaped); got != name {
+			t.Errorf("unescapeName(%q) = %q, want %q", escaped, got, name)
+		}
+	}
+}
+
+// Names are prefixed with their color as templated, the prefix escaped
+// like the names themselves.
+func TestColorName(t *testing.T) {
+	for _, test := range []struct {
+		template, color, want string
+	}{
+		{"", "red", "Roadmap"},
+		{"{emoji} ", "red", "🟥 Roadmap"},
+		{"{emoji} ", "sky_light", "🟦 Roadmap"},
+		{"[{color}] ", "sky_light", "[sky_light] Roadmap"},
+		{"{color}/", "red", "red%2FRoadmap"},
+		{"{emoji} ", "", "Roadmap"},
+		{"{emoji} ", "mauve", "Roadmap"},
+	} {
+		got := colorName(test.template, test.color, "Roadmap")
+		if got != test.want {
+			t.Errorf(
+				"colorName(%q, %q) = %q, want %q",
+				test.template, test.color, got, test.want,
+			)
+		}
+	}
+}
+
+// Cards are listed, and looked up, as named by their template, the names
+// sorting as Trello orders them.
+func TestNameTemplates(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Fix", "shortLink": "aB1", "pos": 24576.5},
+			{"id": "c2", "name": "a/b", "shortLink": "cD2", "pos": 16384}
+		]`,
+	})
+	tree := newTestTree(t, Options{NameTemplates: map[string]string{
+		"card": "{{pos}}-{{shortLink}}-{{name}}{{bogus}}",
+	}})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	cards := board.MetaCardsDir
+
+	entries, err := tree.Entries(context.Background(), cards.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.GetName(), ".") {
+			names = append(names, entry.GetName())
+		}
+	}
+	sort.Strings(names)
+	want := []string{
+		"0000016384-cD2-a%2Fb{{bogus}}",
+		"0000024576.5-aB1-Fix{{bogus}}",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("cards listed as %q, want %q", names, want)
+	}
+	card, err := tree.Lookup(context.Background(), cards.GetNodeID(), want[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.GetTrelloID() != "c1" {
+		t.Errorf("%s looked up as %s", want[1], card.GetTrelloID())
+	}
+}
+
+// Cards and lists named alike, or not at all, are told apart by their short
+// IDs, each reachable by a path of its own.
+func TestUniqueNames(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "TODO", "shortLink": "aa", "idList": "l1"},
+			{"id": "c2", "name": "TODO", "shortLink": "bb", "idList": "l1"},
+			{"id": "c3", "name": "", "shortLink": "cc", "idList": "l2"},
+			{"id": "c4", "name": "a/b", "shortLink": "dd", "idList": "l2"}
+		]`,
+		"/boards/b1/lists": `[
+			{"id": "6200000000l1", "name": "Doing"},
+			{"id": "6200000000l2", "name": "Doing"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	cards := lookupPath(t, tree, "eng/Roadmap/cards")
+	entries, err := tree.Entries(context.Background(), cards.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]string)
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.GetName(), ".") {
+			ids[entry.GetName()] = entry.GetTrelloID()
+		}
+	}
+	want := map[string]string{
+		"TODO": "c1", "TODO (bb)": "c2", "cc": "c3", "a%2Fb": "c4",
+	}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("cards listed as %q, want %q", ids, want)
+	}
+	for name, id := range want {
+		card := lookupPath(t, tree, "eng/Roadmap/cards/"+name)
+		if card.GetTrelloID() != id {
+			t.Errorf("%s looked up as %s, want %s",
+				name, card.GetTrelloID(), id)
+		}
+	}
+
+	first := lookupPath(t, tree, "eng/Roadmap/lists/Doing")
+	second := lookupPath(t, tree, "eng/Roadmap/lists/Doing (0000l2)")
+	if first.GetTrelloID() != "6200000000l1" ||
+		second.GetTrelloID() != "6200000000l2" {
+		t.Errorf("lists looked up as %s and %s",
+			first.GetTrelloID(), second.GetTrelloID())
+	}
+}
@@ -0,0 +1,614 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A minimal, read-only, 9P2000 server exporting the node tree, for those
+// environments where FUSE is not available (e.g., WSL, plan9port).
+
+const (
+	p9Tversion = 100
+	p9Tauth    = 102
+	p9Tattach  = 104
+	p9Rerror   = 107
+	p9Tflush   = 108
+	p9Twalk    = 110
+	p9Topen    = 112
+	p9Tcreate  = 114
+	p9Tread    = 116
+	p9Twrite   = 118
+	p9Tclunk   = 120
+	p9Tremove  = 122
+	p9Tstat    = 124
+	p9Twstat   = 126
+)
+
+const (
+	p9QTDir     = 0x80
+	p9DMDir     = 0x80000000
+	p9ORead     = 0
+	p9OExec     = 3
+	p9OTrunc    = 0x10
+	p9ORClose   = 0x40
+	p9MaxMsize  = 64 * 1024
+	p9IOHdrSize = 24
+
+	// size[4] type[1] tag[2], heading every message.
+	p9HdrSize = 7
+)
+
+var errP9ShortMsg = errors.New("short message")
+var errP9ReadOnly = errors.New("read-only filesystem")
+var errP9UnknownFid = errors.New("unknown fid")
+
+type p9Encoder struct {
+	buf []byte
+}
+
+func (e *p9Encoder) u8(v uint8) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *p9Encoder) u16(v uint16) {
+	e.buf = append(e.buf, 0, 0)
+	binary.LittleEndian.PutUint16(e.buf[len(e.buf)-2:], v)
+}
+
+func (e *p9Encoder) u32(v uint32) {
+	e.buf = append(e.buf, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(e.buf[len(e.buf)-4:], v)
+}
+
+func (e *p9Encoder) u64(v uint64) {
+	e.buf = append(e.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint64(e.buf[len(e.buf)-8:], v)
+}
+
+func (e *p9Encoder) str(s string) {
+	e.u16(uint16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *p9Encoder) qid(node FSNode) {
+	if node.GetNodeAttrs().Mode.IsDir() {
+		e.u8(p9QTDir)
+	} else {
+		e.u8(0)
+	}
+	e.u32(0)
+	e.u64(uint64(node.GetNodeID()))
+}
+
+type p9Decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *p9Decoder) take(n int) []byte {
+	if d.err != nil || len(d.buf) < n {
+		d.err = errP9ShortMsg
+		return make([]byte, n)
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *p9Decoder) u8() uint8 {
+	return d.take(1)[0]
+}
+
+func (d *p9Decoder) u16() uint16 {
+	return binary.LittleEndian.Uint16(d.take(2))
+}
+
+func (d *p9Decoder) u32() uint32 {
+	return binary.LittleEndian.Uint32(d.take(4))
+}
+
+func (d *p9Decoder) u64() uint64 {
+	return binary.LittleEndian.Uint64(d.take(8))
+}
+
+func (d *p9Decoder) str() string {
+	n := d.u16()
+	return string(d.take(int(n)))
+}
+
+func p9Stat(node FSNode) []byte {
+	attrs := node.GetNodeAttrs()
+	mode := uint32(attrs.Mode.Perm())
+	length := attrs.Size
+	if attrs.Mode.IsDir() {
+		mode |= p9DMDir
+		length = 0
+	}
+
+	e := &p9Encoder{}
+	e.u16(0) // size, filled in below
+	e.u16(0) // type
+	e.u32(0) // dev
+	e.qid(node)
+	e.u32(mode)
+	e.u32(uint32(attrs.Atime.Unix()))
+	e.u32(uint32(attrs.Mtime.Unix()))
+	e.u64(length)
+	e.str(node.GetName())
+	e.str(fmt.Sprint(attrs.Uid))
+	e.str(fmt.Sprint(attrs.Gid))
+	e.str(fmt.Sprint(attrs.Uid))
+	binary.LittleEndian.PutUint16(e.buf, uint16(len(e.buf)-2))
+	return e.buf
+}
+
+type p9Fid struct {
+	// nodes from the root up to, and including, this fid's node, along with
+	// their inode IDs, kept from being reused for as long as the fid is
+	// around; see remember().
+	path []FSNode
+	ids  []fuseops.InodeID
+	open bool
+
+	dirEntries [][]byte
+	dirIndex   int
+	dirOffset  uint64
+}
+
+func (fid *p9Fid) node() FSNode {
+	return fid.path[len(fid.path)-1]
+}
+
+// Obtain a fid for the nodes along 'path', holding a lookup reference on
+// each, as the kernel does on those it's handed, so that their inode IDs,
+// which qids are made of, aren't reused while the fid refers to them. Fails
+// if any of them has been removed meanwhile.
+func (c *p9Conn) remember(path []FSNode) (*p9Fid, error) {
+	fid := &p9Fid{path: path}
+	for _, node := range path {
+		id := c.tree.Remember(node)
+		if id == 0 {
+			c.forget(fid)
+			return nil, errors.New("file not found")
+		}
+		fid.ids = append(fid.ids, id)
+	}
+	return fid, nil
+}
+
+// Drop the references held by a fid, once clunked.
+func (c *p9Conn) forget(fid *p9Fid) {
+	for _, id := range fid.ids {
+		c.tree.Forget(id, 1)
+	}
+	fid.ids = nil
+}
+
+// Clunk a fid, if it exists.
+func (c *p9Conn) clunk(fid uint32) {
+	if f, exists := c.fids[fid]; exists {
+		c.forget(f)
+		delete(c.fids, fid)
+	}
+}
+
+// Clunk all of the connection's fids, e.g. once it's closed.
+func (c *p9Conn) clunkAll() {
+	for fid := range c.fids {
+		c.clunk(fid)
+	}
+}
+
+type p9Conn struct {
+	tree  *Tree
+	conn  net.Conn
+	msize uint32
+	fids  map[uint32]*p9Fid
+
+	// a pooled buffer holding the whole reply to the message being
+	// handled, room for its header included, should the handler have
+	// built it there rather than returned its body; see read().
+	reply *[]byte
+}
+
+// The 9P server doesn't authenticate clients: whoever can reach it may read
+// whatever the configured credentials can. Addresses without a host, e.g.
+// ':5640', are thus taken to be on the loopback interface rather than on all
+// of them, which must be asked for explicitly, e.g. as '0.0.0.0:5640'.
+func p9ListenAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// Whether a listening address is only reachable from this host.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	} else if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func Serve9P(tree *Tree, addr string) error {
+
+	addr = p9ListenAddr(addr)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("9p > listening on %s\n", addr)
+	if !isLoopback(addr) {
+		log.Printf(
+			"9p > warning: %s may be reached from other hosts, "+
+				"and clients aren't authenticated\n",
+			addr,
+		)
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		c := &p9Conn{
+			tree:  tree,
+			conn:  conn,
+			msize: p9MaxMsize,
+			fids:  make(map[uint32]*p9Fid),
+		}
+		go c.serve()
+	}
+}
+
+func (c *p9Conn) serve() {
+	defer c.conn.Close()
+	defer c.clunkAll()
+	log.Printf("9p > new connection from %s\n", c.conn.RemoteAddr())
+
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(c.conn, hdr[:]); err != nil {
+			if err != io.EOF {
+				log.Printf("9p > error reading message: %s\n", err)
+			}
+			return
+		}
+		size := binary.LittleEndian.Uint32(hdr[:])
+		if size < 7 || size > c.msize {
+			log.Printf("9p > bad message size %d\n", size)
+			return
+		}
+		pooled := getBuffer(int(size - 4))
+		msg := *pooled
+		if _, err := io.ReadFull(c.conn, msg); err != nil {
+			log.Printf("9p > error reading message: %s\n", err)
+			return
+		}
+
+		mtype := msg[0]
+		tag := binary.LittleEndian.Uint16(msg[1:3])
+		d := &p9Decoder{buf: msg[3:]}
+		body, err := c.handle(mtype, d)
+		if err == nil && d.err != nil {
+			err = d.err
+		}
+		reply := c.reply
+		c.reply = nil
+		if reply != nil && err != nil {
+			putBuffer(reply)
+			reply = nil
+		}
+
+		if reply == nil {
+			// nothing refers to the message once handled; the reply is
+			// built in its buffer.
+			e := &p9Encoder{buf: msg[:0]}
+			e.u32(0)
+			if err != nil {
+				e.u8(p9Rerror)
+				e.u16(tag)
+				e.str(err.Error())
+			} else {
+				e.u8(mtype + 1)
+				e.u16(tag)
+				e.buf = append(e.buf, body...)
+			}
+			*pooled = e.buf
+			reply = pooled
+		} else {
+			putBuffer(pooled)
+			(*reply)[4] = mtype + 1
+			binary.LittleEndian.PutUint16((*reply)[5:], tag)
+		}
+		binary.LittleEndian.PutUint32(*reply, uint32(len(*reply)))
+		_, err = c.conn.Write(*reply)
+		putBuffer(reply)
+		if err != nil {
+			log.Printf("9p > error writing reply: %s\n", err)
+			return
+		}
+	}
+}
+
+func (c *p9Conn) handle(mtype uint8, d *p9Decoder) ([]byte, error) {
+	switch mtype {
+	case p9Tversion:
+		return c.version(d)
+	case p9Tauth:
+		return nil, errors.New("authentication not required")
+	case p9Tattach:
+		return c.attach(d)
+	case p9Tflush:
+		// requests are handled sequentially, there is nothing to flush.
+		return nil, nil
+	case p9Twalk:
+		return c.walk(d)
+	case p9Topen:
+		return c.open(d)
+	case p9Tread:
+		return c.read(d)
+	case p9Tclunk:
+		c.clunk(d.u32())
+		return nil, nil
+	case p9Tstat:
+		return c.stat(d)
+	case p9Tremove:
+		c.clunk(d.u32())
+		return nil, errP9ReadOnly
+	case p9Tcreate, p9Twrite, p9Twstat:
+		return nil, errP9ReadOnly
+	}
+	return nil, fmt.Errorf("unknown message type %d", mtype)
+}
+
+func (c *p9Conn) version(d *p9Decoder) ([]byte, error) {
+	msize := d.u32()
+	version := d.str()
+
+	if msize < c.msize {
+		c.msize = msize
+	}
+	c.clunkAll()
+	if !strings.HasPrefix(version, "9P2000") {
+		version = "unknown"
+	} else {
+		version = "9P2000"
+	}
+
+	e := &p9Encoder{}
+	e.u32(c.msize)
+	e.str(version)
+	return e.buf, nil
+}
+
+func (c *p9Conn) attach(d *p9Decoder) ([]byte, error) {
+	fid := d.u32()
+	d.u32() // afid
+	d.str() // uname
+	d.str() // aname
+
+	if _, exists := c.fids[fid]; exists {
+		return nil, errors.New("fid in use")
+	}
+	root := c.tree.GetNode(fuseops.RootInodeID)
+	f, err := c.remember([]FSNode{root})
+	if err != nil {
+		return nil, err
+	}
+	c.fids[fid] = f
+
+	e := &p9Encoder{}
+	e.qid(root)
+	return e.buf, nil
+}
+
+func (c *p9Conn) walk(d *p9Decoder) ([]byte, error) {
+	fid := d.u32()
+	newFid := d.u32()
+	n := d.u16()
+	names := make([]string, n)
+	for i := range names {
+		names[i] = d.str()
+	}
+
+	f, exists := c.fids[fid]
+	if !exists {
+		return nil, errP9UnknownFid
+	}
+	if f.open {
+		return nil, errors.New("fid is open")
+	}
+	if _, exists := c.fids[newFid]; exists && newFid != fid {
+		return nil, errors.New("fid in use")
+	}
+
+	path := make([]FSNode, len(f.path))
+	copy(path, f.path)
+
+	e := &p9Encoder{}
+	e.u16(0) // number of qids, filled in below
+	var walked uint16
+	for _, name := range names {
+		cur := path[len(path)-1]
+		if !cur.GetNodeAttrs().Mode.IsDir() {
+			break
+		}
+		if name == ".." {
+			if len(path) > 1 {
+				path = path[:len(path)-1]
+			}
+		} else if name != "." {
+			child, err := c.tree.Lookup(
+				context.Background(), cur.GetNodeID(), name,
+			)
+			if err != nil {
+				break
+			}
+			path = append(path, child)
+		}
+		e.qid(path[len(path)-1])
+		walked++
+	}
+
+	if walked == 0 && n > 0 {
+		return nil, errors.New("file not found")
+	}
+	if walked == n {
+		walkedFid, err := c.remember(path)
+		if err != nil {
+			return nil, err
+		}
+		c.clunk(newFid)
+		c.fids[newFid] = walkedFid
+	}
+	binary.LittleEndian.PutUint16(e.buf, walked)
+	return e.buf, nil
+}
+
+func (c *p9Conn) open(d *p9Decoder) ([]byte, error) {
+	fid := d.u32()
+	mode := d.u8()
+
+	f, exists := c.fids[fid]
+	if !exists {
+		return nil, errP9UnknownFid
+	}
+	if mode&p9OTrunc != 0 || mode&p9ORClose != 0 ||
+		(mode&3 != p9ORead && mode&3 != p9OExec) {
+		return nil, errP9ReadOnly
+	}
+	f.open = true
+
+	e := &p9Encoder{}
+	e.qid(f.node())
+	e.u32(c.msize - p9IOHdrSize)
+	return e.buf, nil
+}
+
+func (c *p9Conn) read(d *p9Decoder) ([]byte, error) {
+	fid := d.u32()
+	offset := d.u64()
+	count := d.u32()
+
+	f, exists := c.fids[fid]
+	if !exists {
+		return nil, errP9UnknownFid
+	}
+	if !f.open {
+		return nil, errors.New("fid not open")
+	}
+	if count > c.msize-p9IOHdrSize {
+		count = c.msize - p9IOHdrSize
+	}
+
+	node := f.node()
+	var data []byte
+	if node.GetNodeAttrs().Mode.IsDir() {
+		if offset == 0 {
+			entries, err := c.tree.Entries(
+				context.Background(), node.GetNodeID(),
+			)
+			if err != nil {
+				return nil, err
+			}
+			f.dirEntries = make([][]byte, len(entries))
+			for i, entry := range entries {
+				f.dirEntries[i] = p9Stat(entry)
+			}
+			f.dirIndex = 0
+			f.dirOffset = 0
+		} else if offset != f.dirOffset {
+			return nil, errors.New("bad offset in directory read")
+		}
+		// entries are gathered right into the reply, as files are read.
+		pooled := getBuffer(p9HdrSize + 4 + int(count))
+		buf := (*pooled)[:p9HdrSize+4]
+		for ; f.dirIndex < len(f.dirEntries); f.dirIndex++ {
+			entry := f.dirEntries[f.dirIndex]
+			if len(buf)-p9HdrSize-4+len(entry) > int(count) {
+				break
+			}
+			buf = append(buf, entry...)
+		}
+		n := len(buf) - p9HdrSize - 4
+		f.dirOffset += uint64(n)
+		binary.LittleEndian.PutUint32(buf[p9HdrSize:], uint32(n))
+		*pooled = buf
+		c.reply = pooled
+		return nil, nil
+	} else if node.GetNodeAttrs().Mode&os.ModeSymlink != 0 {
+		// plain 9P2000 has no symlinks; present them as their target.
+		target, err := node.ReadLink()
+		if err != nil {
+			return nil, err
+		}
+		if offset < uint64(len(target)) {
+			data = []byte(target[offset:])
+		}
+		if len(data) > int(count) {
+			data = data[:count]
+		}
+	} else {
+		// files are read right into the reply, built in a pooled buffer
+		// whose header is filled in once sent.
+		pooled := getBuffer(p9HdrSize + 4 + int(count))
+		buf := *pooled
+		n, err := c.tree.ReadAt(
+			context.Background(), node.GetNodeID(), buf[p9HdrSize+4:],
+			int64(offset),
+		)
+		if err != nil && err != io.EOF {
+			putBuffer(pooled)
+			return nil, err
+		}
+		binary.LittleEndian.PutUint32(buf[p9HdrSize:], uint32(n))
+		*pooled = buf[:p9HdrSize+4+n]
+		c.reply = pooled
+		return nil, nil
+	}
+
+	e := &p9Encoder{}
+	e.u32(uint32(len(data)))
+	e.buf = append(e.buf, data...)
+	return e.buf, nil
+}
+
+func (c *p9Conn) stat(d *p9Decoder) ([]byte, error) {
+	fid := d.u32()
+
+	f, exists := c.fids[fid]
+	if !exists {
+		return nil, errP9UnknownFid
+	}
+	stat := p9Stat(f.node())
+
+	e := &p9Encoder{}
+	e.u16(uint16(len(stat)))
+	e.buf = append(e.buf, stat...)
+	return e.buf, nil
+}
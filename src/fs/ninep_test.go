@@ -0,0 +1,96 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+func TestP9ListenAddr(t *testing.T) {
+	for _, test := range []struct {
+		addr     string
+		want     string
+		loopback bool
+	}{
+		{":5640", "127.0.0.1:5640", true},
+		{"localhost:5640", "localhost:5640", true},
+		{"127.0.0.1:5640", "127.0.0.1:5640", true},
+		{"[::1]:5640", "[::1]:5640", true},
+		{"0.0.0.0:5640", "0.0.0.0:5640", false},
+		{"192.168.1.2:5640", "192.168.1.2:5640", false},
+	} {
+		got := p9ListenAddr(test.addr)
+		if got != test.want {
+			t.Errorf(
+				"p9ListenAddr(%q) = %q, want %q", test.addr, got, test.want,
+			)
+		}
+		if loopback := isLoopback(got); loopback != test.loopback {
+			t.Errorf(
+				"isLoopback(%q) = %v, want %v", got, loopback, test.loopback,
+			)
+		}
+	}
+}
+
+// Nodes walked to by fids keep their inode IDs until the fids are clunked,
+// even once removed, lest qids end up referring to some other node.
+func TestP9FidReferences(t *testing.T) {
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "My board"})
+	root := tree.GetNode(fuseops.RootInodeID)
+	c := &p9Conn{tree: tree, fids: make(map[uint32]*p9Fid)}
+
+	f, err := c.remember([]FSNode{root, board})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.fids[1] = f
+	// cloning it, as with a walk of no names.
+	e := &p9Encoder{}
+	e.u32(1)
+	e.u32(2)
+	e.u16(0)
+	if _, err := c.handle(p9Twalk, &p9Decoder{buf: e.buf}); err != nil {
+		t.Fatal(err)
+	}
+
+	id := board.GetNodeID()
+	isFree := func() bool {
+		tree.lock.Lock()
+		defer tree.lock.Unlock()
+		for _, free := range tree.freeInodes {
+			if free == id {
+				return true
+			}
+		}
+		return false
+	}
+	tree.lock.Lock()
+	tree.removeNode(board)
+	tree.lock.Unlock()
+	if _, err := c.remember([]FSNode{root, board}); err == nil {
+		t.Errorf("fid obtained for a removed node")
+	}
+
+	for _, fid := range []uint32{1, 2} {
+		if isFree() {
+			t.Fatalf("inode %d freed before fid %d was clunked", id, fid)
+		}
+		c.clunk(fid)
+	}
+	if !isFree() {
+		t.Errorf("inode %d not freed once its fids were clunked", id)
+	}
+}
@@ -9,7 +9,12 @@
  */
 package fs
 
-import "github.com/jacobsa/fuse/fuseops"
+import (
+	"os"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
 
 type FSNode interface {
 	Lock()
@@ -22,9 +27,33 @@ type FSNode interface {
 	GetNodeID() fuseops.InodeID
 	GetNodeAttrs() fuseops.InodeAttributes
 	SetNodeID(fuseops.InodeID)
+	GetParent() FSNode
+	MarkAccessed()
+	GetLastAccessed() time.Time
+	setTree(*Tree, *updateLock)
+	getUpdates() *updateLock
+	isLoaded() bool
+	markStale()
 
 	LookupChild(string) (FSNode, error)
 
-	ReadDir([]byte, int) int
+	GetEntries() []FSNode
 	ReadAt([]byte, int64) (int, error)
+	ReadLink() (string, error)
+
+	WriteAt([]byte, int64) (int, error)
+	Truncate(uint64) error
+	Flush() error
+	Release() error
+
+	Unlink(string) error
+	Create(string) (FSNode, error)
+	MkDir(string) (FSNode, error)
+	RmDir(string) error
+	Rename(string, FSNode, string) error
+	Chmod(os.FileMode) error
+	SetMtime(time.Time) error
+
+	ListXattrs() []string
+	GetXattr(string) ([]byte, error)
 }
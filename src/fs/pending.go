@@ -0,0 +1,117 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// The '.trellofs/pending' directory, holding a read-only file per request
+// changing a card that's queued for Trello couldn't be reached, named
+// '<seq>-<method>-<card id>', describing the request as JSON. Files go away
+// as their requests are made, or refused. Empty unless there's a journal;
+// see TrelloCtx.SetJournal().
+type FSPendingDir struct {
+	BaseFSNode
+
+	Files []*FSGeneratedFile
+	bySeq map[uint64]*FSGeneratedFile
+}
+
+func newPendingDir(parent FSNode) *FSPendingDir {
+	return &FSPendingDir{
+		BaseFSNode: newSnapshotNode(parent, "pending", "_trellofs/pending"),
+		bySeq:      make(map[uint64]*FSGeneratedFile),
+	}
+}
+
+func (node *FSPendingDir) ShouldUpdate() bool {
+	return node.shouldUpdate(5.0)
+}
+
+func pendingName(op *trello.PendingOp) string {
+	card := strings.SplitN(strings.TrimPrefix(op.Endpoint, "/cards/"), "/", 2)
+	return fmt.Sprintf("%08d-%s-%s", op.Seq, op.Method, card[0])
+}
+
+// Describe a queued request as it's queued now.
+func (node *FSPendingDir) describe(seq uint64) ([]byte, error) {
+	for _, op := range node.tree.ctx.Pending() {
+		if op.Seq == seq {
+			contents, err := json.MarshalIndent(op, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			return append(contents, '\n'), nil
+		}
+	}
+	return nil, nil
+}
+
+func (node *FSPendingDir) Update() ([]FSNode, []FSNode, error) {
+	ops := node.tree.ctx.Pending()
+
+	node.Lock()
+	defer node.Unlock()
+
+	var newNodes, rmNodes []FSNode
+	var files []*FSGeneratedFile
+	bySeq := make(map[uint64]*FSGeneratedFile)
+	for _, op := range ops {
+		file, exists := node.bySeq[op.Seq]
+		if !exists {
+			seq := op.Seq
+			file = newGeneratedFile(
+				node, pendingName(op), 0.0,
+				func() ([]byte, error) { return node.describe(seq) },
+			)
+			newNodes = append(newNodes, file)
+		}
+		files = append(files, file)
+		bySeq[op.Seq] = file
+	}
+	for seq, file := range node.bySeq {
+		if _, exists := bySeq[seq]; !exists {
+			rmNodes = append(rmNodes, file)
+		}
+	}
+	node.Files = files
+	node.bySeq = bySeq
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSPendingDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, file := range node.Files {
+		if file.GetName() == name {
+			return file, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSPendingDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Files))
+	for i, file := range node.Files {
+		entries[i] = file
+	}
+	return entries
+}
@@ -0,0 +1,91 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+// When a card's directory is listed, the cards following it in its list are
+// likely to be next, e.g. when walked by 'grep -r' or 'find'. Their contents
+// fetched from Trello, e.g. comments and checklists, are then fetched ahead
+// by the refresh workers, for up to prefetchSiblings cards, and only while
+// there's plenty of API budget left.
+const prefetchSiblings = 4
+
+// Have the contents of the cards following 'card' in its list prefetched by
+// the refresh workers. Must be called with the tree's lock held.
+func (tree *Tree) prefetchSiblings(card *FSCard) {
+	if tree.apiBudget() < refreshLowBudget {
+		return
+	}
+	boardNode, isCardsDir := card.GetParent().(*FSBoardCardsDirMeta)
+	if !isCardsDir {
+		return
+	}
+
+	card.Lock()
+	listID := card.Card.ListID
+	card.Unlock()
+	boardNode.BoardNode.Lock()
+	list := boardNode.BoardNode.ByListID[listID]
+	boardNode.BoardNode.Unlock()
+	if list == nil {
+		return
+	}
+
+	list.Lock()
+	var siblings []*FSCard
+	for i, sibling := range list.Cards {
+		if sibling == card {
+			siblings = append(siblings, list.Cards[i+1:]...)
+			break
+		}
+	}
+	list.Unlock()
+	if len(siblings) > prefetchSiblings {
+		siblings = siblings[:prefetchSiblings]
+	}
+
+	for _, sibling := range siblings {
+		tree.queuePrefetch(sibling)
+	}
+}
+
+// Have a card refreshed by the workers, whether it's due or not, followed by
+// those of its entries yet to be loaded. Must be called with the tree's lock
+// held.
+func (tree *Tree) queuePrefetch(card *FSCard) {
+	if tree.prefetches[card] {
+		return
+	}
+	if !tree.queued[card] {
+		select {
+		case tree.refreshes <- card:
+			tree.queued[card] = true
+		default:
+			return
+		}
+	}
+	tree.prefetches[card] = true
+}
+
+// Queue the entries of a card just refreshed for being prefetched, as far
+// as they're yet to be loaded. Must be called with the tree's lock held.
+func (tree *Tree) prefetchEntries(card FSNode) {
+	if tree.inodes[card.GetNodeID()] != card {
+		return
+	}
+	// cards' own entries are made from what's already known.
+	for _, entry := range card.GetEntries() {
+		if entry.GetNodeID() == 0 {
+			tree.addNode(entry)
+		}
+		if !entry.isLoaded() {
+			tree.queueRefresh(entry)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Listing a card has its siblings queued for the refresh workers to prefetch,
+// rather than prefetched there and then.
+func TestPrefetchSiblings(t *testing.T) {
+	withFixtures(t, nil)
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "My board"})
+	list := &FSList{BaseFSNode: BaseFSNode{TrelloID: "l1"}}
+	board.ByListID["l1"] = list
+	for _, id := range []string{"c1", "c2", "c3"} {
+		card := newTestCard(board, &trello.Card{
+			ID: id, Name: id, ListID: "l1",
+		})
+		list.Cards = append(list.Cards, card)
+	}
+	listed, sibling := list.Cards[0], list.Cards[1]
+
+	// keeping the workers from getting to them meanwhile.
+	tree.updates.Lock()
+	tree.lock.Lock()
+	tree.prefetchSiblings(listed)
+	queued := tree.prefetches[sibling]
+	tree.lock.Unlock()
+	if !queued {
+		t.Errorf("sibling not queued for prefetching")
+	}
+	if sibling.MetaComments != nil {
+		t.Errorf("sibling prefetched there and then")
+	}
+	tree.updates.Unlock()
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		sibling.Lock()
+		comments := sibling.MetaComments
+		sibling.Unlock()
+		tree.lock.Lock()
+		prefetched := comments != nil && comments.GetNodeID() != 0
+		tree.lock.Unlock()
+		if prefetched {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("sibling not prefetched")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
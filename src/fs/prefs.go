@@ -0,0 +1,162 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Board preferences exposed as control files, keyed by Trello's pref name.
+var boardPrefs = []struct {
+	name string
+	get  func(*trello.BoardPrefs) string
+}{
+	{"permissionLevel", func(p *trello.BoardPrefs) string {
+		return p.PermissionLevel
+	}},
+	{"comments", func(p *trello.BoardPrefs) string { return p.Comments }},
+	{"voting", func(p *trello.BoardPrefs) string { return p.Voting }},
+	{"invitations", func(p *trello.BoardPrefs) string {
+		return p.Invitations
+	}},
+	{"selfJoin", func(p *trello.BoardPrefs) string {
+		return strconv.FormatBool(p.SelfJoin)
+	}},
+	{"cardCovers", func(p *trello.BoardPrefs) string {
+		return strconv.FormatBool(p.CardCovers)
+	}},
+	{"cardAging", func(p *trello.BoardPrefs) string { return p.CardAging }},
+	{"calendarFeedEnabled", func(p *trello.BoardPrefs) string {
+		return strconv.FormatBool(p.CalendarFeedEnabled)
+	}},
+}
+
+// Directory holding one control file per board preference. Reading a file
+// returns the preference's current value; writing to it sets a new value.
+type FSBoardPrefsDir struct {
+	BaseFSNode
+
+	BoardNode *FSBoard
+
+	prefs  map[string]string
+	files  []*FSControlFile
+	byName map[string]*FSControlFile
+}
+
+func newBoardPrefsDir(board *FSBoard) *FSBoardPrefsDir {
+	return &FSBoardPrefsDir{
+		BaseFSNode: BaseFSNode{
+			name: "_prefs",
+			uid:  board.uid,
+			gid:  board.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  board.uid,
+				Gid:  board.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/_prefs", board.GetTrelloID()),
+			Ctx:      board.Ctx,
+			parent:   board,
+		},
+		BoardNode: board,
+		prefs:     make(map[string]string),
+		byName:    make(map[string]*FSControlFile),
+	}
+}
+
+func (node *FSBoardPrefsDir) ShouldUpdate() bool {
+	return node.shouldUpdate(30.0)
+}
+
+func (node *FSBoardPrefsDir) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	board := node.BoardNode.Board
+	prefs, err := board.GetPrefs(node.api())
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, pref := range boardPrefs {
+		node.prefs[pref.name] = pref.get(prefs)
+	}
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	if len(node.files) == 0 {
+		for _, pref := range boardPrefs {
+			file := newControlFile(
+				node, pref.name, 0.0,
+				node.getter(pref.name), node.setter(pref.name),
+			)
+			node.files = append(node.files, file)
+			node.byName[pref.name] = file
+			newNodes = append(newNodes, file)
+		}
+	}
+	node.markUpdated()
+	return newNodes, nil, nil
+}
+
+func (node *FSBoardPrefsDir) getter(name string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		node.Lock()
+		defer node.Unlock()
+		return []byte(node.prefs[name] + "\n"), nil
+	}
+}
+
+func (node *FSBoardPrefsDir) setter(name string) func([]byte) error {
+	return func(data []byte) error {
+		value := strings.TrimSpace(string(data))
+		board := node.BoardNode.Board
+		log.Printf(
+			"set pref %s = '%s' on board %s (%s)\n",
+			name, value, board.Name, board.ID,
+		)
+		if err := board.SetPref(node.api(), name, value); err != nil {
+			return err
+		}
+		node.Lock()
+		node.prefs[name] = value
+		node.Unlock()
+		return nil
+	}
+}
+
+func (node *FSBoardPrefsDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if file, exists := node.byName[name]; exists {
+		return file, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSBoardPrefsDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	var entries []FSNode = make([]FSNode, len(node.files))
+	for i, file := range node.files {
+		entries[i] = file
+	}
+	return entries
+}
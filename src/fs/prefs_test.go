@@ -0,0 +1,60 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// A board's '_prefs' files read as its preferences, and set them when
+// written to.
+func TestBoardPrefs(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/boards/b1": `{
+			"id": "b1", "prefs": {"voting": "disabled", "selfJoin": true}
+		}`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	prefs := newBoardPrefsDir(board)
+	tree.lock.Lock()
+	tree.addNode(prefs)
+	tree.lock.Unlock()
+
+	entries, err := tree.Entries(context.Background(), prefs.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	voting := entryNamed(entries, "voting")
+	if voting == nil {
+		t.Fatal("no 'voting' preference")
+	}
+	if got := readFile(t, tree, voting); got != "disabled\n" {
+		t.Errorf("voting = %q, want %q", got, "disabled\n")
+	}
+	selfJoin := entryNamed(entries, "selfJoin")
+	if got := readFile(t, tree, selfJoin); got != "true\n" {
+		t.Errorf("selfJoin = %q, want %q", got, "true\n")
+	}
+
+	if err := writeFile(t, tree, voting, "members\n"); err != nil {
+		t.Fatal(err)
+	}
+	want := "PUT /boards/b1/prefs/voting?value=members"
+	if got := requestsMade(t, dir); len(got) != 1 || got[0] != want {
+		t.Errorf("requests made: %q, want %q", got, want)
+	}
+	if got := readFile(t, tree, voting); got != "members\n" {
+		t.Errorf("voting = %q once set, want %q", got, "members\n")
+	}
+}
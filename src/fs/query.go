@@ -0,0 +1,345 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A filter over cards, e.g.
+//
+//	label == "bug" AND (due < 7d OR idle > 30)
+//
+// Comparisons are combined with AND, OR, and NOT, and grouped with
+// parentheses. Fields are 'name', 'desc', 'list', 'shortLink', 'label',
+// 'member', 'due', and 'idle'. Text fields are compared with '==', '!=', or
+// matched against a glob with '~'; 'label' and 'member' hold when any of the
+// card's labels or members does. 'due' is compared against a date, either
+// absolute ('2022-05-01', or RFC 3339) or relative to now ('7d', '-12h',
+// '2w'), or against 'none'; 'idle' against a number of days.
+type Query struct {
+	expr string
+	root queryNode
+}
+
+// What a query is evaluated against.
+type queryCard struct {
+	name        string
+	desc        string
+	list        string
+	shortLink   string
+	labels      []string
+	colors      []string
+	members     []string
+	due         time.Time // zero if none
+	dueComplete bool
+	idle        int
+}
+
+type queryNode interface {
+	eval(card *queryCard, now time.Time) bool
+}
+
+type queryAnd struct{ lhs, rhs queryNode }
+type queryOr struct{ lhs, rhs queryNode }
+type queryNot struct{ node queryNode }
+
+func (node *queryAnd) eval(card *queryCard, now time.Time) bool {
+	return node.lhs.eval(card, now) && node.rhs.eval(card, now)
+}
+
+func (node *queryOr) eval(card *queryCard, now time.Time) bool {
+	return node.lhs.eval(card, now) || node.rhs.eval(card, now)
+}
+
+func (node *queryNot) eval(card *queryCard, now time.Time) bool {
+	return !node.node.eval(card, now)
+}
+
+var queryTextFields = map[string]func(card *queryCard) []string{
+	"name": func(card *queryCard) []string { return []string{card.name} },
+	"desc": func(card *queryCard) []string { return []string{card.desc} },
+	"list": func(card *queryCard) []string { return []string{card.list} },
+	"shortLink": func(card *queryCard) []string {
+		return []string{card.shortLink}
+	},
+	"label":  func(card *queryCard) []string { return card.labels },
+	"member": func(card *queryCard) []string { return card.members },
+}
+
+// A comparison of a text field. For fields with several values, '!=' holds
+// if none of them is equal.
+type queryText struct {
+	values func(card *queryCard) []string
+	op     string
+	value  string
+}
+
+func (node *queryText) eval(card *queryCard, now time.Time) bool {
+	for _, value := range node.values(card) {
+		switch node.op {
+		case "==", "!=":
+			if value == node.value {
+				return node.op == "=="
+			}
+		case "~":
+			if matched, _ := path.Match(node.value, value); matched {
+				return true
+			}
+		}
+	}
+	return node.op == "!="
+}
+
+type queryIdle struct {
+	op    string
+	value int
+}
+
+func (node *queryIdle) eval(card *queryCard, now time.Time) bool {
+	if card.idle < 0 {
+		return false
+	}
+	return compareInts(card.idle, node.op, node.value)
+}
+
+// A comparison of the due date, against either an absolute date, one
+// relative to the time of evaluation, or none.
+type queryDue struct {
+	op       string
+	none     bool
+	date     time.Time
+	relative time.Duration
+}
+
+func (node *queryDue) eval(card *queryCard, now time.Time) bool {
+	if node.none {
+		return card.due.IsZero() == (node.op == "==")
+	} else if card.due.IsZero() {
+		return false
+	}
+	date := node.date
+	if date.IsZero() {
+		date = now.Add(node.relative)
+	}
+	if node.op == "==" || node.op == "!=" {
+		y1, m1, d1 := card.due.UTC().Date()
+		y2, m2, d2 := date.UTC().Date()
+		same := y1 == y2 && m1 == m2 && d1 == d2
+		return same == (node.op == "==")
+	}
+	cmp := 0
+	if card.due.Before(date) {
+		cmp = -1
+	} else if card.due.After(date) {
+		cmp = 1
+	}
+	return compareInts(cmp, node.op, 0)
+}
+
+func compareInts(lhs int, op string, rhs int) bool {
+	switch op {
+	case "==":
+		return lhs == rhs
+	case "!=":
+		return lhs != rhs
+	case "<":
+		return lhs < rhs
+	case "<=":
+		return lhs <= rhs
+	case ">":
+		return lhs > rhs
+	case ">=":
+		return lhs >= rhs
+	}
+	return false
+}
+
+var queryTokenRE = regexp.MustCompile(
+	`^(\s+|\(|\)|==|!=|<=|>=|<|>|~|"(?:[^"\\]|\\.)*"|[^\s()=!<>~"]+)`,
+)
+
+var relativeDateRE = regexp.MustCompile(`^(-?\d+)([hdw])$`)
+
+type queryParser struct {
+	tokens []string
+	pos    int
+}
+
+// Parse a query, as described in Query.
+func ParseQuery(expr string) (*Query, error) {
+	parser := &queryParser{}
+	for rest := expr; rest != ""; {
+		token := queryTokenRE.FindString(rest)
+		if token == "" {
+			return nil, fmt.Errorf("unexpected '%s'", rest)
+		}
+		rest = rest[len(token):]
+		if strings.TrimSpace(token) != "" {
+			parser.tokens = append(parser.tokens, token)
+		}
+	}
+
+	root, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	} else if parser.pos < len(parser.tokens) {
+		return nil, fmt.Errorf("unexpected '%s'", parser.tokens[parser.pos])
+	}
+	return &Query{expr: expr, root: root}, nil
+}
+
+func (query *Query) String() string {
+	return query.expr
+}
+
+func (query *Query) matches(card *queryCard, now time.Time) bool {
+	return query.root.eval(card, now)
+}
+
+func (parser *queryParser) peek() string {
+	if parser.pos >= len(parser.tokens) {
+		return ""
+	}
+	return parser.tokens[parser.pos]
+}
+
+func (parser *queryParser) next() (string, error) {
+	if parser.pos >= len(parser.tokens) {
+		return "", fmt.Errorf("unexpected end of query")
+	}
+	parser.pos++
+	return parser.tokens[parser.pos-1], nil
+}
+
+func (parser *queryParser) parseOr() (queryNode, error) {
+	lhs, err := parser.parseAnd()
+	for err == nil && strings.EqualFold(parser.peek(), "OR") {
+		parser.pos++
+		var rhs queryNode
+		if rhs, err = parser.parseAnd(); err == nil {
+			lhs = &queryOr{lhs, rhs}
+		}
+	}
+	return lhs, err
+}
+
+func (parser *queryParser) parseAnd() (queryNode, error) {
+	lhs, err := parser.parseUnary()
+	for err == nil && strings.EqualFold(parser.peek(), "AND") {
+		parser.pos++
+		var rhs queryNode
+		if rhs, err = parser.parseUnary(); err == nil {
+			lhs = &queryAnd{lhs, rhs}
+		}
+	}
+	return lhs, err
+}
+
+func (parser *queryParser) parseUnary() (queryNode, error) {
+	if strings.EqualFold(parser.peek(), "NOT") {
+		parser.pos++
+		node, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNot{node}, nil
+	} else if parser.peek() == "(" {
+		parser.pos++
+		node, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if token, err := parser.next(); err != nil {
+			return nil, err
+		} else if token != ")" {
+			return nil, fmt.Errorf("expected ')', got '%s'", token)
+		}
+		return node, nil
+	}
+	return parser.parseComparison()
+}
+
+func (parser *queryParser) parseComparison() (queryNode, error) {
+	field, err := parser.next()
+	if err != nil {
+		return nil, err
+	}
+	op, err := parser.next()
+	if err != nil {
+		return nil, err
+	}
+	value, err := parser.next()
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(value, `"`) {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", value)
+		}
+		value = unquoted
+	}
+
+	if values, isText := queryTextFields[field]; isText {
+		if op != "==" && op != "!=" && op != "~" {
+			return nil, badOperator(field, op)
+		}
+		return &queryText{values: values, op: op, value: value}, nil
+	}
+
+	if field != "idle" && field != "due" {
+		return nil, fmt.Errorf("unknown field '%s'", field)
+	}
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return nil, badOperator(field, op)
+	}
+	if field == "idle" {
+		days, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number of days '%s'", value)
+		}
+		return &queryIdle{op: op, value: days}, nil
+	}
+	return parseDueComparison(op, value)
+}
+
+func badOperator(operand string, op string) error {
+	return fmt.Errorf("'%s' can't be compared with '%s'", operand, op)
+}
+
+func parseDueComparison(op string, value string) (queryNode, error) {
+	if value == "none" {
+		if op != "==" && op != "!=" {
+			return nil, badOperator(value, op)
+		}
+		return &queryDue{op: op, none: true}, nil
+	}
+	if match := relativeDateRE.FindStringSubmatch(value); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		unit := map[string]time.Duration{
+			"h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour,
+		}[match[2]]
+		return &queryDue{op: op, relative: time.Duration(n) * unit}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return &queryDue{op: op, date: date}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid date '%s'", value)
+}
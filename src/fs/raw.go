@@ -0,0 +1,33 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// A card's, list's, or board's '.raw.json', holding the entity as Trello
+// serves it, 'kind' being its endpoint's, e.g. "cards". Unlike the meta
+// files, nothing is left out, so scripts may have all of it, e.g. with jq.
+func newRawFile(
+	parent FSNode,
+	ctx *trello.TrelloCtx,
+	kind string,
+) *FSGeneratedFile {
+	var file *FSGeneratedFile
+	file = newGeneratedFile(
+		parent, ".raw.json", 30.0, func() ([]byte, error) {
+			return trello.GetRaw(
+				file.tree.api(ctx, file.updates), kind, parent.GetTrelloID(),
+			)
+		},
+	)
+	return file
+}
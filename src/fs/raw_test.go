@@ -0,0 +1,68 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+)
+
+// Boards, lists, and cards' '.raw.json' hold them as Trello serves them,
+// fields the meta files don't know of included.
+func TestRawFiles(t *testing.T) {
+	raw := map[string]string{
+		"/boards/b1": `{"id": "b1", "name": "Roadmap", "idOrganization": "w1",
+			"prefs": {"background": "blue"}}`,
+		"/lists/l1": `{"id": "l1", "name": "To do", "softLimit": 10}`,
+		"/cards/c1": `{"id": "c1", "name": "Fix", "idList": "l1",
+			"customFieldItems": [{"idCustomField": "f1", "value": {}}]}`,
+	}
+	fixtures := map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": `[{"id": "c1", "name": "Fix", "idList": "l1"}]`,
+		"/boards/b1/lists": `[{"id": "l1", "name": "To do"}]`,
+		"/lists/l1/cards":  `[{"id": "c1", "name": "Fix", "idList": "l1"}]`,
+	}
+	for endpoint, body := range raw {
+		fixtures[endpoint] = body
+	}
+	withFixtures(t, fixtures)
+	tree := newTestTree(t, Options{})
+
+	// as read all at once.
+	var wg sync.WaitGroup
+	for endpoint, path := range map[string]string{
+		"/boards/b1": "eng/Roadmap/.raw.json",
+		"/lists/l1":  "eng/Roadmap/lists/To do/.raw.json",
+		"/cards/c1":  "eng/Roadmap/cards/Fix/.raw.json",
+	} {
+		file := lookupPath(t, tree, path)
+		want := raw[endpoint]
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			buf := make([]byte, 1<<16)
+			n, err := tree.ReadAt(
+				context.Background(), file.GetNodeID(), buf, 0,
+			)
+			if err != nil && err != io.EOF {
+				t.Errorf("read %s: %s", path, err)
+			} else if got := string(buf[:n]); got != want {
+				t.Errorf("%s:\n%s\nwant:\n%s", path, got, want)
+			}
+		}(path)
+	}
+	wg.Wait()
+}
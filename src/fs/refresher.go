@@ -0,0 +1,166 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"container/heap"
+	"log"
+	"time"
+)
+
+const refreshTick = 10 * time.Second
+
+// Below this fraction of the API budget, only nodes accessed within
+// refreshHotWindow are refreshed in the background; below refreshMinBudget,
+// background refreshes stop altogether until the budget recovers.
+const refreshLowBudget = 0.3
+const refreshMinBudget = 0.1
+const refreshHotWindow = 30 * time.Second
+
+// Nodes due for a refresh, most recently accessed first.
+type refreshQueue []FSNode
+
+func (q refreshQueue) Len() int { return len(q) }
+
+func (q refreshQueue) Less(i, j int) bool {
+	return q[i].GetLastAccessed().After(q[j].GetLastAccessed())
+}
+
+func (q refreshQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *refreshQueue) Push(x interface{}) {
+	*q = append(*q, x.(FSNode))
+}
+
+func (q *refreshQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	node := old[n-1]
+	*q = old[:n-1]
+	return node
+}
+
+// Periodically refresh those nodes that have been accessed recently, so
+// they are up to date by the time they are accessed again. Nodes nobody has
+// accessed within the idle timeout are left alone, and will only be
+// refreshed again once accessed, keeping idle mounts from generating API
+// traffic.
+func (tree *Tree) refresher() {
+	for range time.Tick(refreshTick) {
+		tree.refreshActive()
+	}
+}
+
+// The lowest API budget left amongst all credentials in use.
+func (tree *Tree) apiBudget() float64 {
+	budget := 1.0
+	for _, ctx := range tree.allContexts() {
+		if b := ctx.Budget(); b < budget {
+			budget = b
+		}
+	}
+	return budget
+}
+
+func (tree *Tree) refreshActive() {
+
+	tree.lock.Lock()
+	queue := &refreshQueue{}
+	for _, node := range tree.inodes {
+		if node == nil {
+			continue
+		}
+		if time.Since(node.GetLastAccessed()) < tree.opts.IdleTimeout &&
+			node.ShouldUpdate() {
+			*queue = append(*queue, node)
+		}
+	}
+	tree.lock.Unlock()
+
+	if queue.Len() == 0 {
+		return
+	}
+	log.Printf("refresher > %d nodes due for refresh\n", queue.Len())
+	heap.Init(queue)
+
+	// Don't hold the locks for the whole pass, or we would be blocking
+	// operations for as long as it takes.
+	for queue.Len() > 0 {
+		node := heap.Pop(queue).(FSNode)
+
+		budget := tree.apiBudget()
+		if budget < refreshMinBudget ||
+			(budget < refreshLowBudget &&
+				time.Since(node.GetLastAccessed()) >= refreshHotWindow) {
+			log.Printf(
+				"refresher > api budget at %.0f%%, deferring %d nodes\n",
+				budget*100, queue.Len()+1,
+			)
+			return
+		}
+
+		updates := tree.lockUpdatesFor(nil, node)
+		tree.lock.Lock()
+		tree.refreshNode(node)
+		tree.lock.Unlock()
+		tree.unlockUpdates(updates)
+	}
+}
+
+// Directories looked up, or listed, while out of date are refreshed by a
+// pool of workers, rather than while the lookup waits, so those are
+// answered straight away from what is already known. Workers refreshing
+// nodes on different boards do so in parallel. Requests beyond the queue's
+// size are dropped, to be picked up by the periodic refresh.
+const refreshWorkers = 4
+const refreshQueueSize = 256
+
+// Refresh a directory about to be served. Those yet to be loaded, or marked
+// stale, e.g. after being modified, are refreshed right away, having nothing
+// worth serving, failing if they can't be; others only once a worker gets to
+// them. Whether it's loaded is as the caller found it when deciding whether
+// to take its updates lock, which must be held if not. Must be called with
+// the tree's lock held.
+func (tree *Tree) refreshDir(node FSNode, loaded bool) error {
+	if !loaded {
+		return tree.refreshNode(node)
+	}
+	tree.queueRefresh(node)
+	return nil
+}
+
+// Have a node refreshed by the workers, if it's due and not already queued.
+// Must be called with the tree's lock held.
+func (tree *Tree) queueRefresh(node FSNode) {
+	if tree.queued[node] || !node.ShouldUpdate() {
+		return
+	}
+	select {
+	case tree.refreshes <- node:
+		tree.queued[node] = true
+	default:
+	}
+}
+
+func (tree *Tree) refreshWorker() {
+	for node := range tree.refreshes {
+		updates := tree.lockUpdatesFor(nil, node)
+		tree.lock.Lock()
+		delete(tree.queued, node)
+		prefetch := tree.prefetches[node]
+		delete(tree.prefetches, node)
+		tree.refreshNode(node)
+		if prefetch {
+			tree.prefetchEntries(node)
+		}
+		tree.lock.Unlock()
+		tree.unlockUpdates(updates)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"container/heap"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Only nodes accessed within the idle timeout are refreshed in the
+// background; others are left alone until accessed again.
+func TestRefreshActive(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/boards/b1/cards": `[{"id": "c1", "name": "One"}]`,
+		"/boards/b2/cards": `[{"id": "c2", "name": "Two"}]`,
+	})
+	tree := newTestTree(t, Options{IdleTimeout: time.Minute})
+	active := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Active"})
+	idle := newTestBoard(tree, &trello.Board{ID: "b2", Name: "Idle"})
+	active.MetaCardsDir.MarkAccessed()
+
+	tree.refreshActive()
+	if !active.MetaCardsDir.isLoaded() {
+		t.Errorf("recently accessed node not refreshed")
+	}
+	if idle.MetaCardsDir.isLoaded() {
+		t.Errorf("never accessed node refreshed")
+	}
+}
+
+// Nodes due are refreshed most recently accessed first.
+func TestRefreshQueueOrder(t *testing.T) {
+	withFixtures(t, nil)
+	tree := newTestTree(t, Options{})
+	queue := &refreshQueue{}
+	for name, ago := range map[string]time.Duration{
+		"Old": time.Minute, "New": 0, "Older": 2 * time.Minute,
+	} {
+		board := newTestBoard(tree, &trello.Board{ID: name, Name: name})
+		board.lastAccess = time.Now().Add(-ago)
+		heap.Push(queue, board)
+	}
+	for _, want := range []string{"New", "Old", "Older"} {
+		if node := heap.Pop(queue).(FSNode); node.GetName() != want {
+			t.Errorf("refreshed %s, want %s", node.GetName(), want)
+		}
+	}
+}
+
+// Directories merely out of date are listed straight away, as they were,
+// while refreshed in the background, even if that's held back meanwhile.
+func TestRefreshInBackground(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/boards/b1/cards": `[{"id": "c1", "name": "One"}]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	cards := board.MetaCardsDir
+	if err := <-listing(tree, cards); err != nil {
+		t.Fatal(err)
+	}
+	err := ioutil.WriteFile(
+		filepath.Join(dir, "boards-b1-cards.json"),
+		[]byte(`[{"id": "c1", "name": "One"}, {"id": "c2", "name": "Two"}]`),
+		0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.lock.Lock()
+	cards.Lock()
+	cards.lastUpdate = time.Now().Add(-time.Hour)
+	cards.Unlock()
+	tree.lock.Unlock()
+
+	updates := tree.lockUpdatesFor(nil, board)
+	select {
+	case err := <-listing(tree, cards):
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("listing waited for the directory to be refreshed")
+	}
+	if board.ByCardID["c2"] != nil {
+		t.Errorf("directory refreshed while its board's updates were held")
+	}
+	tree.unlockUpdates(updates)
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		time.Sleep(10 * time.Millisecond)
+		tree.lock.Lock()
+		board.Lock()
+		refreshed := board.ByCardID["c2"] != nil
+		board.Unlock()
+		tree.lock.Unlock()
+		if refreshed {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("directory never refreshed in the background")
+		}
+	}
+}
+
+// Nodes are refreshed as often as configured for what they hold, never
+// once loaded if configured so, or as often as they would otherwise.
+func TestRefreshIntervals(t *testing.T) {
+	withFixtures(t, map[string]string{"/boards/b1/cards": `[]`})
+	tree := newTestTree(t, Options{
+		RefreshIntervals: map[string]time.Duration{
+			"cards": 0, "lists": time.Hour,
+		},
+	})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	list := newTestList(board, &trello.List{ID: "l1", Name: "To do"})
+	cards := board.MetaCardsDir
+	if !cards.ShouldUpdate() {
+		t.Errorf("cards never to be refreshed not loaded")
+	}
+	if err := <-listing(tree, cards); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		node FSNode
+		ago  time.Duration
+		want bool
+	}{
+		{cards, 24 * time.Hour, false},
+		{list, 30 * time.Minute, false},
+		{list, 2 * time.Hour, true},
+		{board, time.Minute, true},
+		{board, 10 * time.Second, false},
+	} {
+		base := map[FSNode]*BaseFSNode{
+			cards: &cards.BaseFSNode,
+			list:  &list.BaseFSNode,
+			board: &board.BaseFSNode,
+		}[test.node]
+		base.Lock()
+		base.lastUpdate = time.Now().Add(-test.ago)
+		base.Unlock()
+		if got := test.node.ShouldUpdate(); got != test.want {
+			t.Errorf(
+				"%s updated %s ago due: %v, want %v",
+				test.node.GetName(), test.ago, got, test.want,
+			)
+		}
+	}
+}
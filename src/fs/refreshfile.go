@@ -0,0 +1,115 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Name of the file in every directory through which it's refreshed.
+const refreshFileName = ".refresh"
+
+// A directory's '.refresh' file. Writing anything to it refreshes the
+// directory right away, along with whatever within it has been loaded or
+// accessed, whether due or not. Reads as empty.
+type FSRefreshFile struct {
+	BaseFSNode
+
+	dir FSNode
+}
+
+// Obtain a directory's refresh file, creating it if needed. Must be called
+// with the tree's lock held.
+func (tree *Tree) refreshFile(dir FSNode) *FSRefreshFile {
+	if file, exists := tree.refreshFiles[dir]; exists {
+		return file
+	}
+	attrs := dir.GetNodeAttrs()
+	file := &FSRefreshFile{
+		BaseFSNode: BaseFSNode{
+			name: refreshFileName,
+			uid:  attrs.Uid,
+			gid:  attrs.Gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode:  0600,
+				Nlink: 1,
+				Uid:   attrs.Uid,
+				Gid:   attrs.Gid,
+			},
+			isDir: false,
+			TrelloID: fmt.Sprintf(
+				"%s/%s", dir.GetTrelloID(), refreshFileName,
+			),
+			parent: dir,
+		},
+		dir: dir,
+	}
+	tree.refreshFiles[dir] = file
+	tree.addNode(file)
+	return file
+}
+
+// Refresh a node, then whichever of its children have been loaded or
+// accessed, regardless of whether they're due. Must be called with both the
+// updates lock and the tree's lock held.
+func (tree *Tree) refreshSubtree(node FSNode) error {
+	node.markStale()
+	if err := tree.refreshNode(node); err != nil {
+		return err
+	}
+	children := node.GetEntries()
+	// the root's '.trellofs' and the like are the tree's own entries.
+	if node.GetNodeID() == fuseops.RootInodeID {
+		children = append(children, tree.rootEntries...)
+	}
+	for _, child := range children {
+		if child.GetParent() != node || child.GetNodeID() == 0 {
+			continue
+		}
+		if child.isLoaded() || !child.GetLastAccessed().IsZero() {
+			tree.refreshSubtree(child)
+		}
+	}
+	return nil
+}
+
+func (node *FSRefreshFile) ShouldUpdate() bool {
+	return false
+}
+
+func (node *FSRefreshFile) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, fuse.EINVAL
+}
+
+func (node *FSRefreshFile) LookupChild(name string) (FSNode, error) {
+	return nil, fuse.ENOENT
+}
+
+func (node *FSRefreshFile) GetEntries() []FSNode {
+	return nil
+}
+
+// Called with the updates lock held.
+func (node *FSRefreshFile) WriteAt(data []byte, offset int64) (int, error) {
+	node.tree.lock.Lock()
+	defer node.tree.lock.Unlock()
+	if err := node.tree.refreshSubtree(node.dir); err != nil {
+		return 0, apiErrno(err)
+	}
+	return len(data), nil
+}
+
+// Writes are handled as they come, so there's nothing to truncate.
+func (node *FSRefreshFile) Truncate(size uint64) error {
+	return nil
+}
@@ -0,0 +1,60 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// Writing to a directory's '.refresh' refreshes it right away, along with
+// whatever within it was loaded, though not due, leaving the rest be.
+func TestRefreshFile(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": `[{"id": "c1", "name": "Fix"}]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := lookupPath(t, tree, "eng/Roadmap").(*FSBoard)
+	lookupPath(t, tree, "eng/Roadmap/cards/Fix")
+	err := ioutil.WriteFile(
+		filepath.Join(dir, "boards-b1-cards.json"),
+		[]byte(`[{"id": "c1", "name": "Fix"}, {"id": "c2", "name": "Ship"}]`),
+		0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board.MetaCardsDir.ShouldUpdate() {
+		t.Fatal("cards due for a refresh already")
+	}
+
+	refresh := lookupPath(t, tree, "eng/Roadmap/.refresh")
+	_, err = tree.WriteAt(
+		context.Background(), refresh.GetNodeID(), []byte("1\n"), 0,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := board.MetaCardsDir.LookupChild("Ship"); err != nil {
+		t.Errorf("cards not refreshed: %v", err)
+	}
+	if board.MetaListsDir.isLoaded() {
+		t.Errorf("lists never looked at loaded")
+	}
+	if got := readFile(t, tree, refresh); got != "" {
+		t.Errorf(".refresh read as %q", got)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// A card's '.reminder' file, there for as long as the card has a due date,
+// and modified at it, so that reminder scripts may find cards coming due
+// with, e.g., 'find -name .reminder -newermt now ! -newermt tomorrow'. It
+// holds a systemd timer unit going off at the due date.
+func newReminderFile(card *FSCard) *FSGeneratedFile {
+	return newGeneratedFile(card, ".reminder", 30.0, card.reminder)
+}
+
+// Generate the card's systemd timer unit, as
+//
+//	[Unit]
+//	Description=Trello card due: <name>
+//
+//	[Timer]
+//	OnCalendar=<due date, in UTC>
+//	Persistent=true
+//
+// to be installed as '<unit>.timer', next to a '<unit>.service' doing the
+// reminding.
+func (node *FSCard) reminder() ([]byte, error) {
+	node.Lock()
+	name := node.Card.Name
+	due := cardMtime(node.Card)
+	node.Unlock()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "[Unit]\nDescription=Trello card due: %s\n\n", name)
+	fmt.Fprintf(
+		&buf, "[Timer]\nOnCalendar=%s\nPersistent=true\n",
+		due.UTC().Format("2006-01-02 15:04:05 UTC"),
+	)
+	return buf.Bytes(), nil
+}
+
+// Keep the card's reminder file in line with its due date, returning the
+// file if it was just created, or removed. Cards only have one from the v2
+// layout on. Called with the card's lock held.
+func (node *FSCard) updateReminder() (FSNode, FSNode) {
+	due := cardMtime(node.Card)
+	if due.IsZero() || !node.tree.opts.layoutSince("v2") {
+		removed := node.MetaReminder
+		node.MetaReminder = nil
+		if removed == nil {
+			return nil, nil
+		}
+		return nil, removed
+	}
+
+	var added FSNode
+	if node.MetaReminder == nil {
+		node.MetaReminder = newReminderFile(node)
+		added = node.MetaReminder
+	}
+	node.setReminderMtime(due)
+	return added, nil
+}
+
+// Called with the card's lock held.
+func (node *FSCard) setReminderMtime(due time.Time) {
+	if node.MetaReminder == nil || due.IsZero() {
+		return
+	}
+	node.MetaReminder.setModTime(due)
+}
@@ -0,0 +1,65 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Cards due have a '.reminder', modified at their due date, holding a
+// systemd timer going off then, following the due date as it's changed.
+func TestCardReminder(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Fix", "due": "2022-03-01T12:00:00.000Z"},
+			{"id": "c2", "name": "Someday"}
+		]`,
+		"/boards/b1/lists": `[]`,
+		"/cards/c1": `{"id": "c1", "name": "Fix",
+			"due": "2022-03-01T12:00:00.000Z"}`,
+		"PUT /cards/c1": `{"id": "c1", "name": "Fix",
+			"due": "2022-04-01T09:30:00.000Z"}`,
+	})
+	tree := newTestTree(t, Options{Layout: "v2"})
+	reminder := lookupPath(t, tree, "eng/Roadmap/cards/Fix/.reminder")
+
+	want := "[Unit]\nDescription=Trello card due: Fix\n\n" +
+		"[Timer]\nOnCalendar=2022-03-01 12:00:00 UTC\nPersistent=true\n"
+	if got := readFile(t, tree, reminder); got != want {
+		t.Errorf(".reminder:\n%s\nwant:\n%s", got, want)
+	}
+	due := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	if mtime := reminder.GetNodeAttrs().Mtime; !mtime.Equal(due) {
+		t.Errorf(".reminder modified at %s, want %s", mtime, due)
+	}
+
+	dueFile := lookupPath(t, tree, "eng/Roadmap/cards/Fix/Due")
+	if err := writeFile(t, tree, dueFile, "2022-04-01T09:30:00Z"); err != nil {
+		t.Fatal(err)
+	}
+	due = time.Date(2022, 4, 1, 9, 30, 0, 0, time.UTC)
+	if mtime := reminder.GetNodeAttrs().Mtime; !mtime.Equal(due) {
+		t.Errorf(".reminder modified at %s once due later, want %s",
+			mtime, due)
+	}
+
+	someday := lookupPath(t, tree, "eng/Roadmap/cards/Someday")
+	ctx := context.Background()
+	_, err := tree.Lookup(ctx, someday.GetNodeID(), ".reminder")
+	if err == nil {
+		t.Errorf("reminder for a card never due")
+	}
+}
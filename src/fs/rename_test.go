@@ -0,0 +1,94 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// Boards, lists, and cards renamed in Trello are renamed once refreshed,
+// keeping their inodes, and are no longer found by their old names.
+func TestRenames(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Fix", "shortLink": "aa", "idList": "l1"}
+		]`,
+		"/boards/b1/lists": `[{"id": "l1", "name": "To do"}]`,
+		"/lists/l1/cards": `[
+			{"id": "c1", "name": "Fix", "shortLink": "aa", "idList": "l1"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	card := lookupPath(t, tree, "eng/Roadmap/cards/Fix")
+	list := lookupPath(t, tree, "eng/Roadmap/lists/To do")
+	board := lookupPath(t, tree, "eng/Roadmap")
+	lookupPath(t, tree, "eng/Roadmap/lists/To do/Fix")
+
+	for name, body := range map[string]string{
+		"organizations-w1-boards": `[
+			{"id": "b1", "name": "Plans", "idOrganization": "w1"}
+		]`,
+		"boards-b1-cards": `[
+			{"id": "c1", "name": "Fixed", "shortLink": "aa", "idList": "l1"}
+		]`,
+		"boards-b1-lists": `[{"id": "l1", "name": "Done"}]`,
+		"lists-l1-cards": `[
+			{"id": "c1", "name": "Fixed", "shortLink": "aa", "idList": "l1"}
+		]`,
+	} {
+		err := ioutil.WriteFile(
+			filepath.Join(dir, name+".json"), []byte(body), 0600,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// as the renamed are listed meanwhile.
+	refresh := lookupPath(t, tree, ".trellofs/refresh")
+	cards := lookupPath(t, tree, "eng/Roadmap/cards")
+	refreshed := make(chan error)
+	go func() { refreshed <- writeFile(t, tree, refresh, "1\n") }()
+	cardsListed := listing(tree, cards)
+	listListed := listing(tree, list)
+	for _, done := range []<-chan error{refreshed, cardsListed, listListed} {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for path, node := range map[string]FSNode{
+		"eng/Plans":                  board,
+		"eng/Plans/cards/Fixed":      card,
+		"eng/Plans/lists/Done":       list,
+		"eng/Plans/lists/Done/Fixed": card,
+	} {
+		if got := lookupPath(t, tree, path); got != node {
+			t.Errorf("%s looked up as another node", path)
+		}
+	}
+	ctx := context.Background()
+	for parent, name := range map[string]string{
+		"eng":             "Roadmap",
+		"eng/Plans/cards": "Fix",
+		"eng/Plans/lists": "To do",
+	} {
+		dir := lookupPath(t, tree, parent)
+		if _, err := tree.Lookup(ctx, dir.GetNodeID(), name); err == nil {
+			t.Errorf("%s still found in %s", name, parent)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// The '.resolve' directory translates Trello URLs into paths: looking up
+// 'c/<shortLink>' or 'b/<shortLink>' yields a symlink to the corresponding
+// card's, or board's, directory.
+type FSResolveDir struct {
+	BaseFSNode
+
+	tree  *Tree
+	kinds []*FSResolveKindDir
+}
+
+func newResolveDir(tree *Tree, root FSNode) *FSResolveDir {
+	return &FSResolveDir{
+		BaseFSNode: BaseFSNode{
+			name: ".resolve",
+			uid:  tree.uid,
+			gid:  tree.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0500 | os.ModeDir,
+				Uid:  tree.uid,
+				Gid:  tree.gid,
+			},
+			isDir:    true,
+			TrelloID: "_resolve",
+			parent:   root,
+		},
+		tree: tree,
+	}
+}
+
+func (node *FSResolveDir) ShouldUpdate() bool {
+	node.Lock()
+	defer node.Unlock()
+	return node.kinds == nil
+}
+
+func (node *FSResolveDir) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	for _, kind := range []string{"b", "c"} {
+		kindDir := &FSResolveKindDir{
+			BaseFSNode: BaseFSNode{
+				name:      kind,
+				uid:       node.uid,
+				gid:       node.gid,
+				NodeAttrs: node.GetNodeAttrs(),
+				isDir:     true,
+				TrelloID:  node.GetTrelloID() + "/" + kind,
+				parent:    node,
+			},
+			tree:   node.tree,
+			kind:   kind,
+			byName: make(map[string]*FSSymlink),
+		}
+		node.kinds = append(node.kinds, kindDir)
+		newNodes = append(newNodes, kindDir)
+	}
+	node.markUpdated()
+	return newNodes, nil, nil
+}
+
+func (node *FSResolveDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, kind := range node.kinds {
+		if kind.GetName() == name {
+			return kind, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSResolveDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.kinds))
+	for i, kind := range node.kinds {
+		entries[i] = kind
+	}
+	return entries
+}
+
+// Resolves short links for a given kind of entity: 'b' for boards, 'c' for
+// cards. Lists previously resolved links.
+type FSResolveKindDir struct {
+	BaseFSNode
+
+	tree   *Tree
+	kind   string
+	links  []*FSSymlink
+	byName map[string]*FSSymlink
+}
+
+func (node *FSResolveKindDir) ShouldUpdate() bool {
+	return false
+}
+
+func (node *FSResolveKindDir) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, nil
+}
+
+// Called with both the updates lock and the tree's lock held, during lookup,
+// as resolving may load the entity's workspace or board. Which is done
+// before taking the node's lock, lest it be held while the tree's is waited
+// for.
+func (node *FSResolveKindDir) LookupChild(name string) (FSNode, error) {
+	var target FSNode
+	var err error
+	if node.kind == "b" {
+		target, err = node.tree.resolveBoard(name)
+	} else {
+		target, err = node.tree.resolveCard(name)
+	}
+	if err != nil {
+		log.Printf("resolve > unable to resolve %s/%s\n", node.kind, name)
+		return nil, fuse.ENOENT
+	}
+
+	node.Lock()
+	defer node.Unlock()
+	path := relativePath(node, target)
+	if link, exists := node.byName[name]; exists {
+		link.setTarget(path)
+		return link, nil
+	}
+	link := newSymlink(node, name, path)
+	node.links = append(node.links, link)
+	node.byName[name] = link
+	return link, nil
+}
+
+func (node *FSResolveKindDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.links))
+	for i, link := range node.links {
+		entries[i] = link
+	}
+	return entries
+}
+
+// Obtain the contexts to try when fetching an entity we know nothing about,
+// starting with the main context.
+func (tree *Tree) allContexts() []*trello.TrelloCtx {
+	contexts := []*trello.TrelloCtx{tree.ctx}
+	for _, account := range tree.opts.Accounts {
+		contexts = append(contexts, account.Ctx)
+	}
+	for _, ctx := range tree.opts.WorkspaceCtx {
+		contexts = append(contexts, ctx)
+	}
+	return contexts
+}
+
+// Find a board's node given its ID or short link, loading its workspace if
+// needed. Must be called with both the updates lock and the tree's lock
+// held.
+func (tree *Tree) resolveBoard(id string) (*FSBoard, error) {
+
+	var board *trello.Board
+	for _, ctx := range tree.allContexts() {
+		b, err := trello.GetBoard(tree.api(ctx, nil), id)
+		if err == nil && b.ID != "" {
+			board = b
+			break
+		}
+	}
+	if board == nil {
+		return nil, fuse.ENOENT
+	}
+
+	if node, ok := tree.nodeByTrelloID(board.ID).(*FSBoard); ok {
+		return node, nil
+	}
+
+	ws := tree.nodeByTrelloID(board.OrganizationID)
+	if ws == nil {
+		tree.refreshRoots()
+		ws = tree.nodeByTrelloID(board.OrganizationID)
+	}
+	if ws == nil {
+		return nil, fuse.ENOENT
+	}
+	tree.refreshNode(ws)
+
+	if node, ok := tree.nodeByTrelloID(board.ID).(*FSBoard); ok {
+		return node, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+// Find a card's node given its ID or short link, loading its board if
+// needed. Must be called with both the updates lock and the tree's lock
+// held.
+func (tree *Tree) resolveCard(id string) (*FSCard, error) {
+
+	var card *trello.Card
+	for _, ctx := range tree.allContexts() {
+		c, err := trello.GetCard(tree.api(ctx, nil), id)
+		if err == nil && c.ID != "" {
+			card = c
+			break
+		}
+	}
+	if card == nil {
+		return nil, fuse.ENOENT
+	}
+
+	if node, ok := tree.nodeByTrelloID(card.ID).(*FSCard); ok {
+		return node, nil
+	}
+
+	board, err := tree.resolveBoard(card.BoardID)
+	if err != nil {
+		return nil, err
+	}
+	tree.refreshNode(board)
+	tree.refreshNode(board.MetaCardsDir)
+
+	if node, ok := tree.nodeByTrelloID(card.ID).(*FSCard); ok {
+		return node, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+// Known cards, by their short links, kept apart from the tree's lock so
+// that cards may be found from within nodes' updates, e.g. by mirrors. The
+// index's lock is only held briefly, and may be taken with any other held.
+type shortLinkIndex struct {
+	lock  sync.Mutex
+	cards map[string]*FSCard
+}
+
+// Index a card as it's added to the tree. Short links never change.
+func (index *shortLinkIndex) add(card *FSCard) {
+	if card.Card == nil || card.Card.ShortLink == "" {
+		return
+	}
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	if index.cards == nil {
+		index.cards = make(map[string]*FSCard)
+	}
+	index.cards[card.Card.ShortLink] = card
+}
+
+func (index *shortLinkIndex) remove(card *FSCard) {
+	if card.Card == nil {
+		return
+	}
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	if index.cards[card.Card.ShortLink] == card {
+		delete(index.cards, card.Card.ShortLink)
+	}
+}
+
+// Find a known card by its short link, or nil if it's not known. May be
+// called with any lock held.
+func (tree *Tree) cardByShortLink(shortLink string) *FSCard {
+	index := &tree.byShortLink
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	return index.cards[shortLink]
+}
@@ -0,0 +1,45 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"testing"
+)
+
+// Looking up a card's short link in '.resolve/c' loads its workspace and
+// board as needed, yielding a link to the card's directory.
+func TestResolveCard(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/cards/AbCd": `{
+			"id": "c1", "shortLink": "AbCd", "name": "Ship it",
+			"idBoard": "b1"
+		}`,
+		"/boards/b1": `{
+			"id": "b1", "name": "Roadmap", "idOrganization": "w1"
+		}`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "shortLink": "AbCd", "name": "Ship it"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+
+	link := lookupPath(t, tree, ".resolve/c/AbCd")
+	target, err := link.ReadLink()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "../../eng/Roadmap/cards/Ship it"; target != want {
+		t.Errorf("link to %q, want %q", target, want)
+	}
+}
@@ -18,7 +18,6 @@ import (
 
 	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fuseops"
-	"github.com/jacobsa/fuse/fuseutil"
 )
 
 type TrelloTreeRoot struct {
@@ -27,10 +26,56 @@ type TrelloTreeRoot struct {
 	workspaces []*FSWorkspace
 	byID       map[string]*FSWorkspace
 	byName     map[string]*FSWorkspace
+
+	// per-workspace contexts, keyed by workspace name or ID
+	wsCtx map[string]*trello.TrelloCtx
+
+	useDisplayName bool
+
+	// workspaces to be mounted
+	wsFilter Filter
+}
+
+func (node *TrelloTreeRoot) workspaceCtx(
+	ws *trello.Workspace,
+) *trello.TrelloCtx {
+	if ctx, exists := node.wsCtx[ws.ID]; exists {
+		return ctx
+	}
+	if ctx, exists := node.wsCtx[ws.Name]; exists {
+		return ctx
+	}
+	return node.Ctx
+}
+
+// Obtain the directory name for a new workspace. If display names are used,
+// workspaces sharing a display name are disambiguated by their short name.
+func (node *TrelloTreeRoot) workspaceDirName(
+	ws *trello.Workspace,
+	displayNames map[string]int,
+) string {
+	if !node.useDisplayName || ws.DisplayName == "" {
+		return escapeName(ws.Name)
+	}
+	name := escapeName(ws.DisplayName)
+	_, exists := node.byName[name]
+	if exists || displayNames[ws.DisplayName] > 1 {
+		name = fmt.Sprintf("%s (%s)", name, escapeName(ws.Name))
+	}
+	return name
+}
+
+func hasWorkspace(workspaces []trello.Workspace, key string) bool {
+	for _, ws := range workspaces {
+		if ws.ID == key || ws.Name == key {
+			return true
+		}
+	}
+	return false
 }
 
 func (node *TrelloTreeRoot) ShouldUpdate() bool {
-	return node.shouldUpdate(60.0)
+	return node.shouldRefresh("workspaces", 60.0)
 }
 
 func (node *TrelloTreeRoot) Update() ([]FSNode, []FSNode, error) {
@@ -38,21 +83,55 @@ func (node *TrelloTreeRoot) Update() ([]FSNode, []FSNode, error) {
 	node.Lock()
 	defer node.Unlock()
 
-	workspaces, err := trello.GetWorkspaces(node.Ctx)
+	workspaces, err := trello.GetWorkspaces(node.api())
 	if err != nil {
 		log.Printf("error updating workspaces for root node: %s\n", err)
 		return nil, nil, err
 	}
 
+	// Workspaces with their own credentials may not be visible to the
+	// main user; obtain them directly.
+	for key, ctx := range node.wsCtx {
+		if hasWorkspace(workspaces, key) {
+			continue
+		}
+		ws, err := trello.GetWorkspace(node.tree.api(ctx, nil), key)
+		if err != nil {
+			log.Printf("error obtaining workspace %s: %s\n", key, err)
+			continue
+		}
+		workspaces = append(workspaces, *ws)
+	}
+
+	// Filter out unwanted workspaces before going any further, so we
+	// never ask about their boards.
+	selected := make([]trello.Workspace, 0, len(workspaces))
+	for _, ws := range workspaces {
+		if node.wsFilter.selects(ws.Name, ws.DisplayName, ws.ID) &&
+			node.tree.opts.Boards.selectsWorkspace(
+				ws.Name, ws.DisplayName, ws.ID,
+			) {
+			selected = append(selected, ws)
+		}
+	}
+	workspaces = selected
+
+	displayNames := make(map[string]int)
+	for _, ws := range workspaces {
+		displayNames[ws.DisplayName]++
+	}
+
 	var newNodes []FSNode = make([]FSNode, 0)
 	for i, ws := range workspaces {
-		if _, exists := node.byID[ws.ID]; exists {
+		if existing, exists := node.byID[ws.ID]; exists {
+			node.renameWorkspace(existing, &workspaces[i], displayNames)
 			continue
 		}
 
+		name := node.workspaceDirName(&workspaces[i], displayNames)
 		newItem := &FSWorkspace{
 			BaseFSNode: BaseFSNode{
-				name: ws.Name,
+				name: name,
 				uid:  node.uid,
 				gid:  node.gid,
 				NodeAttrs: fuseops.InodeAttributes{
@@ -62,7 +141,8 @@ func (node *TrelloTreeRoot) Update() ([]FSNode, []FSNode, error) {
 				},
 				isDir:    true,
 				TrelloID: ws.ID,
-				Ctx:      node.Ctx,
+				Ctx:      node.workspaceCtx(&workspaces[i]),
+				parent:   node,
 			},
 			ByID:      make(map[string]*FSBoard),
 			ByName:    make(map[string]*FSBoard),
@@ -70,11 +150,11 @@ func (node *TrelloTreeRoot) Update() ([]FSNode, []FSNode, error) {
 		}
 		newNodes = append(newNodes, newItem)
 		node.byID[ws.ID] = newItem
-		node.byName[ws.Name] = newItem
+		node.byName[name] = newItem
 		node.workspaces = append(node.workspaces, newItem)
 		log.Printf(
-			"update root: workspace %s (%s)\n",
-			ws.Name, ws.ID,
+			"update root: workspace %s (%s) as %s\n",
+			ws.Name, ws.ID, name,
 		)
 	}
 	for _, ws := range node.workspaces {
@@ -87,6 +167,28 @@ func (node *TrelloTreeRoot) Update() ([]FSNode, []FSNode, error) {
 	return newNodes, nil, nil
 }
 
+// Keep a workspace's directory named after it, should it have been renamed.
+func (node *TrelloTreeRoot) renameWorkspace(
+	dir *FSWorkspace,
+	ws *trello.Workspace,
+	displayNames map[string]int,
+) {
+	dir.Lock()
+	*dir.Workspace = *ws
+	dir.Unlock()
+
+	delete(node.byName, dir.GetName())
+	name := node.workspaceDirName(ws, displayNames)
+	if name != dir.GetName() {
+		log.Printf(
+			"workspace %s (%s) renamed to %s\n",
+			dir.GetName(), ws.ID, name,
+		)
+		dir.setName(name)
+	}
+	node.byName[name] = dir
+}
+
 func (node *TrelloTreeRoot) LookupChild(name string) (FSNode, error) {
 
 	node.Lock()
@@ -100,33 +202,13 @@ func (node *TrelloTreeRoot) LookupChild(name string) (FSNode, error) {
 	return nil, fuse.ENOENT
 }
 
-func (node *TrelloTreeRoot) ReadDir(dst []byte, offset int) int {
+func (node *TrelloTreeRoot) GetEntries() []FSNode {
 	node.Lock()
 	defer node.Unlock()
 
-	fmt.Printf(
-		"read dir %s (%s) id %d, offset %d\n",
-		node.GetName(),
-		node.GetTrelloID(),
-		node.GetNodeID(),
-		offset,
-	)
-	var size int
-	for i := offset; i < len(node.workspaces); i++ {
-		ws := node.workspaces[i]
-		tmp := fuseutil.WriteDirent(dst[size:], fuseutil.Dirent{
-			Name:   ws.name,
-			Inode:  ws.GetNodeID(),
-			Type:   fuseutil.DT_Directory,
-			Offset: fuseops.DirOffset(i + 1),
-		})
-		if tmp == 0 {
-			log.Printf(
-				"read dir > no more space to write dirent for %s\n", ws.name,
-			)
-			break
-		}
-		size += tmp
+	entries := make([]FSNode, len(node.workspaces))
+	for i, ws := range node.workspaces {
+		entries[i] = ws
 	}
-	return size
+	return entries
 }
@@ -0,0 +1,236 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Workspaces with their own credentials are obtained with them, even if
+// not visible to the main user, and browsed with them.
+func TestRootWorkspaceCredentials(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "mine"}]`,
+		"/organizations/theirs":     `{"id": "w2", "name": "theirs"}`,
+	})
+	theirs := trello.Trello("me", "key", "token")
+	tree := newTestTree(t, Options{
+		WorkspaceCtx: map[string]*trello.TrelloCtx{"theirs": theirs},
+	})
+
+	entries, err := tree.Entries(context.Background(), fuseops.RootInodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mine, ok := entryNamed(entries, "mine").(*FSWorkspace)
+	if !ok {
+		t.Fatal("main user's workspace not listed")
+	}
+	if mine.Ctx != tree.ctx {
+		t.Errorf("main user's workspace browsed with other credentials")
+	}
+	ws, ok := entryNamed(entries, "theirs").(*FSWorkspace)
+	if !ok {
+		t.Fatal("workspace with its own credentials not listed")
+	}
+	if ws.Ctx != theirs {
+		t.Errorf("workspace not browsed with its own credentials")
+	}
+}
+
+// Workspaces may be named after their display name, those sharing one told
+// apart by their short name.
+func TestRootWorkspaceDisplayNames(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[
+			{"id": "w1", "name": "eng", "displayName": "Engineering"},
+			{"id": "w2", "name": "eng2", "displayName": "Engineering"},
+			{"id": "w3", "name": "ops", "displayName": "Operations"},
+			{"id": "w4", "name": "misc"}
+		]`,
+	})
+	tree := newTestTree(t, Options{UseDisplayName: true})
+
+	entries, err := tree.Entries(context.Background(), fuseops.RootInodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"Engineering (eng)", "Engineering (eng2)", "Operations", "misc",
+	} {
+		if entryNamed(entries, name) == nil {
+			t.Errorf("no workspace named %q", name)
+		}
+	}
+}
+
+// A single workspace may be mounted as the root, its boards living there,
+// failing to mount should it not be found.
+func TestRootWorkspace(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/organizations/eng":       `{"id": "w1", "name": "eng"}`,
+		"/organizations/w1/boards": `[{"id": "b1", "name": "Roadmap"}]`,
+	})
+	tree := newTestTree(t, Options{RootWorkspace: "eng"})
+
+	root, isWorkspace := tree.GetNode(fuseops.RootInodeID).(*FSWorkspace)
+	if !isWorkspace || root.GetTrelloID() != "w1" {
+		t.Fatal("workspace not mounted as the root")
+	}
+	entries, err := tree.Entries(context.Background(), fuseops.RootInodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, isBoard := entryNamed(entries, "Roadmap").(*FSBoard); !isBoard {
+		t.Errorf("workspace's board not found in the root")
+	}
+
+	_, err = NewTree(0, 0, tree.ctx, Options{RootWorkspace: "ops"})
+	if err == nil {
+		t.Errorf("mounted a workspace not found")
+	}
+}
+
+// Only the workspaces, and boards, selected by the configured filters are
+// mounted.
+func TestRootFilters(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[
+			{"id": "w1", "name": "eng"},
+			{"id": "w2", "name": "eng-old"},
+			{"id": "w3", "name": "misc"}
+		]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap"},
+			{"id": "b2", "name": "Retro 2021"},
+			{"id": "b3", "name": "Retro 2022"}
+		]`,
+	})
+	tree := newTestTree(t, Options{
+		WorkspaceFilter: Filter{
+			Include: []string{"eng*"}, Exclude: []string{"*-old"},
+		},
+		BoardFilters: map[string]Filter{
+			"eng": {Exclude: []string{"Retro *"}},
+		},
+		Boards: BoardSelection{"eng/*", "eng/b3"},
+	})
+	ctx := context.Background()
+
+	entries, err := tree.Entries(ctx, fuseops.RootInodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 || entryNamed(entries, "eng") == nil {
+		t.Fatal("workspace selected not mounted")
+	}
+	for _, name := range []string{"eng-old", "misc"} {
+		if entryNamed(entries, name) != nil {
+			t.Errorf("workspace %s mounted", name)
+		}
+	}
+	entries, err = tree.Entries(ctx, entryNamed(entries, "eng").GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entryNamed(entries, "Roadmap") == nil {
+		t.Errorf("board selected not mounted")
+	}
+	for _, name := range []string{"Retro 2021", "Retro 2022"} {
+		if entryNamed(entries, name) != nil {
+			t.Errorf("board %s mounted", name)
+		}
+	}
+}
+
+func TestBoardSelection(t *testing.T) {
+	sel := BoardSelection{"eng", "ops/Deploy*"}
+	for _, test := range []struct {
+		ws, board string
+		want      bool
+	}{
+		{"eng", "Roadmap", true},
+		{"ops", "Deployments", true},
+		{"ops", "Oncall", false},
+		{"misc", "Roadmap", false},
+	} {
+		got := sel.selectsBoard([]string{test.ws}, []string{test.board})
+		if got != test.want {
+			t.Errorf("%s/%s selected: %v", test.ws, test.board, got)
+		}
+	}
+	if !sel.selectsWorkspace("ops") || sel.selectsWorkspace("misc") {
+		t.Errorf("workspaces selected regardless of their boards")
+	}
+}
+
+// Only the boards selected are mounted, by workspace and board, along with
+// every board of the workspaces selected alone; workspaces with no boards
+// selected aren't mounted at all.
+func TestMountBoardSelection(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[
+			{"id": "w1", "name": "eng"},
+			{"id": "w2", "name": "ops"},
+			{"id": "w3", "name": "personal"}
+		]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"},
+			{"id": "b2", "name": "Bugs", "idOrganization": "w1"},
+			{"id": "b3", "name": "Release", "shortLink": "rl",
+				"idOrganization": "w1"}
+		]`,
+		"/organizations/w2/boards": `[
+			{"id": "b4", "name": "Oncall", "idOrganization": "w2"}
+		]`,
+	})
+	tree := newTestTree(t, Options{
+		Boards: BoardSelection{"eng/Road*", "w1/rl", "ops"},
+	})
+	names := func(dir FSNode) []string {
+		entries, err := tree.Entries(context.Background(), dir.GetNodeID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.GetName(), ".") {
+				names = append(names, entry.GetName())
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	root := tree.GetNode(fuseops.RootInodeID)
+	if got, want := names(root), []string{"eng", "ops"}; !reflect.DeepEqual(
+		got, want,
+	) {
+		t.Errorf("workspaces mounted: %q, want %q", got, want)
+	}
+	for ws, want := range map[string][]string{
+		"eng": {"Release", "Roadmap"},
+		"ops": {"Oncall"},
+	} {
+		if got := names(lookupPath(t, tree, ws)); !reflect.DeepEqual(
+			got, want,
+		) {
+			t.Errorf("boards mounted on %s: %q, want %q", ws, got, want)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// The '.trellofs/schema' directory, describing what the running version
+// presents, as JSON, so tools may find their way around rather than
+// hardcode paths:
+//
+//	layout.json	the layout, as path templates, e.g. '/<workspace>/<board>'
+//	meta.json	the names of cards' meta files and boards' preferences
+//	xattrs.json	the extended attributes, and what has them
+//	control.json	the files in '.trellofs'
+type FSSchemaDir struct {
+	BaseFSNode
+
+	ctl   *FSCtlDir
+	Files []*FSGeneratedFile
+}
+
+// A path template, as found in layout.json. Writable directories are those
+// in which entries may be created.
+type schemaPath struct {
+	Path     string `json:"path"`
+	Type     string `json:"type"`
+	Writable bool   `json:"writable"`
+}
+
+// A file, as found in meta.json and control.json.
+type schemaFile struct {
+	Name     string `json:"name"`
+	Type     string `json:"type,omitempty"`
+	Readable bool   `json:"readable"`
+	Writable bool   `json:"writable"`
+}
+
+type schemaXattr struct {
+	Name string `json:"name"`
+	On   string `json:"on"`
+}
+
+func newSchemaDir(ctl *FSCtlDir) *FSSchemaDir {
+	node := &FSSchemaDir{
+		BaseFSNode: BaseFSNode{
+			name: "schema",
+			uid:  ctl.uid,
+			gid:  ctl.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0500 | os.ModeDir,
+				Uid:  ctl.uid,
+				Gid:  ctl.gid,
+			},
+			isDir:    true,
+			TrelloID: ctl.GetTrelloID() + "/schema",
+			parent:   ctl,
+		},
+		ctl: ctl,
+	}
+	for _, file := range []struct {
+		name     string
+		describe func() interface{}
+	}{
+		{"layout.json", node.layout},
+		{"meta.json", node.meta},
+		{"xattrs.json", node.xattrs},
+		{"control.json", node.control},
+	} {
+		describe := file.describe
+		node.Files = append(node.Files, newGeneratedFile(
+			node, file.name, 0.0, func() ([]byte, error) {
+				// templates' '<' and '>' are left alone.
+				var buf bytes.Buffer
+				encoder := json.NewEncoder(&buf)
+				encoder.SetEscapeHTML(false)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(describe()); err != nil {
+					return nil, err
+				}
+				return buf.Bytes(), nil
+			},
+		))
+	}
+	return node
+}
+
+// Describe the layout, as of the layout version in use, as
+//
+//	{"version": <layout version>, "versions": [<layout versions>],
+//	 "paths": [{"path": <template>, "type": <dir|file|symlink>,
+//	            "writable": <bool>}, ...]}
+//
+// Every directory also holds a writable '.refresh'.
+func (node *FSSchemaDir) layout() interface{} {
+	opts := &node.ctl.tree.opts
+	ws := "/<workspace>"
+	if len(opts.Accounts) > 0 {
+		ws = "/<account>/<workspace>"
+	}
+	board := ws + "/<board>"
+	card := board + "/cards/<card>"
+	list := board + "/lists/<list>"
+	prefs := board + "/_prefs"
+	due := board + "/views/due/<overdue|today|this-week|later|none>"
+
+	dir := func(path string) schemaPath {
+		return schemaPath{Path: path, Type: "dir"}
+	}
+	file := func(path string) schemaPath {
+		return schemaPath{Path: path, Type: "file"}
+	}
+	symlink := func(path string) schemaPath {
+		return schemaPath{Path: path, Type: "symlink"}
+	}
+	writable := func(path schemaPath) schemaPath {
+		path.Writable = true
+		return path
+	}
+	listCard := symlink(list + "/<card>")
+	if opts.HardlinkCards || !opts.layoutSince("v2") {
+		listCard = dir(list + "/<card>")
+	}
+	paths := []schemaPath{
+		dir(ws),
+		file(ws + "/.workload"),
+		writable(dir(ws + "/.import")),
+		writable(file(ws + "/.import/<export>")),
+		file(ws + "/.import/<export>.progress"),
+		dir(ws + "/.recently-removed"),
+		file(ws + "/.recently-removed/<card or list>"),
+		dir(board),
+		dir(board + "/cards"),
+		dir(card),
+		symlink(board + "/cards/<mirrored card>"),
+		file(card + "/<meta file>"),
+		writable(file(card + "/comments")),
+		writable(file(card + "/labels")),
+		writable(file(card + "/members")),
+		writable(file(card + "/card.md")),
+		file(card + "/card.eml"),
+		file(card + "/.raw.json"),
+		dir(card + "/checklists"),
+		dir(card + "/checklists/<checklist>"),
+		writable(file(card + "/checklists/<checklist>/<item>")),
+		dir(card + "/links"),
+		symlink(card + "/links/<shortLink>"),
+		dir(card + "/attachments"),
+		file(card + "/attachments/<file>"),
+		dir(card + "/.conflict"),
+		file(card + "/.conflict/local.md"),
+		file(card + "/.conflict/remote.md"),
+		writable(file(card + "/.conflict/resolution")),
+		dir(board + "/lists"),
+		dir(board + "/lists/.overflow"),
+		symlink(board + "/lists/.overflow/<list>"),
+		writable(dir(list)),
+		listCard,
+		symlink(list + "/<mirrored card>"),
+		dir(list + "/.stale"),
+		symlink(list + "/.stale/<card>"),
+		writable(file(list + "/.import.csv")),
+		file(list + "/.import.result"),
+		file(list + "/.raw.json"),
+		file(board + "/.summary"),
+		dir(prefs),
+		writable(file(prefs + "/<pref>")),
+		dir(board + "/members"),
+		writable(file(board + "/members/.invite")),
+		file(board + "/members/<username>"),
+		dir(board + "/labels"),
+		file(board + "/labels/.usage"),
+		file(board + "/labels/<label>"),
+		dir(board + "/labels/by-color"),
+		dir(board + "/labels/by-color/<color>"),
+		symlink(board + "/labels/by-color/<color>/<label>"),
+		file(board + "/graph.dot"),
+		file(board + "/graph.mmd"),
+		file(board + "/cycle-times.csv"),
+		file(board + "/.activity.json"),
+		file(board + "/.raw.json"),
+		dir(board + "/views"),
+		dir(board + "/views/<view>"),
+		symlink(board + "/views/<view>/<card>"),
+		writable(file(board + "/.find")),
+		file(board + "/.changelog"),
+		dir(board + "/.duplicates"),
+		dir(board + "/.duplicates/<card>"),
+		symlink(board + "/.duplicates/<card>/<shortLink> (<list>)"),
+		dir(board + "/by-mention"),
+		dir(board + "/by-mention/<username>"),
+		symlink(board + "/by-mention/<username>/<card>"),
+		dir("/.resolve"),
+		dir("/.resolve/<b|c>"),
+		symlink("/.resolve/<b|c>/<shortLink>"),
+		dir("/.trellofs"),
+		file("/.trellofs/<control file>"),
+		dir("/.trellofs/snapshots/<board>/<snapshot>/<list>"),
+		file("/.trellofs/snapshots/<board>/<snapshot>/<list>/<card>"),
+		writable(dir("/.trellofs/search")),
+		dir("/.trellofs/search/<query>"),
+		symlink("/.trellofs/search/<query>/<card>"),
+		dir("/.trellofs/schema"),
+		file("/.trellofs/schema/<file>.json"),
+		dir("/.trellofs/pending"),
+		file("/.trellofs/pending/<seq>-<method>-<card id>"),
+		dir("/.me"),
+		file("/.me/cards"),
+		dir("/.me/searches"),
+		file("/.me/searches/<search>"),
+		writable(dir("/.inbox")),
+		file("/.inbox/<file>"),
+	}
+	if opts.layoutSince("v2") {
+		paths = append(paths,
+			file(card+"/.reminder"),
+			file(list+"/.stats"),
+			dir(due),
+			symlink(due+"/<card>"),
+			dir(board+"/views/labels/<label>"),
+			symlink(board+"/views/labels/<label>/<card>"),
+			dir(board+"/views/colors/<color>"),
+			symlink(board+"/views/colors/<color>/<card>"),
+			dir(board+"/views/members/<username>"),
+			symlink(board+"/views/members/<username>/<card>"),
+		)
+	}
+	if len(opts.Accounts) > 0 {
+		paths = append([]schemaPath{dir("/<account>")}, paths...)
+	}
+	return struct {
+		Version  string       `json:"version"`
+		Versions []string     `json:"versions"`
+		Paths    []schemaPath `json:"paths"`
+	}{opts.layout(), Layouts, paths}
+}
+
+// Describe the meta files found in a card's directory, and the preferences
+// found in a board's, as
+//
+//	{"card": [{"name": <name>, "readable": true, "writable": <bool>}, ...],
+//	 "boardPrefs": [...]}
+func (node *FSSchemaDir) meta() interface{} {
+	var card []schemaFile
+	for _, entry := range getMeta(trello.Card{}) {
+		_, writable := writableCardMeta[entry.Name]
+		card = append(card, schemaFile{
+			Name: entry.Name, Readable: true, Writable: writable,
+		})
+	}
+	for _, name := range []string{"DaysIdle", "DaysInList"} {
+		card = append(card, schemaFile{Name: name, Readable: true})
+	}
+	var prefs []schemaFile
+	for _, pref := range boardPrefs {
+		prefs = append(prefs, schemaFile{
+			Name: pref.name, Readable: true, Writable: true,
+		})
+	}
+	return struct {
+		Card       []schemaFile `json:"card"`
+		BoardPrefs []schemaFile `json:"boardPrefs"`
+	}{card, prefs}
+}
+
+// Describe the extended attributes, as
+//
+//	[{"name": <attribute>, "on": <what has it, e.g. 'card'>}, ...]
+func (node *FSSchemaDir) xattrs() interface{} {
+	var xattrs []schemaXattr
+	for _, name := range (&FSCard{}).ListXattrs() {
+		xattrs = append(xattrs, schemaXattr{Name: name, On: "card"})
+	}
+	return xattrs
+}
+
+// Describe the entries of '.trellofs', as
+//
+//	[{"name": <name>, "type": <dir|file>, "readable": <bool>,
+//	  "writable": <bool>}, ...]
+func (node *FSSchemaDir) control() interface{} {
+	var files []schemaFile
+	for _, entry := range node.ctl.GetEntries() {
+		mode := entry.GetNodeAttrs().Mode
+		kind := "file"
+		if mode.IsDir() {
+			kind = "dir"
+		}
+		files = append(files, schemaFile{
+			Name:     entry.GetName(),
+			Type:     kind,
+			Readable: mode&0400 != 0,
+			Writable: mode&0200 != 0,
+		})
+	}
+	return files
+}
+
+// Never changes once loaded.
+func (node *FSSchemaDir) ShouldUpdate() bool {
+	return !node.isLoaded()
+}
+
+func (node *FSSchemaDir) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	var newNodes []FSNode
+	for _, file := range node.Files {
+		if file.GetNodeID() == 0 {
+			newNodes = append(newNodes, file)
+		}
+	}
+	node.markUpdated()
+	return newNodes, nil, nil
+}
+
+func (node *FSSchemaDir) LookupChild(name string) (FSNode, error) {
+	for _, file := range node.Files {
+		if file.GetName() == name {
+			return file, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSSchemaDir) GetEntries() []FSNode {
+	entries := make([]FSNode, len(node.Files))
+	for i, file := range node.Files {
+		entries[i] = file
+	}
+	return entries
+}
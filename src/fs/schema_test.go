@@ -0,0 +1,78 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// '.trellofs/schema' describes the layout in use, the card's meta files and
+// the control files as they are, so tools may rely on it.
+func TestSchema(t *testing.T) {
+	for _, layout := range []string{"v1", "v2"} {
+		withFixtures(t, map[string]string{
+			"/members/me/organizations": `[]`,
+		})
+		tree := newTestTree(t, Options{Layout: layout})
+		schema := func(name string, v interface{}) {
+			node := lookupPath(t, tree, ".trellofs/schema/"+name)
+			err := json.Unmarshal([]byte(readFile(t, tree, node)), v)
+			if err != nil {
+				t.Fatalf("%s %s: %s", layout, name, err)
+			}
+		}
+
+		var described struct {
+			Version string       `json:"version"`
+			Paths   []schemaPath `json:"paths"`
+		}
+		schema("layout.json", &described)
+		if described.Version != layout {
+			t.Errorf("layout %s described as %s", layout, described.Version)
+		}
+		reminders := false
+		for _, path := range described.Paths {
+			if path.Path == "/<workspace>/<board>/cards/<card>/.reminder" {
+				reminders = true
+			}
+		}
+		if reminders != (layout == "v2") {
+			t.Errorf("layout %s describes reminders: %v", layout, reminders)
+		}
+
+		var meta struct {
+			Card []schemaFile `json:"card"`
+		}
+		schema("meta.json", &meta)
+		writable := map[string]bool{}
+		for _, file := range meta.Card {
+			writable[file.Name] = file.Writable
+		}
+		if !writable["Desc"] || writable["Name"] {
+			t.Errorf("%s card meta described as writable: %v",
+				layout, writable)
+		}
+
+		// every control file described is there, as described.
+		var control []schemaFile
+		schema("control.json", &control)
+		if len(control) == 0 {
+			t.Fatalf("%s control files not described", layout)
+		}
+		for _, file := range control {
+			node := lookupPath(t, tree, ".trellofs/"+file.Name)
+			if node.GetNodeAttrs().Mode.IsDir() != (file.Type == "dir") {
+				t.Errorf("%s .trellofs/%s described as a %s",
+					layout, file.Name, file.Type)
+			}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"log"
+	"os"
+	"sort"
+	"syscall"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// The '.trellofs/search' directory, where making a directory named after a
+// query, as understood by Trello's search (e.g., "label:bug due:week"),
+// saves the search: the directory then holds a symlink per matching card,
+// through '.resolve', and is kept up to date until removed. Searches are only
+// kept for as long as the filesystem is mounted.
+type FSSearchDir struct {
+	BaseFSNode
+
+	Searches []*FSSearch
+}
+
+// A search's results, as symlinks named after the matching cards.
+type FSSearch struct {
+	BaseFSNode
+
+	Query string
+
+	Links  []*FSSymlink
+	byCard map[string]*FSSymlink
+}
+
+func newSearchDir(parent *FSCtlDir) *FSSearchDir {
+	return &FSSearchDir{
+		BaseFSNode: BaseFSNode{
+			name: "search",
+			uid:  parent.uid,
+			gid:  parent.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  parent.uid,
+				Gid:  parent.gid,
+			},
+			isDir:    true,
+			TrelloID: parent.GetTrelloID() + "/search",
+			Ctx:      parent.tree.ctx,
+			parent:   parent,
+		},
+	}
+}
+
+// Searches are only ever made through MkDir().
+func (node *FSSearchDir) ShouldUpdate() bool {
+	return false
+}
+
+func (node *FSSearchDir) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, nil
+}
+
+// Save a search, run once the new directory is first looked into.
+func (node *FSSearchDir) MkDir(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, search := range node.Searches {
+		if search.GetName() == name {
+			return nil, syscall.EEXIST
+		}
+	}
+	query := unescapeName(name)
+	log.Printf("save search '%s'\n", query)
+	search := &FSSearch{
+		BaseFSNode: BaseFSNode{
+			name:      name,
+			uid:       node.uid,
+			gid:       node.gid,
+			NodeAttrs: node.GetNodeAttrs(),
+			isDir:     true,
+			TrelloID:  node.GetTrelloID() + "/" + name,
+			Ctx:       node.Ctx,
+			parent:    node,
+		},
+		Query:  query,
+		byCard: make(map[string]*FSSymlink),
+	}
+	search.NodeAttrs.Mode = 0500 | os.ModeDir
+	node.Searches = append(node.Searches, search)
+	return search, nil
+}
+
+// Drop a saved search.
+func (node *FSSearchDir) RmDir(name string) error {
+	node.Lock()
+	defer node.Unlock()
+
+	for i, search := range node.Searches {
+		if search.GetName() == name {
+			log.Printf("drop search '%s'\n", search.Query)
+			node.Searches = append(
+				node.Searches[:i], node.Searches[i+1:]...,
+			)
+			return nil
+		}
+	}
+	return fuse.ENOENT
+}
+
+func (node *FSSearchDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, search := range node.Searches {
+		if search.GetName() == name {
+			return search, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSSearchDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Searches))
+	for i, search := range node.Searches {
+		entries[i] = search
+	}
+	return entries
+}
+
+func (node *FSSearch) ShouldUpdate() bool {
+	return node.shouldUpdate(60.0)
+}
+
+// Links point at '.resolve/c/<shortLink>', so that cards are only looked up
+// when followed. Cards keep their link's name for as long as they match.
+func (node *FSSearch) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	cards, err := trello.Search(node.api(), node.Query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+	var links []*FSSymlink
+	byCard := make(map[string]*FSSymlink)
+	taken := make(map[string]bool)
+	for _, link := range node.byCard {
+		taken[link.GetName()] = true
+	}
+	for _, card := range cards {
+		if _, exists := byCard[card.ID]; exists || card.ShortLink == "" {
+			continue
+		}
+		link, exists := node.byCard[card.ID]
+		if !exists {
+			name := uniqueName(
+				escapeName(card.Name), card.ShortLink, card.ID,
+				func(name string) bool { return taken[name] },
+			)
+			taken[name] = true
+			link = newSymlink(
+				node, name,
+				pathToRoot(node)+".resolve/c/"+card.ShortLink,
+			)
+			link.TrelloID = node.GetTrelloID() + "/" + card.ID
+			newNodes = append(newNodes, link)
+		}
+		byCard[card.ID] = link
+		links = append(links, link)
+	}
+	for id, link := range node.byCard {
+		if _, exists := byCard[id]; !exists {
+			rmNodes = append(rmNodes, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].GetName() < links[j].GetName()
+	})
+	node.byCard = byCard
+	node.Links = links
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSSearch) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, link := range node.Links {
+		if link.GetName() == name {
+			return link, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSSearch) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Links))
+	for i, link := range node.Links {
+		entries[i] = link
+	}
+	return entries
+}
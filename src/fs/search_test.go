@@ -0,0 +1,71 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+// Directories made in '.trellofs/search' hold links to the cards matching
+// the query they're named after, through '.resolve', until removed.
+func TestSearchDirs(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/search": `{"cards": [
+			{"id": "c1", "name": "Crash", "shortLink": "aa"},
+			{"id": "c2", "name": "Crash", "shortLink": "bb"},
+			{"id": "c3", "name": "Typo", "shortLink": "cc"}
+		]}`,
+	})
+	tree := newTestTree(t, Options{})
+	searches := lookupPath(t, tree, ".trellofs/search")
+	ctx := context.Background()
+	const query = "label:bug due:week"
+
+	search, err := tree.MkDir(ctx, searches.GetNodeID(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tree.MkDir(ctx, searches.GetNodeID(), query)
+	if err != syscall.EEXIST {
+		t.Errorf("search saved twice: %v", err)
+	}
+	if search.(*FSSearch).Query != query {
+		t.Errorf("searching for %q", search.(*FSSearch).Query)
+	}
+	entries, err := tree.Entries(ctx, search.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := make(map[string]string)
+	for _, entry := range entries {
+		if entry.GetName() != ".refresh" {
+			links[entry.GetName()], _ = entry.ReadLink()
+		}
+	}
+	want := map[string]string{
+		"Crash":      "../../../.resolve/c/aa",
+		"Crash (bb)": "../../../.resolve/c/bb",
+		"Typo":       "../../../.resolve/c/cc",
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("search found %q, want %q", links, want)
+	}
+
+	if err := tree.RmDir(ctx, searches.GetNodeID(), query); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Lookup(ctx, searches.GetNodeID(), query); err == nil {
+		t.Errorf("search removed still found")
+	}
+}
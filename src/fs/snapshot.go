@@ -0,0 +1,390 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jecluis/trellofs/src/cache"
+)
+
+// A board's open cards at some point in time, to be compared against the
+// board at some other, e.g. for a weekly report. Snapshots are kept in
+// Options.SnapshotDir, as '<board id>/<name>.json', or in memory if unset.
+type boardSnapshot struct {
+	Name      string         `json:"name"`
+	Board     string         `json:"board"`
+	BoardName string         `json:"boardName"`
+	Taken     time.Time      `json:"taken"`
+	Cards     []snapshotCard `json:"cards"`
+}
+
+type snapshotCard struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	List   string   `json:"list"`
+	Labels []string `json:"labels"`
+	Due    string   `json:"due,omitempty"`
+	Desc   string   `json:"desc,omitempty"`
+}
+
+// What the current state of a board is called when diffing.
+const snapshotNow = "now"
+
+// Find a mounted board by name, ID, or short link, loading workspaces if
+// need be. Must be called with both the updates lock and the tree's lock
+// held.
+func (tree *Tree) boardByKey(key string) (*FSBoard, error) {
+	if board := tree.findBoard(key); board != nil {
+		return board, nil
+	}
+	tree.refreshRoots()
+	var workspaces []*FSWorkspace
+	for _, node := range tree.inodes {
+		if ws, isWorkspace := node.(*FSWorkspace); isWorkspace {
+			workspaces = append(workspaces, ws)
+		}
+	}
+	for _, ws := range workspaces {
+		tree.refreshNode(ws)
+	}
+	if board := tree.findBoard(key); board != nil {
+		return board, nil
+	}
+	return nil, fmt.Errorf("board '%s' not mounted", key)
+}
+
+func (tree *Tree) findBoard(key string) *FSBoard {
+	for _, node := range tree.inodes {
+		board, isBoard := node.(*FSBoard)
+		if !isBoard {
+			continue
+		}
+		board.Lock()
+		b := board.Board
+		board.Unlock()
+		if b.ID == key || b.Name == key || b.ShortLink == key {
+			return board
+		}
+	}
+	return nil
+}
+
+// Take a snapshot of the board's current state. Must be called with both
+// the updates lock and the tree's lock held.
+func (node *FSBoard) snapshot(name string) *boardSnapshot {
+	tree := node.tree
+	tree.refreshNode(node)
+	tree.refreshNode(node.MetaCardsDir)
+	tree.refreshNode(node.MetaListsDir)
+
+	node.Lock()
+	defer node.Unlock()
+
+	snapshot := &boardSnapshot{
+		Name:      name,
+		Board:     node.Board.ID,
+		BoardName: node.Board.Name,
+		Taken:     time.Now().UTC(),
+	}
+	for _, cardNode := range node.Cards {
+		cardNode.Lock()
+		card := cardNode.Card
+		entry := snapshotCard{
+			ID: card.ID, Name: card.Name, Due: card.Due, Desc: card.Desc,
+		}
+		if list, exists := node.ByListID[card.ListID]; exists {
+			entry.List = list.List.Name
+		}
+		for _, label := range card.Labels {
+			if label.Name == "" {
+				entry.Labels = append(entry.Labels, label.Color)
+			} else {
+				entry.Labels = append(entry.Labels, label.Name)
+			}
+		}
+		cardNode.Unlock()
+		sort.Strings(entry.Labels)
+		snapshot.Cards = append(snapshot.Cards, entry)
+	}
+	return snapshot
+}
+
+func validSnapshotName(name string) error {
+	if name == "" || name == snapshotNow || strings.HasPrefix(name, ".") ||
+		strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("invalid snapshot name '%s'", name)
+	}
+	return nil
+}
+
+func (tree *Tree) storeSnapshot(snapshot *boardSnapshot) error {
+	if tree.opts.SnapshotDir == "" {
+		tree.snapshots[snapshot.Board+"/"+snapshot.Name] = snapshot
+		return nil
+	}
+	dir := filepath.Join(tree.opts.SnapshotDir, snapshot.Board)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	contents, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return cache.WriteFile(filepath.Join(dir, snapshot.Name+".json"), contents)
+}
+
+// Names of the board's snapshots, sorted. Must be called with the updates
+// lock held.
+func (tree *Tree) listSnapshots(board string) []string {
+	var names []string
+	if tree.opts.SnapshotDir == "" {
+		for key := range tree.snapshots {
+			if strings.HasPrefix(key, board+"/") {
+				names = append(names, strings.TrimPrefix(key, board+"/"))
+			}
+		}
+	} else {
+		files, _ := ioutil.ReadDir(filepath.Join(tree.opts.SnapshotDir, board))
+		for _, file := range files {
+			if strings.HasSuffix(file.Name(), ".json") {
+				names = append(names, strings.TrimSuffix(file.Name(), ".json"))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IDs of the boards with snapshots, sorted. Must be called with the updates
+// lock held.
+func (tree *Tree) snapshotBoards() []string {
+	seen := make(map[string]bool)
+	if tree.opts.SnapshotDir == "" {
+		for key := range tree.snapshots {
+			seen[strings.SplitN(key, "/", 2)[0]] = true
+		}
+	} else {
+		files, _ := ioutil.ReadDir(tree.opts.SnapshotDir)
+		for _, file := range files {
+			if file.IsDir() {
+				seen[file.Name()] = true
+			}
+		}
+	}
+	var boards []string
+	for board := range seen {
+		boards = append(boards, board)
+	}
+	sort.Strings(boards)
+	return boards
+}
+
+func (tree *Tree) removeSnapshot(board string, name string) error {
+	if tree.opts.SnapshotDir == "" {
+		delete(tree.snapshots, board+"/"+name)
+		return nil
+	}
+	return os.Remove(
+		filepath.Join(tree.opts.SnapshotDir, board, name+".json"),
+	)
+}
+
+func (tree *Tree) loadSnapshot(board string, name string) (
+	*boardSnapshot, error,
+) {
+	if err := validSnapshotName(name); err != nil {
+		return nil, err
+	}
+	if tree.opts.SnapshotDir == "" {
+		snapshot, exists := tree.snapshots[board+"/"+name]
+		if !exists {
+			return nil, fmt.Errorf("no snapshot '%s'", name)
+		}
+		return snapshot, nil
+	}
+	contents, err := ioutil.ReadFile(
+		filepath.Join(tree.opts.SnapshotDir, board, name+".json"),
+	)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no snapshot '%s'", name)
+	} else if err != nil {
+		return nil, err
+	}
+	var snapshot boardSnapshot
+	if err := json.Unmarshal(contents, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot '%s': %s", name, err)
+	}
+	return &snapshot, nil
+}
+
+// Snapshot a board, by name, ID, or short link, as 'name', replacing any
+// previous snapshot by that name.
+func (tree *Tree) SnapshotBoard(key string, name string) error {
+	tree.updates.Lock()
+	defer tree.updates.Unlock()
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	if err := validSnapshotName(name); err != nil {
+		return err
+	}
+	board, err := tree.boardByKey(key)
+	if err != nil {
+		return err
+	}
+	return tree.storeSnapshot(board.snapshot(name))
+}
+
+// Compare two snapshots of a board, by name, ID, or short link, writing the
+// changes from one to the other to the board's '.changelog'. Either may be
+// "now", for the board as it currently is. Returns how many changes there
+// were.
+func (tree *Tree) DiffSnapshots(key string, from string, to string) (
+	int, error,
+) {
+	tree.updates.Lock()
+	defer tree.updates.Unlock()
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	board, err := tree.boardByKey(key)
+	if err != nil {
+		return 0, err
+	}
+	tree.refreshNode(board)
+	load := func(name string) (*boardSnapshot, error) {
+		if name == snapshotNow {
+			return board.snapshot(name), nil
+		}
+		return tree.loadSnapshot(board.GetTrelloID(), name)
+	}
+	before, err := load(from)
+	if err != nil {
+		return 0, err
+	}
+	after, err := load(to)
+	if err != nil {
+		return 0, err
+	}
+
+	changelog, changes := diffSnapshots(before, after)
+	board.Lock()
+	header := fmt.Sprintf(
+		"Changes to %s from %s (%s) to %s (%s)\n",
+		board.Board.Name,
+		before.Name, before.Taken.Format(time.RFC3339),
+		after.Name, after.Taken.Format(time.RFC3339),
+	)
+	board.changelog = append([]byte(header), changelog...)
+	board.Unlock()
+	board.MetaChangelog.Lock()
+	board.MetaChangelog.markStale()
+	board.MetaChangelog.Unlock()
+	return changes, nil
+}
+
+// Describe the changes between two snapshots, one section per kind of
+// change, cards sorted by name within each. Returns the description, and
+// how many changes there were.
+func diffSnapshots(before *boardSnapshot, after *boardSnapshot) (
+	[]byte, int,
+) {
+	byID := make(map[string]*snapshotCard)
+	for i := range before.Cards {
+		byID[before.Cards[i].ID] = &before.Cards[i]
+	}
+
+	var added, moved, renamed, relabeled []string
+	cards := append([]snapshotCard{}, after.Cards...)
+	sort.Slice(cards, func(i, j int) bool {
+		return cards[i].Name < cards[j].Name
+	})
+	for _, card := range cards {
+		old, existed := byID[card.ID]
+		if !existed {
+			added = append(added, fmt.Sprintf("%s (%s)", card.Name, card.List))
+			continue
+		}
+		delete(byID, card.ID)
+		if old.List != card.List {
+			moved = append(moved, fmt.Sprintf(
+				"%s: %s -> %s", card.Name, old.List, card.List,
+			))
+		}
+		if old.Name != card.Name {
+			renamed = append(renamed, fmt.Sprintf(
+				"%s -> %s", old.Name, card.Name,
+			))
+		}
+		if change := labelChanges(old.Labels, card.Labels); change != "" {
+			relabeled = append(relabeled, card.Name+": "+change)
+		}
+	}
+
+	var closed []string
+	for _, card := range byID {
+		closed = append(closed, fmt.Sprintf("%s (%s)", card.Name, card.List))
+	}
+	sort.Strings(closed)
+
+	var buf bytes.Buffer
+	changes := 0
+	section := func(title string, entries []string) {
+		if len(entries) == 0 {
+			return
+		}
+		fmt.Fprintf(&buf, "\n%s:\n", title)
+		for _, entry := range entries {
+			fmt.Fprintf(&buf, "  %s\n", entry)
+		}
+		changes += len(entries)
+	}
+	section("Added", added)
+	section("Moved", moved)
+	section("Closed, or moved off the board", closed)
+	section("Renamed", renamed)
+	section("Labels", relabeled)
+	if changes == 0 {
+		buf.WriteString("\nNo changes.\n")
+	}
+	return buf.Bytes(), changes
+}
+
+// Labels added to, and removed from, a sorted set of labels, as
+// '+added -removed'.
+func labelChanges(before []string, after []string) string {
+	had := make(map[string]bool)
+	for _, label := range before {
+		had[label] = true
+	}
+	var changes []string
+	for _, label := range after {
+		if had[label] {
+			delete(had, label)
+		} else {
+			changes = append(changes, "+"+label)
+		}
+	}
+	for _, label := range before {
+		if had[label] {
+			changes = append(changes, "-"+label)
+		}
+	}
+	return strings.Join(changes, " ")
+}
@@ -0,0 +1,153 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Boards snapshotted are diffed against how they are now, the changes
+// written to their '.changelog'.
+func TestDiffSnapshots(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/lists": `[
+			{"id": "l1", "name": "To do"},
+			{"id": "l2", "name": "Done"}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Fix", "idList": "l1",
+				"labels": [{"name": "bug"}]},
+			{"id": "c2", "name": "Docs", "idList": "l1"}
+		]`,
+	})
+	tree := newTestTree(t, Options{SnapshotDir: t.TempDir()})
+	board := lookupPath(t, tree, "eng/Roadmap").(*FSBoard)
+
+	if err := tree.SnapshotBoard("Roadmap", "monday"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.SnapshotBoard("Roadmap", "../monday"); err == nil {
+		t.Errorf("snapshot named outside the board's")
+	}
+	err := ioutil.WriteFile(
+		filepath.Join(dir, "boards-b1-cards.json"), []byte(`[
+			{"id": "c1", "name": "Fix crash", "idList": "l2",
+				"labels": [{"name": "urgent"}]},
+			{"id": "c3", "name": "Ship", "idList": "l1"}
+		]`), 0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.lock.Lock()
+	board.MetaCardsDir.markStale()
+	tree.lock.Unlock()
+
+	changes, err := tree.DiffSnapshots("b1", "monday", snapshotNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes != 5 {
+		t.Errorf("%d changes, want 5", changes)
+	}
+	file := lookupPath(t, tree, "eng/Roadmap/.changelog")
+	changelog := readFile(t, tree, file)
+	want := `
+Added:
+  Ship (To do)
+
+Moved:
+  Fix crash: To do -> Done
+
+Closed, or moved off the board:
+  Docs (To do)
+
+Renamed:
+  Fix -> Fix crash
+
+Labels:
+  Fix crash: +urgent -bug
+`
+	if !strings.HasPrefix(changelog, "Changes to Roadmap from monday") ||
+		!strings.HasSuffix(changelog, want) {
+		t.Errorf("changelog:\n%s\nwant:\n%s", changelog, want)
+	}
+	if _, err := tree.DiffSnapshots("b1", "sunday", "monday"); err == nil {
+		t.Errorf("diffed a snapshot never taken")
+	}
+}
+
+// Boards are snapshotted once the last snapshot taken is older than the
+// interval, keeping the most recent ones, and snapshots are browsed as
+// read-only trees of lists and cards.
+func TestAutoSnapshots(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/lists": `[{"id": "l1", "name": "To do"}]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Fix", "idList": "l1", "desc": "Crashes",
+				"labels": [{"name": "bug"}]}
+		]`,
+	})
+	snapshots := t.TempDir()
+	now := time.Now().UTC()
+	var names []string
+	for _, ago := range []time.Duration{48 * time.Hour, 3 * time.Hour} {
+		name := autoSnapshotPrefix + now.Add(-ago).Format(autoSnapshotLayout)
+		err := os.MkdirAll(filepath.Join(snapshots, "b1"), 0700)
+		if err == nil {
+			err = ioutil.WriteFile(
+				filepath.Join(snapshots, "b1", name+".json"),
+				[]byte(`{"board": "b1", "cards": []}`), 0600,
+			)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	tree := newTestTree(t, Options{
+		SnapshotDir:      snapshots,
+		SnapshotInterval: time.Hour,
+		SnapshotKeep:     2,
+	})
+	// snapshotted here, rather than by the tree in the background, which
+	// could otherwise be at it while the snapshots are being cleaned up.
+	tree.opts.SnapshotBoards = []string{"Roadmap"}
+
+	tree.autoSnapshot()
+	tree.autoSnapshot()
+	tree.updates.Lock()
+	got := tree.listSnapshots("b1")
+	tree.updates.Unlock()
+	if len(got) != 2 || got[0] != names[1] {
+		t.Fatalf("snapshots %q, want the last of %q and a new one", got, names)
+	}
+
+	card := lookupPath(
+		t, tree, ".trellofs/snapshots/Roadmap/"+got[1]+"/To do/Fix",
+	)
+	want := "id: c1\nname: Fix\nlist: To do\nlabels: bug\ndue: \n\nCrashes\n"
+	if contents := readFile(t, tree, card); contents != want {
+		t.Errorf("snapshotted card:\n%s\nwant:\n%s", contents, want)
+	}
+}
@@ -0,0 +1,396 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Boards in Options.SnapshotBoards are snapshotted every SnapshotInterval,
+// as 'auto-<time>', keeping the last SnapshotKeep such snapshots. Whether
+// one is due is told by the last one taken, so the schedule holds across
+// mounts when snapshots are kept on disk.
+const autoSnapshotPrefix = "auto-"
+const autoSnapshotLayout = "2006-01-02T15:04:05Z"
+const autoSnapshotTick = time.Minute
+
+func (tree *Tree) snapshotter() {
+	for {
+		tree.autoSnapshot()
+		time.Sleep(autoSnapshotTick)
+	}
+}
+
+func (tree *Tree) autoSnapshot() {
+	tree.updates.Lock()
+	defer tree.updates.Unlock()
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	now := time.Now().UTC()
+	for _, key := range tree.opts.SnapshotBoards {
+		board, err := tree.boardByKey(key)
+		if err != nil {
+			log.Printf("snapshots > %s\n", err)
+			continue
+		}
+		var auto []string
+		for _, name := range tree.listSnapshots(board.GetTrelloID()) {
+			if strings.HasPrefix(name, autoSnapshotPrefix) {
+				auto = append(auto, name)
+			}
+		}
+		if len(auto) > 0 {
+			last, err := time.Parse(
+				autoSnapshotLayout,
+				strings.TrimPrefix(auto[len(auto)-1], autoSnapshotPrefix),
+			)
+			if err == nil && now.Sub(last) < tree.opts.SnapshotInterval {
+				continue
+			}
+		}
+
+		name := autoSnapshotPrefix + now.Format(autoSnapshotLayout)
+		if err := tree.storeSnapshot(board.snapshot(name)); err != nil {
+			log.Printf("snapshots > error storing %s: %s\n", name, err)
+			continue
+		}
+		log.Printf("snapshots > took %s of board %s\n", name, key)
+		auto = append(auto, name)
+		for keep := tree.opts.SnapshotKeep; keep > 0 && len(auto) > keep; {
+			err := tree.removeSnapshot(board.GetTrelloID(), auto[0])
+			if err != nil {
+				log.Printf("snapshots > error removing %s: %s\n", auto[0], err)
+			}
+			auto = auto[1:]
+		}
+	}
+}
+
+// The '.trellofs/snapshots' directory, holding a directory per board with
+// snapshots, itself holding a read-only tree per snapshot, as
+// '<snapshot>/<list>/<card>'.
+type FSSnapshotsDir struct {
+	BaseFSNode
+
+	Boards  []*FSSnapshotBoardDir
+	byBoard map[string]*FSSnapshotBoardDir
+}
+
+type FSSnapshotBoardDir struct {
+	BaseFSNode
+
+	BoardID   string
+	Snapshots []*FSSnapshotDir
+	byName    map[string]*FSSnapshotDir
+}
+
+type FSSnapshotDir struct {
+	BaseFSNode
+
+	BoardID string
+	Lists   []*FSSnapshotListDir
+}
+
+type FSSnapshotListDir struct {
+	BaseFSNode
+
+	Cards []*FSGeneratedFile
+}
+
+func newSnapshotNode(parent FSNode, name string, trelloID string) BaseFSNode {
+	attrs := parent.GetNodeAttrs()
+	return BaseFSNode{
+		name: name,
+		uid:  attrs.Uid,
+		gid:  attrs.Gid,
+		NodeAttrs: fuseops.InodeAttributes{
+			Mode: 0500 | os.ModeDir,
+			Uid:  attrs.Uid,
+			Gid:  attrs.Gid,
+		},
+		isDir:    true,
+		TrelloID: trelloID,
+		parent:   parent,
+	}
+}
+
+func newSnapshotsDir(parent FSNode) *FSSnapshotsDir {
+	return &FSSnapshotsDir{
+		BaseFSNode: newSnapshotNode(parent, "snapshots", "_trellofs/snapshots"),
+		byBoard:    make(map[string]*FSSnapshotBoardDir),
+	}
+}
+
+func (node *FSSnapshotsDir) ShouldUpdate() bool {
+	return node.shouldUpdate(30.0)
+}
+
+// Called with the updates lock held, as it is when refreshed.
+func (node *FSSnapshotsDir) Update() ([]FSNode, []FSNode, error) {
+	boardIDs := node.tree.snapshotBoards()
+	names := make(map[string]string)
+	for _, id := range boardIDs {
+		names[id] = node.boardName(id)
+	}
+
+	node.Lock()
+	defer node.Unlock()
+
+	var newNodes, rmNodes []FSNode
+	var boards []*FSSnapshotBoardDir
+	byBoard := make(map[string]*FSSnapshotBoardDir)
+	for _, id := range boardIDs {
+		board, exists := node.byBoard[id]
+		if !exists {
+			board = &FSSnapshotBoardDir{
+				BaseFSNode: newSnapshotNode(
+					node, escapeName(names[id]), node.GetTrelloID()+"/"+id,
+				),
+				BoardID: id,
+				byName:  make(map[string]*FSSnapshotDir),
+			}
+			newNodes = append(newNodes, board)
+		}
+		boards = append(boards, board)
+		byBoard[id] = board
+	}
+	for id, board := range node.byBoard {
+		if _, exists := byBoard[id]; !exists {
+			rmNodes = append(rmNodes, board)
+		}
+	}
+	node.Boards = boards
+	node.byBoard = byBoard
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+// A board's directory is named after it, as it's called now if mounted, or
+// as it was when last snapshotted otherwise. Must be called with the updates
+// lock held.
+func (node *FSSnapshotsDir) boardName(id string) string {
+	node.tree.lock.Lock()
+	board, isBoard := node.tree.nodeByTrelloID(id).(*FSBoard)
+	node.tree.lock.Unlock()
+	if isBoard {
+		board.Lock()
+		defer board.Unlock()
+		return board.Board.Name
+	}
+	names := node.tree.listSnapshots(id)
+	if len(names) > 0 {
+		snapshot, err := node.tree.loadSnapshot(id, names[len(names)-1])
+		if err == nil && snapshot.BoardName != "" {
+			return snapshot.BoardName
+		}
+	}
+	return id
+}
+
+func (node *FSSnapshotsDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, board := range node.Boards {
+		if board.GetName() == name {
+			return board, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSSnapshotsDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Boards))
+	for i, board := range node.Boards {
+		entries[i] = board
+	}
+	return entries
+}
+
+func (node *FSSnapshotBoardDir) ShouldUpdate() bool {
+	return node.shouldUpdate(30.0)
+}
+
+// Called with the updates lock held, as it is when refreshed.
+func (node *FSSnapshotBoardDir) Update() ([]FSNode, []FSNode, error) {
+	names := node.tree.listSnapshots(node.BoardID)
+
+	node.Lock()
+	defer node.Unlock()
+
+	var newNodes, rmNodes []FSNode
+	var snapshots []*FSSnapshotDir
+	byName := make(map[string]*FSSnapshotDir)
+	for _, name := range names {
+		snapshot, exists := node.byName[name]
+		if !exists {
+			snapshot = &FSSnapshotDir{
+				BaseFSNode: newSnapshotNode(
+					node, name, node.GetTrelloID()+"/"+name,
+				),
+				BoardID: node.BoardID,
+			}
+			newNodes = append(newNodes, snapshot)
+		}
+		snapshots = append(snapshots, snapshot)
+		byName[name] = snapshot
+	}
+	for name, snapshot := range node.byName {
+		if _, exists := byName[name]; !exists {
+			rmNodes = append(rmNodes, snapshot)
+		}
+	}
+	node.Snapshots = snapshots
+	node.byName = byName
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSSnapshotBoardDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if snapshot, exists := node.byName[name]; exists {
+		return snapshot, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSSnapshotBoardDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Snapshots))
+	for i, snapshot := range node.Snapshots {
+		entries[i] = snapshot
+	}
+	return entries
+}
+
+// Snapshots don't change, so they're only loaded once.
+func (node *FSSnapshotDir) ShouldUpdate() bool {
+	return !node.isLoaded()
+}
+
+// Called with the updates lock held, as it is when refreshed.
+func (node *FSSnapshotDir) Update() ([]FSNode, []FSNode, error) {
+	snapshot, err := node.tree.loadSnapshot(node.BoardID, node.GetName())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	node.Lock()
+	defer node.Unlock()
+
+	var newNodes []FSNode
+	byList := make(map[string]*FSSnapshotListDir)
+	taken := make(map[*FSSnapshotListDir]map[string]bool)
+	for _, card := range snapshot.Cards {
+		list, exists := byList[card.List]
+		if !exists {
+			list = &FSSnapshotListDir{
+				BaseFSNode: newSnapshotNode(
+					node, escapeName(card.List),
+					fmt.Sprintf("%s/%d", node.GetTrelloID(), len(byList)),
+				),
+			}
+			byList[card.List] = list
+			taken[list] = make(map[string]bool)
+			node.Lists = append(node.Lists, list)
+			newNodes = append(newNodes, list)
+		}
+		name := escapeName(card.Name)
+		if taken[list][name] {
+			name = fmt.Sprintf("%s (%s)", name, card.ID)
+		}
+		taken[list][name] = true
+
+		contents := []byte(fmt.Sprintf(
+			"id: %s\nname: %s\nlist: %s\nlabels: %s\ndue: %s\n\n%s\n",
+			card.ID, card.Name, card.List, strings.Join(card.Labels, ", "),
+			card.Due, card.Desc,
+		))
+		file := newGeneratedFile(list, name, 0.0, func() ([]byte, error) {
+			return contents, nil
+		})
+		file.TrelloID = list.GetTrelloID() + "/" + card.ID
+		file.contents = contents
+		file.setSize(uint64(len(contents)))
+		list.Cards = append(list.Cards, file)
+		newNodes = append(newNodes, file)
+	}
+	node.markUpdated()
+	return newNodes, nil, nil
+}
+
+func (node *FSSnapshotDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, list := range node.Lists {
+		if list.GetName() == name {
+			return list, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSSnapshotDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Lists))
+	for i, list := range node.Lists {
+		entries[i] = list
+	}
+	return entries
+}
+
+func (node *FSSnapshotListDir) ShouldUpdate() bool {
+	return false
+}
+
+func (node *FSSnapshotListDir) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, nil
+}
+
+func (node *FSSnapshotListDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, card := range node.Cards {
+		if card.GetName() == name {
+			return card, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSSnapshotListDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Cards))
+	for i, card := range node.Cards {
+		entries[i] = card
+	}
+	return entries
+}
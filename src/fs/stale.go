@@ -0,0 +1,119 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Symlinks to a list's cards that have been idle for longer than the
+// configured number of days.
+type FSListStaleDir struct {
+	BaseFSNode
+
+	ListNode *FSList
+
+	Links  []*FSSymlink
+	byCard map[string]*FSSymlink
+}
+
+func newListStaleDir(list *FSList) *FSListStaleDir {
+	return &FSListStaleDir{
+		BaseFSNode: BaseFSNode{
+			name: ".stale",
+			uid:  list.uid,
+			gid:  list.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0500 | os.ModeDir,
+				Uid:  list.uid,
+				Gid:  list.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/.stale", list.GetTrelloID()),
+			Ctx:      list.Ctx,
+			parent:   list,
+		},
+		ListNode: list,
+		byCard:   make(map[string]*FSSymlink),
+	}
+}
+
+func (node *FSListStaleDir) ShouldUpdate() bool {
+	return node.shouldUpdate(60.0)
+}
+
+func (node *FSListStaleDir) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	node.ListNode.Lock()
+	cards := append([]*FSCard{}, node.ListNode.Cards...)
+	node.ListNode.Unlock()
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+	var links []*FSSymlink
+	byCard := make(map[string]*FSSymlink)
+	threshold := node.tree.opts.StaleDays
+	for _, card := range cards {
+		if card.daysIdle() <= threshold {
+			continue
+		}
+		link, exists := node.byCard[card.GetTrelloID()]
+		if !exists {
+			link = newSymlink(
+				node, card.GetName(), relativePath(node, card),
+			)
+			link.TrelloID = fmt.Sprintf(
+				"%s/%s", node.GetTrelloID(), card.GetTrelloID(),
+			)
+			newNodes = append(newNodes, link)
+		}
+		byCard[card.GetTrelloID()] = link
+		links = append(links, link)
+	}
+	for id, link := range node.byCard {
+		if _, exists := byCard[id]; !exists {
+			rmNodes = append(rmNodes, link)
+		}
+	}
+	node.byCard = byCard
+	node.Links = links
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSListStaleDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, link := range node.Links {
+		if link.GetName() == name {
+			return link, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSListStaleDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Links))
+	for i, link := range node.Links {
+		entries[i] = link
+	}
+	return entries
+}
@@ -0,0 +1,62 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Cards tell how long they've been idle for, and those idle for longer
+// than configured are linked from their list's '.stale'.
+func TestListStale(t *testing.T) {
+	withFixtures(t, nil)
+	tree := newTestTree(t, Options{StaleDays: 7})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	list := &FSList{
+		BaseFSNode: BaseFSNode{
+			name: "To do", TrelloID: "l1", parent: board, isDir: true,
+		},
+		BoardNode: board,
+	}
+	ago := func(days int) string {
+		return time.Now().AddDate(0, 0, -days).Format(time.RFC3339)
+	}
+	idle := newTestCard(board, &trello.Card{
+		ID: "c1", Name: "Idle", LastActive: ago(10),
+	})
+	busy := newTestCard(board, &trello.Card{
+		ID: "c2", Name: "Busy", LastActive: ago(1),
+	})
+	list.Cards = []*FSCard{idle, busy}
+	stale := newListStaleDir(list)
+	addNodes(tree, list, stale)
+
+	if got, err := idle.GetXattr(xattrDaysIdle); string(got) != "10" {
+		t.Errorf("%s = %q (%v), want %q", xattrDaysIdle, got, err, "10")
+	}
+	entries, err := tree.Entries(context.Background(), stale.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entryNamed(entries, "Busy") != nil {
+		t.Errorf("busy card listed as stale")
+	}
+	link := entryNamed(entries, "Idle")
+	if link == nil {
+		t.Fatal("idle card not listed as stale")
+	}
+	if target, _ := link.ReadLink(); target != "../../cards/Idle" {
+		t.Errorf("stale card linked to %q", target)
+	}
+}
@@ -0,0 +1,178 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Log levels, as set through '.trellofs/log_level': everything, only
+// messages about errors, or nothing at all.
+var logLevels = []string{"debug", "error", "off"}
+
+// Filters what's logged according to the log level. Installed as the log's
+// output once the level is first set.
+type logFilter struct {
+	lock  sync.Mutex
+	out   io.Writer
+	level string
+}
+
+var logOutput = &logFilter{level: "debug"}
+
+func (filter *logFilter) Write(p []byte) (int, error) {
+	filter.lock.Lock()
+	defer filter.lock.Unlock()
+
+	switch filter.level {
+	case "off":
+		return len(p), nil
+	case "error":
+		if !bytes.Contains(bytes.ToLower(p), []byte("error")) {
+			return len(p), nil
+		}
+	}
+	return filter.out.Write(p)
+}
+
+func getLogLevel() ([]byte, error) {
+	logOutput.lock.Lock()
+	defer logOutput.lock.Unlock()
+	return []byte(logOutput.level + "\n"), nil
+}
+
+func setLogLevel(contents []byte) error {
+	level := strings.TrimSpace(string(contents))
+	known := false
+	for _, name := range logLevels {
+		known = known || name == level
+	}
+	if !known {
+		log.Printf(
+			"error setting log level: unknown level '%s'; one of %s\n",
+			level, strings.Join(logLevels, ", "),
+		)
+		return syscall.EINVAL
+	}
+
+	logOutput.lock.Lock()
+	if logOutput.out == nil {
+		logOutput.out = log.Writer()
+		log.SetOutput(logOutput)
+	}
+	logOutput.level = level
+	logOutput.lock.Unlock()
+	log.Printf("log level set to %s\n", level)
+	return nil
+}
+
+// Describe how the filesystem is getting along with Trello, as
+//
+//	connection	<online|unreachable|failing|unknown>
+//	budget	<fraction of the rate limit budget left>
+//	inflight	<requests in flight>/<max>
+//	last-success	<time>
+//	last-failure	<time>	<error>
+//	wip-breach	<list>	<cards>/<limit>
+//
+// with a 'wip-breach' line for each list over its WIP limit.
+func (tree *Tree) status() ([]byte, error) {
+	status := trello.GetStatus()
+
+	connection := "unknown"
+	if status.LastSuccess.After(status.LastFailure) {
+		connection = "online"
+	} else if status.Unreachable {
+		connection = "unreachable"
+	} else if !status.LastFailure.IsZero() {
+		connection = "failing"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "connection\t%s\n", connection)
+	fmt.Fprintf(&buf, "budget\t%.2f\n", tree.apiBudget())
+	fmt.Fprintf(
+		&buf, "inflight\t%d/%d\n", status.Inflight, status.MaxInflight,
+	)
+	if !status.LastSuccess.IsZero() {
+		fmt.Fprintf(
+			&buf, "last-success\t%s\n",
+			status.LastSuccess.Format(time.RFC3339),
+		)
+	}
+	if !status.LastFailure.IsZero() {
+		fmt.Fprintf(
+			&buf, "last-failure\t%s\t%s\n",
+			status.LastFailure.Format(time.RFC3339), status.LastError,
+		)
+	}
+	for _, breach := range tree.wipBreaches() {
+		fmt.Fprintf(&buf, "wip-breach\t%s\n", breach)
+	}
+	return buf.Bytes(), nil
+}
+
+// Count what's been done, as '<counter>\t<count>' lines: operations served,
+// as 'op.<operation>', requests made to Trello, as 'api.<method>', those
+// failed, as 'api.failures', and GET requests found unchanged through their
+// ETags, as 'api.etag-hits', or not, as 'api.etag-misses'; along with how
+// many nodes are known.
+func (tree *Tree) stats() ([]byte, error) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	counters := make(map[string]uint64)
+	for op, count := range tree.ops {
+		counters["op."+op] = count
+	}
+	status := trello.GetStatus()
+	for method, count := range status.Requests {
+		counters["api."+method] = count
+	}
+	counters["api.failures"] = status.Failures
+	counters["api.etag-hits"] = status.ETagHits
+	counters["api.etag-misses"] = status.ETagMisses
+	nodes := uint64(0)
+	for _, node := range tree.inodes {
+		if node != nil {
+			nodes++
+		}
+	}
+	counters["nodes"] = nodes
+
+	var names []string
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s\t%d\n", name, counters[name])
+	}
+	return buf.Bytes(), nil
+}
+
+// Refresh everything already browsed, as if written to the root's
+// '.refresh'. Called with the updates lock held.
+func (tree *Tree) refreshAll(contents []byte) error {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	return tree.refreshSubtree(tree.inodes[fuseops.RootInodeID])
+}
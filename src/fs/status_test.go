@@ -0,0 +1,69 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// '.trellofs' tells how the filesystem is getting along, counts what it's
+// done, refreshes everything loaded when written to, and sets what's
+// logged.
+func TestCtlDir(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+	})
+	tree := newTestTree(t, Options{})
+
+	status := readFile(t, tree, lookupPath(t, tree, ".trellofs/status"))
+	for _, field := range []string{"connection\t", "budget\t", "inflight\t"} {
+		if !strings.Contains(status, "\n"+field) &&
+			!strings.HasPrefix(status, field) {
+			t.Errorf("status missing %q:\n%s", field, status)
+		}
+	}
+	stats := readFile(t, tree, lookupPath(t, tree, ".trellofs/stats"))
+	for _, counter := range []string{"op.lookup\t", "op.read\t", "nodes\t"} {
+		if !strings.Contains(stats, counter) {
+			t.Errorf("stats missing %q:\n%s", counter, stats)
+		}
+	}
+
+	lookupPath(t, tree, "eng")
+	err := ioutil.WriteFile(
+		filepath.Join(dir, "members-me-organizations.json"),
+		[]byte(`[{"id": "w1", "name": "eng"}, {"id": "w2", "name": "ops"}]`),
+		0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh := lookupPath(t, tree, ".trellofs/refresh")
+	if err := writeFile(t, tree, refresh, "1\n"); err != nil {
+		t.Fatal(err)
+	}
+	lookupPath(t, tree, "ops")
+
+	level := lookupPath(t, tree, ".trellofs/log_level")
+	defer setLogLevel([]byte("debug"))
+	if err := writeFile(t, tree, level, "error\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, tree, level); got != "error\n" {
+		t.Errorf("log level %q once set to error", got)
+	}
+	if err := writeFile(t, tree, level, "verbose\n"); err != syscall.EINVAL {
+		t.Errorf("unknown log level set: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+)
+
+type cardCounts struct {
+	open   int
+	closed int
+}
+
+// Generate the board's summary, with per-list open and closed card counts,
+// followed by the board's totals over those same lists, i.e. its open ones,
+// cards on closed lists counting towards neither. One tab-separated line per
+// entity:
+//
+//	list	<name>	<open>	<closed>
+//	board	<name>	<open>	<closed>
+func (node *FSBoard) summary() ([]byte, error) {
+
+	board := node.Board
+	lists, err := board.GetLists(node.api())
+	if err != nil {
+		return nil, err
+	}
+	cards, err := board.GetAllCards(node.api())
+	if err != nil {
+		return nil, err
+	}
+
+	var total cardCounts
+	byList := make(map[string]*cardCounts)
+	for _, list := range lists {
+		byList[list.ID] = &cardCounts{}
+	}
+	for _, card := range cards {
+		counts := byList[card.ListID]
+		if counts == nil {
+			continue
+		}
+		if card.Closed {
+			counts.closed++
+			total.closed++
+		} else {
+			counts.open++
+			total.open++
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, list := range lists {
+		counts := byList[list.ID]
+		fmt.Fprintf(
+			&buf, "list\t%s\t%d\t%d\n", list.Name, counts.open, counts.closed,
+		)
+	}
+	fmt.Fprintf(
+		&buf, "board\t%s\t%d\t%d\n", board.Name, total.open, total.closed,
+	)
+	return buf.Bytes(), nil
+}
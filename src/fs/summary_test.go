@@ -0,0 +1,47 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// The board's totals add up its lists' counts, cards on closed lists
+// counting towards neither.
+func TestSummary(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/boards/b1/lists": `[
+			{"id": "l1", "name": "To do"},
+			{"id": "l2", "name": "Done"}
+		]`,
+		"/boards/b1/cards/all": `[
+			{"id": "c1", "idList": "l1"},
+			{"id": "c2", "idList": "l1", "closed": true},
+			{"id": "c3", "idList": "l2"},
+			{"id": "c4", "idList": "l3"},
+			{"id": "c5", "idList": "l3", "closed": true}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "My board"})
+
+	got, err := board.summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "list\tTo do\t1\t1\n" +
+		"list\tDone\t1\t0\n" +
+		"board\tMy board\t2\t1\n"
+	if string(got) != want {
+		t.Errorf("summary:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"os"
+	"strings"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+type FSSymlink struct {
+	BaseFSNode
+
+	target string
+}
+
+func newSymlink(parent FSNode, name string, target string) *FSSymlink {
+	attrs := parent.GetNodeAttrs()
+	return &FSSymlink{
+		BaseFSNode: BaseFSNode{
+			name: name,
+			uid:  attrs.Uid,
+			gid:  attrs.Gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode:  0777 | os.ModeSymlink,
+				Nlink: 1,
+				Uid:   attrs.Uid,
+				Gid:   attrs.Gid,
+				Size:  uint64(len(target)),
+			},
+			isDir:    false,
+			TrelloID: parent.GetTrelloID() + "/" + name,
+			parent:   parent,
+		},
+		target: target,
+	}
+}
+
+func (node *FSSymlink) setTarget(target string) {
+	node.Lock()
+	defer node.Unlock()
+	node.target = target
+	node.setSize(uint64(len(target)))
+}
+
+func (node *FSSymlink) ShouldUpdate() bool {
+	return false
+}
+
+func (node *FSSymlink) Update() ([]FSNode, []FSNode, error) {
+	return nil, nil, fuse.EINVAL
+}
+
+func (node *FSSymlink) LookupChild(name string) (FSNode, error) {
+	return nil, fuse.ENOENT
+}
+
+func (node *FSSymlink) GetEntries() []FSNode {
+	return nil
+}
+
+func (node *FSSymlink) ReadLink() (string, error) {
+	node.Lock()
+	defer node.Unlock()
+	return node.target, nil
+}
+
+// Obtain a node's path, relative to the filesystem's root.
+func nodePath(node FSNode) string {
+	var names []string
+	for n := node; n.GetParent() != nil; n = n.GetParent() {
+		names = append([]string{n.GetName()}, names...)
+	}
+	return strings.Join(names, "/")
+}
+
+// Obtain the relative path from a directory to the root, e.g. "../../".
+func pathToRoot(from FSNode) string {
+	var up string
+	for n := from; n.GetParent() != nil; n = n.GetParent() {
+		up += "../"
+	}
+	return up
+}
+
+// Obtain the relative path from a directory to a given node.
+func relativePath(from FSNode, to FSNode) string {
+	return pathToRoot(from) + nodePath(to)
+}
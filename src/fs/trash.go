@@ -0,0 +1,232 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// A workspace's '.recently-removed' directory, holding a file per card or
+// list deleted or archived on any of its boards in the last
+// Options.TrashDays days, and not since restored, describing what was
+// removed, from where, by whom, and when.
+type FSWorkspaceTrashDir struct {
+	BaseFSNode
+
+	WorkspaceNode *FSWorkspace
+
+	Files  []*FSGeneratedFile
+	ByName map[string]*FSGeneratedFile
+
+	// what's been removed, by the removed card's or list's ID.
+	byEntity map[string]*trashEntry
+}
+
+type trashEntry struct {
+	kind    string
+	id      string
+	name    string
+	board   string
+	list    string
+	removal string
+	by      string
+	at      string
+
+	file *FSGeneratedFile
+}
+
+func newWorkspaceTrashDir(ws *FSWorkspace) *FSWorkspaceTrashDir {
+	return &FSWorkspaceTrashDir{
+		BaseFSNode: BaseFSNode{
+			name: ".recently-removed",
+			uid:  ws.uid,
+			gid:  ws.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0500 | os.ModeDir,
+				Uid:  ws.uid,
+				Gid:  ws.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/.recently-removed", ws.GetTrelloID()),
+			Ctx:      ws.Ctx,
+			parent:   ws,
+		},
+		WorkspaceNode: ws,
+		ByName:        make(map[string]*FSGeneratedFile),
+		byEntity:      make(map[string]*trashEntry),
+	}
+}
+
+// Obtain the card or list an action is about, and whether the action
+// removed it, rather than restored it. Nil if the action is none of those.
+func trashEntryFor(action *trello.Action) (*trashEntry, bool) {
+	entry := &trashEntry{
+		kind:    "card",
+		id:      action.Data.Card.ID,
+		name:    action.Data.Card.Name,
+		board:   action.Data.Board.Name,
+		list:    action.Data.List.Name,
+		removal: "archived",
+		by:      action.MemberCreator.Username,
+		at:      action.Date,
+	}
+	switch action.Type {
+	case "deleteCard":
+		entry.removal = "deleted"
+		return entry, true
+	case "updateCard":
+		return entry, action.Data.Card.Closed
+	case "updateList":
+		entry.kind = "list"
+		entry.id = action.Data.List.ID
+		entry.name = action.Data.List.Name
+		entry.list = ""
+		return entry, action.Data.List.Closed
+	}
+	return nil, false
+}
+
+// Describe what was removed, as
+//
+//	type: <card|list>
+//	id: <ID>
+//	name: <name>
+//	board: <board>
+//	list: <list the card was on>
+//	removal: <archived|deleted>
+//	by: <username>
+//	at: <date>
+//
+// Deleted cards' names aren't known.
+func (entry *trashEntry) describe() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "type: %s\nid: %s\n", entry.kind, entry.id)
+	fmt.Fprintf(&buf, "name: %s\nboard: %s\n", entry.name, entry.board)
+	if entry.kind == "card" {
+		fmt.Fprintf(&buf, "list: %s\n", entry.list)
+	}
+	fmt.Fprintf(
+		&buf, "removal: %s\nby: %s\nat: %s\n",
+		entry.removal, entry.by, entry.at,
+	)
+	return buf.Bytes()
+}
+
+func (node *FSWorkspaceTrashDir) ShouldUpdate() bool {
+	return node.shouldUpdate(60.0)
+}
+
+// Cards and lists are as the most recent action on them left them, so those
+// removed and since restored aren't listed.
+func (node *FSWorkspaceTrashDir) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	ws := node.WorkspaceNode
+	ws.Lock()
+	workspace := *ws.Workspace
+	ws.Unlock()
+	since := time.Now().AddDate(0, 0, -node.tree.opts.TrashDays)
+	actions, err := workspace.GetRemovals(node.api(), since)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+
+	decided := make(map[string]bool)
+	trashed := make(map[string]bool)
+	for i := range actions {
+		entry, removed := trashEntryFor(&actions[i])
+		if entry == nil || entry.id == "" || decided[entry.id] {
+			continue
+		}
+		decided[entry.id] = true
+		if !removed {
+			continue
+		}
+		trashed[entry.id] = true
+
+		if existing, exists := node.byEntity[entry.id]; exists {
+			entry.file = existing.file
+			*existing = *entry
+			continue
+		}
+		suffix := shortID(entry.id)
+		if link := actions[i].Data.Card.ShortLink; entry.kind == "card" &&
+			link != "" {
+			suffix = link
+		}
+		name := uniqueName(
+			escapeName(entry.name), suffix, entry.id,
+			func(name string) bool { return node.ByName[name] != nil },
+		)
+		entry.file = newGeneratedFile(
+			node, name, 60.0, func() ([]byte, error) {
+				node.Lock()
+				defer node.Unlock()
+				return entry.describe(), nil
+			},
+		)
+		entry.file.TrelloID = node.GetTrelloID() + "/" + entry.id
+		node.byEntity[entry.id] = entry
+		node.Files = append(node.Files, entry.file)
+		node.ByName[name] = entry.file
+		newNodes = append(newNodes, entry.file)
+	}
+
+	for id, entry := range node.byEntity {
+		if trashed[id] {
+			continue
+		}
+		delete(node.byEntity, id)
+		delete(node.ByName, entry.file.GetName())
+		rmNodes = append(rmNodes, entry.file)
+	}
+	var remaining []*FSGeneratedFile
+	for _, file := range node.Files {
+		if node.ByName[file.GetName()] == file {
+			remaining = append(remaining, file)
+		}
+	}
+	node.Files = remaining
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSWorkspaceTrashDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	if file, exists := node.ByName[name]; exists {
+		return file, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSWorkspaceTrashDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Files))
+	for i, file := range node.Files {
+		entries[i] = file
+	}
+	return entries
+}
@@ -0,0 +1,87 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Cards and lists archived or deleted are described under the workspace's
+// '.recently-removed', unless since restored.
+func TestWorkspaceTrash(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards":  `[]`,
+		"/organizations/w1/actions": `[
+			{"type": "updateCard", "date": "2022-03-04T00:00:00Z",
+				"memberCreator": {"username": "alice"}, "data": {
+				"card": {"id": "c1", "name": "Fix", "shortLink": "aa",
+					"closed": true},
+				"board": {"name": "Roadmap"}, "list": {"name": "Doing"}
+			}},
+			{"type": "deleteCard", "date": "2022-03-03T00:00:00Z",
+				"memberCreator": {"username": "bob"}, "data": {
+				"card": {"id": "c2", "shortLink": "bb"},
+				"board": {"name": "Roadmap"}, "list": {"name": "To do"}
+			}},
+			{"type": "updateList", "date": "2022-03-02T00:00:00Z",
+				"memberCreator": {"username": "alice"}, "data": {
+				"list": {"id": "6200000000l1", "name": "Old", "closed": true},
+				"board": {"name": "Roadmap"}
+			}},
+			{"type": "updateCard", "date": "2022-03-02T00:00:00Z",
+				"memberCreator": {"username": "bob"}, "data": {
+				"card": {"id": "c3", "name": "Setup", "shortLink": "cc",
+					"closed": false},
+				"board": {"name": "Roadmap"}, "list": {"name": "Doing"}
+			}},
+			{"type": "updateCard", "date": "2022-03-01T00:00:00Z",
+				"memberCreator": {"username": "bob"}, "data": {
+				"card": {"id": "c3", "name": "Setup", "shortLink": "cc",
+					"closed": true},
+				"board": {"name": "Roadmap"}, "list": {"name": "Doing"}
+			}}
+		]`,
+	})
+	tree := newTestTree(t, Options{TrashDays: 7})
+	trash := lookupPath(t, tree, "eng/.recently-removed")
+	entries, err := tree.Entries(context.Background(), trash.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.GetName())
+	}
+	sort.Strings(names)
+	want := []string{".refresh", "Fix", "Old", "bb"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("removed: %q, want %q", names, want)
+	}
+
+	for name, want := range map[string]string{
+		"Fix": "type: card\nid: c1\nname: Fix\nboard: Roadmap\n" +
+			"list: Doing\nremoval: archived\nby: alice\n" +
+			"at: 2022-03-04T00:00:00Z\n",
+		"bb": "type: card\nid: c2\nname: \nboard: Roadmap\n" +
+			"list: To do\nremoval: deleted\nby: bob\n" +
+			"at: 2022-03-03T00:00:00Z\n",
+		"Old": "type: list\nid: 6200000000l1\nname: Old\nboard: Roadmap\n" +
+			"removal: archived\nby: alice\nat: 2022-03-02T00:00:00Z\n",
+	} {
+		file := entryNamed(entries, name)
+		if got := readFile(t, tree, file); got != want {
+			t.Errorf("%s:\n%s\nwant:\n%s", name, got, want)
+		}
+	}
+}
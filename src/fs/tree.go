@@ -0,0 +1,1069 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+type Options struct {
+	// Per-workspace contexts, keyed by workspace name or ID.
+	WorkspaceCtx map[string]*trello.TrelloCtx
+
+	// Name workspace directories after their display name, instead of
+	// their short name.
+	UseDisplayName bool
+
+	// Mount the workspace with this name or ID as the filesystem's root,
+	// instead of listing all workspaces.
+	RootWorkspace string
+
+	// Accounts to mount, each as a top-level directory listing its
+	// workspaces, instead of listing the workspaces of the tree's own
+	// context. Not along with RootWorkspace.
+	Accounts []Account
+
+	// Where the tree is mounted, to present absolute paths, e.g. in
+	// '.trellofs/bookmarks'. Paths are relative to the root, if unset.
+	MountPoint string
+
+	// Directory layout to present, one of Layouts; the first, if unset.
+	Layout string
+
+	// How often to refresh each kind of entity, keyed by "workspaces",
+	// "boards", "lists", or "cards". Entities not listed are refreshed as
+	// often as they are by default; those set to zero never are, once
+	// loaded.
+	RefreshIntervals map[string]time.Duration
+
+	// Nodes not accessed for this long are no longer refreshed in the
+	// background, until they are accessed again.
+	IdleTimeout time.Duration
+
+	// Allow removing members from boards by unlinking their files.
+	AllowMemberRemoval bool
+
+	// Cards idle for more than this many days are considered stale.
+	StaleDays int
+
+	// Boards' 'lists' list at most this many lists, the rest being left
+	// to their '.overflow'; see listsoverflow.go. No limit if zero.
+	MaxLists int
+
+	// Present cards on their lists as their directories themselves, the
+	// same inode as under the board's 'cards', rather than as symlinks.
+	HardlinkCards bool
+
+	// Cards and lists removed in the last this many days are listed under
+	// each workspace's '.recently-removed'.
+	TrashDays int
+
+	// Give cards 'WordCount' and 'BrokenLinks' files, describing their
+	// description as a document; see lint.go.
+	DocMeta bool
+
+	// Layout, as for time.Format, and timezone in which to render card
+	// dates, e.g. in their 'Due' files; see dates.go. RFC 3339 and UTC, as
+	// Trello has them, by default.
+	DateFormat string
+	Location   *time.Location
+
+	// Template prefixing board and label names with their color, e.g.
+	// "{emoji} " or "[{color}] ". Empty to leave names alone.
+	ColorPrefix string
+
+	// Templates naming boards, lists, and cards, keyed by "board", "list",
+	// or "card". Entities without a template are named after their name.
+	NameTemplates map[string]string
+
+	// Name boards, lists, and cards after their Trello ID. Either for all
+	// of them, or only within the workspaces and boards in IDNamesIn.
+	IDNames   bool
+	IDNamesIn []string
+
+	// Workspaces to mount, by name, display name, or ID; and, keyed by
+	// workspace name or ID, which of their boards to mount, by name or ID.
+	WorkspaceFilter Filter
+	BoardFilters    map[string]Filter
+
+	// Boards to mount, as '<workspace>/<board>' patterns, in addition to
+	// the filters above; see BoardSelection.
+	Boards BoardSelection
+
+	// Queries materialized as directories under each board's 'views',
+	// keyed by the directory's name.
+	Views map[string]*Query
+
+	// Commands run on events, keyed by event; see hooks.go. Cards are due
+	// soon once due within DueSoon.
+	Hooks   map[string][]string
+	DueSoon time.Duration
+
+	// ID of the list cards dropped into '.inbox' are filed in. No inbox
+	// unless set.
+	InboxList string
+
+	// Directory board snapshots are kept in, so they outlive the mount. In
+	// memory only, if unset.
+	SnapshotDir string
+
+	// Boards, by name, ID, or short link, to snapshot every
+	// SnapshotInterval, keeping the last SnapshotKeep such snapshots, or
+	// all of them if zero; see snapshots.go.
+	SnapshotBoards   []string
+	SnapshotInterval time.Duration
+	SnapshotKeep     int
+
+	// How many cards lists may hold, keyed by '<board>/<list>', or by list
+	// ID; and whether to refuse new cards on lists at their limit, rather
+	// than only reporting lists over it. See wip.go.
+	WIPLimits        map[string]int
+	EnforceWIPLimits bool
+}
+
+// The Trello node tree, independent from whichever protocol is being used to
+// export it. Keeps track of all known nodes, indexed by their inode ID.
+// Removed nodes' inode IDs are reused once the kernel forgets them.
+type Tree struct {
+	// the root listing workspaces, unless a workspace, or several accounts,
+	// are mounted instead.
+	Root *TrelloTreeRoot
+
+	uid uint32
+	gid uint32
+
+	// the tree's lock guards the nodes known, and is only held briefly.
+	// Updating nodes, and changing them, may take a few requests to
+	// Trello, so they are serialized by the updates locks instead, always
+	// taken before the tree's; see refreshNode(). Each board has its own,
+	// so that boards are updated in parallel, taken along with a shared
+	// hold on the tree's; nodes living outside boards, e.g. workspaces,
+	// which boards come and go with, take the tree's alone, excluding all
+	// others. See lockUpdatesFor().
+	lock    sync.Mutex
+	updates sync.RWMutex
+
+	// the context of the operation holding the tree's updates lock, if
+	// any, which requests made on its behalf are bound to; see api().
+	// Guarded by the updates lock.
+	opCtx context.Context
+
+	inodes     map[fuseops.InodeID]FSNode
+	nextInode  fuseops.InodeID
+	freeInodes []fuseops.InodeID
+	byID       map[string]fuseops.InodeID
+
+	// cards by their short links; see resolve.go.
+	byShortLink shortLinkIndex
+
+	// how many times the kernel was handed each inode ID and has yet to
+	// forget it.
+	lookups map[fuseops.InodeID]uint64
+
+	// boards attached to, or detached from, the live mount, by name, ID,
+	// or short link; these override the configured board filters. Guarded
+	// by the tree's updates lock, as they're looked at when updating
+	// workspaces.
+	attached map[string]bool
+	detached map[string]bool
+
+	// structural entries living in the root, regardless of its kind.
+	rootEntries []FSNode
+
+	// directories waiting to be refreshed in the background; see
+	// refresher.go.
+	refreshes chan FSNode
+	queued    map[FSNode]bool
+
+	// cards queued for their contents to be prefetched; see prefetch.go.
+	prefetches map[FSNode]bool
+
+	// operations served, by operation; see status.go.
+	ops map[string]uint64
+
+	// operations last failed for requests to Trello failing; see
+	// lasterrors.go.
+	failures []opFailure
+
+	// each directory's '.refresh' file, once looked up or listed; see
+	// refreshfile.go.
+	refreshFiles map[FSNode]*FSRefreshFile
+
+	// boards whose cards are being walked through; see walk.go.
+	walks boardWalks
+
+	// the user's cards, with their due dates; see agenda.go.
+	agendas agendas
+
+	// board snapshots, when not kept on disk, keyed by '<board id>/<name>'.
+	// Guarded by the tree's updates lock.
+	snapshots map[string]*boardSnapshot
+
+	ctx  *trello.TrelloCtx
+	opts Options
+}
+
+func (tree *Tree) initRoot() FSNode {
+	tree.Root = tree.newTreeRoot("/", tree.ctx, tree.opts.WorkspaceCtx)
+	tree.Root.NodeID = fuseops.RootInodeID
+	tree.Root.TrelloID = "rootID"
+	return tree.Root
+}
+
+// Obtain a directory listing the workspaces seen through 'ctx', or through
+// 'wsCtx' for those listed there.
+func (tree *Tree) newTreeRoot(
+	name string,
+	ctx *trello.TrelloCtx,
+	wsCtx map[string]*trello.TrelloCtx,
+) *TrelloTreeRoot {
+	return &TrelloTreeRoot{
+		BaseFSNode: BaseFSNode{
+			name: name,
+			uid:  tree.uid,
+			gid:  tree.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  tree.uid,
+				Gid:  tree.gid,
+			},
+			isDir: true,
+			Ctx:   ctx,
+		},
+		byID:           make(map[string]*FSWorkspace),
+		byName:         make(map[string]*FSWorkspace),
+		wsCtx:          wsCtx,
+		useDisplayName: tree.opts.UseDisplayName,
+		wsFilter:       tree.opts.WorkspaceFilter,
+	}
+}
+
+func (tree *Tree) initWorkspaceRoot(key string) (FSNode, error) {
+
+	ctx := tree.ctx
+	if wsCtx, exists := tree.opts.WorkspaceCtx[key]; exists {
+		ctx = wsCtx
+	}
+	ws, err := trello.GetWorkspace(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if ws.ID == "" {
+		return nil, fmt.Errorf("workspace %s not found", key)
+	}
+	for _, k := range []string{ws.ID, ws.Name} {
+		if wsCtx, exists := tree.opts.WorkspaceCtx[k]; exists {
+			ctx = wsCtx
+		}
+	}
+
+	return &FSWorkspace{
+		BaseFSNode: BaseFSNode{
+			name:   "/",
+			uid:    tree.uid,
+			gid:    tree.gid,
+			NodeID: fuseops.RootInodeID,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0700 | os.ModeDir,
+				Uid:  tree.uid,
+				Gid:  tree.gid,
+			},
+			isDir:    true,
+			TrelloID: ws.ID,
+			Ctx:      ctx,
+		},
+		ByID:      make(map[string]*FSBoard),
+		ByName:    make(map[string]*FSBoard),
+		Workspace: ws,
+	}, nil
+}
+
+func NewTree(
+	uid uint32,
+	gid uint32,
+	ctx *trello.TrelloCtx,
+	opts Options,
+) (*Tree, error) {
+	tree := &Tree{
+		uid:       uid,
+		gid:       gid,
+		inodes:    make(map[fuseops.InodeID]FSNode),
+		nextInode: fuseops.RootInodeID + 1,
+		byID:      make(map[string]fuseops.InodeID),
+		lookups:   make(map[fuseops.InodeID]uint64),
+		ctx:       ctx,
+
+		attached: make(map[string]bool),
+		detached: make(map[string]bool),
+		opts:     opts,
+
+		refreshes:  make(chan FSNode, refreshQueueSize),
+		queued:     make(map[FSNode]bool),
+		prefetches: make(map[FSNode]bool),
+		snapshots:  make(map[string]*boardSnapshot),
+		walks:      boardWalks{boards: make(map[string]*boardWalk)},
+
+		refreshFiles: make(map[FSNode]*FSRefreshFile),
+		ops:          make(map[string]uint64),
+	}
+	if opts.RootWorkspace != "" {
+		root, err := tree.initWorkspaceRoot(opts.RootWorkspace)
+		if err != nil {
+			return nil, err
+		}
+		tree.inodes[fuseops.RootInodeID] = root
+	} else if len(opts.Accounts) > 0 {
+		tree.inodes[fuseops.RootInodeID] = tree.initAccountsRoot()
+	} else {
+		tree.inodes[fuseops.RootInodeID] = tree.initRoot()
+	}
+	root := tree.inodes[fuseops.RootInodeID]
+	root.setTree(tree, nil)
+	tree.byID[root.GetTrelloID()] = fuseops.RootInodeID
+	if accounts, isAccounts := root.(*FSAccountsRoot); isAccounts {
+		for _, account := range accounts.Accounts {
+			tree.addNode(account)
+		}
+	}
+
+	resolve := newResolveDir(tree, root)
+	tree.addNode(resolve)
+	tree.rootEntries = append(tree.rootEntries, resolve)
+
+	ctl := newCtlDir(tree, root)
+	tree.addNode(ctl)
+	tree.rootEntries = append(tree.rootEntries, ctl)
+
+	me := newMeDir(tree, root)
+	tree.addNode(me)
+	tree.rootEntries = append(tree.rootEntries, me)
+
+	if opts.InboxList != "" {
+		inbox := newInboxDir(tree, root)
+		tree.addNode(inbox)
+		tree.rootEntries = append(tree.rootEntries, inbox)
+	}
+
+	go tree.refresher()
+	if len(opts.SnapshotBoards) > 0 {
+		go tree.snapshotter()
+	}
+	for i := 0; i < refreshWorkers; i++ {
+		go tree.refreshWorker()
+	}
+	return tree, nil
+}
+
+// Assign an inode ID to a new node. Must be called with the tree's lock held.
+// Nodes already known keep theirs: those created by an update may be looked
+// up, and added, before the update returns them.
+func (tree *Tree) addNode(n FSNode) {
+	if id := n.GetNodeID(); id != 0 && tree.inodes[id] == n {
+		return
+	}
+	id := tree.nextInode
+	if numFree := len(tree.freeInodes); numFree > 0 {
+		id = tree.freeInodes[numFree-1]
+		log.Printf(
+			"refresh > reuse id %d for %s (%s)\n",
+			id, n.GetName(), n.GetTrelloID(),
+		)
+		tree.freeInodes = tree.freeInodes[:numFree-1]
+	} else {
+		tree.nextInode++
+	}
+	tree.inodes[id] = n
+	tree.byID[n.GetTrelloID()] = id
+	if card, isCard := n.(*FSCard); isCard {
+		tree.byShortLink.add(card)
+	}
+	n.SetNodeID(id)
+	n.setTree(tree, updatesOf(n))
+	log.Printf(
+		"added new node %s (%s) id %d\n",
+		n.GetName(),
+		n.GetTrelloID(),
+		n.GetNodeID(),
+	)
+}
+
+// Obtain a known node by its Trello ID, or nil if it is not known.
+// Must be called with the tree's lock held.
+func (tree *Tree) nodeByTrelloID(id string) FSNode {
+	if inode, exists := tree.byID[id]; exists {
+		return tree.inodes[inode]
+	}
+	return nil
+}
+
+// Update a node if it's due, returning why it couldn't be, if it couldn't.
+// Must be called with both the node's updates lock and the tree's lock
+// held. The latter is released for as long as the node is being updated, as
+// that may take a few requests to Trello, so that operations not needing any
+// update carry on meanwhile. Nodes' Update() is thus called without the
+// tree's lock held, and must not take it while holding their own lock; they
+// refresh other nodes through refresh(), within the same board unless
+// holding the tree's updates lock.
+func (tree *Tree) refreshNode(node FSNode) error {
+
+	// the node may have been removed since it was last looked up.
+	if tree.inodes[node.GetNodeID()] != node {
+		return nil
+	}
+	if !node.ShouldUpdate() {
+		return nil
+	}
+	log.Printf(
+		"refreshing node id %d, %s (%s)\n",
+		node.GetNodeID(), node.GetName(), node.GetTrelloID(),
+	)
+	tree.lock.Unlock()
+	add, rm, err := node.Update()
+	tree.lock.Lock()
+
+	if err != nil {
+		log.Printf(
+			"error updating node %s (%s) id %d: %s\n",
+			node.GetName(),
+			node.GetTrelloID(),
+			node.GetNodeID(),
+			err,
+		)
+		return err
+	}
+
+	for _, n := range add {
+		tree.addNode(n)
+	}
+
+	for _, n := range rm {
+		tree.removeNode(n)
+	}
+	return nil
+}
+
+// A board's updates lock, serializing updates to, and changes of, the nodes
+// living within it.
+type updateLock struct {
+	lock sync.Mutex
+
+	// the context of the operation holding the lock, if any, which
+	// requests made on its behalf are bound to; see Tree.api(). Guarded by
+	// the lock.
+	ctx context.Context
+}
+
+// The updates lock serializing a node's updates, i.e. that of the board it
+// lives within, or nil for nodes living outside boards, whose updates are
+// serialized by the tree's. Its parents must be known.
+func updatesOf(n FSNode) *updateLock {
+	for ; n != nil; n = n.GetParent() {
+		if board, isBoard := n.(*FSBoard); isBoard {
+			return &board.boardUpdates
+		}
+	}
+	return nil
+}
+
+// Take the updates lock for the given nodes on behalf of an operation, with
+// requests made while it's held bound to the operation's context, so that
+// they're abandoned should the operation be interrupted. Operations on
+// behalf of no one in particular, e.g. refreshing in the background, take
+// the lock with a nil context. Nodes living within the same board take its
+// updates lock, along with a shared hold on the tree's, so that other boards
+// carry on meanwhile; others, e.g. nodes across boards, take the tree's,
+// excluding all others. Returns the lock to be passed to unlockUpdates().
+func (tree *Tree) lockUpdatesFor(
+	ctx context.Context,
+	nodes ...FSNode,
+) *updateLock {
+	var updates *updateLock
+	for i, node := range nodes {
+		nodeUpdates := node.getUpdates()
+		if i > 0 && nodeUpdates != updates {
+			updates = nil
+			break
+		}
+		updates = nodeUpdates
+	}
+	if updates == nil {
+		tree.updates.Lock()
+		tree.opCtx = ctx
+		return nil
+	}
+	tree.updates.RLock()
+	updates.lock.Lock()
+	updates.ctx = ctx
+	return updates
+}
+
+func (tree *Tree) unlockUpdates(updates *updateLock) {
+	if updates == nil {
+		tree.opCtx = nil
+		tree.updates.Unlock()
+		return
+	}
+	updates.ctx = nil
+	updates.lock.Unlock()
+	tree.updates.RUnlock()
+}
+
+// Take the updates lock for a node on behalf of an operation while holding
+// the tree's lock, which is released meanwhile, lest whoever holds the
+// former be waiting for the latter. Nodes may thus have been removed by the
+// time it returns.
+func (tree *Tree) lockUpdates(ctx context.Context, node FSNode) *updateLock {
+	tree.lock.Unlock()
+	updates := tree.lockUpdatesFor(ctx, node)
+	tree.lock.Lock()
+	return updates
+}
+
+// Bind a context to the operation holding the updates lock serializing
+// 'updates', i.e. holding the tree's, if anyone does, or else the board's.
+// Must be called with the updates lock held.
+func (tree *Tree) api(
+	ctx *trello.TrelloCtx,
+	updates *updateLock,
+) *trello.TrelloCtx {
+	opCtx := tree.opCtx
+	if opCtx == nil && updates != nil {
+		opCtx = updates.ctx
+	}
+	if opCtx == nil {
+		return ctx
+	}
+	return ctx.WithContext(opCtx)
+}
+
+// Refresh a node from within another's Update(), i.e. with the node's
+// updates lock held, but not the tree's.
+func (tree *Tree) refresh(node FSNode) error {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	return tree.refreshNode(node)
+}
+
+// Remove a node from the tree, along with any nodes canonically living below
+// it, freeing their inodes, or leaving them to be freed once forgotten by the
+// kernel. Must be called with the tree's lock held.
+func (tree *Tree) removeNode(n FSNode) {
+	id := n.GetNodeID()
+	if id == 0 || tree.inodes[id] != n {
+		return
+	}
+	for _, child := range n.GetEntries() {
+		if child.GetParent() == n {
+			tree.removeNode(child)
+		}
+	}
+	if file, exists := tree.refreshFiles[n]; exists {
+		delete(tree.refreshFiles, n)
+		tree.removeNode(file)
+	}
+	delete(tree.inodes, id)
+	if tree.byID[n.GetTrelloID()] == id {
+		delete(tree.byID, n.GetTrelloID())
+	}
+	if card, isCard := n.(*FSCard); isCard {
+		tree.byShortLink.remove(card)
+	}
+	if tree.lookups[id] == 0 {
+		tree.freeInodes = append(tree.freeInodes, id)
+	}
+	log.Printf(
+		"removed node %s (%s) id %d\n",
+		n.GetName(), n.GetTrelloID(), id,
+	)
+}
+
+// Account for the kernel having been handed a node, e.g. as looked up, until
+// it forgets it. Returns the node's inode ID, or 0 if it has been removed in
+// the meantime.
+func (tree *Tree) Remember(n FSNode) fuseops.InodeID {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	id := n.GetNodeID()
+	if id == 0 || tree.inodes[id] != n {
+		return 0
+	}
+	tree.lookups[id]++
+	return id
+}
+
+// Account for the kernel forgetting a node as many times as it was handed
+// it. Removed nodes' inode IDs may then be reused.
+func (tree *Tree) Forget(id fuseops.InodeID, count uint64) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	tree.ops["forget"]++
+
+	lookups, exists := tree.lookups[id]
+	if !exists {
+		return
+	} else if count < lookups {
+		tree.lookups[id] = lookups - count
+		return
+	}
+	delete(tree.lookups, id)
+	if _, exists := tree.inodes[id]; !exists {
+		tree.freeInodes = append(tree.freeInodes, id)
+	}
+}
+
+// Obtain how many nodes are known, and the size of the files amongst them.
+func (tree *Tree) Usage() (uint64, uint64) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	var nodes uint64
+	var size uint64
+	for _, node := range tree.inodes {
+		if node == nil {
+			continue
+		}
+		nodes++
+		if attrs := node.GetNodeAttrs(); !attrs.Mode.IsDir() {
+			size += attrs.Size
+		}
+	}
+	return nodes, size
+}
+
+// Obtain the node for a given inode ID, or nil if it does not exist.
+func (tree *Tree) GetNode(id fuseops.InodeID) FSNode {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	return tree.inodes[id]
+}
+
+// Look up a child by name, refreshing its parent first if it has yet to be
+// loaded, or in the background if it's merely out of date. Only the former
+// waits for other nodes being updated.
+func (tree *Tree) Lookup(
+	ctx context.Context,
+	parentID fuseops.InodeID,
+	name string,
+) (FSNode, error) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	tree.ops["lookup"]++
+
+	if tree.inodes[parentID] == nil {
+		log.Printf(
+			"lookup %s, parent id %d not found\n", name, parentID,
+		)
+		return nil, fuse.ENOENT
+	}
+	if parentID == fuseops.RootInodeID {
+		for _, entry := range tree.rootEntries {
+			if entry.GetName() == name {
+				return entry, nil
+			}
+		}
+	}
+	parent := tree.inodes[parentID]
+	if name == refreshFileName && parent.GetNodeAttrs().Mode.IsDir() {
+		return tree.refreshFile(parent), nil
+	}
+	parent.MarkAccessed()
+	loaded := parent.isLoaded()
+	if !loaded {
+		defer tree.unlockUpdates(tree.lockUpdates(ctx, parent))
+		if tree.inodes[parentID] != parent {
+			return nil, fuse.ENOENT
+		}
+	}
+	if err := tree.refreshDir(parent, loaded); err != nil {
+		return nil, apiErrno(err)
+	}
+
+	child, err := parent.LookupChild(name)
+	if err != nil {
+		return nil, fuse.ENOENT
+	}
+	// nodes may be created on lookup, in which case they still need an ID.
+	if child.GetNodeID() == 0 {
+		tree.addNode(child)
+	}
+	return child, nil
+}
+
+// Obtain a directory's entries, refreshing it first if it has yet to be
+// loaded, or in the background if it's merely out of date. As with lookups,
+// only the former waits for other nodes being updated; listing cards has
+// their siblings prefetched in the background too.
+func (tree *Tree) Entries(
+	ctx context.Context,
+	id fuseops.InodeID,
+) ([]FSNode, error) {
+	tree.lock.Lock()
+	tree.ops["readdir"]++
+
+	if tree.inodes[id] == nil {
+		tree.lock.Unlock()
+		log.Printf("entries > failed to find inode %d\n", id)
+		return nil, fuse.ENOENT
+	}
+	node := tree.inodes[id]
+	tree.lock.Unlock()
+	log.Printf(
+		"entries > id %d, %s (%s)\n",
+		node.GetNodeID(), node.GetName(), node.GetTrelloID(),
+	)
+
+	node.MarkAccessed()
+	card, isCard := node.(*FSCard)
+	if isCard {
+		tree.noteCardListed(ctx, card)
+	}
+	loaded := node.isLoaded()
+	if !loaded {
+		defer tree.unlockUpdates(tree.lockUpdatesFor(ctx, node))
+	}
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	if tree.inodes[id] != node {
+		return nil, fuse.ENOENT
+	}
+	if err := tree.refreshDir(node, loaded); err != nil {
+		return nil, apiErrno(err)
+	}
+	entries := node.GetEntries()
+	for _, entry := range entries {
+		if entry.GetNodeID() == 0 {
+			tree.addNode(entry)
+		}
+	}
+	if isCard {
+		tree.prefetchSiblings(card)
+	}
+	if id == fuseops.RootInodeID {
+		entries = append(entries, tree.rootEntries...)
+	}
+	entries = append(entries, tree.refreshFile(node))
+	return entries, nil
+}
+
+// Read a file's contents at a given offset, refreshing it first if needed,
+// in which case it waits for other nodes being updated. The contents are
+// read holding neither the tree's lock nor the updates lock, as reading an
+// attachment downloads it, which shouldn't hold back other operations.
+func (tree *Tree) ReadAt(
+	ctx context.Context,
+	id fuseops.InodeID,
+	dst []byte,
+	offset int64,
+) (int, error) {
+	tree.lock.Lock()
+	tree.ops["read"]++
+
+	node := tree.inodes[id]
+	if node == nil {
+		tree.lock.Unlock()
+		return 0, fuse.ENOENT
+	}
+	node.MarkAccessed()
+	if node.ShouldUpdate() {
+		updates := tree.lockUpdates(ctx, node)
+		if tree.inodes[id] != node {
+			tree.lock.Unlock()
+			tree.unlockUpdates(updates)
+			return 0, fuse.ENOENT
+		}
+		tree.refreshNode(node)
+		tree.lock.Unlock()
+		tree.unlockUpdates(updates)
+	} else {
+		tree.lock.Unlock()
+	}
+
+	var bytes int
+	var err error
+	if attachment, isAttachment := node.(*FSAttachment); isAttachment {
+		// downloaded as read, so the read may be interrupted.
+		bytes, err = attachment.download(ctx, dst, offset)
+	} else {
+		bytes, err = node.ReadAt(dst, offset)
+	}
+
+	log.Printf(
+		"read > read %s (%s) id %d, bytes: %d\n",
+		node.GetName(), node.GetTrelloID(), node.GetNodeID(), bytes,
+	)
+	return bytes, err
+}
+
+// Obtain a node about to be changed, counting the operation, or nil if it
+// does not exist. Changes are made without the tree's lock held, and with
+// the updates lock held only by those that may make requests to Trello, so
+// that these don't hold back operations served from what's already known.
+func (tree *Tree) changing(id fuseops.InodeID, op string) FSNode {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	tree.ops[op]++
+
+	node := tree.inodes[id]
+	if node != nil {
+		node.MarkAccessed()
+	}
+	return node
+}
+
+// Obtain a node about to be changed, as changing() does, with its updates
+// lock held on behalf of an operation, to be released with unlockUpdates();
+// nil if it does not exist, or it was removed while waiting for the lock, in
+// which case the lock isn't held.
+func (tree *Tree) lockChanging(
+	ctx context.Context,
+	id fuseops.InodeID,
+	op string,
+) (FSNode, *updateLock) {
+	node := tree.changing(id, op)
+	if node == nil {
+		return nil, nil
+	}
+	updates := tree.lockUpdatesFor(ctx, node)
+	if tree.GetNode(id) != node {
+		tree.unlockUpdates(updates)
+		return nil, nil
+	}
+	return node, updates
+}
+
+// Write to a file at a given offset. Writes are buffered, under the node's
+// own lock, until flushed, so they needn't wait for nodes being updated;
+// but for writes to '.refresh' files, which refresh as they come.
+func (tree *Tree) WriteAt(
+	ctx context.Context,
+	id fuseops.InodeID,
+	data []byte,
+	offset int64,
+) (int, error) {
+	node := tree.changing(id, "write")
+	if node == nil {
+		return 0, fuse.ENOENT
+	}
+	if _, isRefresh := node.(*FSRefreshFile); isRefresh {
+		defer tree.unlockUpdates(tree.lockUpdatesFor(ctx, node))
+	}
+	return node.WriteAt(data, offset)
+}
+
+// Truncate a file. As with writes, only the file's buffer is truncated, to
+// be committed when flushed.
+func (tree *Tree) Truncate(
+	ctx context.Context,
+	id fuseops.InodeID,
+	size uint64,
+) error {
+	node := tree.changing(id, "truncate")
+	if node == nil {
+		return fuse.ENOENT
+	}
+	return node.Truncate(size)
+}
+
+// Change a node's permissions.
+func (tree *Tree) Chmod(
+	ctx context.Context,
+	id fuseops.InodeID,
+	mode os.FileMode,
+) error {
+	node, updates := tree.lockChanging(ctx, id, "chmod")
+	if node == nil {
+		return fuse.ENOENT
+	}
+	defer tree.unlockUpdates(updates)
+	return node.Chmod(mode)
+}
+
+// Change a node's modification time.
+func (tree *Tree) SetMtime(
+	ctx context.Context,
+	id fuseops.InodeID,
+	mtime time.Time,
+) error {
+	node, updates := tree.lockChanging(ctx, id, "setmtime")
+	if node == nil {
+		return fuse.ENOENT
+	}
+	defer tree.unlockUpdates(updates)
+	return node.SetMtime(mtime)
+}
+
+// Commit whatever has been written to a file.
+func (tree *Tree) Flush(ctx context.Context, id fuseops.InodeID) error {
+	node, updates := tree.lockChanging(ctx, id, "flush")
+	if node == nil {
+		return fuse.ENOENT
+	}
+	defer tree.unlockUpdates(updates)
+	return node.Flush()
+}
+
+// Let a file know it's no longer open at all, for files acting once they're
+// written and closed for good, rather than whenever flushed.
+func (tree *Tree) Release(ctx context.Context, id fuseops.InodeID) error {
+	node, updates := tree.lockChanging(ctx, id, "release")
+	if node == nil {
+		return fuse.ENOENT
+	}
+	defer tree.unlockUpdates(updates)
+	return node.Release()
+}
+
+// Remove a child by name from a directory.
+func (tree *Tree) Unlink(
+	ctx context.Context,
+	parentID fuseops.InodeID,
+	name string,
+) error {
+	parent, updates := tree.lockChanging(ctx, parentID, "unlink")
+	if parent == nil {
+		return fuse.ENOENT
+	}
+	defer tree.unlockUpdates(updates)
+	_, isMembers := parent.(*FSBoardMembersDir)
+	if isMembers && !tree.opts.AllowMemberRemoval {
+		log.Printf(
+			"unlink > refusing to remove member %s, not allowed by config\n",
+			name,
+		)
+		return syscall.EPERM
+	}
+	return parent.Unlink(name)
+}
+
+func (tree *Tree) ListXattrs(id fuseops.InodeID) ([]string, error) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	tree.ops["listxattr"]++
+
+	if tree.inodes[id] == nil {
+		return nil, fuse.ENOENT
+	}
+	return tree.inodes[id].ListXattrs(), nil
+}
+
+func (tree *Tree) GetXattr(id fuseops.InodeID, name string) ([]byte, error) {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	tree.ops["getxattr"]++
+
+	if tree.inodes[id] == nil {
+		return nil, fuse.ENOENT
+	}
+	return tree.inodes[id].GetXattr(name)
+}
+
+// Create a new file in a directory.
+func (tree *Tree) Create(
+	ctx context.Context,
+	parentID fuseops.InodeID,
+	name string,
+) (FSNode, error) {
+	parent, updates := tree.lockChanging(ctx, parentID, "create")
+	if parent == nil {
+		return nil, fuse.ENOENT
+	}
+	defer tree.unlockUpdates(updates)
+	child, err := parent.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	tree.addNode(child)
+	return child, nil
+}
+
+// Create a new directory in a directory.
+func (tree *Tree) MkDir(
+	ctx context.Context,
+	parentID fuseops.InodeID,
+	name string,
+) (FSNode, error) {
+	parent, updates := tree.lockChanging(ctx, parentID, "mkdir")
+	if parent == nil {
+		return nil, fuse.ENOENT
+	}
+	defer tree.unlockUpdates(updates)
+	tree.refresh(parent)
+	child, err := parent.MkDir(name)
+	if err != nil {
+		return nil, err
+	}
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	tree.addNode(child)
+	return child, nil
+}
+
+// Remove a directory from a directory, along with whatever lives below it.
+func (tree *Tree) RmDir(
+	ctx context.Context,
+	parentID fuseops.InodeID,
+	name string,
+) error {
+	parent, updates := tree.lockChanging(ctx, parentID, "rmdir")
+	if parent == nil {
+		return fuse.ENOENT
+	}
+	defer tree.unlockUpdates(updates)
+	child, err := parent.LookupChild(name)
+	if err != nil {
+		return err
+	}
+	if err := parent.RmDir(name); err != nil {
+		return err
+	}
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	tree.removeNode(child)
+	return nil
+}
+
+// Move an entry from one directory to another, keeping its inode.
+func (tree *Tree) Rename(
+	ctx context.Context,
+	oldParentID fuseops.InodeID,
+	oldName string,
+	newParentID fuseops.InodeID,
+	newName string,
+) error {
+	oldParent := tree.changing(oldParentID, "rename")
+	newParent := tree.GetNode(newParentID)
+	if oldParent == nil || newParent == nil {
+		return fuse.ENOENT
+	}
+	// moving across boards takes the tree's updates lock.
+	defer tree.unlockUpdates(tree.lockUpdatesFor(ctx, oldParent, newParent))
+	if tree.GetNode(oldParentID) != oldParent ||
+		tree.GetNode(newParentID) != newParent {
+		return fuse.ENOENT
+	}
+	newParent.MarkAccessed()
+	return oldParent.Rename(oldName, newParent, newName)
+}
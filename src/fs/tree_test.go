@@ -0,0 +1,300 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// List a directory in the background, returning how that went once done.
+func listing(tree *Tree, dir FSNode) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := tree.Entries(context.Background(), dir.GetNodeID())
+		done <- err
+	}()
+	return done
+}
+
+// Boards are updated in parallel, one being updated not holding back
+// another's, while nodes living outside boards wait for all of them.
+func TestUpdatesPerBoard(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/boards/b1/cards": `[{"id": "c1", "name": "One"}]`,
+		"/boards/b2/cards": `[{"id": "c2", "name": "Two"}]`,
+	})
+	tree := newTestTree(t, Options{})
+	b1 := newTestBoard(tree, &trello.Board{ID: "b1", Name: "One"})
+	b2 := newTestBoard(tree, &trello.Board{ID: "b2", Name: "Two"})
+
+	updates := tree.lockUpdatesFor(nil, b1)
+	if updates == nil {
+		t.Fatal("board updates serialized by the tree's lock")
+	}
+	select {
+	case err := <-listing(tree, b2.MetaCardsDir):
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("board held back by another being updated")
+	}
+	held := listing(tree, b1.MetaCardsDir)
+	select {
+	case <-held:
+		t.Fatal("board updated while already being updated")
+	case <-time.After(50 * time.Millisecond):
+	}
+	tree.unlockUpdates(updates)
+	if err := <-held; err != nil {
+		t.Fatal(err)
+	}
+	if b1.ByCardID["c1"] == nil || b2.ByCardID["c2"] == nil {
+		t.Fatal("boards' cards not loaded")
+	}
+
+	root := tree.GetNode(fuseops.RootInodeID)
+	updates = tree.lockUpdatesFor(nil, root)
+	if updates != nil {
+		t.Fatal("root's updates serialized by a board's lock")
+	}
+	tree.lock.Lock()
+	b2.MetaCardsDir.markStale()
+	tree.lock.Unlock()
+	held = listing(tree, b2.MetaCardsDir)
+	select {
+	case <-held:
+		t.Fatal("board updated while the tree's updates lock was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	tree.unlockUpdates(updates)
+	if err := <-held; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Files already loaded are read, and written to their buffers, while their
+// board is being updated, though only committed once it's done.
+func TestIOWhileUpdating(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/cards/c1/actions": `[
+			{"date": "2022-03-01", "memberCreator": {"username": "alice"},
+				"data": {"text": "On it"}}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	card := newTestCard(board, &trello.Card{ID: "c1", Name: "Fix"})
+	comments := newCommentsFile(card)
+	addNodes(tree, comments)
+	ctx := context.Background()
+	before := readFile(t, tree, comments)
+
+	updates := tree.lockUpdatesFor(nil, board)
+	done := make(chan error, 1)
+	go func() {
+		id := comments.GetNodeID()
+		buf := make([]byte, len(before))
+		if _, err := tree.ReadAt(ctx, id, buf, 0); err != nil {
+			done <- err
+			return
+		}
+		_, err := tree.WriteAt(ctx, id, []byte("Thanks\n"), int64(len(buf)))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("file read and written only once its board was updated")
+	}
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- tree.Flush(ctx, comments.GetNodeID()) }()
+	select {
+	case <-flushed:
+		t.Fatal("file committed while its board was being updated")
+	case <-time.After(50 * time.Millisecond):
+	}
+	tree.unlockUpdates(updates)
+	if err := <-flushed; err != nil {
+		t.Fatal(err)
+	}
+	requests := []string{"POST /cards/c1/actions/comments?text=Thanks"}
+	if got := requestsMade(t, dir); !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+}
+
+// Cards renamed while their boards are refreshed in parallel may be listed,
+// and their names and attributes served, meanwhile. Meant to be run with
+// '-race'.
+func TestUpdatesConcurrent(t *testing.T) {
+	dir := withFixtures(t, nil)
+	setCards := func(board string, round int) {
+		name := filepath.Join(dir, "boards-"+board+"-cards.json")
+		err := ioutil.WriteFile(name+".tmp", []byte(fmt.Sprintf(
+			`[{"id": "%s-c1", "name": "Card %d", "due": "2022-%02d-01"}]`,
+			board, round, round%12+1,
+		)), 0600)
+		if err == nil {
+			err = os.Rename(name+".tmp", name)
+		}
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	tree := newTestTree(t, Options{})
+	boards := []*FSBoard{
+		newTestBoard(tree, &trello.Board{ID: "b1", Name: "One"}),
+		newTestBoard(tree, &trello.Board{ID: "b2", Name: "Two"}),
+	}
+
+	const rounds = 20
+	var wg sync.WaitGroup
+	for _, board := range boards {
+		board := board
+		setCards(board.GetTrelloID(), 0)
+		if err := <-listing(tree, board.MetaCardsDir); err != nil {
+			t.Fatal(err)
+		}
+		tree.lock.Lock()
+		refresh := tree.refreshFile(board.MetaCardsDir)
+		tree.lock.Unlock()
+
+		card := board.ByCardID[board.GetTrelloID()+"-c1"]
+		done := make(chan bool)
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			defer close(done)
+			for round := 1; round <= rounds; round++ {
+				setCards(board.GetTrelloID(), round)
+				_, err := tree.WriteAt(
+					context.Background(), refresh.GetNodeID(), []byte("1"), 0,
+				)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		// as when serving the card's attributes, or a listing's names.
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				card.GetName()
+				card.GetNodeAttrs()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, board := range boards {
+		card := board.ByCardID[board.GetTrelloID()+"-c1"]
+		if card == nil {
+			t.Fatalf("board %s lost its card", board.GetName())
+		}
+		want := fmt.Sprintf("Card %d", rounds)
+		if name := card.GetName(); name != want {
+			t.Errorf("card named %q, want %q", name, want)
+		}
+		if board.ByCardName[card.GetName()] != card {
+			t.Errorf("card not found by its new name")
+		}
+	}
+}
+
+// Cards gone from Trello leave the tree, their inodes freed once the kernel
+// has forgotten them.
+func TestRemoveGoneNodes(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "One"},
+			{"id": "c2", "name": "Two"},
+			{"id": "c3", "name": "Three"}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	cards := board.MetaCardsDir
+	if err := <-listing(tree, cards); err != nil {
+		t.Fatal(err)
+	}
+	one, two := board.ByCardID["c1"], board.ByCardID["c2"]
+	// as handed to the kernel on lookup.
+	if tree.Remember(one) == 0 {
+		t.Fatal("card not known to the tree")
+	}
+	oneID, twoID := one.GetNodeID(), two.GetNodeID()
+
+	err := ioutil.WriteFile(
+		filepath.Join(dir, "boards-b1-cards.json"),
+		[]byte(`[{"id": "c3", "name": "Three"}]`), 0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.lock.Lock()
+	cards.markStale()
+	tree.lock.Unlock()
+	if err := <-listing(tree, cards); err != nil {
+		t.Fatal(err)
+	}
+
+	if board.ByCardID["c1"] != nil || board.ByCardName["Two"] != nil {
+		t.Errorf("cards gone from Trello left on their board")
+	}
+	for _, id := range []fuseops.InodeID{oneID, twoID} {
+		if tree.GetNode(id) != nil {
+			t.Errorf("card gone from Trello still known as %d", id)
+		}
+	}
+	freed := func(id fuseops.InodeID) bool {
+		tree.lock.Lock()
+		defer tree.lock.Unlock()
+		for _, free := range tree.freeInodes {
+			if free == id {
+				return true
+			}
+		}
+		return false
+	}
+	if !freed(twoID) {
+		t.Errorf("inode of a card never looked up not freed")
+	}
+	if freed(oneID) {
+		t.Errorf("inode freed while the kernel still knows of it")
+	}
+	tree.Forget(oneID, 1)
+	if !freed(oneID) {
+		t.Errorf("inode not freed once forgotten")
+	}
+}
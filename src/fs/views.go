@@ -0,0 +1,332 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// A board's 'views' directory, holding a directory per view configured in
+// Options.Views, and the 'due', 'labels', 'colors', and 'members' views.
+type FSBoardViewsDir struct {
+	BaseFSNode
+
+	BoardNode *FSBoard
+
+	Views   []*FSBoardView
+	Due     *FSBoardDueViews
+	Labels  *FSBoardGroupViews
+	Colors  *FSBoardGroupViews
+	Members *FSBoardGroupViews
+}
+
+// Tells whether a card belongs in a view.
+type viewFilter func(card *queryCard, now time.Time) bool
+
+// Symlinks to a board's cards passing a filter, e.g. matching a query.
+type FSBoardView struct {
+	BaseFSNode
+
+	BoardNode *FSBoard
+	filter    viewFilter
+
+	// whether the view is on cards' due dates, which are kept fresh
+	// through the user's agenda; see agenda.go.
+	agenda bool
+
+	Links  []*FSSymlink
+	byCard map[string]*FSSymlink
+}
+
+func newBoardView(
+	parent FSNode,
+	board *FSBoard,
+	name string,
+	filter viewFilter,
+) *FSBoardView {
+	return &FSBoardView{
+		BaseFSNode: BaseFSNode{
+			name:      escapeName(name),
+			uid:       board.uid,
+			gid:       board.gid,
+			NodeAttrs: parent.GetNodeAttrs(),
+			isDir:     true,
+			TrelloID:  fmt.Sprintf("%s/%s", parent.GetTrelloID(), name),
+			Ctx:       board.Ctx,
+			parent:    parent,
+		},
+		BoardNode: board,
+		filter:    filter,
+		byCard:    make(map[string]*FSSymlink),
+	}
+}
+
+func newBoardViewsDir(board *FSBoard) *FSBoardViewsDir {
+	return &FSBoardViewsDir{
+		BaseFSNode: BaseFSNode{
+			name: "views",
+			uid:  board.uid,
+			gid:  board.gid,
+			NodeAttrs: fuseops.InodeAttributes{
+				Mode: 0500 | os.ModeDir,
+				Uid:  board.uid,
+				Gid:  board.gid,
+			},
+			isDir:    true,
+			TrelloID: fmt.Sprintf("%s/views", board.GetTrelloID()),
+			Ctx:      board.Ctx,
+			parent:   board,
+		},
+		BoardNode: board,
+	}
+}
+
+// Views are only ever set up once.
+func (node *FSBoardViewsDir) ShouldUpdate() bool {
+	node.Lock()
+	defer node.Unlock()
+	return node.getLastUpdated().IsZero()
+}
+
+func (node *FSBoardViewsDir) Update() ([]FSNode, []FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	var names []string
+	for name := range node.tree.opts.Views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// being refreshed as a whole, e.g. through the board's '.refresh', has
+	// the views updated again, though they're already set up.
+	var newNodes []FSNode = make([]FSNode, 0)
+	if node.Views == nil {
+		for _, name := range names {
+			view := newBoardView(
+				node, node.BoardNode, name,
+				node.tree.opts.Views[name].matches,
+			)
+			node.Views = append(node.Views, view)
+			newNodes = append(newNodes, view)
+		}
+	}
+	// views configured as 'due', 'labels', 'colors', or 'members' take
+	// their place, and they're only there from the v2 layout on.
+	builtin := node.tree.opts.layoutSince("v2")
+	_, exists := node.tree.opts.Views["due"]
+	if builtin && !exists && node.Due == nil {
+		node.Due = newBoardDueViews(node)
+		newNodes = append(newNodes, node.Due)
+	}
+	_, exists = node.tree.opts.Views["labels"]
+	if builtin && !exists && node.Labels == nil {
+		node.Labels = newBoardLabelViews(node)
+		newNodes = append(newNodes, node.Labels)
+	}
+	_, exists = node.tree.opts.Views["colors"]
+	if builtin && !exists && node.Colors == nil {
+		node.Colors = newBoardColorViews(node)
+		newNodes = append(newNodes, node.Colors)
+	}
+	_, exists = node.tree.opts.Views["members"]
+	if builtin && !exists && node.Members == nil {
+		node.Members = newBoardMemberViews(node)
+		newNodes = append(newNodes, node.Members)
+	}
+	node.markUpdated()
+	return newNodes, nil, nil
+}
+
+func (node *FSBoardViewsDir) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, view := range node.Views {
+		if view.GetName() == name {
+			return view, nil
+		}
+	}
+	if node.Due != nil && node.Due.GetName() == name {
+		return node.Due, nil
+	}
+	for _, group := range []*FSBoardGroupViews{
+		node.Labels, node.Colors, node.Members,
+	} {
+		if group != nil && group.GetName() == name {
+			return group, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSBoardViewsDir) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, 0, len(node.Views)+4)
+	for _, view := range node.Views {
+		entries = append(entries, view)
+	}
+	if node.Due != nil {
+		entries = append(entries, node.Due)
+	}
+	for _, group := range []*FSBoardGroupViews{
+		node.Labels, node.Colors, node.Members,
+	} {
+		if group != nil {
+			entries = append(entries, group)
+		}
+	}
+	return entries
+}
+
+// Obtain what queries are evaluated against for the board's known cards,
+// keyed by card node.
+func (node *FSBoard) queryCards() map[*FSCard]*queryCard {
+
+	node.Lock()
+	cards := append([]*FSCard{}, node.Cards...)
+	lists := make(map[string]string)
+	for id, list := range node.ByListID {
+		lists[id] = list.List.Name
+	}
+	membersDir := node.MetaMembers
+	node.Unlock()
+
+	members := membersDir.usernames()
+
+	result := make(map[*FSCard]*queryCard)
+	for _, cardNode := range cards {
+		idle := cardNode.daysIdle()
+		cardNode.Lock()
+		card := cardNode.Card
+		entry := &queryCard{
+			name:        card.Name,
+			desc:        card.Desc,
+			list:        lists[card.ListID],
+			shortLink:   card.ShortLink,
+			dueComplete: card.DueComplete,
+			idle:        idle,
+		}
+		for _, label := range card.Labels {
+			entry.labels = append(entry.labels, label.Name)
+			if label.Name == "" {
+				entry.labels[len(entry.labels)-1] = label.Color
+			}
+			entry.colors = append(entry.colors, labelColor(label.Color))
+		}
+		for _, id := range card.MemberIDs {
+			if username, exists := members[id]; exists {
+				entry.members = append(entry.members, username)
+			} else {
+				entry.members = append(entry.members, id)
+			}
+		}
+		if due, err := time.Parse(time.RFC3339, card.Due); err == nil {
+			entry.due = due
+		}
+		cardNode.Unlock()
+		result[cardNode] = entry
+	}
+	return result
+}
+
+func (node *FSBoardView) ShouldUpdate() bool {
+	return node.shouldUpdate(60.0)
+}
+
+// Views are evaluated against the cards, lists, and members already known,
+// which are refreshed first if due. Views on due dates also have the user's
+// own cards take on their due dates from the agenda, which is obtained more
+// often than the board's cards.
+func (node *FSBoardView) Update() ([]FSNode, []FSNode, error) {
+	boardNode := node.BoardNode
+	node.tree.refresh(boardNode.MetaCardsDir)
+	if node.agenda {
+		if _, err := node.tree.agendaCards(node.api()); err != nil {
+			log.Printf(
+				"error obtaining agenda for view %s: %s\n",
+				node.GetTrelloID(), err,
+			)
+		}
+	}
+	node.tree.refresh(boardNode.MetaListsDir)
+	node.tree.refresh(boardNode.MetaMembers)
+
+	node.Lock()
+	defer node.Unlock()
+
+	var newNodes []FSNode = make([]FSNode, 0)
+	var rmNodes []FSNode = make([]FSNode, 0)
+	var links []*FSSymlink
+	byCard := make(map[string]*FSSymlink)
+	now := time.Now()
+	for card, entry := range boardNode.queryCards() {
+		if !node.filter(entry, now) {
+			continue
+		}
+		link, exists := node.byCard[card.GetTrelloID()]
+		if !exists {
+			link = newSymlink(
+				node, card.GetName(), relativePath(node, card),
+			)
+			link.TrelloID = fmt.Sprintf(
+				"%s/%s", node.GetTrelloID(), card.GetTrelloID(),
+			)
+			newNodes = append(newNodes, link)
+		}
+		byCard[card.GetTrelloID()] = link
+		links = append(links, link)
+	}
+	for id, link := range node.byCard {
+		if _, exists := byCard[id]; !exists {
+			rmNodes = append(rmNodes, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].GetName() < links[j].GetName()
+	})
+	node.byCard = byCard
+	node.Links = links
+
+	node.markUpdated()
+	return newNodes, rmNodes, nil
+}
+
+func (node *FSBoardView) LookupChild(name string) (FSNode, error) {
+	node.Lock()
+	defer node.Unlock()
+
+	for _, link := range node.Links {
+		if link.GetName() == name {
+			return link, nil
+		}
+	}
+	return nil, fuse.ENOENT
+}
+
+func (node *FSBoardView) GetEntries() []FSNode {
+	node.Lock()
+	defer node.Unlock()
+
+	entries := make([]FSNode, len(node.Links))
+	for i, link := range node.Links {
+		entries[i] = link
+	}
+	return entries
+}
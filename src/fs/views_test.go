@@ -0,0 +1,228 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Views configured link to the board's cards matching their queries, by
+// the labels, due dates, and members' usernames the cards are known with.
+func TestBoardViews(t *testing.T) {
+	in := func(days int) string {
+		return time.Now().AddDate(0, 0, days).Format(time.RFC3339)
+	}
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": fmt.Sprintf(`[
+			{"id": "c1", "name": "Crash", "due": %q, "idMembers": ["m1"],
+				"labels": [{"id": "lb1", "name": "bug", "color": "red"}]},
+			{"id": "c2", "name": "Typo", "due": %q,
+				"labels": [{"id": "lb1", "name": "bug", "color": "red"}]},
+			{"id": "c3", "name": "Export", "due": %q, "idMembers": ["m1"]}
+		]`, in(2), in(30), in(1)),
+		"/boards/b1/lists":   `[]`,
+		"/boards/b1/members": `[{"id": "m1", "username": "alice"}]`,
+	})
+	views := make(map[string]*Query)
+	for name, expr := range map[string]string{
+		"urgent": `label == "bug" AND due < 7d`,
+		"mine":   `member == "alice"`,
+	} {
+		query, err := ParseQuery(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		views[name] = query
+	}
+	tree := newTestTree(t, Options{Views: views})
+
+	for name, want := range map[string][]string{
+		"urgent": {"Crash"},
+		"mine":   {"Crash", "Export"},
+	} {
+		view := lookupPath(t, tree, "eng/Roadmap/views/"+name)
+		entries, err := tree.Entries(context.Background(), view.GetNodeID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, entry := range entries {
+			if entry.GetName() == ".refresh" {
+				continue
+			}
+			got = append(got, entry.GetName())
+			target, _ := entry.ReadLink()
+			card := "../../../../eng/Roadmap/cards/" + entry.GetName()
+			if target != card {
+				t.Errorf("%s linked to %q, want %q", name, target, card)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("view %s holds %q, want %q", name, got, want)
+		}
+	}
+	if _, err := ParseQuery(`due < someday`); err == nil {
+		t.Errorf("query on an invalid date parsed")
+	}
+}
+
+// From the v2 layout on, boards' 'views/due' bucket their cards by when
+// they're due, leaving out those past due but complete.
+func TestDueViews(t *testing.T) {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	at := func(date time.Time) string { return date.Format(time.RFC3339) }
+	withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": fmt.Sprintf(`[
+			{"id": "c1", "name": "Late", "due": %q},
+			{"id": "c2", "name": "Done", "due": %q, "dueComplete": true},
+			{"id": "c3", "name": "Now", "due": %q},
+			{"id": "c4", "name": "Soon", "due": %q},
+			{"id": "c5", "name": "Someday", "due": %q},
+			{"id": "c6", "name": "Whenever"}
+		]`,
+			at(now.AddDate(0, 0, -2)), at(now.AddDate(0, 0, -2)),
+			at(today.Add(23*time.Hour+59*time.Minute)),
+			at(today.AddDate(0, 0, 1).Add(12*time.Hour)),
+			at(now.AddDate(0, 0, 30)),
+		),
+		"/boards/b1/lists":   `[]`,
+		"/boards/b1/members": `[]`,
+	})
+	tree := newTestTree(t, Options{Layout: "v2"})
+
+	// there's no more of the week left on Sundays.
+	soon := "this-week"
+	if today.Weekday() == time.Sunday {
+		soon = "later"
+	}
+	want := map[string][]string{
+		"overdue": {"Late"}, "today": {"Now"}, "none": {"Whenever"},
+		"this-week": nil, "later": {"Someday"},
+	}
+	want[soon] = append([]string{"Soon"}, want[soon]...)
+	for bucket, cards := range want {
+		view := lookupPath(t, tree, "eng/Roadmap/views/due/"+bucket)
+		entries, err := tree.Entries(context.Background(), view.GetNodeID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, entry := range entries {
+			if entry.GetName() == ".refresh" {
+				continue
+			}
+			got = append(got, entry.GetName())
+			target, _ := entry.ReadLink()
+			card := "../../../../../eng/Roadmap/cards/" + entry.GetName()
+			if target != card {
+				t.Errorf("%s linked to %q, want %q", bucket, target, card)
+			}
+		}
+		if !reflect.DeepEqual(got, cards) {
+			t.Errorf("%s holds %q, want %q", bucket, got, cards)
+		}
+	}
+}
+
+// From the v2 layout on, boards' 'views/labels' and 'views/members' hold a
+// view per label and member found on their cards, following the cards as
+// they're refreshed.
+func TestGroupViews(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/members/me/organizations": `[{"id": "w1", "name": "eng"}]`,
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "idOrganization": "w1"}
+		]`,
+		"/boards/b1/cards": `[
+			{"id": "c1", "name": "Crash", "idMembers": ["m1"],
+				"labels": [{"id": "lb1", "name": "bug", "color": "red"}]},
+			{"id": "c2", "name": "Typo", "idMembers": ["m1", "m2"],
+				"labels": [{"id": "lb1", "name": "bug", "color": "red"}]}
+		]`,
+		"/boards/b1/lists": `[]`,
+		"/boards/b1/members": `[
+			{"id": "m1", "username": "alice"}, {"id": "m2", "username": "bob"}
+		]`,
+	})
+	tree := newTestTree(t, Options{Layout: "v2"})
+	ctx := context.Background()
+	views := func(want map[string][]string) {
+		t.Helper()
+		for path, cards := range want {
+			view := lookupPath(t, tree, "eng/Roadmap/views/"+path)
+			entries, err := tree.Entries(ctx, view.GetNodeID())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, entry := range entries {
+				if entry.GetName() != ".refresh" {
+					got = append(got, entry.GetName())
+				}
+			}
+			if !reflect.DeepEqual(got, cards) {
+				t.Errorf("%s holds %q, want %q", path, got, cards)
+			}
+		}
+	}
+	views(map[string][]string{
+		"labels/bug":    {"Crash", "Typo"},
+		"members/alice": {"Crash", "Typo"},
+		"members/bob":   {"Typo"},
+	})
+
+	err := ioutil.WriteFile(
+		filepath.Join(dir, "boards-b1-cards.json"), []byte(`[
+			{"id": "c1", "name": "Crash", "idMembers": ["m2"],
+				"labels": [{"id": "lb1", "name": "bug", "color": "red"}]},
+			{"id": "c2", "name": "Typo", "idMembers": [],
+				"labels": [{"id": "lb2", "name": "docs", "color": "blue"}]}
+		]`), 0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// as the views are listed meanwhile.
+	labels := lookupPath(t, tree, "eng/Roadmap/views/labels")
+	members := lookupPath(t, tree, "eng/Roadmap/views/members")
+	refresh := lookupPath(t, tree, "eng/Roadmap/.refresh")
+	refreshed := make(chan error)
+	go func() { refreshed <- writeFile(t, tree, refresh, "1\n") }()
+	for _, done := range []<-chan error{
+		refreshed, listing(tree, labels), listing(tree, members),
+	} {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	views(map[string][]string{
+		"labels/bug":  {"Crash"},
+		"labels/docs": {"Typo"},
+		"members/bob": {"Crash"},
+	})
+	if _, err := tree.Lookup(ctx, members.GetNodeID(), "alice"); err == nil {
+		t.Errorf("view of a member without cards left")
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// A board is being walked, e.g. by 'grep -r' or 'rg', once walkCards of its
+// cards are listed within walkWindow of each other. The contents of all of
+// its cards, i.e. their checklists, attachments, and comments, are then
+// fetched in a single request, rather than a few per card, and the cards'
+// files are made from those until walkTimeout passes without any of its
+// cards being listed. Contents are fetched anew once older than walkMaxAge.
+const walkCards = 8
+const walkWindow = 5 * time.Second
+const walkTimeout = 30 * time.Second
+const walkMaxAge = 30 * time.Second
+
+type boardWalk struct {
+	// when each of the board's cards was last listed, by card ID.
+	listed map[string]time.Time
+
+	// the contents of the board's cards while walked, by card ID.
+	contents map[string]*trello.CardContents
+	fetched  time.Time
+}
+
+type boardWalks struct {
+	lock   sync.Mutex
+	boards map[string]*boardWalk
+}
+
+// Note a card being listed, fetching the contents of all of its board's
+// cards if the board is being walked, on behalf of the listing operation.
+// Called without the tree's lock held, as fetching may take a while.
+func (tree *Tree) noteCardListed(ctx context.Context, card *FSCard) {
+	card.Lock()
+	board := card.Card.Board
+	cardID := card.Card.ID
+	card.Unlock()
+	if board == nil {
+		return
+	}
+
+	walks := &tree.walks
+	walks.lock.Lock()
+	walk, exists := walks.boards[board.ID]
+	if !exists {
+		walk = &boardWalk{listed: make(map[string]time.Time)}
+		walks.boards[board.ID] = walk
+	}
+	now := time.Now()
+	walk.listed[cardID] = now
+	recent := 0
+	for id, listed := range walk.listed {
+		if now.Sub(listed) < walkWindow {
+			recent++
+		} else if now.Sub(listed) >= walkTimeout {
+			delete(walk.listed, id)
+		}
+	}
+	stale := time.Since(walk.fetched) >= walkMaxAge
+	walking := recent >= walkCards || walk.contents != nil
+	walks.lock.Unlock()
+
+	if !walking || !stale {
+		return
+	}
+	log.Printf(
+		"walk > fetching contents of cards on board %s (%s)\n",
+		board.Name, board.ID,
+	)
+	contents, err := board.GetCardContents(card.Ctx.WithContext(ctx))
+	if err != nil {
+		return
+	}
+	walks.lock.Lock()
+	walk.contents = contents
+	walk.fetched = time.Now()
+	walks.lock.Unlock()
+}
+
+// The contents of a card, if its board is being walked; nil otherwise.
+func (tree *Tree) walkedContents(card *trello.Card) *trello.CardContents {
+	walks := &tree.walks
+	walks.lock.Lock()
+	defer walks.lock.Unlock()
+
+	walk, exists := walks.boards[card.BoardID]
+	if !exists || walk.contents == nil {
+		return nil
+	}
+	var last time.Time
+	for _, listed := range walk.listed {
+		if listed.After(last) {
+			last = listed
+		}
+	}
+	if time.Since(last) >= walkTimeout {
+		log.Printf("walk > done walking board %s\n", card.BoardID)
+		walk.contents = nil
+		return nil
+	}
+	return walk.contents[card.ID]
+}
+
+func (tree *Tree) cardChecklists(
+	ctx *trello.TrelloCtx, card *trello.Card,
+) ([]trello.Checklist, error) {
+	if contents := tree.walkedContents(card); contents != nil {
+		return contents.Checklists, nil
+	}
+	return card.GetChecklists(ctx)
+}
+
+func (tree *Tree) cardAttachments(
+	ctx *trello.TrelloCtx, card *trello.Card,
+) ([]trello.Attachment, error) {
+	if contents := tree.walkedContents(card); contents != nil {
+		return contents.Attachments, nil
+	}
+	return card.GetAttachments(ctx)
+}
+
+func (tree *Tree) cardComments(
+	ctx *trello.TrelloCtx, card *trello.Card,
+) ([]trello.Action, error) {
+	if contents := tree.walkedContents(card); contents != nil {
+		return contents.Comments, nil
+	}
+	return card.GetComments(ctx)
+}
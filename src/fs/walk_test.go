@@ -0,0 +1,79 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Boards whose cards are listed in quick succession, as by 'grep -r', have
+// the contents of all of their cards fetched at once, which cards' files
+// are then made from, until the walk is over.
+func TestWalkBoard(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/boards/b1/cards": `[
+			{"id": "c1", "checklists": [{"id": "cl1", "name": "Steps"}],
+				"actions": [{"data": {"text": "On it"}}]},
+			{"id": "c2", "attachments": [{"id": "a1", "name": "log.txt"}]}
+		]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	var cards []*FSCard
+	for i := 1; i <= walkCards; i++ {
+		id := fmt.Sprintf("c%d", i)
+		cards = append(cards, newTestCard(board, &trello.Card{
+			ID: id, Name: "Card " + id, BoardID: "b1",
+		}))
+	}
+
+	first := cards[0].Card
+	if tree.walkedContents(first) != nil {
+		t.Fatal("board walked before its cards were listed")
+	}
+	var wg sync.WaitGroup
+	for _, card := range cards {
+		wg.Add(1)
+		go func(card *FSCard) {
+			defer wg.Done()
+			if err := <-listing(tree, card); err != nil {
+				t.Error(err)
+			}
+		}(card)
+	}
+	wg.Wait()
+
+	checklists, err := tree.cardChecklists(tree.ctx, first)
+	if err != nil || len(checklists) != 1 || checklists[0].Name != "Steps" {
+		t.Errorf("walked card's checklists %v (%v)", checklists, err)
+	}
+	comments, err := tree.cardComments(tree.ctx, first)
+	if err != nil || len(comments) != 1 || comments[0].Data.Text != "On it" {
+		t.Errorf("walked card's comments %v (%v)", comments, err)
+	}
+	attachments, err := tree.cardAttachments(tree.ctx, cards[1].Card)
+	if err != nil || len(attachments) != 1 {
+		t.Errorf("walked card's attachments %v (%v)", attachments, err)
+	}
+
+	tree.walks.lock.Lock()
+	for id := range tree.walks.boards["b1"].listed {
+		tree.walks.boards["b1"].listed[id] = time.Now().Add(-walkTimeout)
+	}
+	tree.walks.lock.Unlock()
+	if tree.walkedContents(first) != nil {
+		t.Errorf("board still walked once its cards are no longer listed")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"log"
+	"strings"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// A board mounted, along with the context it's reached through.
+type MountedBoard struct {
+	ID  string
+	Ctx *trello.TrelloCtx
+}
+
+// The boards currently mounted, as far as they've been loaded.
+func (tree *Tree) MountedBoards() []MountedBoard {
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	var boards []MountedBoard
+	for _, node := range tree.inodes {
+		if board, isBoard := node.(*FSBoard); isBoard {
+			boards = append(boards, MountedBoard{
+				ID: board.GetTrelloID(), Ctx: board.Ctx,
+			})
+		}
+	}
+	return boards
+}
+
+// Have whatever an action on a board may have changed refreshed, e.g. as
+// pushed by a webhook, rather than waiting for it to be refreshed when due:
+// the board's cards, the lists and card involved, and its lists, labels, or
+// members, depending on the action's type.
+func (tree *Tree) ApplyAction(action *trello.Action) {
+	tree.updates.Lock()
+	defer tree.updates.Unlock()
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+
+	data := &action.Data
+	board, isBoard := tree.nodeByTrelloID(data.Board.ID).(*FSBoard)
+	if !isBoard {
+		return
+	}
+	log.Printf(
+		"action %s on board %s (%s)\n",
+		action.Type, board.GetName(), data.Board.ID,
+	)
+
+	board.Lock()
+	if board.MetaCardsDir == nil {
+		// not loaded yet, so there's nothing to refresh.
+		board.Unlock()
+		return
+	}
+	stale := []FSNode{board.MetaCardsDir}
+	if strings.Contains(action.Type, "List") {
+		stale = append(stale, board.MetaListsDir)
+	}
+	if strings.Contains(action.Type, "Label") {
+		stale = append(stale, board.MetaLabels)
+	}
+	if strings.Contains(action.Type, "Member") {
+		stale = append(stale, board.MetaMembers)
+	}
+	for _, list := range []trello.ActionEntity{
+		data.List, data.ListBefore, data.ListAfter,
+	} {
+		if listNode, exists := board.ByListID[list.ID]; exists {
+			stale = append(stale, listNode)
+		}
+	}
+	card := board.ByCardID[data.Card.ID]
+	board.Unlock()
+	if card != nil {
+		stale = append(stale, card.GetEntries()...)
+	}
+
+	for _, node := range stale {
+		node.Lock()
+		node.markStale()
+		node.Unlock()
+		tree.queueRefresh(node)
+	}
+}
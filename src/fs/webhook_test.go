@@ -0,0 +1,56 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Actions pushed on a board have its cards refreshed in the background,
+// rather than when next due; actions on boards not mounted are ignored.
+func TestApplyAction(t *testing.T) {
+	dir := withFixtures(t, map[string]string{
+		"/boards/b1/cards": `[{"id": "c1", "name": "Fix"}]`,
+	})
+	tree := newTestTree(t, Options{})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	if err := <-listing(tree, board.MetaCardsDir); err != nil {
+		t.Fatal(err)
+	}
+	err := ioutil.WriteFile(
+		filepath.Join(dir, "boards-b1-cards.json"),
+		[]byte(`[{"id": "c1", "name": "Fix crash"}]`), 0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	action := &trello.Action{Type: "updateCard"}
+	action.Data.Board.ID = "b2"
+	tree.ApplyAction(action)
+	action.Data.Board.ID = "b1"
+	action.Data.Card.ID = "c1"
+	tree.ApplyAction(action)
+
+	card := board.ByCardID["c1"]
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if card.GetName() == "Fix crash" {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("card not refreshed on an action pushed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
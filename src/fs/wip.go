@@ -0,0 +1,124 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"syscall"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Lists may be given work-in-progress limits, through Options.WIPLimits, on
+// how many cards they hold. Lists over their limit are warned about in their
+// '.stats' file, and in '.trellofs/status'; with Options.EnforceWIPLimits,
+// cards can't be created on, or moved to, lists at their limit either.
+
+// Obtain a list's limit, or 0 if it has none. Lists are found by their ID,
+// or as '<board>/<list>', by either's name or ID, or the board's short link.
+func (opts *Options) wipLimit(board *trello.Board, list *trello.List) int {
+	if limit, exists := opts.WIPLimits[list.ID]; exists {
+		return limit
+	}
+	for _, b := range []string{board.Name, board.ID, board.ShortLink} {
+		for _, l := range []string{list.Name, list.ID} {
+			if limit, exists := opts.WIPLimits[b+"/"+l]; exists {
+				return limit
+			}
+		}
+	}
+	return 0
+}
+
+// How many cards the list holds, and its limit, or 0 if it has none. Must
+// be called with the list's lock held.
+func (node *FSList) wip() (int, int) {
+	boardNode := node.BoardNode
+	boardNode.Lock()
+	board := *boardNode.Board
+	boardNode.Unlock()
+	return len(node.Cards), node.tree.opts.wipLimit(&board, node.List)
+}
+
+// Whether the list may take another card, failing with EDQUOT if it's at
+// its limit and limits are enforced. Must be called with the list's lock
+// held.
+func (node *FSList) checkWIP() error {
+	if !node.tree.opts.EnforceWIPLimits {
+		return nil
+	}
+	cards, limit := node.wip()
+	if limit > 0 && cards >= limit {
+		log.Printf(
+			"list %s (%s) at its WIP limit of %d cards\n",
+			node.GetName(), node.GetTrelloID(), limit,
+		)
+		return syscall.EDQUOT
+	}
+	return nil
+}
+
+// Describe the list, as
+//
+//	cards	<cards on the list>
+//	wip-limit	<limit, or '-' if none>
+//	warning	over WIP limit (<cards>/<limit>)
+//
+// with the warning only there while the list is over its limit.
+func (node *FSList) stats() ([]byte, error) {
+	node.Lock()
+	cards, limit := node.wip()
+	node.Unlock()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "cards\t%d\n", cards)
+	if limit <= 0 {
+		buf.WriteString("wip-limit\t-\n")
+		return buf.Bytes(), nil
+	}
+	fmt.Fprintf(&buf, "wip-limit\t%d\n", limit)
+	if cards > limit {
+		fmt.Fprintf(&buf, "warning\tover WIP limit (%d/%d)\n", cards, limit)
+	}
+	return buf.Bytes(), nil
+}
+
+// Lists known to be over their limit, as '<path>\t<cards>/<limit>', sorted
+// by path.
+func (tree *Tree) wipBreaches() []string {
+	if len(tree.opts.WIPLimits) == 0 {
+		return nil
+	}
+	tree.lock.Lock()
+	var lists []*FSList
+	for _, node := range tree.inodes {
+		if list, isList := node.(*FSList); isList {
+			lists = append(lists, list)
+		}
+	}
+	tree.lock.Unlock()
+
+	var breaches []string
+	for _, list := range lists {
+		list.Lock()
+		cards, limit := list.wip()
+		list.Unlock()
+		if limit > 0 && cards > limit {
+			breaches = append(breaches, fmt.Sprintf(
+				"/%s\t%d/%d", nodePath(list), cards, limit,
+			))
+		}
+	}
+	sort.Strings(breaches)
+	return breaches
+}
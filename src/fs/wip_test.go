@@ -0,0 +1,99 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Lists over their WIP limit are warned about in their '.stats' and in
+// '.trellofs/status', and, with limits enforced, take no more cards.
+func TestWIPLimits(t *testing.T) {
+	withFixtures(t, nil)
+	tree := newTestTree(t, Options{
+		WIPLimits:        map[string]int{"Roadmap/Doing": 1},
+		EnforceWIPLimits: true,
+	})
+	board := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	todo := newTestList(board, &trello.List{ID: "l1", Name: "To do"})
+	doing := newTestList(board, &trello.List{ID: "l2", Name: "Doing"})
+	for _, card := range []*trello.Card{
+		{ID: "c1", Name: "One"}, {ID: "c2", Name: "Two"},
+	} {
+		doing.addCard(newTestCard(board, card))
+	}
+	waiting := newTestCard(board, &trello.Card{ID: "c3", Name: "Three"})
+	todo.addCard(waiting)
+	ctx := context.Background()
+
+	stats, err := doing.stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "cards\t2\nwip-limit\t1\nwarning\tover WIP limit (2/1)\n"
+	if string(stats) != want {
+		t.Errorf("stats:\n%s\nwant:\n%s", stats, want)
+	}
+	if stats, _ := todo.stats(); string(stats) != "cards\t1\nwip-limit\t-\n" {
+		t.Errorf("stats of a list with no limit:\n%s", stats)
+	}
+
+	if _, err := tree.MkDir(ctx, doing.GetNodeID(), "Four"); err !=
+		syscall.EDQUOT {
+		t.Errorf("card created on a list over its limit: %v", err)
+	}
+	err = tree.Rename(
+		ctx, todo.GetNodeID(), waiting.GetName(),
+		doing.GetNodeID(), waiting.GetName(),
+	)
+	if err != syscall.EDQUOT {
+		t.Errorf("card moved to a list over its limit: %v", err)
+	}
+
+	// breaches are reported while cards come and go.
+	var coming []*FSCard
+	for _, name := range []string{"Four", "Five", "Six", "Seven"} {
+		coming = append(coming, newTestCard(board, &trello.Card{
+			ID: strings.ToLower(name), Name: name,
+		}))
+	}
+	var wg sync.WaitGroup
+	for _, card := range coming {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			status, err := tree.status()
+			if err != nil {
+				t.Error(err)
+			} else if !strings.Contains(
+				string(status), "wip-breach\t/Doing\t",
+			) {
+				t.Errorf("breach not in the status:\n%s", status)
+			}
+		}()
+		go func(card *FSCard) {
+			defer wg.Done()
+			doing.Lock()
+			doing.addCard(card)
+			doing.Unlock()
+		}(card)
+	}
+	wg.Wait()
+	if breaches := tree.wipBreaches(); len(breaches) != 1 ||
+		breaches[0] != "/Doing\t6/1" {
+		t.Errorf("breaches: %q", breaches)
+	}
+}
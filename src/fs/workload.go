@@ -0,0 +1,78 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Generate the workspace's workload report, from the cached cards of all its
+// boards: how many open cards are assigned to each member, busiest first. One
+// tab-separated line per member, named after their username if known:
+//
+//	<member>	<open cards>
+func (node *FSWorkspace) workload() ([]byte, error) {
+
+	node.Lock()
+	boards := append([]*FSBoard{}, node.Boards...)
+	node.Unlock()
+
+	counts := make(map[string]int)
+	usernames := make(map[string]string)
+	for _, board := range boards {
+		board.Lock()
+		cards := append([]*FSCard{}, board.Cards...)
+		members := board.MetaMembers
+		board.Unlock()
+
+		if members != nil {
+			members.Lock()
+			for _, member := range members.byMember {
+				usernames[member.ID] = member.Username
+			}
+			members.Unlock()
+		}
+
+		for _, card := range cards {
+			card.Lock()
+			if !card.Card.Closed {
+				for _, id := range card.Card.MemberIDs {
+					counts[id]++
+				}
+			}
+			card.Unlock()
+		}
+	}
+
+	var names []string
+	byName := make(map[string]int)
+	for id, count := range counts {
+		name := id
+		if username, exists := usernames[id]; exists {
+			name = username
+		}
+		names = append(names, name)
+		byName[name] = count
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if byName[names[i]] != byName[names[j]] {
+			return byName[names[i]] > byName[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s\t%d\n", name, byName[name])
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,55 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// A workspace's workload counts the open cards assigned to each member
+// across its boards, busiest first, by username where known.
+func TestWorkspaceWorkload(t *testing.T) {
+	withFixtures(t, nil)
+	tree := newTestTree(t, Options{})
+	roadmap := newTestBoard(tree, &trello.Board{ID: "b1", Name: "Roadmap"})
+	ops := newTestBoard(tree, &trello.Board{ID: "b2", Name: "Ops"})
+	roadmap.MetaMembers = newBoardMembersDir(roadmap)
+	roadmap.MetaMembers.byMember["alice"] = &trello.Member{
+		ID: "m1", Username: "alice",
+	}
+	for board, cards := range map[*FSBoard][]trello.Card{
+		roadmap: {
+			{ID: "c1", MemberIDs: []string{"m1", "m2"}},
+			{ID: "c2", MemberIDs: []string{"m1"}},
+			{ID: "c3", MemberIDs: []string{"m1"}, Closed: true},
+		},
+		ops: {
+			{ID: "c4", MemberIDs: []string{"m2"}},
+			{ID: "c5", MemberIDs: []string{"m2"}},
+		},
+	} {
+		for _, card := range cards {
+			card := card
+			card.Name = card.ID
+			newTestCard(board, &card)
+		}
+	}
+	ws := &FSWorkspace{Boards: []*FSBoard{roadmap, ops}}
+
+	got, err := ws.workload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "m2\t3\nalice\t2\n"; string(got) != want {
+		t.Errorf("workload:\n%s\nwant:\n%s", got, want)
+	}
+}
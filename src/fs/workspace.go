@@ -17,7 +17,6 @@ import (
 
 	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fuseops"
-	"github.com/jacobsa/fuse/fuseutil"
 )
 
 type FSWorkspace struct {
@@ -27,11 +26,15 @@ type FSWorkspace struct {
 	ByID   map[string]*FSBoard
 	ByName map[string]*FSBoard
 
+	MetaWorkload *FSGeneratedFile
+	MetaTrash    *FSWorkspaceTrashDir
+	MetaImport   *FSWorkspaceImportDir
+
 	Workspace *trello.Workspace
 }
 
 func (node *FSWorkspace) ShouldUpdate() bool {
-	return node.shouldUpdate(60.0)
+	return node.shouldRefresh("boards", 60.0)
 }
 
 func (node *FSWorkspace) Update() ([]FSNode, []FSNode, error) {
@@ -43,7 +46,7 @@ func (node *FSWorkspace) Update() ([]FSNode, []FSNode, error) {
 		node.Workspace.Name, node.Workspace.ID,
 	)
 
-	boards, err := node.Workspace.GetBoards(node.Ctx)
+	boards, err := node.Workspace.GetBoards(node.api())
 	if err != nil {
 		log.Printf(
 			"error updating boards for workspace %s: %s\n",
@@ -59,14 +62,38 @@ func (node *FSWorkspace) Update() ([]FSNode, []FSNode, error) {
 	)
 
 	var newNodes []FSNode = make([]FSNode, 0)
+	seen := make(map[string]bool)
+	filter := node.tree.opts.boardFilter(
+		node.Workspace.Name, node.Workspace.ID,
+	)
+	wsKeys := []string{
+		node.Workspace.Name, node.Workspace.DisplayName, node.Workspace.ID,
+	}
 	for i, board := range boards {
-		if _, exists := node.ByID[board.ID]; exists {
+		wanted := node.tree.boardWanted(
+			filter, wsKeys, board.Name, board.ID, board.ShortLink,
+		)
+		if !wanted {
+			continue
+		}
+		seen[board.ID] = true
+		if existing, exists := node.ByID[board.ID]; exists {
+			node.renameBoard(existing, &boards[i])
 			continue
 		}
 
+		idNames := node.tree.opts.idNamed(
+			node.Workspace.ID, node.Workspace.Name,
+			board.ID, board.Name, board.ShortLink,
+		)
+		name := uniqueName(
+			node.tree.opts.boardName(&boards[i], idNames),
+			board.ShortLink, board.ID,
+			func(name string) bool { return node.ByName[name] != nil },
+		)
 		newItem := &FSBoard{
 			BaseFSNode: BaseFSNode{
-				name: board.Name,
+				name: name,
 				uid:  node.uid,
 				gid:  node.gid,
 				NodeAttrs: fuseops.InodeAttributes{
@@ -77,30 +104,92 @@ func (node *FSWorkspace) Update() ([]FSNode, []FSNode, error) {
 				isDir:    true,
 				TrelloID: board.ID,
 				Ctx:      node.Ctx,
+				parent:   node,
 			},
 			ByCardID:   make(map[string]*FSCard),
 			ByCardName: make(map[string]*FSCard),
 			ByListID:   make(map[string]*FSList),
 			ByListName: make(map[string]*FSList),
 			Board:      &boards[i],
+			idNames:    idNames,
 		}
 		newNodes = append(newNodes, newItem)
 		node.ByID[board.ID] = newItem
-		node.ByName[board.Name] = newItem
+		node.ByName[name] = newItem
 		node.Boards = append(node.Boards, newItem)
 	}
+
+	var removedNodes []FSNode
+	boardsLeft := make([]*FSBoard, 0, len(node.Boards))
+	for _, board := range node.Boards {
+		if seen[board.GetTrelloID()] {
+			boardsLeft = append(boardsLeft, board)
+			continue
+		}
+		log.Printf(
+			"board %s (%s) gone from workspace %s (%s)\n",
+			board.GetName(), board.GetTrelloID(), node.name, node.TrelloID,
+		)
+		removedNodes = append(removedNodes, board)
+		delete(node.ByID, board.GetTrelloID())
+		delete(node.ByName, board.GetName())
+	}
+	node.Boards = boardsLeft
+
+	if node.MetaWorkload == nil {
+		node.MetaWorkload = newGeneratedFile(
+			node, ".workload", 30.0, node.workload,
+		)
+		node.MetaImport = newWorkspaceImportDir(node)
+		node.MetaTrash = newWorkspaceTrashDir(node)
+		newNodes = append(
+			newNodes, node.MetaWorkload, node.MetaImport, node.MetaTrash,
+		)
+	}
 	node.markUpdated()
 	log.Printf(
 		"updated workspace %s (%s): %d new nodes, %d total boards\n",
 		node.name, node.TrelloID, len(newNodes), len(node.Boards),
 	)
-	return newNodes, nil, nil
+	return newNodes, removedNodes, nil
+}
+
+// Keep a board's directory named after it, should it have been renamed.
+func (node *FSWorkspace) renameBoard(boardNode *FSBoard, board *trello.Board) {
+	boardNode.Lock()
+	*boardNode.Board = *board
+	boardNode.Unlock()
+
+	name, renamed := renamedName(
+		boardNode.GetName(),
+		node.tree.opts.boardName(board, boardNode.idNames),
+		board.ShortLink, board.ID,
+		func(name string) bool {
+			other := node.ByName[name]
+			return other != nil && other != boardNode
+		},
+	)
+	if !renamed {
+		return
+	}
+	log.Printf(
+		"board %s (%s) renamed to %s\n",
+		boardNode.GetName(), board.ID, name,
+	)
+	delete(node.ByName, boardNode.GetName())
+	boardNode.setName(name)
+	node.ByName[name] = boardNode
 }
 
 func (node *FSWorkspace) LookupChild(name string) (FSNode, error) {
 	node.Lock()
 	defer node.Unlock()
 
+	for _, entry := range node.metaEntries() {
+		if entry.GetName() == name {
+			return entry, nil
+		}
+	}
 	for _, board := range node.Boards {
 		if board.name == name {
 			return board, nil
@@ -109,33 +198,21 @@ func (node *FSWorkspace) LookupChild(name string) (FSNode, error) {
 	return nil, fuse.ENOENT
 }
 
-func (node *FSWorkspace) ReadDir(dst []byte, offset int) int {
+func (node *FSWorkspace) GetEntries() []FSNode {
 	node.Lock()
 	defer node.Unlock()
 
-	log.Printf(
-		"read dir %s (%s) id %d, offset %d\n",
-		node.GetName(),
-		node.GetTrelloID(),
-		node.GetNodeID(),
-		offset,
-	)
-	var size int
-	for i := offset; i < len(node.Boards); i++ {
-		board := node.Boards[i]
-		tmp := fuseutil.WriteDirent(dst[size:], fuseutil.Dirent{
-			Name:   board.name,
-			Inode:  board.GetNodeID(),
-			Type:   fuseutil.DT_Directory,
-			Offset: fuseops.DirOffset(i + 1),
-		})
-		if tmp == 0 {
-			log.Printf(
-				"read dir > no more space to write dirent for %s\n", board.name,
-			)
-			break
-		}
-		size += tmp
+	entries := make([]FSNode, 0, len(node.Boards)+3)
+	for _, board := range node.Boards {
+		entries = append(entries, board)
+	}
+	return append(entries, node.metaEntries()...)
+}
+
+// The workspace's structural entries, once it has been updated.
+func (node *FSWorkspace) metaEntries() []FSNode {
+	if node.MetaWorkload == nil {
+		return nil
 	}
-	return size
+	return []FSNode{node.MetaWorkload, node.MetaImport, node.MetaTrash}
 }
@@ -0,0 +1,62 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package fs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Boards within the subtrees configured are named after their ID, their
+// name in their 'name' file, as are their cards.
+func TestWorkspaceIDNames(t *testing.T) {
+	withFixtures(t, map[string]string{
+		"/organizations/w1/boards": `[
+			{"id": "b1", "name": "Roadmap", "shortLink": "rm"},
+			{"id": "b2", "name": "Ops", "shortLink": "op"}
+		]`,
+		"/boards/b2/cards": `[{"id": "c1", "name": "Deploy"}]`,
+	})
+	tree := newTestTree(t, Options{IDNamesIn: []string{"op"}})
+	ws := newTestWorkspace(tree, &trello.Workspace{ID: "w1", Name: "eng"})
+	ctx := context.Background()
+
+	entries, err := tree.Entries(ctx, ws.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Roadmap", "b2"} {
+		if entryNamed(entries, name) == nil {
+			t.Errorf("board %q not listed", name)
+		}
+	}
+	board := entryNamed(entries, "b2")
+	entries, err = tree.Entries(ctx, board.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := entryNamed(entries, "name")
+	if name == nil {
+		t.Fatal("ID-named board has no name file")
+	}
+	if got := readFile(t, tree, name); got != "Ops\n" {
+		t.Errorf("board's name file holds %q", got)
+	}
+	cards := entryNamed(entries, "cards")
+	entries, err = tree.Entries(ctx, cards.GetNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entryNamed(entries, "c1") == nil {
+		t.Errorf("card not named after its ID")
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+
+// A persistent queue of requests changing cards, made while Trello couldn't
+// be reached, so they survive until they can be made, even across mounts.
+// Each request is kept as a JSON file in the journal's directory, named
+// after its sequence number.
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jecluis/trellofs/src/cache"
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+type Journal struct {
+	dir string
+
+	lock    sync.Mutex
+	ops     []*trello.PendingOp
+	nextSeq uint64
+}
+
+// Open the journal in 'dir', creating it if need be, along with whatever
+// requests were queued in it. Requests whose files a crash left corrupt are
+// discarded, as are temporary files never renamed into place; see
+// cache.WriteFile().
+func Open(dir string) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	journal := &Journal{dir: dir, nextSeq: 1}
+	for _, file := range files {
+		name := file.Name()
+		path := filepath.Join(dir, name)
+		if file.IsDir() {
+			continue
+		} else if cache.IsTempFile(name) {
+			os.Remove(path)
+			continue
+		} else if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, ".json"), 10, 64)
+		if err != nil {
+			continue
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var op trello.PendingOp
+		err = json.Unmarshal(contents, &op)
+		if err == nil && (op.Method == "" || op.Endpoint == "") {
+			err = fmt.Errorf("incomplete request")
+		}
+		if err != nil {
+			log.Printf("journal > discarding corrupt %s: %s\n", name, err)
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		op.Seq = seq
+		journal.ops = append(journal.ops, &op)
+		if seq >= journal.nextSeq {
+			journal.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(journal.ops, func(i, j int) bool {
+		return journal.ops[i].Seq < journal.ops[j].Seq
+	})
+	return journal, nil
+}
+
+func (j *Journal) path(seq uint64) string {
+	return filepath.Join(j.dir, fmt.Sprintf("%016d.json", seq))
+}
+
+// Write a request's file, replacing it atomically, and durably.
+func (j *Journal) write(op *trello.PendingOp) error {
+	contents, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	return cache.WriteFile(j.path(op.Seq), contents)
+}
+
+func (j *Journal) Append(op *trello.PendingOp) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	op.Seq = j.nextSeq
+	if err := j.write(op); err != nil {
+		return err
+	}
+	j.nextSeq++
+	queued := *op
+	j.ops = append(j.ops, &queued)
+	return nil
+}
+
+func (j *Journal) Update(op *trello.PendingOp) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	for i, queued := range j.ops {
+		if queued.Seq != op.Seq {
+			continue
+		}
+		if err := j.write(op); err != nil {
+			return err
+		}
+		updated := *op
+		j.ops[i] = &updated
+		return nil
+	}
+	return os.ErrNotExist
+}
+
+func (j *Journal) Remove(op *trello.PendingOp) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	err := os.Remove(j.path(op.Seq))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	} else if err := cache.SyncDir(j.dir); err != nil {
+		return err
+	}
+	for i, queued := range j.ops {
+		if queued.Seq == op.Seq {
+			j.ops = append(j.ops[:i], j.ops[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Copies of the requests queued, so they may be changed before updating
+// them.
+func (j *Journal) Pending() []*trello.PendingOp {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	ops := make([]*trello.PendingOp, len(j.ops))
+	for i, op := range j.ops {
+		copied := *op
+		ops[i] = &copied
+	}
+	return ops
+}
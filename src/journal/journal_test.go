@@ -0,0 +1,136 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package journal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Requests queued at once are each given their own sequence number, and are
+// found queued, in order, once the journal is opened again.
+func TestJournalReopened(t *testing.T) {
+	dir := t.TempDir()
+	journal, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const queued = 16
+	var wg sync.WaitGroup
+	for i := 0; i < queued; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := journal.Append(&trello.PendingOp{
+				Method: "PUT", Endpoint: fmt.Sprintf("/cards/c%d", i),
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	pending := journal.Pending()
+	if len(pending) != queued {
+		t.Fatalf("%d requests pending, want %d", len(pending), queued)
+	}
+	first := pending[0]
+	first.Attempts = 2
+	first.LastError = "unreachable"
+	if err := journal.Update(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Remove(pending[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reopened.Pending()
+	if len(got) != queued-1 {
+		t.Fatalf("%d requests pending once reopened, want %d",
+			len(got), queued-1)
+	}
+	for i, op := range got {
+		if i > 0 && op.Seq <= got[i-1].Seq {
+			t.Errorf("#%d pending after #%d", op.Seq, got[i-1].Seq)
+		}
+		if op.Seq == pending[1].Seq {
+			t.Errorf("#%d pending once removed", op.Seq)
+		}
+	}
+	if !reflect.DeepEqual(got[0], first) {
+		t.Errorf("#%d reopened as %+v, want %+v", first.Seq, got[0], first)
+	}
+
+	// requests queued from then on follow those already queued.
+	op := &trello.PendingOp{Method: "DELETE", Endpoint: "/cards/c1"}
+	if err := reopened.Append(op); err != nil {
+		t.Fatal(err)
+	}
+	if last := got[len(got)-1]; op.Seq <= last.Seq {
+		t.Errorf("#%d queued after #%d", op.Seq, last.Seq)
+	}
+}
+
+// Whatever a crash may have left behind, requests written in part or not
+// renamed into place, is discarded as the journal is opened, keeping the
+// rest.
+func TestJournalCorrupt(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := t.TempDir()
+	journal, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := &trello.PendingOp{Method: "PUT", Endpoint: "/cards/c1"}
+	if err := journal.Append(op); err != nil {
+		t.Fatal(err)
+	}
+	leftovers := map[string]string{
+		".tmp-123":               `{"method": "PUT"`,
+		journal.path(op.Seq + 1): `{"method": "PUT", "endpoint": "/ca`,
+		journal.path(op.Seq + 2): `{"method": "PUT"}`,
+	}
+	for name, body := range leftovers {
+		path := filepath.Join(dir, filepath.Base(name))
+		if err := ioutil.WriteFile(path, []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if journal, err = Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	pending := journal.Pending()
+	if len(pending) != 1 || pending[0].Seq != op.Seq {
+		t.Errorf("pending once reopened: %+v", pending)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("%d files left behind", len(files)-1)
+	}
+}
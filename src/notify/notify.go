@@ -0,0 +1,169 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+
+// Relays the user's Trello notifications, e.g. being added to a card, or a
+// card being due soon, as desktop notifications through 'notify-send', and
+// to a hook script, which is given each event as JSON on its standard input.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Notification types relayed when none are configured.
+var DefaultTypes = []string{"addedToCard", "cardDueSoon", "mentionedOnCard"}
+
+type Options struct {
+	// Trello notification types to relay; DefaultTypes if empty.
+	Types []string
+
+	// How often to check for new notifications.
+	Interval time.Duration
+
+	// Show desktop notifications.
+	Desktop bool
+
+	// Path to a script run for each notification, if any.
+	Hook string
+}
+
+// A notification, as handed to the hook script.
+type Event struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Date    string    `json:"date"`
+	Message string    `json:"message"`
+	Member  string    `json:"member"`
+	Card    EventCard `json:"card"`
+	Board   string    `json:"board"`
+	List    string    `json:"list,omitempty"`
+}
+
+type EventCard struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	ShortLink string `json:"shortLink"`
+	URL       string `json:"url"`
+}
+
+type Notifier struct {
+	ctx   *trello.TrelloCtx
+	opts  Options
+	start time.Time
+	seen  map[string]bool
+}
+
+func NewNotifier(ctx *trello.TrelloCtx, opts Options) *Notifier {
+	if len(opts.Types) == 0 {
+		opts.Types = DefaultTypes
+	}
+	return &Notifier{
+		ctx:   ctx,
+		opts:  opts,
+		start: time.Now(),
+		seen:  make(map[string]bool),
+	}
+}
+
+// Check for new notifications every so often, relaying them. Notifications
+// from before the notifier was created are left alone. Never returns.
+func (n *Notifier) Run() {
+	for {
+		n.poll()
+		time.Sleep(n.opts.Interval)
+	}
+}
+
+func (n *Notifier) poll() {
+	notifications, err := trello.GetNotifications(n.ctx, n.opts.Types)
+	if err != nil {
+		return
+	}
+	for i := len(notifications) - 1; i >= 0; i-- {
+		notification := &notifications[i]
+		if n.seen[notification.ID] {
+			continue
+		}
+		n.seen[notification.ID] = true
+		date, err := time.Parse(time.RFC3339, notification.Date)
+		if err != nil || date.Before(n.start) {
+			continue
+		}
+		n.relay(newEvent(notification))
+	}
+}
+
+var messages = map[string]string{
+	"addedToCard":     "Added to card",
+	"cardDueSoon":     "Card due soon",
+	"mentionedOnCard": "Mentioned on card",
+	"commentCard":     "Comment on card",
+	"changeCard":      "Card changed",
+	"removedFromCard": "Removed from card",
+}
+
+func newEvent(notification *trello.Notification) *Event {
+	data := &notification.Data
+	message, exists := messages[notification.Type]
+	if !exists {
+		message = notification.Type
+	}
+	return &Event{
+		ID:      notification.ID,
+		Type:    notification.Type,
+		Date:    notification.Date,
+		Message: fmt.Sprintf("%s: %s", message, data.Card.Name),
+		Member:  notification.MemberCreator.Username,
+		Card: EventCard{
+			ID:        data.Card.ID,
+			Name:      data.Card.Name,
+			ShortLink: data.Card.ShortLink,
+			URL:       "https://trello.com/c/" + data.Card.ShortLink,
+		},
+		Board: data.Board.Name,
+		List:  data.List.Name,
+	}
+}
+
+func (n *Notifier) relay(event *Event) {
+	log.Printf("notify > %s (%s)\n", event.Message, event.ID)
+
+	if n.opts.Desktop {
+		// names and messages are anyone's, and mustn't pass for options.
+		cmd := exec.Command(
+			"notify-send", "--app-name=trellofs", "--",
+			event.Board, event.Message,
+		)
+		if err := cmd.Run(); err != nil {
+			log.Printf("notify > error running notify-send: %s\n", err)
+		}
+	}
+	if n.opts.Hook != "" {
+		eventJSON, err := json.Marshal(event)
+		if err != nil {
+			return
+		}
+		cmd := exec.Command(n.opts.Hook)
+		cmd.Stdin = bytes.NewReader(eventJSON)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			log.Printf(
+				"notify > error running hook %s: %s: %s\n",
+				n.opts.Hook, err, bytes.TrimSpace(out),
+			)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// Notifications are handed to the hook script once each, oldest first,
+// leaving alone those from before the notifier was started.
+func TestRelayToHook(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := t.TempDir()
+	t.Setenv("TRELLOFS_TEST", dir)
+	at := func(d time.Duration) string {
+		return time.Now().Add(d).Format(time.RFC3339)
+	}
+	err := ioutil.WriteFile(
+		filepath.Join(dir, "members-me-notifications.json"),
+		[]byte(fmt.Sprintf(`[
+			{"id": "n3", "type": "cardDueSoon", "date": %q,
+				"data": {"card": {"id": "c2", "name": "Ship",
+					"shortLink": "sh"}, "board": {"name": "Roadmap"}}},
+			{"id": "n2", "type": "addedToCard", "date": %q,
+				"memberCreator": {"username": "alice"},
+				"data": {"card": {"id": "c1", "name": "Fix",
+					"shortLink": "fx"}, "board": {"name": "Roadmap"},
+					"list": {"name": "To do"}}},
+			{"id": "n1", "type": "addedToCard", "date": %q,
+				"data": {"card": {"id": "c0", "name": "Old"}}}
+		]`, at(time.Hour), at(time.Minute), at(-time.Hour))), 0600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := filepath.Join(dir, "events")
+	hook := filepath.Join(dir, "hook")
+	err = ioutil.WriteFile(
+		hook, []byte("#!/bin/sh\n{ cat; echo; } >> "+events+"\n"), 0700,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := NewNotifier(trello.Trello("me", "", ""), Options{Hook: hook})
+	n.poll()
+	n.poll()
+
+	contents, err := ioutil.ReadFile(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Event
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	for _, line := range lines {
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		event.Date = ""
+		got = append(got, event)
+	}
+	want := []Event{
+		{
+			ID: "n2", Type: "addedToCard", Message: "Added to card: Fix",
+			Member: "alice", Board: "Roadmap", List: "To do",
+			Card: EventCard{
+				ID: "c1", Name: "Fix", ShortLink: "fx",
+				URL: "https://trello.com/c/fx",
+			},
+		},
+		{
+			ID: "n3", Type: "cardDueSoon", Message: "Card due soon: Ship",
+			Board: "Roadmap",
+			Card: EventCard{
+				ID: "c2", Name: "Ship", ShortLink: "sh",
+				URL: "https://trello.com/c/sh",
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events relayed:\n%+v\nwant:\n%+v", got, want)
+	}
+}
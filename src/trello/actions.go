@@ -0,0 +1,191 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type ActionMember struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	FullName string `json:"fullName"`
+}
+
+type ActionEntity struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	ShortLink string `json:"shortLink"`
+	Closed    bool   `json:"closed"`
+}
+
+type ActionData struct {
+	Text string `json:"text"`
+
+	Board      ActionEntity `json:"board"`
+	Card       ActionEntity `json:"card"`
+	List       ActionEntity `json:"list"`
+	ListBefore ActionEntity `json:"listBefore"`
+	ListAfter  ActionEntity `json:"listAfter"`
+}
+
+type Action struct {
+	ID            string       `json:"id"`
+	Type          string       `json:"type"`
+	Date          string       `json:"date"`
+	MemberCreator ActionMember `json:"memberCreator"`
+	Data          ActionData   `json:"data"`
+}
+
+// Obtain the card's comments, most recent first.
+func (card *Card) GetComments(ctx *TrelloCtx) ([]Action, error) {
+
+	endpoint := fmt.Sprintf(
+		"/cards/%s/actions?filter=commentCard&limit=1000", card.ID,
+	)
+	actionsRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf(
+			"error obtaining comments for card %s (%s): %s\n",
+			card.Name, card.ID, err,
+		)
+		return nil, err
+	}
+
+	var actions []Action
+	json.Unmarshal(actionsRaw, &actions)
+	return actions, nil
+}
+
+func (card *Card) AddComment(ctx *TrelloCtx, text string) error {
+
+	endpoint := fmt.Sprintf("/cards/%s/actions/comments", card.ID)
+	_, err := ctx.ApiPost(endpoint, url.Values{"text": {text}})
+	if err != nil {
+		log.Printf(
+			"error commenting on card %s (%s): %s\n",
+			card.Name, card.ID, err,
+		)
+		return err
+	}
+	return nil
+}
+
+// Actions through which cards enter, or leave, a board's lists.
+var listMoveActions = []string{
+	"createCard", "copyCard", "convertToCardFromCheckItem",
+	"moveCardToBoard", "moveCardFromBoard", "updateCard:idList",
+}
+
+// Obtain the comments on the board's cards, most recent first. Trello only
+// keeps track of the most recent 1000.
+func (board *Board) GetComments(ctx *TrelloCtx) ([]Action, error) {
+
+	endpoint := fmt.Sprintf(
+		"/boards/%s/actions?filter=commentCard&limit=1000", board.ID,
+	)
+	actionsRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf(
+			"error obtaining comments for board %s (%s): %s\n",
+			board.Name, board.ID, err,
+		)
+		return nil, err
+	}
+
+	var actions []Action
+	json.Unmarshal(actionsRaw, &actions)
+	return actions, nil
+}
+
+// Obtain the actions moving cards into, or out of, the board's lists, most
+// recent first. Trello only keeps track of the most recent 1000.
+func (board *Board) GetListMoves(ctx *TrelloCtx) ([]Action, error) {
+
+	endpoint := fmt.Sprintf(
+		"/boards/%s/actions?filter=%s&limit=1000",
+		board.ID, strings.Join(listMoveActions, ","),
+	)
+	actionsRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf(
+			"error obtaining list moves for board %s (%s): %s\n",
+			board.Name, board.ID, err,
+		)
+		return nil, err
+	}
+
+	var actions []Action
+	json.Unmarshal(actionsRaw, &actions)
+	return actions, nil
+}
+
+// Actions through which cards and lists are removed from boards, or
+// restored to them.
+var removalActions = []string{
+	"deleteCard", "updateCard:closed", "updateList:closed",
+}
+
+// Obtain the actions removing cards or lists from the workspace's boards, or
+// restoring them, since 'since', most recent first. Trello only keeps track
+// of the most recent 1000.
+func (workspace *Workspace) GetRemovals(
+	ctx *TrelloCtx,
+	since time.Time,
+) ([]Action, error) {
+
+	endpoint := fmt.Sprintf(
+		"/organizations/%s/actions?filter=%s&since=%s&limit=1000",
+		workspace.ID, strings.Join(removalActions, ","),
+		since.UTC().Format(time.RFC3339),
+	)
+	actionsRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf(
+			"error obtaining removals for workspace %s (%s): %s\n",
+			workspace.Name, workspace.ID, err,
+		)
+		return nil, err
+	}
+
+	var actions []Action
+	json.Unmarshal(actionsRaw, &actions)
+	return actions, nil
+}
+
+// Obtain all actions on the board since 'since', most recent first. Trello
+// only keeps track of the most recent 1000.
+func (board *Board) GetActions(
+	ctx *TrelloCtx,
+	since time.Time,
+) ([]Action, error) {
+
+	endpoint := fmt.Sprintf(
+		"/boards/%s/actions?filter=all&since=%s&limit=1000",
+		board.ID, since.UTC().Format(time.RFC3339),
+	)
+	actionsRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf(
+			"error obtaining actions for board %s (%s): %s\n",
+			board.Name, board.ID, err,
+		)
+		return nil, err
+	}
+
+	var actions []Action
+	json.Unmarshal(actionsRaw, &actions)
+	return actions, nil
+}
@@ -0,0 +1,70 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+)
+
+type Attachment struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Bytes    int64  `json:"bytes"`
+	MimeType string `json:"mimeType"`
+	IsUpload bool   `json:"isUpload"`
+}
+
+func (card *Card) GetAttachments(ctx *TrelloCtx) ([]Attachment, error) {
+
+	endpoint := MakeEndpoint(
+		fmt.Sprintf("/cards/%s/attachments", card.ID),
+		[]string{"id", "name", "url", "bytes", "mimeType", "isUpload"},
+	)
+	attachmentsRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf(
+			"error obtaining attachments for card %s (%s): %s\n",
+			card.Name, card.ID, err,
+		)
+		return nil, err
+	}
+
+	var attachments []Attachment
+	json.Unmarshal(attachmentsRaw, &attachments)
+	return attachments, nil
+}
+
+// Upload a file as a new attachment to the card.
+func (card *Card) AddAttachment(
+	ctx *TrelloCtx,
+	name string,
+	data []byte,
+) (*Attachment, error) {
+
+	endpoint := fmt.Sprintf("/cards/%s/attachments", card.ID)
+	attachmentRaw, err := ctx.ApiUpload(
+		endpoint, url.Values{"name": {name}}, "file", name, data,
+	)
+	if err != nil {
+		log.Printf(
+			"error attaching %s to card %s (%s): %s\n",
+			name, card.Name, card.ID, err,
+		)
+		return nil, err
+	}
+
+	var attachment Attachment
+	json.Unmarshal(attachmentRaw, &attachment)
+	return &attachment, nil
+}
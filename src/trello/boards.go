@@ -14,30 +14,152 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 )
 
 type Board struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Desc     string `json:"desc"`
-	DescData string `json:"descData"`
-	Closed   bool   `json:"closed"`
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Desc           string `json:"desc"`
+	DescData       string `json:"descData"`
+	Closed         bool   `json:"closed"`
+	ShortLink      string `json:"shortLink"`
+	ShortURL       string `json:"shortUrl"`
+	OrganizationID string `json:"idOrganization"`
+
+	Prefs BoardPrefs `json:"prefs"`
+}
+
+type BoardPrefs struct {
+	PermissionLevel     string `json:"permissionLevel"`
+	Comments            string `json:"comments"`
+	Voting              string `json:"voting"`
+	Invitations         string `json:"invitations"`
+	SelfJoin            bool   `json:"selfJoin"`
+	CardCovers          bool   `json:"cardCovers"`
+	CardAging           string `json:"cardAging"`
+	CalendarFeedEnabled bool   `json:"calendarFeedEnabled"`
+
+	// a color name, or the ID of a background image.
+	Background string `json:"background"`
 }
 
 type List struct {
-	ID     string `json:"id"`
-	Name   string `json:"name"`
-	Closed bool   `json:"closed"`
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Closed bool    `json:"closed"`
+	Pos    float64 `json:"pos"`
 	Board  *Board
 }
 
+func GetBoard(ctx *TrelloCtx, id string) (*Board, error) {
+
+	endpoint := MakeEndpoint(
+		fmt.Sprintf("/boards/%s", id),
+		[]string{
+			"id", "name", "desc", "descData", "closed",
+			"shortLink", "shortUrl", "idOrganization", "prefs",
+		},
+	)
+	boardRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf("error obtaining board %s: %s\n", id, err)
+		return nil, err
+	}
+
+	var board Board
+	json.Unmarshal(boardRaw, &board)
+	return &board, nil
+}
+
+// A board along with its open lists and cards, as obtained in one request
+// rather than one each; either is nil if Trello left them out.
+type BoardFull struct {
+	Board
+	Lists []List `json:"lists"`
+	Cards []Card `json:"cards"`
+}
+
+// Obtain the board along with its open lists and cards, in one request.
+func (board *Board) GetFull(ctx *TrelloCtx) (*BoardFull, error) {
+
+	endpoint := fmt.Sprintf(
+		"/boards/%s?lists=open&list_fields=id,name,closed,pos&cards=open",
+		board.ID,
+	)
+	boardRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf("error obtaining board %s in full: %s\n", board.ID, err)
+		return nil, err
+	}
+
+	var full BoardFull
+	if err := json.Unmarshal(boardRaw, &full); err != nil {
+		return nil, err
+	}
+	for idx := range full.Lists {
+		(&full.Lists[idx]).Board = board
+	}
+	for idx := range full.Cards {
+		(&full.Cards[idx]).Board = board
+	}
+	return &full, nil
+}
+
+func (board *Board) GetPrefs(ctx *TrelloCtx) (*BoardPrefs, error) {
+
+	endpoint := MakeEndpoint(
+		fmt.Sprintf("/boards/%s", board.ID), []string{"prefs"},
+	)
+	boardRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf(
+			"error obtaining prefs for board %s (%s): %s\n",
+			board.Name, board.ID, err,
+		)
+		return nil, err
+	}
+
+	var res struct {
+		Prefs BoardPrefs `json:"prefs"`
+	}
+	json.Unmarshal(boardRaw, &res)
+	return &res.Prefs, nil
+}
+
+func (board *Board) SetPref(ctx *TrelloCtx, name string, value string) error {
+
+	endpoint := fmt.Sprintf("/boards/%s/prefs/%s", board.ID, name)
+	_, err := ctx.ApiPut(endpoint, url.Values{"value": {value}})
+	if err != nil {
+		log.Printf(
+			"error setting pref %s for board %s (%s): %s\n",
+			name, board.Name, board.ID, err,
+		)
+		return err
+	}
+	return nil
+}
+
 func (board *Board) GetCards(ctx *TrelloCtx) ([]Card, error) {
+	var etag string
+	return board.GetCardsIfChanged(ctx, &etag)
+}
+
+// Obtain the board's cards, unless they're what was obtained when tagged as
+// '*etag', failing with ErrNotModified; see ApiGetIfChanged().
+func (board *Board) GetCardsIfChanged(
+	ctx *TrelloCtx,
+	etag *string,
+) ([]Card, error) {
 
 	endpoint := MakeEndpoint(
 		fmt.Sprintf("/boards/%s/cards", board.ID), nil,
 	)
-	cardsRaw, err := ctx.ApiGet(endpoint)
-	if err != nil {
+	cardsRaw, err := ctx.ApiGetIfChanged(endpoint, etag)
+	if err == ErrNotModified {
+		return nil, err
+	} else if err != nil {
 		log.Printf(
 			"error obtaining cards for board: %s (%s)",
 			board.Name,
@@ -55,13 +177,77 @@ func (board *Board) GetCards(ctx *TrelloCtx) ([]Card, error) {
 	return cards, nil
 }
 
+// Obtain all cards for the board, including closed cards.
+func (board *Board) GetAllCards(ctx *TrelloCtx) ([]Card, error) {
+
+	endpoint := MakeEndpoint(
+		fmt.Sprintf("/boards/%s/cards/all", board.ID), nil,
+	)
+	cardsRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf(
+			"error obtaining all cards for board: %s (%s)",
+			board.Name,
+			board.ID,
+		)
+		return nil, err
+	}
+	var cards []Card
+	json.Unmarshal(cardsRaw, &cards)
+	for idx := range cards {
+		(&cards[idx]).Board = board
+	}
+	return cards, nil
+}
+
+// What a card holds that is otherwise obtained through requests of its own.
+type CardContents struct {
+	Checklists  []Checklist  `json:"checklists"`
+	Attachments []Attachment `json:"attachments"`
+	Comments    []Action     `json:"actions"`
+}
+
+// Obtain the contents of all of the board's open cards in a single request,
+// as nested resources, keyed by card ID.
+func (board *Board) GetCardContents(
+	ctx *TrelloCtx,
+) (map[string]*CardContents, error) {
+
+	endpoint := fmt.Sprintf(
+		"/boards/%s/cards?fields=id&checklists=all&attachments=true"+
+			"&attachment_fields=id,name,url,bytes,mimeType,isUpload"+
+			"&actions=commentCard&actions_limit=1000",
+		board.ID,
+	)
+	cardsRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf(
+			"error obtaining card contents for board %s (%s): %s\n",
+			board.Name, board.ID, err,
+		)
+		return nil, err
+	}
+	var cards []struct {
+		ID string `json:"id"`
+		CardContents
+	}
+	json.Unmarshal(cardsRaw, &cards)
+
+	contents := make(map[string]*CardContents, len(cards))
+	for idx := range cards {
+		contents[cards[idx].ID] = &cards[idx].CardContents
+	}
+	return contents, nil
+}
+
 func (board *Board) GetLists(
 	client *TrelloCtx,
 ) ([]List, error) {
 
+	// only what's needed, as boards may have hundreds of lists.
 	endpoint := MakeEndpoint(
 		fmt.Sprintf("/boards/%s/lists", board.ID),
-		nil,
+		[]string{"id", "name", "closed", "pos"},
 	)
 	listsRaw, err := client.ApiGet(endpoint)
 	if err != nil {
@@ -71,8 +257,8 @@ func (board *Board) GetLists(
 
 	var lists []List
 	json.Unmarshal(listsRaw, &lists)
-	for _, l := range lists {
-		l.Board = board
+	for idx := range lists {
+		(&lists[idx]).Board = board
 	}
 	return lists, nil
 }
@@ -80,13 +266,25 @@ func (board *Board) GetLists(
 func (list *List) GetCards(
 	client *TrelloCtx,
 ) ([]Card, error) {
+	var etag string
+	return list.GetCardsIfChanged(client, &etag)
+}
+
+// Obtain the list's cards, unless they're what was obtained when tagged as
+// '*etag', failing with ErrNotModified; see ApiGetIfChanged().
+func (list *List) GetCardsIfChanged(
+	client *TrelloCtx,
+	etag *string,
+) ([]Card, error) {
 
 	endpoint := MakeEndpoint(
 		fmt.Sprintf("/lists/%s/cards", list.ID),
 		nil,
 	)
-	cardsRaw, err := client.ApiGet(endpoint)
-	if err != nil {
+	cardsRaw, err := client.ApiGetIfChanged(endpoint, etag)
+	if err == ErrNotModified {
+		return nil, err
+	} else if err != nil {
 		log.Printf(
 			"error obtaining cards for list %s (%s)",
 			list.Name, list.ID,
@@ -96,8 +294,58 @@ func (list *List) GetCards(
 
 	var cards []Card
 	json.Unmarshal(cardsRaw, &cards)
-	for _, c := range cards {
-		c.Board = list.Board
+	for idx := range cards {
+		(&cards[idx]).Board = list.Board
 	}
 	return cards, nil
 }
+
+// Create a card named 'name' at the bottom of the list, with any other fields
+// as accepted by 'POST /cards' (e.g., 'desc', 'due', 'idLabels').
+func (list *List) CreateCard(
+	ctx *TrelloCtx,
+	name string,
+	fields url.Values,
+) (*Card, error) {
+
+	params := url.Values{}
+	for k, v := range fields {
+		params[k] = v
+	}
+	params.Set("idList", list.ID)
+	params.Set("name", name)
+	cardRaw, err := ctx.ApiPost("/cards", params)
+	if err != nil {
+		log.Printf(
+			"error creating card %s on list %s (%s): %s\n",
+			name, list.Name, list.ID, err,
+		)
+		return nil, err
+	}
+
+	var card Card
+	json.Unmarshal(cardRaw, &card)
+	card.Board = list.Board
+	return &card, nil
+}
+
+// Create a list named 'name' at the bottom of the board.
+func (board *Board) CreateList(ctx *TrelloCtx, name string) (*List, error) {
+
+	params := url.Values{
+		"idBoard": {board.ID}, "name": {name}, "pos": {"bottom"},
+	}
+	listRaw, err := ctx.ApiPost("/lists", params)
+	if err != nil {
+		log.Printf(
+			"error creating list %s on board %s (%s): %s\n",
+			name, board.Name, board.ID, err,
+		)
+		return nil, err
+	}
+
+	var list List
+	json.Unmarshal(listRaw, &list)
+	list.Board = board
+	return &list, nil
+}
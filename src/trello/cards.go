@@ -9,15 +9,34 @@
  */
 package trello
 
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+)
+
 type CardLabel struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// Describe the label as '<name> (<color>)', or only its color if unnamed.
+func (label CardLabel) String() string {
+	if label.Name == "" {
+		return label.Color
+	}
+	return fmt.Sprintf("%s (%s)", label.Name, label.Color)
 }
 
 type Card struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-	Desc string `json:"desc"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Desc      string `json:"desc"`
+	ShortLink string `json:"shortLink"`
+	URL       string `json:"url"`
 
 	ListID    string   `json:"idList"`
 	BoardID   string   `json:"idBoard"`
@@ -25,8 +44,154 @@ type Card struct {
 
 	Labels      []CardLabel `json:"labels"`
 	Due         string      `json:"due"`
+	Start       string      `json:"start"`
 	DueComplete bool        `json:"dueComplete"`
 	LastActive  string      `json:"dateLastActivity"`
+	Closed      bool        `json:"closed"`
+	Pos         float64     `json:"pos"`
+	Badges      CardBadges  `json:"badges"`
+
+	// what the card stands for, if anything but itself, e.g. "mirror" for
+	// mirror cards, or "separator".
+	Role string `json:"cardRole"`
 
 	Board *Board
 }
+
+// Mirror cards are named after the URL of the card they mirror.
+var mirroredRE = regexp.MustCompile(`trello\.com/c/([A-Za-z0-9]+)`)
+
+// Obtain the short link of the card mirrored by a mirror card, which stands
+// for a card on another board; or "" if the card isn't a mirror.
+func (card *Card) Mirrored() string {
+	if card.Role != "mirror" {
+		return ""
+	}
+	match := mirroredRE.FindStringSubmatch(card.Name)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// What Trello summarizes about a card's contents.
+type CardBadges struct {
+	CheckItems        int `json:"checkItems"`
+	CheckItemsChecked int `json:"checkItemsChecked"`
+}
+
+func GetCard(ctx *TrelloCtx, id string) (*Card, error) {
+
+	endpoint := MakeEndpoint(fmt.Sprintf("/cards/%s", id), nil)
+	cardRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf("error obtaining card %s: %s\n", id, err)
+		return nil, err
+	}
+
+	var card Card
+	json.Unmarshal(cardRaw, &card)
+	return &card, nil
+}
+
+// Update the card's fields, as accepted by 'PUT /cards/{id}' (e.g., 'name',
+// 'desc', 'idList', 'closed'). The card is updated with Trello's response,
+// or with the fields themselves should the request be queued instead.
+func (card *Card) Update(ctx *TrelloCtx, fields url.Values) error {
+
+	endpoint := fmt.Sprintf("/cards/%s", card.ID)
+	cardRaw, err := ctx.ApiPut(endpoint, fields)
+	if err != nil {
+		log.Printf("error updating card %s (%s): %s\n", card.Name, card.ID, err)
+		return err
+	} else if cardRaw == nil {
+		card.apply(fields)
+		return nil
+	}
+	if err := json.Unmarshal(cardRaw, card); err != nil {
+		log.Printf(
+			"error parsing updated card %s (%s): %s\n", card.Name, card.ID, err,
+		)
+		return err
+	}
+	return nil
+}
+
+// Update the card's fields as Trello would, for those we know of.
+func (card *Card) apply(fields url.Values) {
+	for field := range fields {
+		value := fields.Get(field)
+		switch field {
+		case "name":
+			card.Name = value
+		case "desc":
+			card.Desc = value
+		case "idList":
+			card.ListID = value
+		case "due":
+			card.Due = value
+		case "start":
+			card.Start = value
+		case "dueComplete":
+			card.DueComplete = value == "true"
+		case "closed":
+			card.Closed = value == "true"
+		}
+	}
+}
+
+func (card *Card) AddLabel(ctx *TrelloCtx, labelID string) error {
+
+	endpoint := fmt.Sprintf("/cards/%s/idLabels", card.ID)
+	_, err := ctx.ApiPost(endpoint, url.Values{"value": {labelID}})
+	if err != nil {
+		log.Printf(
+			"error adding label %s to card %s (%s): %s\n",
+			labelID, card.Name, card.ID, err,
+		)
+		return err
+	}
+	return nil
+}
+
+func (card *Card) RemoveLabel(ctx *TrelloCtx, labelID string) error {
+
+	endpoint := fmt.Sprintf("/cards/%s/idLabels/%s", card.ID, labelID)
+	if _, err := ctx.ApiDelete(endpoint); err != nil {
+		log.Printf(
+			"error removing label %s from card %s (%s): %s\n",
+			labelID, card.Name, card.ID, err,
+		)
+		return err
+	}
+	return nil
+}
+
+// Assign a member to the card.
+func (card *Card) AddMember(ctx *TrelloCtx, memberID string) error {
+
+	endpoint := fmt.Sprintf("/cards/%s/idMembers", card.ID)
+	_, err := ctx.ApiPost(endpoint, url.Values{"value": {memberID}})
+	if err != nil {
+		log.Printf(
+			"error adding member %s to card %s (%s): %s\n",
+			memberID, card.Name, card.ID, err,
+		)
+		return err
+	}
+	return nil
+}
+
+// Unassign a member from the card.
+func (card *Card) RemoveMember(ctx *TrelloCtx, memberID string) error {
+
+	endpoint := fmt.Sprintf("/cards/%s/idMembers/%s", card.ID, memberID)
+	if _, err := ctx.ApiDelete(endpoint); err != nil {
+		log.Printf(
+			"error removing member %s from card %s (%s): %s\n",
+			memberID, card.Name, card.ID, err,
+		)
+		return err
+	}
+	return nil
+}
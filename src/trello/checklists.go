@@ -0,0 +1,111 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+)
+
+type CheckItem struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	State string `json:"state"` // "complete" or "incomplete"
+}
+
+type Checklist struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	CardID     string      `json:"idCard"`
+	CheckItems []CheckItem `json:"checkItems"`
+}
+
+func (card *Card) CreateChecklist(
+	ctx *TrelloCtx,
+	name string,
+) (*Checklist, error) {
+
+	params := url.Values{"idCard": {card.ID}, "name": {name}}
+	checklistRaw, err := ctx.ApiPost("/checklists", params)
+	if err != nil {
+		log.Printf(
+			"error creating checklist %s on card %s (%s): %s\n",
+			name, card.Name, card.ID, err,
+		)
+		return nil, err
+	}
+
+	var checklist Checklist
+	json.Unmarshal(checklistRaw, &checklist)
+	return &checklist, nil
+}
+
+func (checklist *Checklist) AddItem(
+	ctx *TrelloCtx,
+	name string,
+	checked bool,
+) error {
+
+	endpoint := fmt.Sprintf("/checklists/%s/checkItems", checklist.ID)
+	params := url.Values{
+		"name": {name}, "checked": {strconv.FormatBool(checked)},
+	}
+	if _, err := ctx.ApiPost(endpoint, params); err != nil {
+		log.Printf(
+			"error adding item %s to checklist %s (%s): %s\n",
+			name, checklist.Name, checklist.ID, err,
+		)
+		return err
+	}
+	return nil
+}
+
+func (card *Card) GetChecklists(ctx *TrelloCtx) ([]Checklist, error) {
+
+	endpoint := MakeEndpoint(
+		fmt.Sprintf("/cards/%s/checklists", card.ID), nil,
+	)
+	checklistsRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf(
+			"error obtaining checklists for card %s (%s): %s\n",
+			card.Name, card.ID, err,
+		)
+		return nil, err
+	}
+
+	var checklists []Checklist
+	json.Unmarshal(checklistsRaw, &checklists)
+	return checklists, nil
+}
+
+// Set the item's state, on the card holding its checklist, to either
+// "complete" or "incomplete".
+func (item *CheckItem) SetState(
+	ctx *TrelloCtx,
+	cardID string,
+	state string,
+) error {
+
+	endpoint := fmt.Sprintf("/cards/%s/checkItem/%s", cardID, item.ID)
+	_, err := ctx.ApiPut(endpoint, url.Values{"state": {state}})
+	if err != nil {
+		log.Printf(
+			"error setting item %s (%s) on card %s to %s: %s\n",
+			item.Name, item.ID, cardID, state, err,
+		)
+		return err
+	}
+	item.State = state
+	return nil
+}
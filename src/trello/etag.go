@@ -0,0 +1,93 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"errors"
+	"sync"
+)
+
+// How many bytes of responses are kept around for their ETags. Past that,
+// some are dropped, whichever they are, to make room; responses larger than
+// that aren't kept at all.
+const maxETagBytes = 32 << 20
+
+// What a GET request last obtained, had Trello tagged it. Tags are sent
+// along with requests for the same endpoint, as 'If-None-Match', for Trello
+// to answer '304 Not Modified' rather than with what we already have.
+type etagEntry struct {
+	etag string
+	body []byte
+}
+
+// Responses' tags, by cache key, shared by all copies of a context.
+type etagStore struct {
+	lock    sync.Mutex
+	entries map[string]etagEntry
+	size    int
+}
+
+// Returned by ApiGetIfChanged() for responses the caller already has.
+var ErrNotModified = errors.New("not modified")
+
+// Returned by doRequest() on '304 Not Modified'.
+var errNotModified = errors.New("304 Not Modified")
+
+func (s *etagStore) get(key string) (etagEntry, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry, exists := s.entries[key]
+	return entry, exists
+}
+
+func (s *etagStore) put(key string, etag string, body []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.entries == nil {
+		s.entries = make(map[string]etagEntry)
+	}
+	if last, exists := s.entries[key]; exists {
+		delete(s.entries, key)
+		s.size -= len(last.body)
+	}
+	if len(body) > maxETagBytes {
+		return
+	}
+	for victim, entry := range s.entries {
+		if s.size+len(body) <= maxETagBytes {
+			break
+		}
+		delete(s.entries, victim)
+		s.size -= len(entry.body)
+	}
+	s.entries[key] = etagEntry{etag: etag, body: body}
+	s.size += len(body)
+}
+
+// Perform a GET request, as ApiGet() does, unless what it obtains is what
+// was obtained when Trello tagged it as '*etag', in which case it fails with
+// ErrNotModified, so callers may skip going through it again. '*etag' is set
+// to whatever the response is tagged as, if anything.
+func (t *TrelloCtx) ApiGetIfChanged(
+	endpoint string,
+	etag *string,
+) ([]byte, error) {
+
+	body, tag, err := t.apiGetTagged(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if tag != "" && tag == *etag {
+		return nil, ErrNotModified
+	}
+	*etag = tag
+	return body, nil
+}
@@ -0,0 +1,102 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// The store holds no more than maxETagBytes of responses, however many.
+func TestETagStoreBounded(t *testing.T) {
+	s := &etagStore{}
+	body := make([]byte, maxETagBytes/8)
+	for i := 0; i < 32; i++ {
+		s.put(fmt.Sprintf("key %d", i), "tag", body)
+		if s.size > maxETagBytes {
+			t.Fatalf("put %d: size %d over %d", i, s.size, maxETagBytes)
+		}
+	}
+	if _, exists := s.get("key 31"); !exists {
+		t.Error("last response put not kept")
+	}
+
+	// replacing a response accounts for the one replaced.
+	s.put("key 31", "tag2", body[:1])
+	total := 0
+	for _, entry := range s.entries {
+		total += len(entry.body)
+	}
+	if total != s.size {
+		t.Errorf("size %d, responses hold %d", s.size, total)
+	}
+
+	s.put("huge", "tag", make([]byte, maxETagBytes+1))
+	if _, exists := s.get("huge"); exists {
+		t.Error("response larger than the store kept")
+	}
+}
+
+// Reads are conditional on the last response's ETag, that response being
+// served again should Trello find it unchanged.
+func TestConditionalGet(t *testing.T) {
+	t.Setenv("TRELLOFS_TEST", "")
+	tagged := http.Header{"Etag": {`"v1"`}}
+	transport := &scriptedTransport{responses: []*http.Response{
+		scriptedResponse(http.StatusOK, tagged, `{"id": "b1"}`),
+		scriptedResponse(http.StatusNotModified, tagged, ""),
+		scriptedResponse(http.StatusOK, http.Header{}, `{"id": "b1", "x": 1}`),
+	}}
+	ctx := Trello("me", "key", "etag-token")
+	ctx.client.Transport = transport
+
+	for i, want := range []string{
+		`{"id": "b1"}`, `{"id": "b1"}`, `{"id": "b1", "x": 1}`,
+	} {
+		body, err := ctx.ApiGet("/boards/b1")
+		if err != nil || string(body) != want {
+			t.Errorf("read %d obtained %q (%v), want %q", i, body, err, want)
+		}
+	}
+	for i, want := range []string{"", `"v1"`, `"v1"`} {
+		got := transport.responses[i].Request.Header.Get("If-None-Match")
+		if got != want {
+			t.Errorf("read %d made conditional on %q, want %q", i, got, want)
+		}
+	}
+}
+
+// Responses are kept, and served, from concurrent requests alike.
+func TestETagStoreConcurrent(t *testing.T) {
+	s := &etagStore{}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("key %d", i)
+			for j := 0; j < 100; j++ {
+				tag := fmt.Sprintf("tag %d", j)
+				s.put(key, tag, make([]byte, maxETagBytes/16))
+				if entry, exists := s.get(key); exists &&
+					entry.etag != tag {
+					t.Errorf("%s tagged %q, want %q", key, entry.etag, tag)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if s.size > maxETagBytes {
+		t.Errorf("size %d over %d", s.size, maxETagBytes)
+	}
+}
@@ -0,0 +1,35 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+// A board as exported by Trello's 'Export as JSON', limited to what we are
+// able to reconstruct.
+type BoardExport struct {
+	Name   string  `json:"name"`
+	Desc   string  `json:"desc"`
+	Labels []Label `json:"labels"`
+	Lists  []struct {
+		ID     string  `json:"id"`
+		Name   string  `json:"name"`
+		Closed bool    `json:"closed"`
+		Pos    float64 `json:"pos"`
+	} `json:"lists"`
+	Cards []struct {
+		ID       string   `json:"id"`
+		Name     string   `json:"name"`
+		Desc     string   `json:"desc"`
+		ListID   string   `json:"idList"`
+		LabelIDs []string `json:"idLabels"`
+		Due      string   `json:"due"`
+		Closed   bool     `json:"closed"`
+		Pos      float64  `json:"pos"`
+	} `json:"cards"`
+	Checklists []Checklist `json:"checklists"`
+}
@@ -0,0 +1,57 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"context"
+	"sync"
+)
+
+// Requests may note why they failed in the context they're made with, so
+// whoever made them may tell why, rather than only that they did, e.g. when
+// reporting a failed operation.
+type failureKey struct{}
+
+type failureNote struct {
+	lock sync.Mutex
+	err  error
+}
+
+// Obtain a context in which requests note why they failed; see
+// LastFailure().
+func NoteFailures(ctx context.Context) context.Context {
+	return context.WithValue(ctx, failureKey{}, &failureNote{})
+}
+
+// Why the last request made with 'ctx' to fail did, or nil if none failed,
+// or 'ctx' wasn't obtained through NoteFailures(). Requests cancelled
+// aren't noted.
+func LastFailure(ctx context.Context) error {
+	note, exists := ctx.Value(failureKey{}).(*failureNote)
+	if !exists {
+		return nil
+	}
+	note.lock.Lock()
+	defer note.lock.Unlock()
+	return note.err
+}
+
+func (t *TrelloCtx) noteFailure(err error) {
+	if err == nil || IsCancelled(err) {
+		return
+	}
+	note, exists := t.context.Value(failureKey{}).(*failureNote)
+	if !exists {
+		return
+	}
+	note.lock.Lock()
+	defer note.lock.Unlock()
+	note.err = err
+}
@@ -0,0 +1,71 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"context"
+	"sync"
+)
+
+// A request in flight, which others asking for the same may wait on.
+type flight struct {
+	done chan struct{}
+	body []byte
+	etag string
+	err  error
+}
+
+// Coalesces identical concurrent requests into one, with all callers
+// sharing its result.
+type flightGroup struct {
+	lock    sync.Mutex
+	flights map[string]*flight
+}
+
+// Perform a request, or wait for the same one already in flight, unless
+// 'ctx' is done first. Should whoever's request was waited on give up on it,
+// it's made anew rather than failing those waiting.
+func (g *flightGroup) do(
+	ctx context.Context,
+	key string,
+	request func() ([]byte, string, error),
+) ([]byte, string, error) {
+
+	for {
+		g.lock.Lock()
+		if g.flights == nil {
+			g.flights = make(map[string]*flight)
+		}
+		if f, exists := g.flights[key]; exists {
+			g.lock.Unlock()
+			select {
+			case <-f.done:
+			case <-ctx.Done():
+				return nil, "", ctx.Err()
+			}
+			if IsCancelled(f.err) && ctx.Err() == nil {
+				continue
+			}
+			return f.body, f.etag, f.err
+		}
+		f := &flight{done: make(chan struct{})}
+		g.flights[key] = f
+		g.lock.Unlock()
+
+		f.body, f.etag, f.err = request()
+		close(f.done)
+
+		g.lock.Lock()
+		delete(g.flights, key)
+		g.lock.Unlock()
+
+		return f.body, f.etag, f.err
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Identical requests made while one is in flight share its result, rather
+// than being made again.
+func TestFlightGroupCoalesces(t *testing.T) {
+	g := &flightGroup{}
+	var made int32
+	release := make(chan struct{})
+	request := func() ([]byte, string, error) {
+		atomic.AddInt32(&made, 1)
+		<-release
+		return []byte("board"), "tag", nil
+	}
+
+	const callers = 8
+	var wg sync.WaitGroup
+	results := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body, etag, err := g.do(context.Background(), "/boards/b1", request)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = string(body) + "/" + etag
+		}()
+	}
+	// give every caller the chance to find the request in flight.
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if atomic.LoadInt32(&made) == 1 {
+			time.Sleep(50 * time.Millisecond)
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("request never made")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if made := atomic.LoadInt32(&made); made != 1 {
+		t.Errorf("request made %d times, want once", made)
+	}
+	for i, result := range results {
+		if result != "board/tag" {
+			t.Errorf("caller %d obtained %q", i, result)
+		}
+	}
+	if len(g.flights) != 0 {
+		t.Errorf("%d requests left in flight", len(g.flights))
+	}
+
+	// once done, the same request is made anew.
+	g.do(context.Background(), "/boards/b1", request)
+	if made := atomic.LoadInt32(&made); made != 2 {
+		t.Errorf("request made %d times, want twice", made)
+	}
+}
+
+// Those waiting on a request may give up on it, and those it's given up on
+// by whoever made it make it themselves.
+func TestFlightGroupCancelled(t *testing.T) {
+	g := &flightGroup{}
+	inFlight := make(chan struct{})
+	giveUp := make(chan struct{})
+	first := make(chan error, 1)
+	go func() {
+		_, _, err := g.do(context.Background(), "key",
+			func() ([]byte, string, error) {
+				close(inFlight)
+				<-giveUp
+				return nil, "", context.Canceled
+			},
+		)
+		first <- err
+	}()
+	<-inFlight
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := g.do(ctx, "key", func() ([]byte, string, error) {
+		t.Error("request made by a caller having given up")
+		return nil, "", nil
+	})
+	if err != context.Canceled {
+		t.Errorf("caller having given up obtained %v", err)
+	}
+
+	waiter := make(chan string, 1)
+	go func() {
+		body, _, err := g.do(context.Background(), "key",
+			func() ([]byte, string, error) {
+				return []byte("again"), "", nil
+			},
+		)
+		if err != nil {
+			t.Error(err)
+		}
+		waiter <- string(body)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(giveUp)
+	if err := <-first; !IsCancelled(err) {
+		t.Errorf("request given up on returned %v", err)
+	}
+	select {
+	case body := <-waiter:
+		if body != "again" {
+			t.Errorf("waiter obtained %q, want the request made anew", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiter never obtained a result")
+	}
+}
@@ -0,0 +1,248 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Serves GET requests from a board's JSON export, as obtained through
+// Trello's 'Export as JSON', rather than from Trello, so that archived boards
+// may be browsed without network access. The board is presented as the only
+// board of a workspace of its own, and nothing may be changed.
+type ExportSource struct {
+	Workspace Workspace
+
+	// responses by endpoint path, with whichever member as 'me'.
+	responses map[string][]byte
+
+	// the board's actions, most recent first, as served filtered.
+	actions []exportAction
+}
+
+type exportAction struct {
+	Type string `json:"type"`
+	Data struct {
+		Card ActionEntity           `json:"card"`
+		Old  map[string]interface{} `json:"old"`
+	} `json:"data"`
+
+	raw json.RawMessage
+}
+
+// Load a board's JSON export, to be served through SetExport().
+func LoadExport(path string) (*ExportSource, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var board map[string]interface{}
+	if err := json.Unmarshal(contents, &board); err != nil {
+		return nil, err
+	}
+	var export struct {
+		ID             string                   `json:"id"`
+		Name           string                   `json:"name"`
+		ShortLink      string                   `json:"shortLink"`
+		OrganizationID string                   `json:"idOrganization"`
+		Lists          []map[string]interface{} `json:"lists"`
+		Cards          []map[string]interface{} `json:"cards"`
+		Checklists     []map[string]interface{} `json:"checklists"`
+		Labels         []map[string]interface{} `json:"labels"`
+		Members        []map[string]interface{} `json:"members"`
+		Actions        []json.RawMessage        `json:"actions"`
+	}
+	json.Unmarshal(contents, &export)
+	if export.ID == "" {
+		return nil, fmt.Errorf("%s is not a board export", path)
+	}
+
+	src := &ExportSource{
+		Workspace: Workspace{
+			ID:          export.OrganizationID,
+			Name:        "export",
+			DisplayName: export.Name,
+		},
+		responses: make(map[string][]byte),
+	}
+	if src.Workspace.ID == "" {
+		src.Workspace.ID = "export"
+	}
+	for _, raw := range export.Actions {
+		action := exportAction{raw: raw}
+		json.Unmarshal(raw, &action)
+		src.actions = append(src.actions, action)
+	}
+
+	// what's served through endpoints of their own isn't part of the board.
+	for _, key := range []string{
+		"lists", "cards", "checklists", "labels", "members", "actions",
+	} {
+		delete(board, key)
+	}
+	board["idOrganization"] = src.Workspace.ID
+
+	// cards come with their checklists and comments nested, as when
+	// obtaining their contents in bulk.
+	var cards []map[string]interface{}
+	var openCards []map[string]interface{}
+	for _, card := range export.Cards {
+		id, _ := card["id"].(string)
+		checklists := make([]map[string]interface{}, 0)
+		for _, checklist := range export.Checklists {
+			if checklist["idCard"] == id {
+				checklists = append(checklists, checklist)
+			}
+		}
+		card["checklists"] = checklists
+		card["actions"] = src.cardActions(id, "commentCard")
+		if _, exists := card["attachments"]; !exists {
+			card["attachments"] = make([]interface{}, 0)
+		}
+		cards = append(cards, card)
+		if !exportClosed(card) {
+			openCards = append(openCards, card)
+		}
+
+		for _, key := range []string{id, exportString(card, "shortLink")} {
+			if key == "" {
+				continue
+			}
+			src.put("/cards/"+key, card)
+			src.put("/cards/"+key+"/checklists", checklists)
+			src.put("/cards/"+key+"/attachments", card["attachments"])
+		}
+	}
+	var openLists []map[string]interface{}
+	for _, list := range export.Lists {
+		if exportClosed(list) {
+			continue
+		}
+		openLists = append(openLists, list)
+		src.put("/lists/"+exportString(list, "id"), list)
+		listCards := make([]map[string]interface{}, 0)
+		for _, card := range openCards {
+			if card["idList"] == list["id"] {
+				listCards = append(listCards, card)
+			}
+		}
+		src.put("/lists/"+exportString(list, "id")+"/cards", listCards)
+	}
+
+	for _, key := range []string{export.ID, export.ShortLink} {
+		if key == "" {
+			continue
+		}
+		src.put("/boards/"+key, board)
+		src.put("/boards/"+key+"/cards", openCards)
+		src.put("/boards/"+key+"/cards/all", cards)
+		src.put("/boards/"+key+"/lists", openLists)
+		src.put("/boards/"+key+"/labels", export.Labels)
+		src.put("/boards/"+key+"/members", export.Members)
+	}
+	src.put("/organizations/"+src.Workspace.ID, src.Workspace)
+	src.put(
+		"/organizations/"+src.Workspace.ID+"/boards",
+		[]map[string]interface{}{board},
+	)
+	src.put("/members/me/organizations", []Workspace{src.Workspace})
+	src.put("/members/me/savedSearches", make([]interface{}, 0))
+	src.put("/members/me/cards", make([]interface{}, 0))
+	src.put("/members/me/notifications", make([]interface{}, 0))
+	src.put("/search", map[string]interface{}{"cards": []interface{}{}})
+	return src, nil
+}
+
+func exportString(obj map[string]interface{}, key string) string {
+	value, _ := obj[key].(string)
+	return value
+}
+
+func exportClosed(obj map[string]interface{}) bool {
+	closed, _ := obj["closed"].(bool)
+	return closed
+}
+
+func (src *ExportSource) put(path string, value interface{}) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	src.responses[path] = body
+}
+
+// Obtain the actions on a card, or on the whole board if 'cardID' is empty,
+// matching 'filter' as understood by Trello: comma-separated action types,
+// optionally as '<type>:<field>' for those changing a given field.
+func (src *ExportSource) cardActions(
+	cardID string,
+	filter string,
+) []json.RawMessage {
+
+	types := strings.Split(filter, ",")
+	actions := make([]json.RawMessage, 0)
+	for _, action := range src.actions {
+		if cardID != "" && action.Data.Card.ID != cardID {
+			continue
+		}
+		matches := filter == ""
+		for _, kind := range types {
+			spec := strings.SplitN(kind, ":", 2)
+			if spec[0] != action.Type {
+				continue
+			} else if len(spec) == 2 {
+				if _, changed := action.Data.Old[spec[1]]; !changed {
+					continue
+				}
+			}
+			matches = true
+		}
+		if matches {
+			actions = append(actions, action.raw)
+		}
+	}
+	return actions
+}
+
+func (src *ExportSource) get(endpoint string) ([]byte, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	path := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(path) > 1 && path[0] == "members" {
+		path[1] = "me"
+	}
+
+	if len(path) == 3 && path[2] == "actions" {
+		filter := u.Query().Get("filter")
+		switch path[0] {
+		case "boards", "organizations":
+			return json.Marshal(src.cardActions("", filter))
+		case "cards":
+			return json.Marshal(src.cardActions(path[1], filter))
+		}
+	}
+	if body, exists := src.responses["/"+strings.Join(path, "/")]; exists {
+		return body, nil
+	}
+	return nil, &APIError{
+		Method:     "GET",
+		Endpoint:   endpoint,
+		StatusCode: http.StatusNotFound,
+		Status:     "404 Not Found",
+		Body:       "not part of the export",
+	}
+}
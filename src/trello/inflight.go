@@ -0,0 +1,72 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import "sync"
+
+const DefaultMaxInflight = 10
+
+// Limits how many requests to Trello may be in flight at any given time,
+// across all contexts. Unlike a channel-based semaphore, its size may be
+// adjusted while requests are in flight.
+type inflightSem struct {
+	lock sync.Mutex
+	cond *sync.Cond
+
+	max      int
+	inflight int
+}
+
+var inflight = newInflightSem(DefaultMaxInflight)
+
+func newInflightSem(max int) *inflightSem {
+	sem := &inflightSem{max: max}
+	sem.cond = sync.NewCond(&sem.lock)
+	return sem
+}
+
+func (sem *inflightSem) acquire() {
+	sem.lock.Lock()
+	defer sem.lock.Unlock()
+	for sem.inflight >= sem.max {
+		sem.cond.Wait()
+	}
+	sem.inflight++
+}
+
+func (sem *inflightSem) release() {
+	sem.lock.Lock()
+	defer sem.lock.Unlock()
+	sem.inflight--
+	sem.cond.Broadcast()
+}
+
+// Adjust how many requests may be in flight at once.
+func (sem *inflightSem) setMax(max int) {
+	if max < 1 {
+		max = 1
+	}
+	sem.lock.Lock()
+	defer sem.lock.Unlock()
+	sem.max = max
+	sem.cond.Broadcast()
+}
+
+// Set how many requests may be in flight at once. Lowering it does not
+// affect requests already in flight.
+func SetMaxInflight(max int) {
+	inflight.setMax(max)
+}
+
+func GetMaxInflight() int {
+	inflight.lock.Lock()
+	defer inflight.lock.Unlock()
+	return inflight.max
+}
@@ -0,0 +1,83 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// No more requests are in flight at once than allowed, even as that's
+// adjusted while they're waiting.
+func TestInflightSem(t *testing.T) {
+	sem := newInflightSem(2)
+	var lock sync.Mutex
+	running, most := 0, 0
+	started := make(chan bool, 6)
+	stop := make(chan bool)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem.acquire()
+			defer sem.release()
+			lock.Lock()
+			running++
+			if running > most {
+				most = running
+			}
+			lock.Unlock()
+			started <- true
+			<-stop
+			lock.Lock()
+			running--
+			lock.Unlock()
+		}()
+	}
+	waitStarted := func(n int) {
+		for i := 0; i < n; i++ {
+			select {
+			case <-started:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%d of %d requests started", i, n)
+			}
+		}
+		select {
+		case <-started:
+			t.Fatal("more requests in flight than allowed")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	waitStarted(2)
+
+	sem.setMax(4)
+	waitStarted(2)
+
+	close(stop)
+	wg.Wait()
+	if most != 4 {
+		t.Errorf("%d requests in flight at most, want 4", most)
+	}
+}
+
+func TestSetMaxInflight(t *testing.T) {
+	defer SetMaxInflight(GetMaxInflight())
+	SetMaxInflight(3)
+	if max := GetMaxInflight(); max != 3 {
+		t.Errorf("max inflight %d, want 3", max)
+	}
+	SetMaxInflight(0)
+	if max := GetMaxInflight(); max != 1 {
+		t.Errorf("max inflight %d once set to 0, want 1", max)
+	}
+}
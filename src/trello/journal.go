@@ -0,0 +1,154 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A request changing a card, queued for Trello couldn't be reached when it
+// was made, to be made again once it can.
+type PendingOp struct {
+	// assigned by the journal, in the order requests were queued.
+	Seq uint64 `json:"seq"`
+
+	// the context the request is to be made with, as told by its token,
+	// without the token itself ending up on disk; see TrelloCtx.owner().
+	Owner string `json:"owner"`
+
+	Queued   time.Time  `json:"queued"`
+	Method   string     `json:"method"`
+	Endpoint string     `json:"endpoint"`
+	Params   url.Values `json:"params,omitempty"`
+
+	// times the request was made again and failed for Trello still being
+	// unreachable, and why it last did.
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Persists requests changing cards made while Trello can't be reached, so
+// they're not lost, nor fail, and may be made once it can; see the journal
+// package.
+type Journal interface {
+	// Queue a request, assigning it its sequence number.
+	Append(op *PendingOp) error
+	// Replace what's kept of a request already queued.
+	Update(op *PendingOp) error
+	// Drop a request, once made, or refused.
+	Remove(op *PendingOp) error
+	// The requests queued, in the order they were.
+	Pending() []*PendingOp
+}
+
+// Queue requests changing cards in 'journal' while Trello can't be reached,
+// rather than failing them; see ReplayPending().
+func (t *TrelloCtx) SetJournal(journal Journal) {
+	t.journal = journal
+}
+
+// The requests queued, by any context sharing this one's journal, in the
+// order they were; none if there's no journal.
+func (t *TrelloCtx) Pending() []*PendingOp {
+	if t.journal == nil {
+		return nil
+	}
+	return t.journal.Pending()
+}
+
+// Only requests changing existing cards are queued, e.g. editing, moving,
+// labelling, or commenting on them: whatever creates entities is expected
+// to obtain them, IDs and all, right away.
+func isJournaled(method string, endpoint string) bool {
+	if method == "GET" || !strings.HasPrefix(endpoint, "/cards/") {
+		return false
+	}
+	return strings.TrimPrefix(endpoint, "/cards/") != ""
+}
+
+// Whether this context has requests queued, which those made meanwhile must
+// queue behind, so they're made in order.
+func (t *TrelloCtx) hasPending() bool {
+	owner := t.owner()
+	for _, op := range t.journal.Pending() {
+		if op.Owner == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// Queue a request, for it to be made later on.
+func (t *TrelloCtx) queue(
+	method string,
+	endpoint string,
+	params url.Values,
+	cause error,
+) error {
+	op := &PendingOp{
+		Owner:    t.owner(),
+		Queued:   time.Now(),
+		Method:   method,
+		Endpoint: endpoint,
+		Params:   params,
+	}
+	if cause != nil {
+		op.LastError = cause.Error()
+	}
+	if err := t.journal.Append(op); err != nil {
+		return err
+	}
+	log.Printf("queued %s %s as #%d\n", method, endpoint, op.Seq)
+	return nil
+}
+
+// Make the requests this context queued, in order, until they're all made or
+// Trello is still unreachable, in which case they're tried again on the next
+// call. Requests Trello refuses are dropped. Returns how many requests were
+// made.
+func (t *TrelloCtx) ReplayPending() int {
+	if t.journal == nil {
+		return 0
+	}
+	owner := t.owner()
+	made := 0
+	for _, op := range t.journal.Pending() {
+		if op.Owner != owner {
+			continue
+		}
+		_, err := t.apiRequest(op.Method, op.Endpoint, op.Params)
+		if err != nil && isUnreachable(err) {
+			if IsCancelled(err) {
+				return made
+			}
+			op.Attempts++
+			op.LastError = err.Error()
+			if err := t.journal.Update(op); err != nil {
+				log.Printf("error updating queued #%d: %s\n", op.Seq, err)
+			}
+			return made
+		} else if err != nil {
+			log.Printf(
+				"dropping queued #%d, %s %s, refused: %s\n",
+				op.Seq, op.Method, op.Endpoint, err,
+			)
+		} else {
+			made++
+		}
+		if err := t.journal.Remove(op); err != nil {
+			log.Printf("error removing queued #%d: %s\n", op.Seq, err)
+			return made
+		}
+	}
+	return made
+}
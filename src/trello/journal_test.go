@@ -0,0 +1,133 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// Keeps requests queued in memory rather than on disk.
+type memJournal struct {
+	ops []*PendingOp
+	seq uint64
+}
+
+func (j *memJournal) Append(op *PendingOp) error {
+	j.seq++
+	op.Seq = j.seq
+	queued := *op
+	j.ops = append(j.ops, &queued)
+	return nil
+}
+
+func (j *memJournal) Update(op *PendingOp) error {
+	for i, queued := range j.ops {
+		if queued.Seq == op.Seq {
+			updated := *op
+			j.ops[i] = &updated
+			return nil
+		}
+	}
+	return os.ErrNotExist
+}
+
+func (j *memJournal) Remove(op *PendingOp) error {
+	for i, queued := range j.ops {
+		if queued.Seq == op.Seq {
+			j.ops = append(j.ops[:i], j.ops[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (j *memJournal) Pending() []*PendingOp {
+	ops := make([]*PendingOp, len(j.ops))
+	for i, op := range j.ops {
+		copied := *op
+		ops[i] = &copied
+	}
+	return ops
+}
+
+// Changes to cards made while Trello can't be reached are queued, as are
+// those made after them, and made in order once it can; others fail.
+func TestJournaledRequests(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	t.Setenv("TRELLOFS_TEST", "")
+
+	unavailable := func() *http.Response {
+		return scriptedResponse(
+			http.StatusServiceUnavailable, http.Header{}, "down",
+		)
+	}
+	ok := func() *http.Response {
+		return scriptedResponse(http.StatusOK, http.Header{}, `{}`)
+	}
+	transport := &scriptedTransport{responses: []*http.Response{
+		unavailable(), unavailable(), unavailable(),
+		ok(), ok(),
+	}}
+	journal := &memJournal{}
+	ctx := Trello("me", "key", "journal-token")
+	ctx.client.Transport = transport
+	ctx.SetJournal(journal)
+
+	desc := url.Values{"desc": {"new"}}
+	if _, err := ctx.ApiPut("/cards/c1", desc); err != nil {
+		t.Fatalf("change to a card failed with Trello down: %v", err)
+	}
+	// queued behind the first, without being made.
+	if _, err := ctx.ApiDelete("/cards/c1/idLabels/l1"); err != nil {
+		t.Fatalf("change to a card failed: %v", err)
+	}
+	if _, err := ctx.ApiPost("/boards", nil); err == nil {
+		t.Errorf("board created with Trello down")
+	}
+	if made := len(transport.made); made != 2 {
+		t.Errorf("%d requests made, want 2", made)
+	}
+
+	// still down.
+	if made := ctx.ReplayPending(); made != 0 {
+		t.Errorf("%d queued requests made with Trello down", made)
+	}
+	pending := ctx.Pending()
+	if len(pending) != 2 || pending[0].Attempts != 1 ||
+		pending[0].LastError == "" || pending[1].Attempts != 0 {
+		t.Fatalf("pending once tried again: %+v", pending)
+	}
+
+	if made := ctx.ReplayPending(); made != 2 {
+		t.Errorf("%d queued requests made, want 2", made)
+	}
+	if pending := ctx.Pending(); len(pending) != 0 {
+		t.Errorf("%d requests still pending", len(pending))
+	}
+	var replayed []string
+	for _, resp := range transport.responses[3:] {
+		replayed = append(replayed, resp.Request.Method+" "+
+			resp.Request.URL.Path+"?"+resp.Request.URL.RawQuery)
+	}
+	want := []string{
+		"PUT /1/cards/c1?desc=new",
+		"DELETE /1/cards/c1/idLabels/l1?",
+	}
+	if !reflect.DeepEqual(replayed, want) {
+		t.Errorf("replayed %q, want %q", replayed, want)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+)
+
+type Label struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+func (board *Board) GetLabels(ctx *TrelloCtx) ([]Label, error) {
+
+	endpoint := MakeEndpoint(
+		fmt.Sprintf("/boards/%s/labels", board.ID),
+		[]string{"id", "name", "color"},
+	)
+	labelsRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf(
+			"error obtaining labels for board %s (%s): %s\n",
+			board.Name, board.ID, err,
+		)
+		return nil, err
+	}
+
+	var labels []Label
+	json.Unmarshal(labelsRaw, &labels)
+	return labels, nil
+}
+
+func (board *Board) CreateLabel(
+	ctx *TrelloCtx,
+	name string,
+	color string,
+) (*Label, error) {
+
+	params := url.Values{"idBoard": {board.ID}, "name": {name}}
+	if color != "" {
+		params.Set("color", color)
+	}
+	labelRaw, err := ctx.ApiPost("/labels", params)
+	if err != nil {
+		log.Printf(
+			"error creating label %s on board %s (%s): %s\n",
+			name, board.Name, board.ID, err,
+		)
+		return nil, err
+	}
+
+	var label Label
+	json.Unmarshal(labelRaw, &label)
+	return &label, nil
+}
@@ -0,0 +1,79 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+type Member struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	FullName string `json:"fullName"`
+}
+
+func (board *Board) GetMembers(ctx *TrelloCtx) ([]Member, error) {
+
+	endpoint := MakeEndpoint(
+		fmt.Sprintf("/boards/%s/members", board.ID),
+		[]string{"id", "username", "fullName"},
+	)
+	membersRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf(
+			"error obtaining members for board %s (%s): %s\n",
+			board.Name, board.ID, err,
+		)
+		return nil, err
+	}
+
+	var members []Member
+	json.Unmarshal(membersRaw, &members)
+	return members, nil
+}
+
+// Add a member to the board, either by their username or member ID, or by
+// their email address, in which case Trello will send them an invitation.
+func (board *Board) AddMember(ctx *TrelloCtx, who string) error {
+
+	var err error
+	if strings.Contains(who, "@") {
+		endpoint := fmt.Sprintf("/boards/%s/members", board.ID)
+		_, err = ctx.ApiPut(endpoint, url.Values{"email": {who}})
+	} else {
+		endpoint := fmt.Sprintf("/boards/%s/members/%s", board.ID, who)
+		_, err = ctx.ApiPut(endpoint, url.Values{"type": {"normal"}})
+	}
+	if err != nil {
+		log.Printf(
+			"error adding member %s to board %s (%s): %s\n",
+			who, board.Name, board.ID, err,
+		)
+		return err
+	}
+	return nil
+}
+
+func (board *Board) RemoveMember(ctx *TrelloCtx, memberID string) error {
+
+	endpoint := fmt.Sprintf("/boards/%s/members/%s", board.ID, memberID)
+	if _, err := ctx.ApiDelete(endpoint); err != nil {
+		log.Printf(
+			"error removing member %s from board %s (%s): %s\n",
+			memberID, board.Name, board.ID, err,
+		)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+type NotificationData struct {
+	Text  string       `json:"text"`
+	Card  ActionEntity `json:"card"`
+	Board ActionEntity `json:"board"`
+	List  ActionEntity `json:"list"`
+}
+
+type Notification struct {
+	ID            string           `json:"id"`
+	Type          string           `json:"type"`
+	Date          string           `json:"date"`
+	Unread        bool             `json:"unread"`
+	MemberCreator ActionMember     `json:"memberCreator"`
+	Data          NotificationData `json:"data"`
+}
+
+// Obtain the user's unread notifications of the given types (e.g.,
+// 'addedToCard', 'cardDueSoon'), most recent first.
+func GetNotifications(
+	ctx *TrelloCtx,
+	types []string,
+) ([]Notification, error) {
+
+	endpoint := fmt.Sprintf(
+		"/members/%s/notifications?read_filter=unread&limit=100", ctx.ID,
+	)
+	if len(types) > 0 {
+		endpoint += "&filter=" + strings.Join(types, ",")
+	}
+	notificationsRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf("error obtaining notifications for %s: %s\n", ctx.ID, err)
+		return nil, err
+	}
+
+	var notifications []Notification
+	json.Unmarshal(notificationsRaw, &notifications)
+	return notifications, nil
+}
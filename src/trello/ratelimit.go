@@ -0,0 +1,224 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Trello allows 100 requests per 10 seconds per token.
+const rateLimitWindow = 10 * time.Second
+const rateLimitRequests = 100
+
+// Keeps track of requests made with a token, and of what Trello reports as
+// being left of its budget, to tell how close we are to being rate limited.
+type rateTracker struct {
+	lock sync.Mutex
+
+	sent []time.Time
+
+	// as reported by Trello on the last response, if any.
+	remaining  int
+	max        int
+	reportedAt time.Time
+}
+
+func (r *rateTracker) prune(now time.Time) {
+	i := 0
+	for i < len(r.sent) && now.Sub(r.sent[i]) >= rateLimitWindow {
+		i++
+	}
+	r.sent = r.sent[i:]
+}
+
+func (r *rateTracker) record() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	r.prune(now)
+	r.sent = append(r.sent, now)
+}
+
+func (r *rateTracker) update(resp *http.Response) {
+	remaining, err := strconv.Atoi(
+		resp.Header.Get("X-Rate-Limit-Api-Token-Remaining"),
+	)
+	if err != nil {
+		return
+	}
+	max, err := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Api-Token-Max"))
+	if err != nil || max <= 0 {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.remaining = remaining
+	r.max = max
+	r.reportedAt = time.Now()
+}
+
+// Fraction of the token's request budget left in the current window, from
+// 0 (about to be rate limited) to 1.
+func (t *TrelloCtx) Budget() float64 {
+	r := t.rate
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	r.prune(now)
+	budget := 1.0 - float64(len(r.sent))/rateLimitRequests
+	if r.max > 0 && now.Sub(r.reportedAt) < rateLimitWindow {
+		reported := float64(r.remaining) / float64(r.max)
+		if reported < budget {
+			budget = reported
+		}
+	}
+	if budget < 0 {
+		budget = 0
+	}
+	return budget
+}
+
+// Paces requests made with a token to Trello's limits, allowing bursts of up
+// to a window's worth of requests, refilled at the rate Trello allows.
+type tokenBucket struct {
+	lock     sync.Mutex
+	tokens   float64
+	refilled time.Time
+}
+
+// Buckets are per token, rather than per context, as that's what Trello
+// limits; workspaces may well share a token.
+var buckets = struct {
+	lock    sync.Mutex
+	byToken map[string]*tokenBucket
+}{byToken: make(map[string]*tokenBucket)}
+
+func bucketFor(token string) *tokenBucket {
+	buckets.lock.Lock()
+	defer buckets.lock.Unlock()
+
+	bucket, exists := buckets.byToken[token]
+	if !exists {
+		bucket = &tokenBucket{
+			tokens:   rateLimitRequests,
+			refilled: time.Now(),
+		}
+		buckets.byToken[token] = bucket
+	}
+	return bucket
+}
+
+// Take a token, waiting for one to be available if need be, unless 'ctx' is
+// done first.
+func (b *tokenBucket) take(ctx context.Context) error {
+	rate := rateLimitRequests / rateLimitWindow.Seconds()
+	for {
+		b.lock.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.refilled).Seconds() * rate
+		if b.tokens > rateLimitRequests {
+			b.tokens = rateLimitRequests
+		}
+		b.refilled = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.lock.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		b.lock.Unlock()
+		if err := sleepCtx(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// Sleep for 'd', or until 'ctx' is done, whichever comes first, in which
+// case its error is returned.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Wait until a request may be sent without exceeding Trello's limits, and
+// account for it, unless the request is cancelled meanwhile, or already
+// was.
+func (t *TrelloCtx) throttle() error {
+	if err := t.context.Err(); err != nil {
+		return err
+	}
+	if err := bucketFor(t.Token).take(t.context); err != nil {
+		return err
+	}
+	t.rate.record()
+	return nil
+}
+
+// Requests refused for exceeding the rate limits are retried, up to
+// rateLimitRetries times, after waiting for as long as Trello asks, or
+// otherwise backing off exponentially from rateLimitBackoff. So are those
+// failing for Trello being unreachable, up to the context's retries.
+const rateLimitRetries = 5
+const rateLimitBackoff = time.Second
+const rateLimitMaxBackoff = 30 * time.Second
+
+// How long to wait before retrying a failed request, or false if it
+// shouldn't be retried.
+func (t *TrelloCtx) retryDelay(
+	endpoint string,
+	err error,
+	attempt int,
+) (time.Duration, bool) {
+	if err == nil || IsCancelled(err) {
+		return 0, false
+	}
+	apiErr, isAPIErr := err.(*APIError)
+	if isAPIErr && apiErr.IsRateLimited() {
+		if attempt >= rateLimitRetries {
+			return 0, false
+		}
+		delay := apiErr.RetryAfter
+		if delay <= 0 {
+			delay = backoff(attempt)
+		}
+		log.Printf("rate limited on %s, retrying in %s\n", endpoint, delay)
+		return delay, true
+	}
+	if !isUnreachable(err) || attempt >= t.retries {
+		return 0, false
+	}
+	delay := backoff(attempt)
+	log.Printf(
+		"error requesting %s, retrying in %s: %s\n", endpoint, delay, err,
+	)
+	return delay, true
+}
+
+func backoff(attempt int) time.Duration {
+	delay := rateLimitBackoff << uint(attempt)
+	if delay > rateLimitMaxBackoff {
+		delay = rateLimitMaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,151 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A token's budget is what's left of its window's requests, or less if
+// that's what Trello last reported.
+func TestBudget(t *testing.T) {
+	ctx := &TrelloCtx{rate: &rateTracker{}}
+	if budget := ctx.Budget(); budget != 1.0 {
+		t.Errorf("budget %.2f before any requests, want 1", budget)
+	}
+	for i := 0; i < rateLimitRequests*3/4; i++ {
+		ctx.rate.record()
+	}
+	if budget := ctx.Budget(); budget != 0.25 {
+		t.Errorf("budget %.2f after 3/4 of the requests, want 0.25", budget)
+	}
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("X-Rate-Limit-Api-Token-Remaining", "10")
+	resp.Header.Set("X-Rate-Limit-Api-Token-Max", "100")
+	ctx.rate.update(resp)
+	if budget := ctx.Budget(); budget != 0.1 {
+		t.Errorf("budget %.2f, Trello reporting 10%% left", budget)
+	}
+
+	// requests, and reports, age out of the window.
+	ago := time.Now().Add(-rateLimitWindow)
+	for i := range ctx.rate.sent {
+		ctx.rate.sent[i] = ago
+	}
+	ctx.rate.reportedAt = ago
+	if budget := ctx.Budget(); budget != 1.0 {
+		t.Errorf("budget %.2f once the window's over, want 1", budget)
+	}
+}
+
+// Answers requests as given, in turn, recording when each was made.
+type scriptedTransport struct {
+	responses []*http.Response
+	made      []time.Time
+}
+
+func (s *scriptedTransport) RoundTrip(
+	req *http.Request,
+) (*http.Response, error) {
+	resp := s.responses[len(s.made)]
+	s.made = append(s.made, time.Now())
+	resp.Request = req
+	return resp, nil
+}
+
+func scriptedResponse(
+	status int,
+	header http.Header,
+	body string,
+) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// Reads refused for exceeding the rate limits are retried once Trello says
+// they may be, while those refused for other reasons fail straight away,
+// with a typed error.
+func TestRetryRateLimited(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	transport := &scriptedTransport{responses: []*http.Response{
+		scriptedResponse(
+			http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}},
+			"API_TOKEN_LIMIT_EXCEEDED",
+		),
+		scriptedResponse(http.StatusOK, http.Header{}, `{"id": "b1"}`),
+		scriptedResponse(http.StatusNotFound, http.Header{}, "not found"),
+	}}
+	ctx := Trello("me", "key", "retry-token")
+	ctx.client.Transport = transport
+
+	body, err := ctx.ApiGet("/boards/b1")
+	if err != nil || string(body) != `{"id": "b1"}` {
+		t.Fatalf("obtained %q (%v) once retried", body, err)
+	}
+	if len(transport.made) != 2 {
+		t.Fatalf("%d requests made, want 2", len(transport.made))
+	}
+	waited := transport.made[1].Sub(transport.made[0])
+	if waited < time.Second {
+		t.Errorf("retried after %s, Trello asking for 1s", waited)
+	}
+
+	_, err = ctx.ApiGet("/boards/b2")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("missing board failed with %v", err)
+	}
+	if len(transport.made) != 3 {
+		t.Errorf("missing board requested %d times", len(transport.made)-2)
+	}
+}
+
+// Requests beyond a window's worth wait for the bucket to be refilled, or
+// until cancelled.
+func TestTokenBucket(t *testing.T) {
+	bucket := &tokenBucket{tokens: rateLimitRequests, refilled: time.Now()}
+	start := time.Now()
+	for i := 0; i < rateLimitRequests; i++ {
+		if err := bucket.take(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("burst of a window's requests held back %s", elapsed)
+	}
+	if err := bucket.take(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("request beyond the burst not held back")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bucket.tokens = 0
+	if err := bucket.take(ctx); err != context.Canceled {
+		t.Errorf("cancelled request took a token: %v", err)
+	}
+}
@@ -10,13 +10,23 @@
 package trello
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
 type TrelloCtx struct {
@@ -24,11 +34,61 @@ type TrelloCtx struct {
 	Key   string
 	Token string
 
-	client *http.Client
+	client  *http.Client
+	retries int
+	rate    *rateTracker
+	flights *flightGroup
+	etags   *etagStore
+	cache   ResponseCache
+	journal Journal
+	export  *ExportSource
+
+	// what requests are made with, so they may be cancelled, or given a
+	// deadline; see WithContext().
+	context context.Context
+}
+
+// Persists responses to GET requests, to be served in their stead while
+// Trello can't be reached.
+type ResponseCache interface {
+	Get(key string) ([]byte, time.Time, bool)
+	Put(key string, data []byte)
+}
+
+// Keep responses in 'cache', falling back on them when requests fail.
+func (t *TrelloCtx) SetCache(cache ResponseCache) {
+	t.cache = cache
 }
 
 func Trello(id string, key string, token string) *TrelloCtx {
-	return &TrelloCtx{id, key, token, &http.Client{}}
+	return &TrelloCtx{
+		ID:      id,
+		Key:     key,
+		Token:   token,
+		client:  newClient(HTTPOptions{}),
+		rate:    &rateTracker{},
+		flights: &flightGroup{},
+		etags:   &etagStore{},
+		context: context.Background(),
+	}
+}
+
+// Obtain a copy of the context whose requests are made with 'ctx', so that
+// they're abandoned once it's cancelled or past its deadline, with waiting
+// for the rate limits, or for requests in flight, cut short likewise. The
+// copy shares everything else with the original, e.g. its rate limits and
+// cache, and is meant to be used for the duration of an operation.
+func (t *TrelloCtx) WithContext(ctx context.Context) *TrelloCtx {
+	bound := *t
+	bound.context = ctx
+	return &bound
+}
+
+// Whether a request failed for having been cancelled, rather than for
+// anything to do with Trello. Requests running past their deadline, or
+// timing out, failed for Trello taking too long, on the other hand.
+func IsCancelled(err error) bool {
+	return errors.Is(err, context.Canceled)
 }
 
 func (t *TrelloCtx) NewRequest(
@@ -41,7 +101,7 @@ func (t *TrelloCtx) NewRequest(
 		endpoint = fmt.Sprintf("/%s", endpoint)
 	}
 	ep := fmt.Sprintf("https://api.trello.com/1%s", endpoint)
-	req, err := http.NewRequest(method, ep, body)
+	req, err := http.NewRequestWithContext(t.context, method, ep, body)
 	if err != nil {
 		return nil, err
 	}
@@ -81,26 +141,321 @@ func doTestAPIGet(endpoint string) ([]byte, error) {
 	return contents, nil
 }
 
+// Perform a GET request. Concurrent requests for the same endpoint are
+// coalesced into a single request, sharing its result. If Trello can't be
+// reached, the last response obtained is served instead, if cached; not so
+// if the request was cancelled.
 func (t *TrelloCtx) ApiGet(endpoint string) ([]byte, error) {
+	body, _, err := t.apiGetTagged(endpoint)
+	return body, err
+}
+
+// Perform a GET request, as ApiGet() does, along with the response's ETag,
+// if Trello tagged it.
+func (t *TrelloCtx) apiGetTagged(endpoint string) ([]byte, string, error) {
+	body, etag, err := t.flights.do(
+		t.context, endpoint, func() ([]byte, string, error) {
+			body, etag, err := t.apiGet(endpoint)
+			if t.cache == nil {
+				return body, etag, err
+			}
+			key := t.cacheKey(endpoint)
+			if err == nil {
+				t.cache.Put(key, body)
+				return body, etag, nil
+			} else if !isUnreachable(err) || IsCancelled(err) {
+				return nil, "", err
+			}
+			cached, fetched, exists := t.cache.Get(key)
+			if !exists {
+				return nil, "", err
+			}
+			log.Printf(
+				"serving %s as cached at %s: %s\n",
+				endpoint, fetched.Format(time.RFC3339), err,
+			)
+			return cached, "", nil
+		},
+	)
+	t.noteFailure(err)
+	return body, etag, err
+}
+
+// Serve requests from a board's export instead of Trello, refusing any
+// requests to change things.
+func (t *TrelloCtx) SetExport(export *ExportSource) {
+	t.export = export
+}
+
+// Responses are cached per token, as different tokens may be allowed to see
+// different things, without the token itself ending up on disk.
+func (t *TrelloCtx) cacheKey(endpoint string) string {
+	return fmt.Sprintf("%s %s", t.owner(), endpoint)
+}
+
+// Tell contexts apart by their token, without it ending up on disk.
+func (t *TrelloCtx) owner() string {
+	hash := sha256.Sum256([]byte(t.Token))
+	return hex.EncodeToString(hash[:8])
+}
+
+// Whether a request failed for Trello being unreachable, or unable to serve
+// it right now, rather than for what was asked.
+func isUnreachable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.IsRateLimited()
+	}
+	return true
+}
+
+// Requests refused for exceeding the rate limits are retried, backing off,
+// as are those failing for Trello being unreachable, if so set, unless
+// cancelled meanwhile. Requests are made conditional on what was obtained
+// last, if Trello tagged it, which is then served again should Trello find
+// it unchanged.
+func (t *TrelloCtx) apiGet(endpoint string) ([]byte, string, error) {
+
+	if t.export != nil {
+		body, err := t.export.get(endpoint)
+		return body, "", err
+	}
+	key := t.cacheKey(endpoint)
+	for attempt := 0; ; attempt++ {
+		if err := t.throttle(); err != nil {
+			return nil, "", err
+		}
+		if os.Getenv("TRELLOFS_TEST") != "" {
+			body, err := doTestAPIGet(endpoint)
+			return body, "", err
+		}
+
+		req, err := t.NewRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		last, tagged := t.etags.get(key)
+		if tagged {
+			req.Header.Set("If-None-Match", last.etag)
+		}
+		body, header, err := t.doRequest(req, "GET", endpoint)
+		if err == errNotModified {
+			if !tagged {
+				// not that we asked.
+				return nil, "", err
+			}
+			recordETag(true)
+			return last.body, last.etag, nil
+		} else if err == nil {
+			recordETag(false)
+			etag := header.Get("ETag")
+			if etag != "" {
+				t.etags.put(key, etag, body)
+			}
+			return body, etag, nil
+		}
+		delay, retry := t.retryDelay(endpoint, err, attempt)
+		if !retry {
+			return nil, "", err
+		}
+		if err := sleepCtx(t.context, delay); err != nil {
+			return nil, "", err
+		}
+	}
+}
+
+func doTestAPIRequest(method string, endpoint string) ([]byte, error) {
+	tdir := os.Getenv("TRELLOFS_TEST")
+	f, err := os.OpenFile(
+		fmt.Sprintf("%s/requests.log", tdir),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "%s %s\n", method, endpoint)
 
+	// answered as in '<method>-<endpoint>.json', if there, e.g. with the
+	// IDs of what's being created.
+	ep := strings.TrimPrefix(strings.Split(endpoint, "?")[0], "/")
+	testfn := fmt.Sprintf(
+		"%s/%s-%s.json", tdir, method, strings.ReplaceAll(ep, "/", "-"),
+	)
+	if contents, err := ioutil.ReadFile(testfn); err == nil {
+		return contents, nil
+	}
+	return []byte("{}"), nil
+}
+
+// An API request failed with a non-2xx status.
+type APIError struct {
+	Method     string
+	Endpoint   string
+	StatusCode int
+	Status     string
+	Body       string
+
+	// how long Trello asked to wait before retrying, if it did.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s: %s: %s", e.Method, e.Endpoint, e.Status, e.Body)
+}
+
+// Whether the request was refused for exceeding Trello's rate limits.
+func (e *APIError) IsRateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// Perform a request with its parameters in the query string, as expected by
+// Trello's API for requests modifying state. Requests changing cards are
+// queued instead, if there's a journal, when Trello can't be reached, or
+// while others are queued, so they're made in order; these succeed without
+// a response. See SetJournal().
+func (t *TrelloCtx) ApiRequest(
+	method string,
+	endpoint string,
+	params url.Values,
+) (body []byte, err error) {
+
+	defer func() { t.noteFailure(err) }()
+	if t.journal == nil || !isJournaled(method, endpoint) {
+		return t.apiRequest(method, endpoint, params)
+	}
+	if t.hasPending() {
+		return nil, t.queue(method, endpoint, params, nil)
+	}
+	body, err = t.apiRequest(method, endpoint, params)
+	if err != nil && isUnreachable(err) && !IsCancelled(err) {
+		return nil, t.queue(method, endpoint, params, err)
+	}
+	return body, err
+}
+
+func (t *TrelloCtx) apiRequest(
+	method string,
+	endpoint string,
+	params url.Values,
+) (body []byte, err error) {
+
+	if len(params) > 0 {
+		endpoint = fmt.Sprintf("%s?%s", endpoint, params.Encode())
+	}
+	if t.export != nil {
+		return nil, syscall.EROFS
+	}
+	if err := t.throttle(); err != nil {
+		return nil, err
+	}
 	if os.Getenv("TRELLOFS_TEST") != "" {
-		return doTestAPIGet(endpoint)
+		return doTestAPIRequest(method, endpoint)
 	}
 
-	req, err := t.NewRequest("GET", endpoint, nil)
+	req, err := t.NewRequest(method, endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := t.client.Do(req)
+	body, _, err = t.doRequest(req, method, endpoint)
+	return body, err
+}
+
+// Upload a file through a multipart POST request, as field 'field' named
+// 'filename', along with 'params'.
+func (t *TrelloCtx) ApiUpload(
+	endpoint string,
+	params url.Values,
+	field string,
+	filename string,
+	data []byte,
+) (resp []byte, err error) {
+
+	defer func() { t.noteFailure(err) }()
+	if t.export != nil {
+		return nil, syscall.EROFS
+	}
+	if err := t.throttle(); err != nil {
+		return nil, err
+	}
+	if os.Getenv("TRELLOFS_TEST") != "" {
+		return doTestAPIRequest("POST", fmt.Sprintf(
+			"%s?%s (%s: %d bytes)", endpoint, params.Encode(), field, len(data),
+		))
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, values := range params {
+		for _, value := range values {
+			writer.WriteField(key, value)
+		}
+	}
+	part, err := writer.CreateFormFile(field, filename)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	part.Write(data)
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := t.NewRequest("POST", endpoint, &body)
 	if err != nil {
 		return nil, err
 	}
-	return body, nil
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, _, err = t.doRequest(req, "POST", endpoint)
+	return resp, err
+}
+
+// Perform a request, obtaining its response's body and headers. Fails with
+// errNotModified on '304 Not Modified', for conditional requests.
+func (t *TrelloCtx) doRequest(
+	req *http.Request,
+	method string,
+	endpoint string,
+) (body []byte, header http.Header, err error) {
+
+	defer func() { recordRequest(method, err) }()
+	inflight.acquire()
+	defer inflight.release()
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	t.rate.update(resp)
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, resp.Header, errNotModified
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return nil, nil, &APIError{
+			Method:     method,
+			Endpoint:   endpoint,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       strings.TrimSpace(string(body)),
+			RetryAfter: time.Duration(retryAfter) * time.Second,
+		}
+	}
+	return body, resp.Header, nil
+}
+
+func (t *TrelloCtx) ApiPut(endpoint string, params url.Values) ([]byte, error) {
+	return t.ApiRequest("PUT", endpoint, params)
+}
+
+func (t *TrelloCtx) ApiPost(endpoint string, params url.Values) ([]byte, error) {
+	return t.ApiRequest("POST", endpoint, params)
+}
+
+func (t *TrelloCtx) ApiDelete(endpoint string) ([]byte, error) {
+	return t.ApiRequest("DELETE", endpoint, nil)
 }
 
 func MakeEndpoint(endpoint string, fields []string) string {
@@ -110,3 +465,101 @@ func MakeEndpoint(endpoint string, fields []string) string {
 	}
 	return fmt.Sprintf("%s%s", endpoint, f)
 }
+
+// Obtain an entity as Trello serves it, unmodified, 'kind' being its
+// endpoint's, e.g. "cards", "boards", or "lists".
+func GetRaw(ctx *TrelloCtx, kind string, id string) ([]byte, error) {
+	endpoint := fmt.Sprintf("/%s/%s", kind, id)
+	raw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf("error obtaining %s: %s\n", endpoint, err)
+		return nil, err
+	}
+	return raw, nil
+}
+
+func doTestDownload(fileURL string, offset int64, size int) ([]byte, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	fn := strings.ReplaceAll(strings.TrimPrefix(u.Path, "/"), "/", "-")
+	contents, err := ioutil.ReadFile(
+		fmt.Sprintf("%s/%s", os.Getenv("TRELLOFS_TEST"), fn),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if offset >= int64(len(contents)) {
+		return nil, nil
+	}
+	contents = contents[offset:]
+	if len(contents) > size {
+		contents = contents[:size]
+	}
+	return contents, nil
+}
+
+// Download up to 'size' bytes of a file hosted by Trello, such as an
+// attachment, starting at 'offset'. Only the requested range is transferred,
+// unless the server ignores range requests.
+func (t *TrelloCtx) ApiDownload(
+	fileURL string,
+	offset int64,
+	size int,
+) (data []byte, err error) {
+
+	defer func() { t.noteFailure(err) }()
+	if t.export != nil {
+		return nil, fmt.Errorf("%s: not part of the export", fileURL)
+	}
+	if err := t.throttle(); err != nil {
+		return nil, err
+	}
+	if os.Getenv("TRELLOFS_TEST") != "" {
+		return doTestDownload(fileURL, offset, size)
+	}
+
+	req, err := http.NewRequestWithContext(t.context, "GET", fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	auth := fmt.Sprintf("OAuth oauth_consumer_key=\"%s\", oauth_token=\"%s\"",
+		t.Key, t.Token)
+	req.Header.Add("Authorization", auth)
+	req.Header.Add(
+		"Range", fmt.Sprintf("bytes=%d-%d", offset, offset+int64(size)-1),
+	)
+	inflight.acquire()
+	defer inflight.release()
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	t.rate.update(resp)
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, nil
+	case http.StatusOK:
+		// the whole file it is, then; skip what we weren't asked for.
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			if err == io.EOF {
+				return nil, nil
+			}
+			return nil, err
+		}
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{
+			Method:     "GET",
+			Endpoint:   fileURL,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       strings.TrimSpace(string(body)),
+		}
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, int64(size)))
+}
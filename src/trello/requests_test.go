@@ -0,0 +1,110 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Answers requests once released, unless given up on meanwhile.
+type heldTransport struct {
+	received chan string
+	release  chan struct{}
+}
+
+func (held *heldTransport) RoundTrip(
+	req *http.Request,
+) (*http.Response, error) {
+
+	held.received <- req.Method + " " + req.URL.Path
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case <-held.release:
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader(`{}`)),
+		Request:    req,
+	}, nil
+}
+
+// Requests are abandoned along with the operations making them, leaving
+// those made concurrently on behalf of others be.
+func TestRequestsCancelled(t *testing.T) {
+	t.Setenv("TRELLOFS_TEST", "")
+	held := &heldTransport{
+		received: make(chan string, 3),
+		release:  make(chan struct{}),
+	}
+	api := Trello("me", "key", "requests-cancelled")
+	api.client = &http.Client{Transport: held}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 2)
+	go func() {
+		_, err := api.WithContext(ctx).ApiGet("/boards/b1")
+		cancelled <- err
+	}()
+	go func() {
+		_, err := api.WithContext(ctx).ApiPost("/cards", nil)
+		cancelled <- err
+	}()
+	kept := make(chan error, 1)
+	go func() {
+		_, err := api.ApiGet("/boards/b2")
+		kept <- err
+	}()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-held.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of 3 requests made", i)
+		}
+	}
+
+	cancel()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-cancelled:
+			if !IsCancelled(err) {
+				t.Errorf("request given up on failed with %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("request carried on once given up on")
+		}
+	}
+	select {
+	case err := <-kept:
+		t.Fatalf("request given up on along with others: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(held.release)
+	if err := <-kept; err != nil {
+		t.Fatal(err)
+	}
+
+	// nor are they made once given up on.
+	_, err := api.WithContext(ctx).ApiGet("/boards/b3")
+	if !IsCancelled(err) {
+		t.Errorf("request made once given up on: %v", err)
+	}
+	select {
+	case req := <-held.received:
+		t.Errorf("%s made once given up on", req)
+	default:
+	}
+}
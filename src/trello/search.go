@@ -0,0 +1,97 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+)
+
+type SavedSearch struct {
+	ID    string  `json:"id"`
+	Name  string  `json:"name"`
+	Query string  `json:"query"`
+	Pos   float64 `json:"pos"`
+}
+
+// Obtain the user's saved searches.
+func GetSavedSearches(ctx *TrelloCtx) ([]SavedSearch, error) {
+
+	endpoint := fmt.Sprintf("/members/%s/savedSearches", ctx.ID)
+	searchesRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf("error obtaining saved searches: %s\n", err)
+		return nil, err
+	}
+
+	var searches []SavedSearch
+	json.Unmarshal(searchesRaw, &searches)
+	return searches, nil
+}
+
+// Obtain the cards matching a search query, as understood by Trello's
+// search (e.g., "@me due:week").
+func Search(ctx *TrelloCtx, query string) ([]Card, error) {
+
+	params := url.Values{
+		"query":       {query},
+		"modelTypes":  {"cards"},
+		"cards_limit": {"1000"},
+	}
+	endpoint := fmt.Sprintf("/search?%s", params.Encode())
+	resultsRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf("error searching for '%s': %s\n", query, err)
+		return nil, err
+	}
+
+	var results struct {
+		Cards []Card `json:"cards"`
+	}
+	json.Unmarshal(resultsRaw, &results)
+	return results.Cards, nil
+}
+
+// Obtain the open cards the user is a member of, across all boards.
+func GetMemberCards(ctx *TrelloCtx) ([]Card, error) {
+
+	endpoint := fmt.Sprintf("/members/%s/cards?filter=open", ctx.ID)
+	cardsRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf("error obtaining cards for %s: %s\n", ctx.ID, err)
+		return nil, err
+	}
+
+	var cards []Card
+	json.Unmarshal(cardsRaw, &cards)
+	return cards, nil
+}
+
+// Obtain the open cards the user is a member of, across all boards, as
+// GetMemberCards() does, but with only what's needed to tell when they're
+// due: their IDs, board, list, name, short link, and due date.
+func GetMemberDueDates(ctx *TrelloCtx) ([]Card, error) {
+
+	endpoint := fmt.Sprintf(
+		"/members/%s/cards?filter=open&fields=%s", ctx.ID,
+		"id,idBoard,idList,name,shortLink,due,dueComplete,closed",
+	)
+	cardsRaw, err := ctx.ApiGet(endpoint)
+	if err != nil {
+		log.Printf("error obtaining due dates for %s: %s\n", ctx.ID, err)
+		return nil, err
+	}
+
+	var cards []Card
+	json.Unmarshal(cardsRaw, &cards)
+	return cards, nil
+}
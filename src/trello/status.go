@@ -0,0 +1,89 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"sync"
+	"time"
+)
+
+// How requests to Trello have fared, across all contexts.
+type Status struct {
+	// requests made, by method, and how many of them failed.
+	Requests map[string]uint64
+	Failures uint64
+
+	// GET requests found unchanged since last made, through their ETags,
+	// and those served in full.
+	ETagHits   uint64
+	ETagMisses uint64
+
+	// how many requests are in flight, and may be at once.
+	Inflight    int
+	MaxInflight int
+
+	LastSuccess time.Time
+	LastFailure time.Time
+	LastError   string
+
+	// whether the last request failed for Trello being unreachable.
+	Unreachable bool
+}
+
+var status = struct {
+	lock sync.Mutex
+	Status
+}{Status: Status{Requests: make(map[string]uint64)}}
+
+func recordRequest(method string, err error) {
+	status.lock.Lock()
+	defer status.lock.Unlock()
+
+	status.Requests[method]++
+	if IsCancelled(err) {
+		// says nothing of how Trello is getting along.
+		return
+	} else if err == nil || err == errNotModified {
+		status.LastSuccess = time.Now()
+		status.Unreachable = false
+		return
+	}
+	status.Failures++
+	status.LastFailure = time.Now()
+	status.LastError = err.Error()
+	status.Unreachable = isUnreachable(err)
+}
+
+func recordETag(hit bool) {
+	status.lock.Lock()
+	defer status.lock.Unlock()
+
+	if hit {
+		status.ETagHits++
+	} else {
+		status.ETagMisses++
+	}
+}
+
+func GetStatus() Status {
+	status.lock.Lock()
+	result := status.Status
+	result.Requests = make(map[string]uint64, len(status.Requests))
+	for method, count := range status.Requests {
+		result.Requests[method] = count
+	}
+	status.lock.Unlock()
+
+	inflight.lock.Lock()
+	defer inflight.lock.Unlock()
+	result.Inflight = inflight.inflight
+	result.MaxInflight = inflight.max
+	return result
+}
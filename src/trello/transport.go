@@ -0,0 +1,61 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// How long a request may take, reading its response included, unless set
+// otherwise, lest a hung Trello have requests waiting on it forever.
+const DefaultTimeout = 60 * time.Second
+
+// How requests are made to Trello. Zero values stand for the defaults.
+type HTTPOptions struct {
+	// How long a request may take; DefaultTimeout if not set.
+	Timeout time.Duration
+
+	// How many times GET requests failing for Trello being unreachable
+	// are retried, backing off exponentially. Requests refused for
+	// exceeding the rate limits are retried regardless.
+	Retries int
+
+	// How many idle connections to keep around for reuse.
+	MaxIdleConns int
+
+	// The proxy to go through; whichever the environment asks for, e.g.
+	// through $HTTPS_PROXY, if not set.
+	Proxy *url.URL
+}
+
+func newClient(opts HTTPOptions) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.MaxIdleConns > 0 {
+		// all requests go to the same host, so its limit is what counts.
+		transport.MaxIdleConns = opts.MaxIdleConns
+		transport.MaxIdleConnsPerHost = opts.MaxIdleConns
+	}
+	if opts.Proxy != nil {
+		transport.Proxy = http.ProxyURL(opts.Proxy)
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+// Make requests as set by 'opts', rather than with the defaults.
+func (t *TrelloCtx) SetHTTPOptions(opts HTTPOptions) {
+	t.client = newClient(opts)
+	t.retries = opts.Retries
+}
@@ -0,0 +1,70 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+// Requests go through the proxy, and keep as many connections around, as
+// set, and give up on a hung Trello, retrying as many times as set.
+func TestHTTPOptions(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	t.Setenv("TRELLOFS_TEST", "")
+
+	ctx := Trello("me", "key", "http-options")
+	if ctx.client.Timeout != DefaultTimeout {
+		t.Errorf("requests time out after %s by default", ctx.client.Timeout)
+	}
+	proxy, _ := url.Parse("http://proxy:3128")
+	ctx.SetHTTPOptions(HTTPOptions{
+		Timeout:      100 * time.Millisecond,
+		Retries:      1,
+		MaxIdleConns: 7,
+		Proxy:        proxy,
+	})
+	transport := ctx.client.Transport.(*http.Transport)
+	if transport.MaxIdleConns != 7 || transport.MaxIdleConnsPerHost != 7 {
+		t.Errorf("%d idle connections kept, %d per host",
+			transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+	req, err := ctx.NewRequest("GET", "/boards/b1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if via, err := transport.Proxy(req); err != nil || via != proxy {
+		t.Errorf("requests made through %v (%v)", via, err)
+	}
+
+	// Trello never answering.
+	held := &heldTransport{
+		received: make(chan string, 3),
+		release:  make(chan struct{}),
+	}
+	defer close(held.release)
+	ctx.client.Transport = held
+	start := time.Now()
+	if _, err := ctx.ApiGet("/boards/b1"); err == nil || IsCancelled(err) {
+		t.Errorf("request to a hung Trello failed with %v", err)
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("request to a hung Trello given up on after %s", took)
+	}
+	if made := len(held.received); made != 2 {
+		t.Errorf("request made %d times, want it retried once", made)
+	}
+}
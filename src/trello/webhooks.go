@@ -0,0 +1,105 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package trello
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// What Trello posts to a webhook's callback URL for each action on its
+// model.
+type WebhookEvent struct {
+	Action Action `json:"action"`
+}
+
+// A webhook, having Trello post actions on a model to a callback URL.
+type Webhook struct {
+	ID          string `json:"id"`
+	ModelID     string `json:"idModel"`
+	CallbackURL string `json:"callbackURL"`
+	Description string `json:"description"`
+}
+
+// Have Trello post actions on a model, e.g. a board, to 'callbackURL'.
+// Trello checks the URL is reachable, with a HEAD request, before creating
+// the webhook. Registering the same callback for the same model again is
+// not an error, and obtains the webhook already registered.
+func CreateWebhook(
+	ctx *TrelloCtx,
+	callbackURL string,
+	modelID string,
+	description string,
+) (*Webhook, error) {
+
+	webhookRaw, err := ctx.ApiPost("/webhooks", url.Values{
+		"callbackURL": {callbackURL},
+		"idModel":     {modelID},
+		"description": {description},
+	})
+	var apiErr *APIError
+	var webhook *Webhook
+	if errors.As(err, &apiErr) &&
+		apiErr.StatusCode == http.StatusBadRequest &&
+		strings.Contains(apiErr.Body, "already exists") {
+		webhook, err = findWebhook(ctx, callbackURL, modelID)
+	} else if err == nil {
+		webhook = &Webhook{}
+		err = json.Unmarshal(webhookRaw, webhook)
+	}
+	if err != nil {
+		log.Printf("error creating webhook for %s: %s\n", modelID, err)
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// Obtain the webhook posting actions on a model to 'callbackURL', among
+// those registered with the context's token. The endpoint holds the token,
+// so the request bypasses the cache, and isn't logged.
+func findWebhook(
+	ctx *TrelloCtx,
+	callbackURL string,
+	modelID string,
+) (*Webhook, error) {
+
+	endpoint := fmt.Sprintf("/tokens/%s/webhooks", ctx.Token)
+	webhooksRaw, err := ctx.ApiRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, errors.New("error obtaining webhooks")
+	}
+	var webhooks []Webhook
+	if err := json.Unmarshal(webhooksRaw, &webhooks); err != nil {
+		return nil, err
+	}
+	for _, webhook := range webhooks {
+		if webhook.ModelID == modelID &&
+			webhook.CallbackURL == callbackURL {
+			return &webhook, nil
+		}
+	}
+	return nil, errors.New("existing webhook not found")
+}
+
+// Have Trello stop posting actions through a webhook.
+func DeleteWebhook(ctx *TrelloCtx, id string) error {
+
+	endpoint := fmt.Sprintf("/webhooks/%s", id)
+	if _, err := ctx.ApiDelete(endpoint); err != nil {
+		log.Printf("error deleting webhook %s: %s\n", id, err)
+		return err
+	}
+	return nil
+}
@@ -14,6 +14,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 )
 
 type Workspace struct {
@@ -48,7 +49,10 @@ func (workspace *Workspace) GetBoards(
 
 	boardsEndpoint := MakeEndpoint(
 		fmt.Sprintf("/organizations/%s/boards", workspace.ID),
-		[]string{"id", "name", "desc", "descData", "closed"},
+		[]string{
+			"id", "name", "desc", "descData", "closed",
+			"shortLink", "shortUrl", "idOrganization", "prefs",
+		},
 	)
 	boardsRaw, err := ctx.ApiGet(boardsEndpoint)
 	if err != nil {
@@ -63,3 +67,48 @@ func (workspace *Workspace) GetBoards(
 	json.Unmarshal(boardsRaw, &boards)
 	return boards, nil
 }
+
+func GetWorkspace(ctx *TrelloCtx, id string) (*Workspace, error) {
+
+	orgEndpoint := MakeEndpoint(
+		fmt.Sprintf("/organizations/%s", id),
+		[]string{"id", "name", "displayName"},
+	)
+	orgRaw, err := ctx.ApiGet(orgEndpoint)
+	if err != nil {
+		log.Printf("error obtaining org %s: %s\n", id, err)
+		return nil, err
+	}
+
+	var org Workspace
+	json.Unmarshal(orgRaw, &org)
+	return &org, nil
+}
+
+// Create a board named 'name' in the workspace, with any other fields as
+// accepted by 'POST /boards' (e.g., 'desc', 'defaultLists').
+func (workspace *Workspace) CreateBoard(
+	ctx *TrelloCtx,
+	name string,
+	fields url.Values,
+) (*Board, error) {
+
+	params := url.Values{}
+	for k, v := range fields {
+		params[k] = v
+	}
+	params.Set("idOrganization", workspace.ID)
+	params.Set("name", name)
+	boardRaw, err := ctx.ApiPost("/boards", params)
+	if err != nil {
+		log.Printf(
+			"error creating board %s in workspace %s (%s): %s\n",
+			name, workspace.Name, workspace.ID, err,
+		)
+		return nil, err
+	}
+
+	var board Board
+	json.Unmarshal(boardRaw, &board)
+	return &board, nil
+}
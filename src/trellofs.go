@@ -12,29 +12,155 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
+	"net/url"
 	"os/user"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/jecluis/trellofs/src/cache"
 	"github.com/jecluis/trellofs/src/config"
+	"github.com/jecluis/trellofs/src/control"
 	"github.com/jecluis/trellofs/src/fs"
+	"github.com/jecluis/trellofs/src/journal"
+	"github.com/jecluis/trellofs/src/notify"
 	"github.com/jecluis/trellofs/src/trello"
+	"github.com/jecluis/trellofs/src/webhook"
 
 	"github.com/jacobsa/fuse"
 )
 
 var fMountPoint = flag.String("mount", "", "Path to Mount point.")
 var fConfigFile = flag.String("config", "", "Path to config file.")
+var fRoot = flag.String(
+	"root", "", "Entity to mount as root, e.g. 'workspace:<name|id>'.",
+)
+var f9PAddr = flag.String(
+	"9p", "",
+	"Address to export the tree over 9P, unauthenticated; "+
+		"on loopback unless a host is given.",
+)
+var fMaxInflight = flag.Int(
+	"max-inflight-requests", trello.DefaultMaxInflight,
+	"Maximum number of concurrent requests to Trello.",
+)
+var fControl = flag.String("control", "", "Path to the control socket.")
+var fIDNames = flag.Bool(
+	"id-names", false, "Name boards, lists, and cards after their ID.",
+)
+var fBoards = flag.String(
+	"boards", "",
+	"Boards to mount, as comma-separated '<workspace>/<board>' patterns.",
+)
+var fLayout = flag.String(
+	"layout", fs.Layouts[0],
+	"Directory layout, "+strings.Join(fs.Layouts, " or ")+".",
+)
+var fFromExport = flag.String(
+	"from-export", "",
+	"Path to a board's JSON export to serve read-only, instead of Trello.",
+)
+
+// Refresh intervals set on the command line, overriding the config's; -1 if
+// unset.
+var fRefresh = map[string]*int{}
+
+func init() {
+	for _, entity := range []string{"workspaces", "boards", "lists", "cards"} {
+		fRefresh[entity] = flag.Int(
+			"refresh."+entity, -1,
+			fmt.Sprintf("Seconds between refreshes of %s; 0 for never.", entity),
+		)
+	}
+}
+
+// Obtain, or set, how many requests to Trello may be in flight at once.
+func cmdMaxInflight(args []string) (string, error) {
+	if len(args) == 0 {
+		return strconv.Itoa(trello.GetMaxInflight()), nil
+	}
+	max, err := strconv.Atoi(args[0])
+	if err != nil || max < 1 {
+		return "", fmt.Errorf("invalid value '%s'", args[0])
+	}
+	trello.SetMaxInflight(max)
+	return "", nil
+}
+
+// Attach boards to, or detach them from, the live mount, registering or
+// deleting their webhooks right away, if serving them.
+func cmdBoards(
+	tree *fs.Tree,
+	webhooks *webhook.Server,
+	attach bool,
+) control.Handler {
+	return func(args []string) (string, error) {
+		if len(args) == 0 {
+			return "", fmt.Errorf("expected a board name, ID, or short link")
+		}
+		key := strings.Join(args, " ")
+		var result string
+		if attach {
+			result = fmt.Sprintf("attached %d", tree.AttachBoard(key))
+		} else {
+			result = fmt.Sprintf("detached %d", tree.DetachBoard(key))
+		}
+		if webhooks != nil {
+			webhooks.Register()
+		}
+		return result, nil
+	}
+}
+
+// Snapshot a board, as 'snapshot-board <name> <board>'.
+func cmdSnapshotBoard(tree *fs.Tree) control.Handler {
+	return func(args []string) (string, error) {
+		if len(args) < 2 {
+			return "", fmt.Errorf("expected a snapshot name and a board")
+		}
+		return "", tree.SnapshotBoard(strings.Join(args[1:], " "), args[0])
+	}
+}
+
+// Diff two of a board's snapshots, as 'diff-snapshots <from> <to> <board>',
+// into the board's '.changelog'.
+func cmdDiffSnapshots(tree *fs.Tree) control.Handler {
+	return func(args []string) (string, error) {
+		if len(args) < 3 {
+			return "", fmt.Errorf("expected two snapshot names and a board")
+		}
+		changes, err := tree.DiffSnapshots(
+			strings.Join(args[2:], " "), args[0], args[1],
+		)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d changes", changes), nil
+	}
+}
 
 func main() {
 
 	flag.Parse()
 
-	if *fMountPoint == "" {
-		log.Fatalf("Must provide mount point via '--mount'")
-	} else if *fConfigFile == "" {
+	if *fMountPoint == "" && *f9PAddr == "" {
+		log.Fatalf("Must provide mount point via '--mount', or '--9p'")
+	} else if *fConfigFile == "" && *fFromExport == "" {
 		log.Fatalf("Must provide config file via '--config'")
 	}
+	knownLayout := false
+	for _, layout := range fs.Layouts {
+		knownLayout = knownLayout || layout == *fLayout
+	}
+	if !knownLayout {
+		log.Fatalf(
+			"Unknown layout '%s'; one of %s",
+			*fLayout, strings.Join(fs.Layouts, ", "),
+		)
+	}
 
 	user, err := user.Current()
 	if err != nil {
@@ -50,20 +176,370 @@ func main() {
 		panic(err)
 	}
 
+	if *fFromExport != "" {
+		serveExport(uint32(uid), uint32(gid), *fFromExport)
+		return
+	}
+
+	var rootWorkspace string
+	if *fRoot != "" {
+		spec := strings.SplitN(*fRoot, ":", 2)
+		if len(spec) != 2 || spec[0] != "workspace" || spec[1] == "" {
+			log.Fatalf("Unsupported root '%s'", *fRoot)
+		}
+		rootWorkspace = spec[1]
+	}
+
 	config, err := config.ReadConfig(*fConfigFile)
 	if err != nil {
 		panic(err)
 	}
 
+	trello.SetMaxInflight(*fMaxInflight)
+
+	if len(config.Accounts) > 0 && rootWorkspace != "" {
+		log.Fatalf("Can't mount a workspace as root along with accounts")
+	}
+	var accounts []fs.Account
+	for _, account := range config.Accounts {
+		if account.Name == "" || strings.HasPrefix(account.Name, ".") ||
+			strings.Contains(account.Name, "/") {
+			log.Fatalf("Invalid account name '%s'", account.Name)
+		}
+		for _, other := range accounts {
+			if other.Name == account.Name {
+				log.Fatalf("Account '%s' given twice", account.Name)
+			}
+		}
+		id := account.ID
+		if id == "" {
+			// as Trello calls whoever the token belongs to.
+			id = "me"
+		}
+		accounts = append(accounts, fs.Account{
+			Name: account.Name,
+			Ctx:  trello.Trello(id, account.Key, account.Token),
+		})
+	}
+
 	trelloCtx := trello.Trello(config.ID, config.Key, config.Token)
-	trelloFS, err := fs.NewTrelloFS(uint32(uid), uint32(gid), trelloCtx)
+	if config.Key == "" && len(accounts) > 0 {
+		trelloCtx = accounts[0].Ctx
+	}
+	wsCtx := make(map[string]*trello.TrelloCtx)
+	for ws, creds := range config.Workspaces {
+		id := creds.ID
+		if id == "" {
+			// as Trello calls whoever the token belongs to.
+			id = "me"
+		}
+		wsCtx[ws] = trello.Trello(id, creds.Key, creds.Token)
+	}
+
+	// every context requests are made with, sharing the same settings.
+	ctxs := []*trello.TrelloCtx{trelloCtx}
+	for _, ctx := range wsCtx {
+		ctxs = append(ctxs, ctx)
+	}
+	for _, account := range accounts {
+		if account.Ctx != trelloCtx {
+			ctxs = append(ctxs, account.Ctx)
+		}
+	}
+
+	if config.HTTP != nil {
+		httpOpts := trello.HTTPOptions{
+			Timeout:      time.Duration(config.HTTP.Timeout) * time.Second,
+			Retries:      config.HTTP.Retries,
+			MaxIdleConns: config.HTTP.MaxIdleConns,
+		}
+		if config.HTTP.Proxy != "" {
+			proxy, err := url.Parse(config.HTTP.Proxy)
+			if err != nil {
+				log.Fatalf("Invalid proxy '%s': %v", config.HTTP.Proxy, err)
+			}
+			httpOpts.Proxy = proxy
+		}
+		for _, ctx := range ctxs {
+			ctx.SetHTTPOptions(httpOpts)
+		}
+	}
+
+	if config.CacheDir != "" {
+		store, err := cache.Open(config.CacheDir)
+		if err != nil {
+			log.Fatalf("error opening cache %s: %v", config.CacheDir, err)
+		}
+		for _, ctx := range ctxs {
+			ctx.SetCache(store)
+		}
+	}
+
+	if config.WriteBack != nil {
+		jrnl, err := journal.Open(config.WriteBack.Dir)
+		if err != nil {
+			log.Fatalf(
+				"error opening journal %s: %v", config.WriteBack.Dir, err,
+			)
+		}
+		for _, ctx := range ctxs {
+			ctx.SetJournal(jrnl)
+		}
+		interval := 30
+		if config.WriteBack.Interval > 0 {
+			interval = config.WriteBack.Interval
+		}
+		go replayPending(ctxs, time.Duration(interval)*time.Second)
+	}
+
+	idleTimeout := 300
+	if config.RefreshIdleTimeout > 0 {
+		idleTimeout = config.RefreshIdleTimeout
+	}
+
+	refresh := make(map[string]time.Duration)
+	for entity, secs := range config.Refresh {
+		if _, known := fRefresh[entity]; !known {
+			log.Fatalf("Unknown entity '%s' to refresh", entity)
+		}
+		refresh[entity] = time.Duration(secs) * time.Second
+	}
+	for entity, secs := range fRefresh {
+		if *secs >= 0 {
+			refresh[entity] = time.Duration(*secs) * time.Second
+		}
+	}
+
+	staleDays := 14
+	if config.StaleDays > 0 {
+		staleDays = config.StaleDays
+	}
+
+	maxLists := 200
+	if config.MaxLists != 0 {
+		maxLists = config.MaxLists
+	}
+
+	var location *time.Location
+	if config.Timezone == "local" {
+		location = time.Local
+	} else if config.Timezone != "" {
+		location, err = time.LoadLocation(config.Timezone)
+		if err != nil {
+			log.Fatalf("Unknown timezone '%s': %v", config.Timezone, err)
+		}
+	}
+
+	trashDays := 7
+	if config.TrashDays > 0 {
+		trashDays = config.TrashDays
+	}
+
+	boardFilters := make(map[string]fs.Filter)
+	for ws, sel := range config.MountBoards {
+		boardFilters[ws] = fs.Filter{Include: sel.Include, Exclude: sel.Exclude}
+	}
+
+	boards := config.Boards
+	if *fBoards != "" {
+		boards = nil
+		for _, pattern := range strings.Split(*fBoards, ",") {
+			boards = append(boards, strings.TrimSpace(pattern))
+		}
+	}
+
+	dueSoonHours := 24
+	if config.DueSoonHours > 0 {
+		dueSoonHours = config.DueSoonHours
+	}
+
+	views := make(map[string]*fs.Query)
+	for name, expr := range config.Views {
+		query, err := fs.ParseQuery(expr)
+		if err != nil {
+			log.Fatalf("Invalid view '%s': %v", name, err)
+		}
+		views[name] = query
+	}
+
+	snapshotDir := config.SnapshotDir
+	if snapshotDir == "" && config.CacheDir != "" {
+		snapshotDir = filepath.Join(config.CacheDir, "snapshots")
+	}
+
+	opts := fs.Options{
+		WorkspaceCtx:   wsCtx,
+		UseDisplayName: config.UseDisplayName,
+		RootWorkspace:  rootWorkspace,
+		Accounts:       accounts,
+		MountPoint:     mountPoint(),
+		Layout:         *fLayout,
+		IdleTimeout:    time.Duration(idleTimeout) * time.Second,
+
+		RefreshIntervals:   refresh,
+		AllowMemberRemoval: config.AllowMemberRemoval,
+		StaleDays:          staleDays,
+		MaxLists:           maxLists,
+		HardlinkCards:      config.HardlinkCards,
+		TrashDays:          trashDays,
+		DocMeta:            config.DocMeta,
+		DateFormat:         config.DateFormat,
+		Location:           location,
+		ColorPrefix:        config.ColorPrefix,
+		NameTemplates:      config.Naming,
+		IDNames:            config.IDNames || *fIDNames,
+		IDNamesIn:          config.IDNamesIn,
+		WorkspaceFilter: fs.Filter{
+			Include: config.MountWorkspaces.Include,
+			Exclude: config.MountWorkspaces.Exclude,
+		},
+		BoardFilters: boardFilters,
+		Boards:       boards,
+		Views:        views,
+		Hooks:        config.Hooks,
+		DueSoon:      time.Duration(dueSoonHours) * time.Hour,
+		InboxList:    config.InboxList,
+		SnapshotDir:  snapshotDir,
+	}
+	if config.Snapshots != nil {
+		interval := 24
+		if config.Snapshots.Interval > 0 {
+			interval = config.Snapshots.Interval
+		}
+		opts.SnapshotBoards = config.Snapshots.Boards
+		opts.SnapshotInterval = time.Duration(interval) * time.Hour
+		opts.SnapshotKeep = config.Snapshots.Keep
+	}
+	if config.WIPLimits != nil {
+		opts.WIPLimits = config.WIPLimits.Lists
+		opts.EnforceWIPLimits = config.WIPLimits.Enforce
+	}
+	tree, err := fs.NewTree(uint32(uid), uint32(gid), trelloCtx, opts)
 	if err != nil {
 		panic(err)
 	}
 
+	var webhooks *webhook.Server
+	if config.Webhook != nil {
+		webhooks = webhook.NewServer(tree, webhook.Options{
+			Listen:      config.Webhook.Listen,
+			CallbackURL: config.Webhook.CallbackURL,
+			Secret:      config.Webhook.Secret,
+		})
+		go func() {
+			if err := webhooks.Run(); err != nil {
+				log.Fatalf("error serving webhooks: %v", err)
+			}
+		}()
+	}
+
+	if *fControl != "" {
+		ctl := control.NewServer()
+		ctl.Handle("max-inflight-requests", cmdMaxInflight)
+		ctl.Handle("attach-board", cmdBoards(tree, webhooks, true))
+		ctl.Handle("detach-board", cmdBoards(tree, webhooks, false))
+		ctl.Handle("snapshot-board", cmdSnapshotBoard(tree))
+		ctl.Handle("diff-snapshots", cmdDiffSnapshots(tree))
+		go func() {
+			if err := ctl.Serve(*fControl); err != nil {
+				log.Fatalf("error serving control socket: %v", err)
+			}
+		}()
+	}
+
+	if config.Notify != nil {
+		interval := 60
+		if config.Notify.Interval > 0 {
+			interval = config.Notify.Interval
+		}
+		notifier := notify.NewNotifier(trelloCtx, notify.Options{
+			Types:    config.Notify.Types,
+			Interval: time.Duration(interval) * time.Second,
+			Desktop:  config.Notify.Desktop,
+			Hook:     config.Notify.Hook,
+		})
+		go notifier.Run()
+	}
+
+	serve(tree, false)
+	if webhooks != nil {
+		webhooks.Close()
+	}
+}
+
+// Make the changes queued while Trello couldn't be reached, every 'interval'
+// while mounted.
+func replayPending(ctxs []*trello.TrelloCtx, interval time.Duration) {
+	for {
+		for _, ctx := range ctxs {
+			if made := ctx.ReplayPending(); made > 0 {
+				log.Printf("made %d queued changes\n", made)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Serve a board's JSON export, read-only and without reaching Trello, with
+// the board as the mount's only entry.
+func serveExport(uid uint32, gid uint32, path string) {
+	export, err := trello.LoadExport(path)
+	if err != nil {
+		log.Fatalf("error loading export: %v", err)
+	}
+	trelloCtx := trello.Trello("me", "", "")
+	trelloCtx.SetExport(export)
+
+	// the export never changes, so there's nothing to refresh.
+	refresh := make(map[string]time.Duration)
+	for entity := range fRefresh {
+		refresh[entity] = 0
+	}
+	tree, err := fs.NewTree(uid, gid, trelloCtx, fs.Options{
+		RootWorkspace:    export.Workspace.ID,
+		MountPoint:       mountPoint(),
+		Layout:           *fLayout,
+		RefreshIntervals: refresh,
+		IDNames:          *fIDNames,
+	})
+	if err != nil {
+		log.Fatalf("error serving export: %v", err)
+	}
+	serve(tree, true)
+}
+
+// Obtain the mount point as an absolute path, if mounting through FUSE.
+func mountPoint() string {
+	if *fMountPoint == "" {
+		return ""
+	}
+	path, err := filepath.Abs(*fMountPoint)
+	if err != nil {
+		return *fMountPoint
+	}
+	return path
+}
+
+// Serve the tree through FUSE at the mount point, or over 9P, or both.
+func serve(tree *fs.Tree, readOnly bool) {
+	if *fMountPoint == "" {
+		if err := fs.Serve9P(tree, *f9PAddr); err != nil {
+			log.Fatalf("error serving 9P on %s: %v", *f9PAddr, err)
+		}
+		return
+	} else if *f9PAddr != "" {
+		go func() {
+			if err := fs.Serve9P(tree, *f9PAddr); err != nil {
+				log.Fatalf("error serving 9P on %s: %v", *f9PAddr, err)
+			}
+		}()
+	}
+
+	trelloFS := fs.NewTrelloFS(tree)
+
 	cfg := &fuse.MountConfig{
 		DisableWritebackCaching: true,
-		ReadOnly:                true, // eventually make read/write
+		ReadOnly:                readOnly,
 	}
 
 	mfs, err := fuse.Mount(*fMountPoint, trelloFS, cfg)
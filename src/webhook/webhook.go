@@ -0,0 +1,187 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+
+// Receives actions on mounted boards as Trello pushes them, through
+// webhooks, so what they change is refreshed right away instead of when
+// next due. Trello must be able to reach the listener through the callback
+// URL, e.g. through a reverse proxy, and checks it can before creating a
+// webhook.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jecluis/trellofs/src/fs"
+	"github.com/jecluis/trellofs/src/trello"
+)
+
+// How often to look for newly mounted boards to register webhooks for.
+const registerInterval = time.Minute
+
+// Largest request body accepted; actions are much smaller than this.
+const maxBodySize = 1 << 20
+
+type Options struct {
+	// Address to listen on, e.g. ':8089'.
+	Listen string
+
+	// URL Trello posts actions to, leading to the listener.
+	CallbackURL string
+
+	// The application's secret, to check requests do come from Trello.
+	// Requests aren't checked if empty.
+	Secret string
+}
+
+type Server struct {
+	tree *fs.Tree
+	opts Options
+
+	// the webhooks registered, by board ID, none once closed.
+	lock       sync.Mutex
+	registered map[string]*registration
+	closed     bool
+}
+
+// A webhook registered for a board, along with the context it was
+// registered with, to delete it with.
+type registration struct {
+	ctx     *trello.TrelloCtx
+	webhook *trello.Webhook
+}
+
+func NewServer(tree *fs.Tree, opts Options) *Server {
+	return &Server{
+		tree:       tree,
+		opts:       opts,
+		registered: make(map[string]*registration),
+	}
+}
+
+// Listen for actions, registering webhooks for boards as they're mounted.
+// Only returns on error.
+func (s *Server) Run() error {
+	srv := &http.Server{Addr: s.opts.Listen, Handler: s}
+	errs := make(chan error, 1)
+	go func() { errs <- srv.ListenAndServe() }()
+
+	for {
+		s.Register()
+		select {
+		case err := <-errs:
+			return err
+		case <-time.After(registerInterval):
+		}
+	}
+}
+
+// Register webhooks for boards mounted since last registered, and delete
+// those of boards no longer mounted, e.g. once detached.
+func (s *Server) Register() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+
+	mounted := make(map[string]bool)
+	for _, board := range s.tree.MountedBoards() {
+		mounted[board.ID] = true
+		if s.registered[board.ID] != nil {
+			continue
+		}
+		webhook, err := trello.CreateWebhook(
+			board.Ctx, s.opts.CallbackURL, board.ID, "trellofs",
+		)
+		if err != nil {
+			continue
+		}
+		log.Printf("webhook > registered for board %s\n", board.ID)
+		s.registered[board.ID] = &registration{
+			ctx: board.Ctx, webhook: webhook,
+		}
+	}
+	for boardID := range s.registered {
+		if !mounted[boardID] {
+			s.unregister(boardID)
+		}
+	}
+}
+
+// Delete all webhooks registered, e.g. once unmounted, so Trello no longer
+// posts actions to a listener that's gone.
+func (s *Server) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for boardID := range s.registered {
+		s.unregister(boardID)
+	}
+	s.closed = true
+}
+
+// Delete a board's webhook. Boards whose webhook couldn't be deleted are
+// forgotten all the same, as Trello deletes webhooks on its own once their
+// callback URL keeps failing. Must be called with the lock held.
+func (s *Server) unregister(boardID string) {
+	reg := s.registered[boardID]
+	delete(s.registered, boardID)
+	if err := trello.DeleteWebhook(reg.ctx, reg.webhook.ID); err == nil {
+		log.Printf("webhook > unregistered for board %s\n", boardID)
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Trello checks the callback URL with a HEAD request.
+	if r.Method == http.MethodHead {
+		return
+	} else if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !s.verify(body, r.Header.Get("X-Trello-Webhook")) {
+		log.Printf("webhook > bad signature from %s\n", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var event trello.WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.tree.ApplyAction(&event.Action)
+}
+
+// Trello signs requests with the HMAC-SHA1 of their body followed by the
+// callback URL, keyed by the application's secret.
+func (s *Server) verify(body []byte, signature string) bool {
+	if s.opts.Secret == "" {
+		return true
+	}
+	mac := hmac.New(sha1.New, []byte(s.opts.Secret))
+	mac.Write(body)
+	mac.Write([]byte(s.opts.CallbackURL))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
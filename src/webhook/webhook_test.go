@@ -0,0 +1,114 @@
+/*
+ * trellofs - A Trello POSIX filesystem
+ * Copyright (C) 2022  Joao Eduardo Luis <joao@wipwd.dev>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ */
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jecluis/trellofs/src/fs"
+	"github.com/jecluis/trellofs/src/trello"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Webhooks are registered for boards once mounted, and deleted when closed,
+// while only actions signed with the application's secret are accepted.
+func TestServer(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := t.TempDir()
+	t.Setenv("TRELLOFS_TEST", dir)
+	for name, body := range map[string]string{
+		"members-me-organizations.json": `[{"id": "w1", "name": "eng"}]`,
+		"organizations-w1-boards.json":  `[{"id": "b1", "name": "Roadmap"}]`,
+		"POST-webhooks.json":            `{"id": "wh1", "idModel": "b1"}`,
+	} {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tree, err := fs.NewTree(0, 0, trello.Trello("me", "", ""), fs.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	ws, err := tree.Lookup(ctx, fuseops.RootInodeID, "eng")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Entries(ctx, ws.GetNodeID()); err != nil {
+		t.Fatal(err)
+	}
+
+	const callback = "https://example.com/trellofs"
+	s := NewServer(tree, Options{CallbackURL: callback, Secret: "secret"})
+	s.Register()
+	s.Register()
+
+	for _, test := range []struct {
+		method    string
+		signature string
+		want      int
+	}{
+		{http.MethodHead, "", http.StatusOK},
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "forged", http.StatusUnauthorized},
+		{http.MethodPost, "", http.StatusOK},
+	} {
+		body := `{"action": {"type": "updateCard",
+			"data": {"board": {"id": "b1"}, "card": {"id": "c1"}}}}`
+		signature := test.signature
+		if test.method == http.MethodPost && signature == "" {
+			mac := hmac.New(sha1.New, []byte("secret"))
+			mac.Write([]byte(body + callback))
+			signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		}
+		req := httptest.NewRequest(test.method, "/", strings.NewReader(body))
+		req.Header.Set("X-Trello-Webhook", signature)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Code != test.want {
+			t.Errorf(
+				"%s signed %q answered %d, want %d",
+				test.method, test.signature, w.Code, test.want,
+			)
+		}
+	}
+
+	s.Close()
+	s.Register()
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "requests.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := []string{
+		"POST /webhooks?callbackURL=https%3A%2F%2Fexample.com%2Ftrellofs" +
+			"&description=trellofs&idModel=b1",
+		"DELETE /webhooks/wh1",
+	}
+	got := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	if !reflect.DeepEqual(got, requests) {
+		t.Errorf("requests made:\n%q\nwant:\n%q", got, requests)
+	}
+}